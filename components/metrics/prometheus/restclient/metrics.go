@@ -26,6 +26,7 @@ import (
 	"github.com/yubo/client-go/tools/metrics"
 	k8smetrics "github.com/yubo/apiserver/components/metrics"
 	"github.com/yubo/apiserver/components/metrics/legacyregistry"
+	clientmetrics "github.com/yubo/apiserver/pkg/client/metrics"
 )
 
 var (
@@ -82,6 +83,33 @@ var (
 		[]string{"code", "method", "host"},
 	)
 
+	requestRetries = k8smetrics.NewCounterVec(
+		&k8smetrics.CounterOpts{
+			Name:           "rest_client_request_retries_total",
+			StabilityLevel: k8smetrics.ALPHA,
+			Help:           "Number of request retries, partitioned by status code of the failed attempt, method, and host.",
+		},
+		[]string{"code", "method", "host"},
+	)
+
+	circuitBreakerState = k8smetrics.NewGaugeVec(
+		&k8smetrics.GaugeOpts{
+			Name:           "rest_client_circuit_breaker_state",
+			StabilityLevel: k8smetrics.ALPHA,
+			Help:           "State of the circuit breaker of the host, 0 closed, 1 half-open, 2 open.",
+		},
+		[]string{"host"},
+	)
+
+	circuitBreakerRejected = k8smetrics.NewCounterVec(
+		&k8smetrics.CounterOpts{
+			Name:           "rest_client_circuit_breaker_rejected_total",
+			StabilityLevel: k8smetrics.ALPHA,
+			Help:           "Number of requests rejected by the open circuit breaker, partitioned by host.",
+		},
+		[]string{"host"},
+	)
+
 	execPluginCertTTLAdapter = &expiryToTTLAdapter{}
 
 	execPluginCertTTL = k8smetrics.NewGaugeFunc(
@@ -152,6 +180,9 @@ func init() {
 	legacyregistry.MustRegister(responseSize)
 	legacyregistry.MustRegister(rateLimiterLatency)
 	legacyregistry.MustRegister(requestResult)
+	legacyregistry.MustRegister(requestRetries)
+	legacyregistry.MustRegister(circuitBreakerState)
+	legacyregistry.MustRegister(circuitBreakerRejected)
 	legacyregistry.RawMustRegister(execPluginCertTTL)
 	legacyregistry.MustRegister(execPluginCertRotation)
 	metrics.Register(metrics.RegisterOpts{
@@ -164,6 +195,11 @@ func init() {
 		RequestResult:         &resultAdapter{requestResult},
 		ExecPluginCalls:       &callsAdapter{m: execPluginCalls},
 	})
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestRetry:           &resultAdapter{requestRetries},
+		CircuitBreakerState:    &circuitStateAdapter{m: circuitBreakerState},
+		CircuitBreakerRejected: &circuitRejectedAdapter{m: circuitBreakerRejected},
+	})
 }
 
 type latencyAdapter struct {
@@ -213,3 +249,19 @@ type callsAdapter struct {
 func (r *callsAdapter) Increment(code int, callStatus string) {
 	r.m.WithLabelValues(fmt.Sprintf("%d", code), callStatus).Inc()
 }
+
+type circuitStateAdapter struct {
+	m *k8smetrics.GaugeVec
+}
+
+func (c *circuitStateAdapter) Set(host string, state int) {
+	c.m.WithLabelValues(host).Set(float64(state))
+}
+
+type circuitRejectedAdapter struct {
+	m *k8smetrics.CounterVec
+}
+
+func (c *circuitRejectedAdapter) Increment(host string) {
+	c.m.WithLabelValues(host).Inc()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	v1 "github.com/yubo/apiserver/pkg/proc/api/v1"
 	"github.com/yubo/apiserver/pkg/proc/options"
 	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/apiserver/pkg/rest/clientgen"
 	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/api/errors"
 	"github.com/yubo/golib/util"
@@ -96,6 +98,11 @@ var (
 )
 
 func main() {
+	// go run server/main.go gen-client client/user/client.go
+	if len(os.Args) == 3 && os.Args[1] == "gen-client" {
+		os.Exit(proc.PrintErrln(genClient(os.Args[2])))
+	}
+
 	command := proc.NewRootCmd(server.WithoutTLS(), proc.WithHooks(hookOps...))
 	code := cli.Run(command)
 	os.Exit(code)
@@ -111,6 +118,18 @@ func start(ctx context.Context) error {
 	return nil
 }
 
+// genClient writes the typed client of the routes to the file
+func genClient(file string) error {
+	module.installWs(rest.NewBaseContainer())
+
+	buf := &bytes.Buffer{}
+	if err := clientgen.Generate(buf, &clientgen.Config{Package: "user", Client: "UserClient"}, rest.WsOptions()...); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0644)
+}
+
 func (p *Module) installWs(http rest.GoRestfulContainer) {
 	rest.SwaggerTagRegister("user", "user Api - swagger api sample")
 	rest.WsRouteBuild(&rest.WsOption{
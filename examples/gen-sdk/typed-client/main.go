@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"examples/gen-sdk/typed-client/user"
+
+	"github.com/yubo/apiserver/pkg/proc"
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/golib/util"
+)
+
+func main() {
+	os.Exit(proc.PrintErrln(run()))
+}
+
+func run() error {
+	cli, err := user.NewUserClient(&rest.Config{Host: "127.0.0.1:8080"})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	created, err := cli.CreateUser(ctx, &user.CreateUserInput{
+		Name:     "Hamilton",
+		NickName: util.String("Ham"),
+		Phone:    util.String("0086-123456"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("CreateUser: %s\n", util.Prettify(created))
+
+	users, err := cli.GetUsers(ctx, &user.GetUsersInput{})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("GetUsers: %s\n", util.Prettify(users))
+
+	got, err := cli.GetUser(ctx, &user.GetUserInput{Name: "Hamilton"})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("GetUser: %s\n", util.Prettify(got))
+
+	return nil
+}
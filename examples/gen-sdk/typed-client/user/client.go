@@ -0,0 +1,154 @@
+// Code generated by clientgen. DO NOT EDIT.
+
+package user
+
+import (
+	"context"
+
+	"github.com/yubo/apiserver/pkg/client"
+	"github.com/yubo/apiserver/pkg/scheme"
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/golib/api"
+)
+
+type CreateUserInput struct {
+	Name     string  `json:"name"`
+	NickName *string `json:"nickName"`
+	Phone    *string `json:"phone"`
+}
+
+type CreateUserOutput struct {
+	Name     string  `json:"name"`
+	NickName *string `json:"nickName"`
+	Phone    *string `json:"phone"`
+}
+
+type GetUsersInput struct {
+	api.PageParams
+	Query *string `param:"query" name:"query" description:"query user"`
+	Count bool    `param:"query" name:"count" description:"just response total count"`
+}
+
+type GetUsersOutput struct {
+	Total int     `json:"total"`
+	List  []*User `json:"list"`
+}
+
+type User struct {
+	Name     string  `json:"name"`
+	NickName *string `json:"nickName"`
+	Phone    *string `json:"phone"`
+}
+
+type GetUserInput struct {
+	Name string `param:"path" name:"user-name"`
+}
+
+type UpdateUserParam struct {
+	Name string `param:"path" name:"user-name"`
+}
+
+type UpdateUserBody struct {
+	Name     string  `json:"-" sql:"where"`
+	NickName *string `json:"nickName"`
+	Phone    *string `json:"phone"`
+}
+
+type DeleteUserInput struct {
+	Name string `param:"path" name:"user-name"`
+}
+
+// UserClient is the typed client of the routes
+type UserClient struct {
+	client *rest.RESTClient
+}
+
+func NewUserClient(config *rest.Config) (*UserClient, error) {
+	if config.NegotiatedSerializer == nil {
+		c := *config
+		c.NegotiatedSerializer = scheme.Codecs
+		config = &c
+	}
+
+	c, err := rest.RESTClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return &UserClient{client: c}, nil
+}
+
+// CreateUser create user
+func (p *UserClient) CreateUser(ctx context.Context, body *CreateUserInput) (*CreateUserOutput, error) {
+	output := &CreateUserOutput{}
+	err := client.NewRequestWithClient(p.client,
+		client.WithMethod("POST"),
+		client.WithPath("/api/user/"),
+		client.WithBody(body),
+		client.WithOutput(output),
+	).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetUsers search/list users
+func (p *UserClient) GetUsers(ctx context.Context, param *GetUsersInput) (*GetUsersOutput, error) {
+	output := &GetUsersOutput{}
+	err := client.NewRequestWithClient(p.client,
+		client.WithMethod("GET"),
+		client.WithPath("/api/user/"),
+		client.WithParams(param),
+		client.WithOutput(output),
+	).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetUser get user
+func (p *UserClient) GetUser(ctx context.Context, param *GetUserInput) (*User, error) {
+	output := &User{}
+	err := client.NewRequestWithClient(p.client,
+		client.WithMethod("GET"),
+		client.WithPath("/api/user/{user-name}"),
+		client.WithParams(param),
+		client.WithOutput(output),
+	).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// UpdateUser update user
+func (p *UserClient) UpdateUser(ctx context.Context, param *UpdateUserParam, body *UpdateUserBody) (*User, error) {
+	output := &User{}
+	err := client.NewRequestWithClient(p.client,
+		client.WithMethod("PUT"),
+		client.WithPath("/api/user/{user-name}"),
+		client.WithParams(param),
+		client.WithBody(body),
+		client.WithOutput(output),
+	).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// DeleteUser delete user
+func (p *UserClient) DeleteUser(ctx context.Context, param *DeleteUserInput) (*User, error) {
+	output := &User{}
+	err := client.NewRequestWithClient(p.client,
+		client.WithMethod("DELETE"),
+		client.WithPath("/api/user/{user-name}"),
+		client.WithParams(param),
+		client.WithOutput(output),
+	).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"os"
 
+	"github.com/yubo/apiserver/components/cli"
 	"github.com/yubo/apiserver/pkg/client"
 	"github.com/yubo/apiserver/pkg/proc"
 	"github.com/yubo/golib/api"
 )
 
+type config struct {
+	Host     string `json:"host" flag:"host" default:"127.0.0.1:8080" description:"the address of the apiserver"`
+	PageSize int    `json:"pageSize" flag:"page-size" default:"10" description:"the size of the page, 0 prints all the users"`
+}
+
 type User struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string `json:"name" out:"NAME"`
+	Description string `json:"description" out:"DESCRIPTION,wide"`
 }
 
 type ListInput struct {
@@ -23,22 +29,42 @@ type ListOutput struct {
 	List  []*User `json:"list"`
 }
 
-func run() error {
-	req, err := client.NewRequest("127.0.0.1:8080",
+func main() {
+	proc.AddConfig("client", &config{}, proc.WithConfigGroup("client"))
+	proc.AddConfig("print", &client.PrintFlags{}, proc.WithConfigGroup("print"))
+
+	command := proc.NewRootCmd(proc.WithRun(run), proc.WithoutLoop())
+	code := cli.Run(command)
+	os.Exit(code)
+}
+
+func run(ctx context.Context) error {
+	cf := &config{}
+	if err := proc.ReadConfig("client", cf); err != nil {
+		return err
+	}
+
+	printFlags := &client.PrintFlags{}
+	if err := proc.ReadConfig("print", printFlags); err != nil {
+		return err
+	}
+	printer, err := printFlags.ToPrinter()
+	if err != nil {
+		return err
+	}
+
+	req, err := client.NewRequest(cf.Host,
 		client.WithParams(&ListInput{
 			PageParams: api.PageParams{
-				PageSize: 10,
+				PageSize: cf.PageSize,
 			},
 		}),
 		client.WithPath("/users"),
 		client.WithOutput(&ListOutput{}),
+		client.WithPrinter(printer, os.Stdout),
 	)
 	if err != nil {
 		return err
 	}
-	return req.Pager(os.Stdout, false).Do(context.Background())
-}
-
-func main() {
-	os.Exit(proc.PrintErrln(run()))
+	return req.Pager(os.Stdout, false).Do(ctx)
 }
@@ -21,6 +21,7 @@ func run() error {
 
 	config := &rest.Config{
 		Host:          "127.0.0.1:8080",
+		BearerToken:   os.Getenv("TOKEN"),
 		ContentConfig: rest.ContentConfig{NegotiatedSerializer: scheme.Codecs},
 	}
 
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/yubo/apiserver/pkg/client"
+	"github.com/yubo/apiserver/pkg/proc"
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/golib/scheme"
+)
+
+func main() {
+	os.Exit(proc.PrintErrln(run()))
+}
+
+func run() error {
+	if len(os.Args) < 2 {
+		return fmt.Errorf("Usage: %s [local:]remote ...", os.Args[0])
+	}
+
+	config := &rest.Config{
+		Host:          "127.0.0.1:8080",
+		BearerToken:   os.Getenv("TOKEN"),
+		ContentConfig: rest.ContentConfig{NegotiatedSerializer: scheme.Codecs},
+	}
+
+	stopCh := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		close(stopCh)
+	}()
+
+	return client.NewPortForwardClient(config, "POST", "/remotecommand/portforward").
+		Forward(os.Args[1:]...).
+		Transport(client.StreamTransport(os.Getenv("STREAM_TRANSPORT"))).
+		Run(stopCh)
+}
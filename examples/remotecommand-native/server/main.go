@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/yubo/apiserver/components/cli"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
 	"github.com/yubo/apiserver/pkg/proc"
 	"github.com/yubo/apiserver/pkg/proc/options"
 	"github.com/yubo/apiserver/pkg/rest"
@@ -19,17 +21,26 @@ import (
 	"k8s.io/klog/v2"
 
 	_ "github.com/yubo/apiserver/pkg/server/register"
+
+	// authn
+	_ "github.com/yubo/apiserver/pkg/authentication/register"
+	_ "github.com/yubo/apiserver/plugin/authenticator/token/tokenfile/register"
+
+	// authz
+	_ "github.com/yubo/apiserver/pkg/authorization/register"
+	_ "github.com/yubo/apiserver/plugin/authorizer/rbac/register"
 )
 
-// This example shows the minimal code needed to get a restful.WebService working.
+// go run ./server/main.go -f ./config.yaml
 //
-// curl -X GET http://localhost:8080/hello
-//
-// go run ./apiserver-watch.go --request-timeout=10
+// the users of ./tokens.cvs are authorized by the rbac rules of ./testdata,
+// the requester of a session is its owner, who kills the session.
 
 type server struct {
 	config   streaming.Config
-	provider streaming.Provider
+	runtime  native.Runtime
+	authz    authorizer.Authorizer
+	selector *native.ExecConfigSelector
 }
 
 func main() {
@@ -44,20 +55,53 @@ func start(ctx context.Context) error {
 		return fmt.Errorf("unable to get http server from the context")
 	}
 
-	recorderProvider, err := native.NewFileRecorderProvider("/tmp")
+	recorderProvider, err := native.NewFileRecorderProvider("/tmp/recordings")
+	if err != nil {
+		return err
+	}
+
+	runtime, err := native.NewRuntime(ctx,
+		native.WithRecorder(recorderProvider),
+		native.WithRecFilePathFactroy(func(id string) string { return id }),
+		native.WithIdleTimeout(time.Hour),
+		native.WithExitGracePeriod(5*time.Minute),
+		native.WithPortForward(native.PortForwardConfig{
+			AllowedAddresses: []string{"127.0.0.1:6060", "127.0.0.1:9000-9100"},
+		}),
+	)
 	if err != nil {
 		return err
 	}
 
+	var authz authorizer.Authorizer
+	if info, ok := options.AuthzFrom(ctx); ok {
+		authz = info.Authorizer
+	}
+
 	srv := &server{
-		config: streaming.DefaultConfig,
-		provider: native.NewProvider(ctx,
-			native.WithRecorder(recorderProvider),
-			native.WithRecFilePathFactroy(func(id string) string { return id }),
-		),
+		config:  streaming.DefaultConfig,
+		runtime: runtime,
+		authz:   authz,
+		// the first exec config which the user is allowed to use, only the command
+		// is matched with the allowlist, so a shell is not allowed
+		selector: &native.ExecConfigSelector{
+			Authorizer: authz,
+			Configs: []native.NamedExecConfig{
+				{Name: "operator"},
+				{Name: "restricted", ExecConfig: native.ExecConfig{
+					AllowedCommands: []string{"ls", "top", "uptime"},
+				}},
+			},
+		},
 	}
 	srv.installWs(http)
 
+	// list, inspect and kill the sessions, the owners are checked by the authorizer
+	native.NewAPI(runtime, authz).Install(http)
+
+	// list, search and play the recordings
+	native.NewRecordingAPI(recorderProvider).Install(http)
+
 	return nil
 }
 
@@ -70,6 +114,10 @@ func (p *server) installWs(http rest.GoRestfulContainer) {
 			{Method: "POST", SubPath: "/exec", Handle: p.exec},
 			{Method: "POST", SubPath: "/attach", Handle: p.attach},
 			{Method: "POST", SubPath: "/portforward", Handle: p.portForward},
+			// the websocket connections are opened by GET
+			{Method: "GET", SubPath: "/exec", Handle: p.exec},
+			{Method: "GET", SubPath: "/attach", Handle: p.attach},
+			{Method: "GET", SubPath: "/portforward", Handle: p.portForward},
 		},
 	})
 }
@@ -84,10 +132,16 @@ func (p *server) exec(w http.ResponseWriter, req *http.Request, in *api.ExecRequ
 		TTY:    in.Tty,
 	}
 
+	// the user of the request is the requester of the session
+	config, err := p.selector.Select(req.Context())
+	if err != nil {
+		return err
+	}
+
 	remotecommandserver.ServeExec(
 		w,
 		req,
-		p.provider,
+		native.NewExecutor(p.runtime, *config),
 		"", // unused: podName
 		"", // unusued: podUID
 		in.ContainerId,
@@ -107,10 +161,11 @@ func (p *server) attach(w http.ResponseWriter, req *http.Request, in *api.Attach
 		Stderr: in.Stderr,
 		TTY:    in.Tty,
 	}
+	// the user of the request attaches in the mode allowed by the authorizer
 	remotecommandserver.ServeAttach(
 		w,
 		req,
-		p.provider,
+		native.NewAttacher(req.Context(), p.runtime, p.authz),
 		"", // unused: podName
 		"", // unusued: podUID
 		in.ContainerId,
@@ -122,15 +177,17 @@ func (p *server) attach(w http.ResponseWriter, req *http.Request, in *api.Attach
 }
 
 func (p *server) portForward(w http.ResponseWriter, req *http.Request, in *api.PortForwardRequest) error {
-	portForwardOptions, err := portforward.BuildV4Options(in.Port)
+	// the ports of the websocket streams are set by the query parameters
+	portForwardOptions, err := portforward.NewV4Options(req)
 	if err != nil {
 		return err
 	}
 
+	// the addresses are checked with the authorizer for the user of the request
 	portforward.ServePortForward(
 		w,
 		req,
-		p.provider,
+		native.NewPortForwarder(req.Context(), p.runtime, p.authz),
 		in.PodSandboxId,
 		"", // unused: podUID
 		portForwardOptions,
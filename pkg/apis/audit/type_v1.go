@@ -225,6 +225,28 @@ type PolicyRule struct {
 	// An empty list means no restrictions will apply.
 	// +optional
 	OmitStages []Stage `json:"omitStages,omitempty" protobuf:"bytes,8,rep,name=omitStages"`
+
+	// SampleRate is the fraction of the matched requests that are recorded, in (0, 1].
+	// The requests that are not sampled are not audited at all.
+	// Nil means every matched request is recorded.
+	// +optional
+	SampleRate *float64 `json:"sampleRate,omitempty" protobuf:"fixed64,9,opt,name=sampleRate"`
+
+	// RateLimit limits the recorded events of the matched requests per user,
+	// the requests over the limit are not audited.
+	// Nil means no limit.
+	// +optional
+	RateLimit *PolicyRateLimit `json:"rateLimit,omitempty" protobuf:"bytes,10,opt,name=rateLimit"`
+}
+
+// PolicyRateLimit is a token bucket of the audited requests per user.
+type PolicyRateLimit struct {
+	// QPS is the number of the requests per second that are audited for a user.
+	QPS float32 `json:"qps" protobuf:"fixed32,1,opt,name=qps"`
+	// Burst is the maximum burst of the audited requests for a user.
+	// Defaults to max(1, QPS) if unset.
+	// +optional
+	Burst int `json:"burst,omitempty" protobuf:"varint,2,opt,name=burst"`
 }
 
 // GroupResources represents resource kinds in an API group.
@@ -41,6 +41,8 @@ func validatePolicyRule(rule audit.PolicyRule, fldPath *field.Path) field.ErrorL
 	allErrs = append(allErrs, validateNonResourceURLs(rule.NonResourceURLs, fldPath.Child("nonResourceURLs"))...)
 	allErrs = append(allErrs, validateResources(rule.Resources, fldPath.Child("resources"))...)
 	allErrs = append(allErrs, validateOmitStages(rule.OmitStages, fldPath.Child("omitStages"))...)
+	allErrs = append(allErrs, validateSampleRate(rule.SampleRate, fldPath.Child("sampleRate"))...)
+	allErrs = append(allErrs, validateRateLimit(rule.RateLimit, fldPath.Child("rateLimit"))...)
 
 	if len(rule.NonResourceURLs) > 0 {
 		if len(rule.Resources) > 0 || len(rule.Namespaces) > 0 {
@@ -131,3 +133,27 @@ func validateOmitStages(omitStages []audit.Stage, fldPath *field.Path) field.Err
 	}
 	return allErrs
 }
+
+func validateSampleRate(rate *float64, fldPath *field.Path) field.ErrorList {
+	if rate == nil {
+		return nil
+	}
+	if !(*rate > 0 && *rate <= 1) {
+		return field.ErrorList{field.Invalid(fldPath, *rate, "must be greater than 0 and less than or equal to 1")}
+	}
+	return nil
+}
+
+func validateRateLimit(limit *audit.PolicyRateLimit, fldPath *field.Path) field.ErrorList {
+	if limit == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if limit.QPS <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("qps"), limit.QPS, "must be greater than 0"))
+	}
+	if limit.Burst < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("burst"), limit.Burst, "must be greater than or equal to 0"))
+	}
+	return allErrs
+}
@@ -23,6 +23,8 @@ import (
 )
 
 func TestValidatePolicy(t *testing.T) {
+	rate := func(r float64) *float64 { return &r }
+
 	validRules := []audit.PolicyRule{
 		{ // Defaulting rule
 			Level: audit.LevelMetadata,
@@ -48,6 +50,11 @@ func TestValidatePolicy(t *testing.T) {
 			OmitStages: []audit.Stage{
 				audit.Stage("RequestReceived"),
 			},
+		}, { // Sampled and rate limited reads
+			Level:      audit.LevelRequestResponse,
+			Verbs:      []string{"get", "list"},
+			SampleRate: rate(0.1),
+			RateLimit:  &audit.PolicyRateLimit{QPS: 1, Burst: 5},
 		},
 	}
 	successCases := []audit.Policy{}
@@ -121,6 +128,22 @@ func TestValidatePolicy(t *testing.T) {
 				audit.Stage("foo"),
 			},
 		},
+		{ // zero sample rate
+			Level:      audit.LevelMetadata,
+			SampleRate: rate(0),
+		},
+		{ // sample rate out of range
+			Level:      audit.LevelMetadata,
+			SampleRate: rate(1.5),
+		},
+		{ // rate limit without qps
+			Level:     audit.LevelMetadata,
+			RateLimit: &audit.PolicyRateLimit{Burst: 5},
+		},
+		{ // negative burst
+			Level:     audit.LevelMetadata,
+			RateLimit: &audit.PolicyRateLimit{QPS: 1, Burst: -1},
+		},
 	}
 	errorCases := []audit.Policy{}
 	for _, rule := range invalidRules {
@@ -0,0 +1,47 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// ParseCSR decodes a PEM encoded CSR
+func ParseCSR(pemBytes []byte) (*x509.CertificateRequest, error) {
+	// extract PEM from request object
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != CertificateRequestBlockType {
+		return nil, errors.New("PEM block type must be CERTIFICATE REQUEST")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return csr, nil
+}
+
+// GetCondition returns the condition of the type, or nil if not present
+func GetCondition(csr *CertificateSigningRequest, conditionType RequestConditionType) *CertificateSigningRequestCondition {
+	for i := range csr.Status.Conditions {
+		if csr.Status.Conditions[i].Type == conditionType {
+			return &csr.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsCertificateRequestApproved returns true if a certificate request has the
+// "Approved" condition and no "Denied" conditions; false otherwise.
+func IsCertificateRequestApproved(csr *CertificateSigningRequest) bool {
+	return GetCondition(csr, CertificateApproved) != nil && GetCondition(csr, CertificateDenied) == nil
+}
+
+// IsCertificateRequestDenied returns true if a certificate request has the "Denied" condition
+func IsCertificateRequestDenied(csr *CertificateSigningRequest) bool {
+	return GetCondition(csr, CertificateDenied) != nil
+}
+
+// IsCertificateRequestDecided returns true if the request was approved or denied, it can't be decided again
+func IsCertificateRequestDecided(csr *CertificateSigningRequest) bool {
+	return GetCondition(csr, CertificateApproved) != nil || GetCondition(csr, CertificateDenied) != nil
+}
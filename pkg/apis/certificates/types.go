@@ -0,0 +1,139 @@
+// k8s.io/kubernetes/pkg/apis/certificates
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificates
+
+import (
+	"github.com/yubo/golib/api"
+)
+
+const (
+	GroupName = "certificates.k8s.io"
+
+	// CertificateRequestBlockType is the PEM block type of a certificate request
+	CertificateRequestBlockType = "CERTIFICATE REQUEST"
+)
+
+// +k8s:deepcopy-gen:interfaces=github.com/yubo/golib/runtime.Object
+
+// CertificateSigningRequest describes a certificate signing request, the
+// certificate is issued by the signer of the apiserver once the request is approved.
+type CertificateSigningRequest struct {
+	api.TypeMeta
+	// Standard object's metadata.
+	api.ObjectMeta `json:"metadata" sql:"inline"`
+
+	// The certificate request itself and any additional information.
+	Spec CertificateSigningRequestSpec `json:"spec"`
+
+	// Derived information about the request.
+	Status CertificateSigningRequestStatus `json:"status"`
+}
+
+// CertificateSigningRequestSpec contains the certificate request.
+type CertificateSigningRequestSpec struct {
+	// Base64-encoded PKCS#10 CSR data
+	Request []byte `json:"request"`
+
+	// ExpirationSeconds is the requested duration of validity of the issued
+	// certificate. The signer may issue a certificate with a shorter duration.
+	// +optional
+	ExpirationSeconds *int32 `json:"expirationSeconds,omitempty"`
+
+	// Usages specifies a set of usage contexts the key will be valid for.
+	// Defaults to the usages of the signer.
+	// +optional
+	Usages []KeyUsage `json:"usages,omitempty"`
+
+	// Information about the requesting user, populated by the server on creation.
+	// +optional
+	Username string `json:"username,omitempty"`
+	// +optional
+	UID string `json:"uid,omitempty"`
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+	// +optional
+	Extra map[string][]string `json:"extra,omitempty"`
+}
+
+// CertificateSigningRequestStatus contains the conditions of the request and the issued certificate.
+type CertificateSigningRequestStatus struct {
+	// Conditions applied to the request, such as approval or denial.
+	// +optional
+	Conditions []CertificateSigningRequestCondition `json:"conditions,omitempty"`
+
+	// If request was approved, the controller will place the issued certificate here.
+	// +optional
+	Certificate []byte `json:"certificate,omitempty"`
+}
+
+type RequestConditionType string
+
+// These are the possible conditions for a certificate request.
+const (
+	CertificateApproved RequestConditionType = "Approved"
+	CertificateDenied   RequestConditionType = "Denied"
+	CertificateFailed   RequestConditionType = "Failed"
+)
+
+type CertificateSigningRequestCondition struct {
+	// request approval state, currently Approved, Denied or Failed.
+	Type RequestConditionType `json:"type"`
+	// brief reason for the request state
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// human readable message with details about the request state
+	// +optional
+	Message string `json:"message,omitempty"`
+	// the user who approved or denied the request, empty for automatic decisions
+	// +optional
+	User string `json:"user,omitempty"`
+	// timestamp for the last update to this condition
+	// +optional
+	LastUpdateTime api.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// KeyUsage specifies valid usage contexts for keys.
+// See: https://tools.ietf.org/html/rfc5280#section-4.2.1.3
+//
+//	https://tools.ietf.org/html/rfc5280#section-4.2.1.12
+type KeyUsage string
+
+const (
+	UsageSigning           KeyUsage = "signing"
+	UsageDigitalSignature  KeyUsage = "digital signature"
+	UsageContentCommitment KeyUsage = "content commitment"
+	UsageKeyEncipherment   KeyUsage = "key encipherment"
+	UsageKeyAgreement      KeyUsage = "key agreement"
+	UsageDataEncipherment  KeyUsage = "data encipherment"
+	UsageServerAuth        KeyUsage = "server auth"
+	UsageClientAuth        KeyUsage = "client auth"
+	UsageCodeSigning       KeyUsage = "code signing"
+	UsageEmailProtection   KeyUsage = "email protection"
+)
+
+// +k8s:deepcopy-gen:interfaces=github.com/yubo/golib/runtime.Object
+
+// CertificateSigningRequestList is a collection of CertificateSigningRequests
+type CertificateSigningRequestList struct {
+	api.TypeMeta
+	// Standard object's metadata.
+	api.ListMeta
+
+	// Items is a list of CertificateSigningRequests
+	Items []CertificateSigningRequest
+}
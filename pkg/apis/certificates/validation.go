@@ -0,0 +1,71 @@
+// from k8s.io/kubernetes/pkg/apis/certificates/validation/validation.go
+package certificates
+
+import (
+	"fmt"
+
+	"github.com/yubo/apiserver/pkg/apis/validation"
+	"github.com/yubo/golib/util/sets"
+	"github.com/yubo/golib/util/validation/field"
+)
+
+var allValidUsages = sets.NewString(
+	string(UsageSigning),
+	string(UsageDigitalSignature),
+	string(UsageContentCommitment),
+	string(UsageKeyEncipherment),
+	string(UsageKeyAgreement),
+	string(UsageDataEncipherment),
+	string(UsageServerAuth),
+	string(UsageClientAuth),
+	string(UsageCodeSigning),
+	string(UsageEmailProtection),
+)
+
+// MinExpirationSeconds is the minimum of the requested duration of a certificate
+const MinExpirationSeconds = 600
+
+// ValidateCertificateRequestName checks the name of a csr, it is a path segment of the api
+func ValidateCertificateRequestName(name string, prefix bool) []string {
+	return validation.NameIsDNSSubdomain(name, prefix)
+}
+
+func ValidateCertificateSigningRequestCreate(csr *CertificateSigningRequest) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	namePath := field.NewPath("metadata", "name")
+	if len(csr.Name) == 0 {
+		allErrs = append(allErrs, field.Required(namePath, ""))
+	}
+	for _, msg := range ValidateCertificateRequestName(csr.Name, false) {
+		allErrs = append(allErrs, field.Invalid(namePath, csr.Name, msg))
+	}
+
+	specPath := field.NewPath("spec")
+	if req, err := ParseCSR(csr.Spec.Request); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("request"), "", err.Error()))
+	} else if err := req.CheckSignature(); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("request"), "", fmt.Sprintf("invalid signature: %v", err)))
+	}
+
+	if csr.Spec.ExpirationSeconds != nil && *csr.Spec.ExpirationSeconds < MinExpirationSeconds {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("expirationSeconds"), *csr.Spec.ExpirationSeconds, fmt.Sprintf("may not specify a duration less than %d seconds", MinExpirationSeconds)))
+	}
+
+	seen := sets.NewString()
+	for i, usage := range csr.Spec.Usages {
+		if !allValidUsages.Has(string(usage)) {
+			allErrs = append(allErrs, field.NotSupported(specPath.Child("usages").Index(i), usage, allValidUsages.List()))
+		}
+		if seen.Has(string(usage)) {
+			allErrs = append(allErrs, field.Duplicate(specPath.Child("usages").Index(i), usage))
+		}
+		seen.Insert(string(usage))
+	}
+
+	if len(csr.Status.Conditions) > 0 || len(csr.Status.Certificate) > 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("status"), "status is set by the server"))
+	}
+
+	return allErrs
+}
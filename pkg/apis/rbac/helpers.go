@@ -21,6 +21,8 @@ import (
 	"strings"
 
 	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/labels"
+	"github.com/yubo/golib/selection"
 	"github.com/yubo/golib/util/sets"
 )
 
@@ -454,6 +456,29 @@ func (r PolicyRule) CompactString() string {
 	return fmt.Sprintf(formatString, formatArgs...)
 }
 
+// DeepCopy creates a new PolicyRule that does not share any slice with the receiver.
+func (r *PolicyRule) DeepCopy() *PolicyRule {
+	if r == nil {
+		return nil
+	}
+	out := &PolicyRule{}
+	out.Verbs = copyStrings(r.Verbs)
+	out.APIGroups = copyStrings(r.APIGroups)
+	out.Resources = copyStrings(r.Resources)
+	out.ResourceNames = copyStrings(r.ResourceNames)
+	out.NonResourceURLs = copyStrings(r.NonResourceURLs)
+	return out
+}
+
+func copyStrings(in []string) []string {
+	if in == nil {
+		return nil
+	}
+	out := make([]string, len(in))
+	copy(out, in)
+	return out
+}
+
 // SortableRuleSlice is the slice of PolicyRule.
 type SortableRuleSlice []PolicyRule
 
@@ -462,3 +487,46 @@ func (s SortableRuleSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 func (s SortableRuleSlice) Less(i, j int) bool {
 	return strings.Compare(s[i].String(), s[j].String()) < 0
 }
+
+// LabelSelectorAsSelector converts the LabelSelector api type into a struct that implements
+// labels.Selector
+// Note: This function should be kept in sync with the selector methods in pkg/labels/selector.go
+func LabelSelectorAsSelector(ps *api.LabelSelector) (labels.Selector, error) {
+	if ps == nil {
+		return labels.Nothing(), nil
+	}
+	if len(ps.MatchLabels)+len(ps.MatchExpressions) == 0 {
+		return labels.Everything(), nil
+	}
+	requirements := make([]labels.Requirement, 0, len(ps.MatchLabels)+len(ps.MatchExpressions))
+	for k, v := range ps.MatchLabels {
+		r, err := labels.NewRequirement(k, selection.Equals, []string{v})
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, *r)
+	}
+	for _, expr := range ps.MatchExpressions {
+		var op selection.Operator
+		switch expr.Operator {
+		case api.LabelSelectorOpIn:
+			op = selection.In
+		case api.LabelSelectorOpNotIn:
+			op = selection.NotIn
+		case api.LabelSelectorOpExists:
+			op = selection.Exists
+		case api.LabelSelectorOpDoesNotExist:
+			op = selection.DoesNotExist
+		default:
+			return nil, fmt.Errorf("%q is not a valid label selector operator", expr.Operator)
+		}
+		r, err := labels.NewRequirement(expr.Key, op, append([]string(nil), expr.Values...))
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, *r)
+	}
+	selector := labels.NewSelector()
+	selector = selector.Add(requirements...)
+	return selector, nil
+}
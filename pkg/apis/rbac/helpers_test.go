@@ -0,0 +1,45 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/labels"
+)
+
+func TestLabelSelectorAsSelector(t *testing.T) {
+	cases := []struct {
+		name     string
+		selector *api.LabelSelector
+		labels   map[string]string
+		match    bool
+		err      bool
+	}{
+		{name: "nil matches nothing", labels: map[string]string{"a": "b"}},
+		{name: "empty matches everything", selector: &api.LabelSelector{}, match: true},
+		{name: "match labels", selector: &api.LabelSelector{MatchLabels: map[string]string{"a": "b"}}, labels: map[string]string{"a": "b"}, match: true},
+		{name: "not in", selector: &api.LabelSelector{MatchExpressions: []api.LabelSelectorRequirement{
+			{Key: "a", Operator: api.LabelSelectorOpNotIn, Values: []string{"b"}},
+		}}, labels: map[string]string{"a": "b"}},
+		{name: "does not exist", selector: &api.LabelSelector{MatchExpressions: []api.LabelSelectorRequirement{
+			{Key: "a", Operator: api.LabelSelectorOpDoesNotExist},
+		}}, labels: map[string]string{"c": "d"}, match: true},
+		{name: "invalid operator", selector: &api.LabelSelector{MatchExpressions: []api.LabelSelectorRequirement{
+			{Key: "a", Operator: "Like"},
+		}}, err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			selector, err := LabelSelectorAsSelector(c.selector)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.match, selector.Matches(labels.Set(c.labels)))
+		})
+	}
+}
@@ -20,6 +20,7 @@ package rbac
 import (
 	"github.com/yubo/apiserver/pkg/apis/validation"
 	path "github.com/yubo/apiserver/pkg/apis/validation"
+	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/util/validation/field"
 )
 
@@ -52,9 +53,49 @@ func ValidateRoleUpdate(role *Role, oldRole *Role) field.ErrorList {
 }
 
 func ValidateClusterRole(role *ClusterRole) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if role.AggregationRule != nil {
+		selectorsPath := field.NewPath("aggregationRule", "clusterRoleSelectors")
+		if len(role.AggregationRule.ClusterRoleSelectors) == 0 {
+			allErrs = append(allErrs, field.Required(selectorsPath, "at least one clusterRoleSelector required if aggregationRule is non-nil"))
+		}
+		for i, selector := range role.AggregationRule.ClusterRoleSelectors {
+			allErrs = append(allErrs, validateLabelSelector(selector, selectorsPath.Index(i))...)
+		}
+	}
+
+	for i, rule := range role.Rules {
+		if err := ValidatePolicyRule(rule, false, field.NewPath("rules").Index(i)); err != nil {
+			allErrs = append(allErrs, err...)
+		}
+	}
+	if len(allErrs) != 0 {
+		return allErrs
+	}
 	return nil
 }
 
+func validateLabelSelector(ps api.LabelSelector, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, expr := range ps.MatchExpressions {
+		exprPath := fldPath.Child("matchExpressions").Index(i)
+		switch expr.Operator {
+		case api.LabelSelectorOpIn, api.LabelSelectorOpNotIn:
+			if len(expr.Values) == 0 {
+				allErrs = append(allErrs, field.Required(exprPath.Child("values"), "must be specified when `operator` is 'In' or 'NotIn'"))
+			}
+		case api.LabelSelectorOpExists, api.LabelSelectorOpDoesNotExist:
+			if len(expr.Values) > 0 {
+				allErrs = append(allErrs, field.Forbidden(exprPath.Child("values"), "may not be specified when `operator` is 'Exists' or 'DoesNotExist'"))
+			}
+		default:
+			allErrs = append(allErrs, field.Invalid(exprPath.Child("operator"), expr.Operator, "not a valid selector operator"))
+		}
+	}
+	return allErrs
+}
+
 func ValidateClusterRoleUpdate(role *ClusterRole, oldRole *ClusterRole) field.ErrorList {
 	allErrs := ValidateClusterRole(role)
 
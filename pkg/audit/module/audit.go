@@ -2,6 +2,7 @@ package module
 
 import (
 	"context"
+	"crypto"
 	"fmt"
 	"io"
 	"net"
@@ -12,19 +13,27 @@ import (
 	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
 	"github.com/yubo/apiserver/pkg/audit"
 	"github.com/yubo/apiserver/pkg/audit/policy"
+	"github.com/yubo/apiserver/pkg/config/configgrpc"
+	"github.com/yubo/apiserver/pkg/config/configtls"
+	"github.com/yubo/apiserver/pkg/grpcclient"
 	"github.com/yubo/apiserver/pkg/proc"
 	v1 "github.com/yubo/apiserver/pkg/proc/api/v1"
 	"github.com/yubo/apiserver/pkg/proc/options"
 	"github.com/yubo/apiserver/pkg/util/webhook"
 	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/configer"
+	"github.com/yubo/golib/util/clock"
 	"github.com/yubo/golib/util/errors"
+	"github.com/yubo/golib/util/keyutil"
 	utilnet "github.com/yubo/golib/util/net"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"k8s.io/klog/v2"
 
 	pluginbuffered "github.com/yubo/apiserver/plugin/audit/buffered"
+	plugindb "github.com/yubo/apiserver/plugin/audit/db"
 	pluginlog "github.com/yubo/apiserver/plugin/audit/log"
+	pluginotlp "github.com/yubo/apiserver/plugin/audit/otlp"
+	pluginsyslog "github.com/yubo/apiserver/plugin/audit/syslog"
 	plugintruncate "github.com/yubo/apiserver/plugin/audit/truncate"
 	pluginwebhook "github.com/yubo/apiserver/plugin/audit/webhook"
 )
@@ -79,15 +88,27 @@ type config struct {
 	// If unspecified, a default is provided.
 	PolicyFile string `json:"policyFile" flag:"audit-policy-file" description:"Path to the file that defines the audit policy configuration."`
 
+	// The policy file is reloaded on the reload signal, and when it's changed.
+	PolicyReloadInterval api.Duration `json:"policyReloadInterval" flag:"audit-policy-reload-interval" description:"The interval of checking the audit policy file for changes, 0 disables it. The policy is also reloaded on the reload signal."`
+
 	// Plugin options
 	LogOptions     AuditLogOptions     `json:"log"`
 	WebhookOptions AuditWebhookOptions `json:"webhook"`
+	DBOptions      AuditDBOptions      `json:"db"`
+	SyslogOptions  AuditSyslogOptions  `json:"syslog"`
+	OTLPOptions    AuditOTLPOptions    `json:"otlp"`
 }
 
 func (c *config) Validate() error {
 	var allErrors []error
+	if c.PolicyReloadInterval.Duration < 0 {
+		allErrors = append(allErrors, fmt.Errorf("--audit-policy-reload-interval %v can't be negative", c.PolicyReloadInterval))
+	}
 	allErrors = append(allErrors, c.LogOptions.Validate()...)
 	allErrors = append(allErrors, c.WebhookOptions.Validate()...)
+	allErrors = append(allErrors, c.DBOptions.Validate()...)
+	allErrors = append(allErrors, c.SyslogOptions.Validate()...)
+	allErrors = append(allErrors, c.OTLPOptions.Validate()...)
 
 	return errors.NewAggregate(allErrors)
 }
@@ -100,6 +121,15 @@ func (p *config) GetTags() map[string]*configer.FieldTag {
 	for k, v := range p.WebhookOptions.GetTags() {
 		tags["webhook."+k] = v
 	}
+	for k, v := range p.DBOptions.GetTags() {
+		tags["db."+k] = v
+	}
+	for k, v := range p.SyslogOptions.GetTags() {
+		tags["syslog."+k] = v
+	}
+	for k, v := range p.OTLPOptions.GetTags() {
+		tags["otlp."+k] = v
+	}
 
 	return tags
 }
@@ -197,6 +227,10 @@ type AuditLogOptions struct {
 	Format     string `json:"format" flag:"audit-log-format" default:"json" description:"-"`
 	Compress   bool   `json:"compress" flag:"audit-log-compress" description:"If set, the rotated log files will be compressed using gzip."`
 
+	// Tamper-evident log, see pluginlog.VerifyChain
+	ChainSigningKeyFile string       `json:"chainSigningKeyFile" flag:"audit-log-chain-signing-key-file" description:"If set, the json log events are hash chained, and the checkpoints are signed by the rsa or ecdsa key in the file. The log can be validated by the 'audit verify' command."`
+	CheckpointInterval  api.Duration `json:"checkpointInterval" flag:"audit-log-checkpoint-interval" description:"The interval of the signed checkpoints of the chained log, a checkpoint is also written on shutdown."`
+
 	BatchOptions    AuditBatchOptions    `json:"batch"`
 	TruncateOptions AuditTruncateOptions `json:"truncate"`
 
@@ -245,6 +279,15 @@ func (o *AuditLogOptions) Validate() []error {
 		allErrors = append(allErrors, fmt.Errorf("invalid audit log format %s, allowed formats are %q", o.Format, strings.Join(pluginlog.AllowedFormats, ",")))
 	}
 
+	if o.ChainSigningKeyFile != "" {
+		if o.Format != pluginlog.FormatJson {
+			allErrors = append(allErrors, fmt.Errorf("--audit-log-chain-signing-key-file requires the %s audit log format", pluginlog.FormatJson))
+		}
+		if o.CheckpointInterval.Duration <= 0 {
+			allErrors = append(allErrors, fmt.Errorf("--audit-log-checkpoint-interval must be positive"))
+		}
+	}
+
 	// Check validities of MaxAge, MaxBackups and MaxSize of log options, if file log backend is enabled.
 	if o.MaxAge < 0 {
 		allErrors = append(allErrors, fmt.Errorf("--audit-log-maxage %v can't be a negative number", o.MaxAge))
@@ -282,11 +325,33 @@ func (o *AuditLogOptions) getWriter() io.Writer {
 	return w
 }
 
-func (o *AuditLogOptions) newBackend(w io.Writer) audit.Backend {
+func (o *AuditLogOptions) newBackend(w io.Writer) (audit.Backend, error) {
 	log := pluginlog.NewBackend(w, o.Format)
+	if o.ChainSigningKeyFile != "" {
+		var err error
+		if log, err = o.newChainedBackend(w); err != nil {
+			return nil, err
+		}
+	}
 	log = o.BatchOptions.wrapBackend(log)
 	log = o.TruncateOptions.wrapBackend(log)
-	return log
+	return log, nil
+}
+
+func (o *AuditLogOptions) newChainedBackend(w io.Writer) (audit.Backend, error) {
+	key, err := keyutil.PrivateKeyFromFile(o.ChainSigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading audit log chain signing key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("audit log chain signing key %T is not a signer", key)
+	}
+
+	return pluginlog.NewChainedBackend(w, pluginlog.ChainConfig{
+		Signer:             signer,
+		CheckpointInterval: o.CheckpointInterval.Duration,
+	})
 }
 
 func validateBackendMode(pluginName string, mode string) error {
@@ -382,6 +447,205 @@ func (o *AuditWebhookOptions) newUntruncatedBackend(customDial utilnet.DialFunc)
 	return webhook, nil
 }
 
+// AuditDBOptions control the database backend for audit events, which serves the events api.
+type AuditDBOptions struct {
+	Enabled       bool         `json:"enabled" flag:"audit-db-enabled" description:"If set, the audit events are stored in the database, and can be queried by the api."`
+	DBName        string       `json:"dbName" flag:"audit-db-name" description:"The name of the database in the db config, the default database is used if empty."`
+	TableName     string       `json:"tableName" flag:"audit-db-table" default:"audit_event" description:"The table of the audit events, it's created if not exist."`
+	Retention     api.Duration `json:"retention" flag:"audit-db-retention" description:"The events older than the retention are pruned, 0 means forever."`
+	PruneInterval api.Duration `json:"pruneInterval" flag:"audit-db-prune-interval" description:"The interval of pruning the events."`
+
+	BatchOptions    AuditBatchOptions    `json:"batch"`
+	TruncateOptions AuditTruncateOptions `json:"truncate"`
+}
+
+func (p *AuditDBOptions) GetTags() map[string]*configer.FieldTag {
+	tags := map[string]*configer.FieldTag{}
+	for k, v := range p.BatchOptions.GetTags(plugindb.PluginName) {
+		tags["batch."+k] = v
+	}
+	for k, v := range p.TruncateOptions.GetTags(plugindb.PluginName) {
+		tags["truncate."+k] = v
+	}
+
+	return tags
+}
+
+func (o *AuditDBOptions) Validate() []error {
+	if !o.enabled() {
+		return nil
+	}
+
+	var allErrors []error
+	if err := validateBackendBatchOptions(plugindb.PluginName, o.BatchOptions); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	if err := o.TruncateOptions.Validate(plugindb.PluginName); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	if o.Retention.Duration < 0 {
+		allErrors = append(allErrors, fmt.Errorf("--audit-db-retention %v can't be negative", o.Retention))
+	}
+	if o.Retention.Duration > 0 && o.PruneInterval.Duration <= 0 {
+		allErrors = append(allErrors, fmt.Errorf("--audit-db-prune-interval must be positive with --audit-db-retention"))
+	}
+	return allErrors
+}
+
+func (o *AuditDBOptions) enabled() bool {
+	return o != nil && o.Enabled
+}
+
+func (o *AuditDBOptions) newBackend(ctx context.Context) (*plugindb.Backend, audit.Backend, error) {
+	db, ok := options.DBFrom(ctx, o.DBName)
+	if !ok {
+		return nil, nil, fmt.Errorf("initializing audit db: can't find db %q from context", o.DBName)
+	}
+
+	backend, err := plugindb.NewBackend(db, plugindb.Config{
+		TableName:     o.TableName,
+		Retention:     o.Retention.Duration,
+		PruneInterval: o.PruneInterval.Duration,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrapped := o.BatchOptions.wrapBackend(backend)
+	wrapped = o.TruncateOptions.wrapBackend(wrapped)
+	return backend, wrapped, nil
+}
+
+// AuditSyslogOptions control the syslog backend, the events are sent as RFC 5424 messages over TCP or TLS.
+type AuditSyslogOptions struct {
+	Address          string                     `json:"address" flag:"audit-syslog-address" description:"The host:port of the syslog server, the syslog backend is enabled if set."`
+	TLS              configtls.TLSClientSetting `json:"tls"`
+	Facility         int                        `json:"facility" flag:"audit-syslog-facility" description:"The syslog facility of the messages, defaults to 13 (log audit)."`
+	AppName          string                     `json:"appName" flag:"audit-syslog-app-name" description:"The APP-NAME of the messages, defaults to the program name."`
+	StructuredDataID string                     `json:"structuredDataID" flag:"audit-syslog-sd-id" default:"audit@32473" description:"The SD-ID of the event fields in the messages."`
+	Timeout          api.Duration               `json:"timeout" flag:"audit-syslog-timeout" description:"The timeout of connecting and writing to the syslog server."`
+
+	BatchOptions    AuditBatchOptions    `json:"batch"`
+	TruncateOptions AuditTruncateOptions `json:"truncate"`
+}
+
+func (p *AuditSyslogOptions) GetTags() map[string]*configer.FieldTag {
+	tags := map[string]*configer.FieldTag{}
+	for k, v := range p.BatchOptions.GetTags(pluginsyslog.PluginName) {
+		tags["batch."+k] = v
+	}
+	for k, v := range p.TruncateOptions.GetTags(pluginsyslog.PluginName) {
+		tags["truncate."+k] = v
+	}
+
+	return tags
+}
+
+func (o *AuditSyslogOptions) Validate() []error {
+	if !o.enabled() {
+		return nil
+	}
+
+	var allErrors []error
+	if err := validateBackendBatchOptions(pluginsyslog.PluginName, o.BatchOptions); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	if err := o.TruncateOptions.Validate(pluginsyslog.PluginName); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	if o.Facility < 0 || o.Facility > 23 {
+		allErrors = append(allErrors, fmt.Errorf("--audit-syslog-facility %d must be in [0, 23]", o.Facility))
+	}
+	return allErrors
+}
+
+func (o *AuditSyslogOptions) enabled() bool {
+	return o != nil && o.Address != ""
+}
+
+func (o *AuditSyslogOptions) newBackend() (audit.Backend, error) {
+	tlsConfig, err := o.TLS.LoadTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("initializing audit syslog: %v", err)
+	}
+
+	backend, err := pluginsyslog.NewBackend(pluginsyslog.Config{
+		Address:          o.Address,
+		TLSConfig:        tlsConfig,
+		Facility:         o.Facility,
+		AppName:          o.AppName,
+		StructuredDataID: o.StructuredDataID,
+		Timeout:          o.Timeout.Duration,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("initializing audit syslog: %v", err)
+	}
+
+	backend = o.BatchOptions.wrapBackend(backend)
+	backend = o.TruncateOptions.wrapBackend(backend)
+	return backend, nil
+}
+
+// AuditOTLPOptions control the otlp logs backend, the events are exported by the otlp grpc protocol.
+type AuditOTLPOptions struct {
+	configgrpc.GRPCClientSettings
+	ServiceName        string            `json:"serviceName" flag:"audit-otlp-service-name" description:"The service.name of the exported logs, defaults to the program name."`
+	ResourceAttributes map[string]string `json:"resourceAttributes"`
+	Timeout            api.Duration      `json:"timeout" flag:"audit-otlp-timeout" description:"The timeout of an export request."`
+
+	BatchOptions    AuditBatchOptions    `json:"batch"`
+	TruncateOptions AuditTruncateOptions `json:"truncate"`
+}
+
+func (p *AuditOTLPOptions) GetTags() map[string]*configer.FieldTag {
+	tags := map[string]*configer.FieldTag{
+		"endpoint": {Flag: []string{"audit-otlp-endpoint"}, Description: "The grpc endpoint of the otlp logs receiver, the otlp backend is enabled if set."},
+	}
+	for k, v := range p.BatchOptions.GetTags(pluginotlp.PluginName) {
+		tags["batch."+k] = v
+	}
+	for k, v := range p.TruncateOptions.GetTags(pluginotlp.PluginName) {
+		tags["truncate."+k] = v
+	}
+
+	return tags
+}
+
+func (o *AuditOTLPOptions) Validate() []error {
+	if !o.enabled() {
+		return nil
+	}
+
+	var allErrors []error
+	if err := validateBackendBatchOptions(pluginotlp.PluginName, o.BatchOptions); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	if err := o.TruncateOptions.Validate(pluginotlp.PluginName); err != nil {
+		allErrors = append(allErrors, err)
+	}
+	return allErrors
+}
+
+func (o *AuditOTLPOptions) enabled() bool {
+	return o != nil && o.Endpoint != ""
+}
+
+func (o *AuditOTLPOptions) newBackend(ctx context.Context) (audit.Backend, error) {
+	conn, err := grpcclient.Dial(ctx, &o.GRPCClientSettings)
+	if err != nil {
+		return nil, fmt.Errorf("initializing audit otlp: %v", err)
+	}
+
+	var backend audit.Backend = pluginotlp.NewBackend(conn, pluginotlp.Config{
+		ServiceName:        o.ServiceName,
+		ResourceAttributes: o.ResourceAttributes,
+		Timeout:            o.Timeout.Duration,
+	})
+	backend = o.BatchOptions.wrapBackend(backend)
+	backend = o.TruncateOptions.wrapBackend(backend)
+	return backend, nil
+}
+
 // AuditDynamicOptions control the configuration of dynamic backends for audit events
 type AuditDynamicOptions struct {
 	// Enabled tells whether the dynamic audit capability is enabled.
@@ -394,6 +658,7 @@ type AuditDynamicOptions struct {
 
 func newConfig() *config {
 	return &config{
+		PolicyReloadInterval: api.Duration{Duration: 10 * time.Second},
 		WebhookOptions: AuditWebhookOptions{
 			InitialBackoff: api.Duration{Duration: pluginwebhook.DefaultInitialBackoffDelay},
 			BatchOptions: AuditBatchOptions{
@@ -404,7 +669,8 @@ func newConfig() *config {
 			//GroupVersionString: "audit.k8s.io/v1",
 		},
 		LogOptions: AuditLogOptions{
-			Format: pluginlog.FormatJson,
+			Format:             pluginlog.FormatJson,
+			CheckpointInterval: api.Duration{Duration: time.Minute},
 			BatchOptions: AuditBatchOptions{
 				Mode:        ModeBlocking,
 				BatchConfig: defaultLogBatchConfig(),
@@ -412,6 +678,32 @@ func newConfig() *config {
 			TruncateOptions: NewAuditTruncateOptions(),
 			//GroupVersionString: "audit.k8s.io/v1",
 		},
+		DBOptions: AuditDBOptions{
+			Retention:     api.Duration{Duration: 720 * time.Hour},
+			PruneInterval: api.Duration{Duration: time.Hour},
+			BatchOptions: AuditBatchOptions{
+				Mode:        ModeBatch,
+				BatchConfig: defaultDBBatchConfig(),
+			},
+			TruncateOptions: NewAuditTruncateOptions(),
+		},
+		SyslogOptions: AuditSyslogOptions{
+			Facility: pluginsyslog.DefaultFacility,
+			Timeout:  api.Duration{Duration: 10 * time.Second},
+			BatchOptions: AuditBatchOptions{
+				Mode:        ModeBatch,
+				BatchConfig: defaultExporterBatchConfig(),
+			},
+			TruncateOptions: NewAuditTruncateOptions(),
+		},
+		OTLPOptions: AuditOTLPOptions{
+			Timeout: api.Duration{Duration: 10 * time.Second},
+			BatchOptions: AuditBatchOptions{
+				Mode:        ModeBatch,
+				BatchConfig: defaultExporterBatchConfig(),
+			},
+			TruncateOptions: NewAuditTruncateOptions(),
+		},
 	}
 }
 
@@ -453,6 +745,30 @@ func defaultLogBatchConfig() pluginbuffered.BatchConfig {
 	}
 }
 
+// defaultDBBatchConfig returns the default BatchConfig used by the DB backend.
+func defaultDBBatchConfig() pluginbuffered.BatchConfig {
+	return pluginbuffered.BatchConfig{
+		BufferSize:   defaultBatchBufferSize,
+		MaxBatchSize: defaultBatchMaxSize,
+		MaxBatchWait: api.Duration{Duration: time.Second},
+		// The inserts are not throttled, a single writer is enough.
+		ThrottleEnable: false,
+		AsyncDelegate:  false,
+	}
+}
+
+// defaultExporterBatchConfig returns the default BatchConfig used by the syslog and otlp backends.
+func defaultExporterBatchConfig() pluginbuffered.BatchConfig {
+	return pluginbuffered.BatchConfig{
+		BufferSize:   defaultBatchBufferSize,
+		MaxBatchSize: defaultBatchMaxSize,
+		// The events are expected by the SIEM or the log store in near real time.
+		MaxBatchWait:   api.Duration{Duration: time.Second},
+		ThrottleEnable: false,
+		AsyncDelegate:  false,
+	}
+}
+
 var (
 	_module = &module{name: moduleName}
 	hookOps = []v1.HookOps{{
@@ -461,6 +777,18 @@ var (
 		HookNum:     v1.ACTION_START,
 		Priority:    v1.PRI_SYS_INIT,
 		SubPriority: v1.PRI_M_AUDIT,
+	}, {
+		Hook:        _module.installAPI,
+		Owner:       moduleName,
+		HookNum:     v1.ACTION_START,
+		Priority:    v1.PRI_MODULE,
+		SubPriority: v1.PRI_M_AUDIT,
+	}, {
+		Hook:        _module.reload,
+		Owner:       moduleName,
+		HookNum:     v1.ACTION_RELOAD,
+		Priority:    v1.PRI_SYS_INIT,
+		SubPriority: v1.PRI_M_AUDIT,
 	}, {
 		Hook:        _module.stop,
 		Owner:       moduleName,
@@ -476,7 +804,11 @@ type module struct {
 	ctx     context.Context
 	cancel  context.CancelFunc
 	checker policy.Checker
+	// policy is the reloadable checker of the policy file
+	policy  *policy.FileChecker
 	backend audit.Backend
+	// dbBackend serves the events api if the db backend is enabled
+	dbBackend *plugindb.Backend
 }
 
 func (p *module) Checker() audit.Checker {
@@ -506,10 +838,42 @@ func (p *module) init(ctx context.Context) (err error) {
 		}
 	}
 
+	if p.policy != nil && cf.PolicyReloadInterval.Duration > 0 {
+		p.policy.Run(cf.PolicyReloadInterval.Duration, clock.RealClock{}, p.ctx.Done())
+	}
+
 	options.WithAudit(ctx, p)
 	return
 }
 
+// installAPI serves the events api of the db backend
+func (p *module) installAPI(ctx context.Context) error {
+	if p.dbBackend == nil {
+		return nil
+	}
+
+	plugindb.NewAPI(p.dbBackend).Install(options.APIServerMustFrom(ctx))
+	klog.InfoS("audit events api installed", "path", plugindb.APIPath+"/events")
+
+	return nil
+}
+
+// reload swaps the policy checker if the policy file has been changed,
+// an invalid policy is logged and the current one is kept.
+func (p *module) reload(ctx context.Context) error {
+	if p.policy == nil {
+		return nil
+	}
+
+	if changed, err := p.policy.Reload(); err != nil {
+		klog.ErrorS(err, "Failed to reload the audit policy, keep the current one")
+	} else if changed {
+		klog.InfoS("Audit policy reloaded", "path", p.config.PolicyFile)
+	}
+
+	return nil
+}
+
 func (p *module) stop(ctx context.Context) error {
 	if p.cancel != nil {
 		p.cancel()
@@ -536,7 +900,9 @@ func (p *module) initAudit() error {
 		if checker == nil {
 			klog.V(2).Info("No audit policy file provided, no events will be recorded for log backend")
 		} else {
-			logBackend = c.LogOptions.newBackend(w)
+			if logBackend, err = c.LogOptions.newBackend(w); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -563,18 +929,49 @@ func (p *module) initAudit() error {
 		}
 	}
 
-	// 4. Apply dynamic options.
+	// 4. Build db backend
+	var dbBackend audit.Backend
+	if c.DBOptions.enabled() {
+		if checker == nil {
+			klog.V(2).Info("No audit policy file provided, no events will be recorded for db backend")
+		} else if p.dbBackend, dbBackend, err = c.DBOptions.newBackend(p.ctx); err != nil {
+			return err
+		}
+	}
+
+	// 5. Build syslog and otlp backends
+	var syslogBackend, otlpBackend audit.Backend
+	if c.SyslogOptions.enabled() {
+		if checker == nil {
+			klog.V(2).Info("No audit policy file provided, no events will be recorded for syslog backend")
+		} else if syslogBackend, err = c.SyslogOptions.newBackend(); err != nil {
+			return err
+		}
+	}
+	if c.OTLPOptions.enabled() {
+		if checker == nil {
+			klog.V(2).Info("No audit policy file provided, no events will be recorded for otlp backend")
+		} else if otlpBackend, err = c.OTLPOptions.newBackend(p.ctx); err != nil {
+			return err
+		}
+	}
+
+	// 6. Apply dynamic options.
 	var dynamicBackend audit.Backend
 	if webhookBackend != nil {
 		// if only webhook is enabled wrap it in the truncate options
 		dynamicBackend = c.WebhookOptions.TruncateOptions.wrapBackend(webhookBackend)
 	}
 
-	// 5. Set the policy checker
-	p.checker = checker
+	// 7. Set the policy checker
+	if checker != nil {
+		p.checker = checker
+		p.policy = checker
+	}
 
-	// 6. Join the log backend with the webhooks
-	p.backend = appendBackend(logBackend, dynamicBackend)
+	// 8. Join the log backend with the webhooks, the db, syslog and otlp
+	p.backend = appendBackend(appendBackend(logBackend, dynamicBackend), dbBackend)
+	p.backend = appendBackend(appendBackend(p.backend, syslogBackend), otlpBackend)
 
 	if p.backend != nil {
 		klog.V(2).Infof("Using audit backend: %s", p.backend)
@@ -583,15 +980,15 @@ func (p *module) initAudit() error {
 	return nil
 }
 
-func (c *config) newPolicyChecker() (policy.Checker, error) {
+func (c *config) newPolicyChecker() (*policy.FileChecker, error) {
 	if c.PolicyFile == "" {
 		return nil, nil
 	}
 
-	if p, err := policy.LoadPolicyFromFile(c.PolicyFile); err != nil {
+	if p, err := policy.NewFileChecker(c.PolicyFile); err != nil {
 		return nil, fmt.Errorf("loading audit policy file: %v", err)
 	} else {
-		return policy.NewChecker(p), nil
+		return p, nil
 	}
 }
 
@@ -603,7 +1000,12 @@ func RegisterConfig() {
 	proc.AddConfig(moduleName, newConfig(), proc.WithConfigGroup("Audit"))
 }
 
+func RegisterCommand() {
+	proc.RegisterCommand(newAuditCmd())
+}
+
 func Register() {
 	RegisterHooks()
 	RegisterConfig()
+	RegisterCommand()
 }
@@ -0,0 +1,78 @@
+package module
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yubo/golib/util/keyutil"
+
+	pluginlog "github.com/yubo/apiserver/plugin/audit/log"
+)
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "audit log tools",
+	}
+	cmd.AddCommand(newVerifyCmd())
+	return cmd
+}
+
+type verifyOptions struct {
+	publicKeyFile string
+	prevHash      string
+}
+
+func newVerifyCmd() *cobra.Command {
+	opts := &verifyOptions{}
+
+	cmd := &cobra.Command{
+		Use:          "verify FILE",
+		Short:        "validate the hash chain and the signed checkpoints of an audit log file",
+		Long:         "validate the hash chain and the signed checkpoints of an audit log file written with --audit-log-chain-signing-key-file, and report the first broken link. FILE '-' means standard input.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.run(cmd.OutOrStdout(), args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.publicKeyFile, "public-key-file", "", "The file of the public keys, certificates or private key of the checkpoint signer.")
+	cmd.Flags().StringVar(&opts.prevHash, "prev-hash", "", "The hash of the last event of the previous file, if the file continues a rotated one.")
+	cmd.MarkFlagRequired("public-key-file")
+
+	return cmd
+}
+
+func (o *verifyOptions) run(out io.Writer, file string) error {
+	keys, err := keyutil.PublicKeysFromFile(o.publicKeyFile)
+	if err != nil {
+		return fmt.Errorf("loading public keys: %v", err)
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ret, err := pluginlog.VerifyChain(r, keys, o.prevHash)
+	if err != nil {
+		if e, ok := err.(*pluginlog.VerifyError); ok {
+			return fmt.Errorf("broken link at %s, %d events verified before it", e, ret.Events)
+		}
+		return err
+	}
+
+	fmt.Fprintf(out, "OK: %d events in %d chains, %d checkpoints\n", ret.Events, ret.Chains, ret.Checkpoints)
+	if ret.Unsigned > 0 {
+		fmt.Fprintf(out, "WARNING: the last %d events are not covered by a checkpoint\n", ret.Unsigned)
+	}
+	return nil
+}
@@ -17,10 +17,14 @@ limitations under the License.
 package policy
 
 import (
+	"math/rand"
 	"strings"
+	"sync"
 
 	"github.com/yubo/apiserver/pkg/apis/audit"
 	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/golib/util/clock"
+	"github.com/yubo/golib/util/flowcontrol"
 )
 
 const (
@@ -36,10 +40,18 @@ type Checker interface {
 
 // NewChecker creates a new policy checker.
 func NewChecker(policy *audit.Policy) Checker {
+	return newChecker(policy, clock.RealClock{}, rand.Float64)
+}
+
+func newChecker(policy *audit.Policy, clock flowcontrol.Clock, random func() float64) *policyChecker {
+	limiters := make([]*userLimiters, len(policy.Rules))
 	for i, rule := range policy.Rules {
 		policy.Rules[i].OmitStages = unionStages(policy.OmitStages, rule.OmitStages)
+		if rule.RateLimit != nil {
+			limiters[i] = newUserLimiters(rule.RateLimit, clock)
+		}
 	}
-	return &policyChecker{*policy}
+	return &policyChecker{Policy: *policy, limiters: limiters, random: random}
 }
 
 func unionStages(stageLists ...[]audit.Stage) []audit.Stage {
@@ -63,17 +75,93 @@ func FakeChecker(level audit.Level, stage []audit.Stage) Checker {
 
 type policyChecker struct {
 	audit.Policy
+	// limiters of the rules with a rate limit, by the index of the rule
+	limiters []*userLimiters
+	random   func() float64
 }
 
 func (p *policyChecker) LevelAndStages(attrs authorizer.Attributes) (audit.Level, []audit.Stage) {
-	for _, rule := range p.Rules {
-		if ruleMatches(&rule, attrs) {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if ruleMatches(rule, attrs) {
+			if !p.sampled(i, attrs) {
+				return audit.LevelNone, rule.OmitStages
+			}
 			return rule.Level, rule.OmitStages
 		}
 	}
 	return DefaultAuditLevel, p.OmitStages
 }
 
+// sampled applies the sample rate and the per user rate limit of the matched rule,
+// the tokens are only taken by the sampled requests.
+func (p *policyChecker) sampled(i int, attrs authorizer.Attributes) bool {
+	rule := &p.Rules[i]
+	if rule.Level == audit.LevelNone {
+		return true
+	}
+
+	if rule.SampleRate != nil && p.random() >= *rule.SampleRate {
+		return false
+	}
+
+	if limiters := p.limiters[i]; limiters != nil {
+		var name string
+		if user := attrs.GetUser(); user != nil {
+			name = user.GetName()
+		}
+		return limiters.tryAccept(name)
+	}
+
+	return true
+}
+
+// maxLimitedUsers bounds the limiters of a rule, all of them are
+// reset when it's exceeded.
+const maxLimitedUsers = 10000
+
+// userLimiters is a token bucket per user for a rule
+type userLimiters struct {
+	sync.Mutex
+	qps      float32
+	burst    int
+	clock    flowcontrol.Clock
+	limiters map[string]flowcontrol.RateLimiter
+}
+
+func newUserLimiters(limit *audit.PolicyRateLimit, clock flowcontrol.Clock) *userLimiters {
+	burst := limit.Burst
+	if burst == 0 {
+		burst = int(limit.QPS)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &userLimiters{
+		qps:      limit.QPS,
+		burst:    burst,
+		clock:    clock,
+		limiters: map[string]flowcontrol.RateLimiter{},
+	}
+}
+
+func (p *userLimiters) tryAccept(user string) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	limiter, ok := p.limiters[user]
+	if !ok {
+		if len(p.limiters) >= maxLimitedUsers {
+			p.limiters = map[string]flowcontrol.RateLimiter{}
+		}
+		limiter = flowcontrol.NewTokenBucketRateLimiterWithClock(p.qps, p.burst, p.clock)
+		p.limiters[user] = limiter
+	}
+
+	return limiter.TryAccept()
+}
+
 // Check whether the rule matches the request attrs.
 func ruleMatches(r *audit.PolicyRule, attrs authorizer.Attributes) bool {
 	user := attrs.GetUser()
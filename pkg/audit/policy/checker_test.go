@@ -19,6 +19,7 @@ package policy
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -26,6 +27,7 @@ import (
 	"github.com/yubo/apiserver/pkg/apis/audit"
 	"github.com/yubo/apiserver/pkg/authentication/user"
 	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	testingclock "github.com/yubo/golib/util/clock/testing"
 )
 
 var (
@@ -345,3 +347,55 @@ func TestUnionStages(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckerSampleRate(t *testing.T) {
+	rate := 0.3
+	random := []float64{0.1, 0.5, 0.29, 0.3}
+	checker := newChecker(&audit.Policy{Rules: []audit.PolicyRule{{
+		Level:      audit.LevelRequestResponse,
+		Verbs:      []string{"get"},
+		SampleRate: &rate,
+	}}}, testingclock.NewFakeClock(time.Now()), func() float64 {
+		r := random[0]
+		random = random[1:]
+		return r
+	})
+
+	var levels []audit.Level
+	for range random {
+		level, _ := checker.LevelAndStages(attrs["namespaced"])
+		levels = append(levels, level)
+	}
+	assert.Equal(t, []audit.Level{
+		audit.LevelRequestResponse,
+		audit.LevelNone,
+		audit.LevelRequestResponse,
+		audit.LevelNone,
+	}, levels)
+}
+
+func TestCheckerRateLimit(t *testing.T) {
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	checker := newChecker(&audit.Policy{Rules: []audit.PolicyRule{{
+		Level:     audit.LevelMetadata,
+		RateLimit: &audit.PolicyRateLimit{QPS: 1, Burst: 2},
+	}}}, fakeClock, nil)
+
+	jerry := &authorizer.AttributesRecord{User: &user.DefaultInfo{Name: "jerry"}, Verb: "get", Path: "/metrics"}
+	levelOf := func(attrs authorizer.Attributes) audit.Level {
+		level, _ := checker.LevelAndStages(attrs)
+		return level
+	}
+
+	// burst
+	assert.Equal(t, audit.LevelMetadata, levelOf(attrs["namespaced"]))
+	assert.Equal(t, audit.LevelMetadata, levelOf(attrs["namespaced"]))
+	assert.Equal(t, audit.LevelNone, levelOf(attrs["namespaced"]))
+
+	// the limit is per user
+	assert.Equal(t, audit.LevelMetadata, levelOf(jerry))
+
+	fakeClock.Step(time.Second)
+	assert.Equal(t, audit.LevelMetadata, levelOf(attrs["namespaced"]))
+	assert.Equal(t, audit.LevelNone, levelOf(attrs["namespaced"]))
+}
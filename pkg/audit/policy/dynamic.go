@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/golib/util"
+	"github.com/yubo/golib/util/clock"
+	"k8s.io/klog/v2"
+)
+
+// FileChecker is a Checker of a policy file that can be reloaded, the checker of the
+// new policy is swapped atomically, an invalid policy keeps the current one.
+// The rate limits of the rules start over after the policy is changed.
+type FileChecker struct {
+	path    string
+	checker atomic.Value // Checker
+
+	mu   sync.Mutex
+	hash [sha256.Size]byte
+}
+
+// NewFileChecker loads the policy file, the file must be valid.
+func NewFileChecker(path string) (*FileChecker, error) {
+	p := &FileChecker{path: path}
+	if _, err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileChecker) LevelAndStages(attrs authorizer.Attributes) (audit.Level, []audit.Stage) {
+	return p.checker.Load().(Checker).LevelAndStages(attrs)
+}
+
+// Reload reads the policy file and swaps the checker if the content has been changed.
+func (p *FileChecker) Reload() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file path %q: %+v", p.path, err)
+	}
+
+	hash := sha256.Sum256(b)
+	if p.checker.Load() != nil && hash == p.hash {
+		return false, nil
+	}
+
+	policy, err := LoadPolicyFromBytes(b)
+	if err != nil {
+		return false, fmt.Errorf("%v: from file %v", err.Error(), p.path)
+	}
+
+	p.checker.Store(NewChecker(policy))
+	p.hash = hash
+	return true, nil
+}
+
+// Run polls the policy file at the interval until stopCh is closed.
+func (p *FileChecker) Run(interval time.Duration, clock clock.WithTicker, stopCh <-chan struct{}) {
+	util.UntilWithTick(func() {
+		if changed, err := p.Reload(); err != nil {
+			klog.ErrorS(err, "Failed to reload the audit policy, keep the current one")
+		} else if changed {
+			klog.InfoS("Audit policy reloaded", "path", p.path)
+		}
+	}, clock.NewTicker(interval).C(), stopCh)
+}
@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yubo/apiserver/pkg/apis/audit"
+)
+
+const sampledPolicy = `
+apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+  - level: RequestResponse
+    verbs: ["get"]
+    sampleRate: 0.5
+    rateLimit:
+      qps: 10
+      burst: 20
+  - level: Metadata
+`
+
+func TestFileCheckerReload(t *testing.T) {
+	f, err := writePolicy(t, `
+apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+  - level: Metadata
+`)
+	require.NoError(t, err)
+	defer os.Remove(f)
+
+	checker, err := NewFileChecker(f)
+	require.NoError(t, err)
+
+	level, _ := checker.LevelAndStages(attrs["namespaced"])
+	assert.Equal(t, audit.LevelMetadata, level)
+
+	// unchanged
+	changed, err := checker.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	// swapped
+	require.NoError(t, ioutil.WriteFile(f, []byte(sampledPolicy), 0644))
+	changed, err = checker.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	rule := checker.checker.Load().(*policyChecker).Rules[0]
+	require.NotNil(t, rule.SampleRate)
+	assert.Equal(t, 0.5, *rule.SampleRate)
+	assert.Equal(t, &audit.PolicyRateLimit{QPS: 10, Burst: 20}, rule.RateLimit)
+
+	// the invalid policy keeps the current one
+	require.NoError(t, ioutil.WriteFile(f, []byte(`
+apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+  - level: Metadata
+    sampleRate: 2
+`), 0644))
+	_, err = checker.Reload()
+	require.Error(t, err)
+
+	assert.Equal(t, rule.SampleRate, checker.checker.Load().(*policyChecker).Rules[0].SampleRate)
+
+	// the hash of the invalid policy isn't recorded
+	require.NoError(t, ioutil.WriteFile(f, []byte(sampledPolicy), 0644))
+	changed, err = checker.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
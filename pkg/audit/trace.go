@@ -0,0 +1,20 @@
+package audit
+
+import (
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+)
+
+const (
+	// TraceIDAnnotationKey is the annotation of the trace id of the request, it's set by
+	// the tracing filter, so the audit events can be correlated with the spans.
+	// The RequestReceived stage is sent before the request is traced and doesn't have it.
+	TraceIDAnnotationKey = "trace.opentelemetry.io/trace-id"
+	// SpanIDAnnotationKey is the annotation of the server span id of the request.
+	SpanIDAnnotationKey = "trace.opentelemetry.io/span-id"
+)
+
+// TraceContextFrom returns the hex encoded trace id and span id of the event,
+// they are empty if the request isn't traced.
+func TraceContextFrom(ev *auditinternal.Event) (traceID, spanID string) {
+	return ev.Annotations[TraceIDAnnotationKey], ev.Annotations[SpanIDAnnotationKey]
+}
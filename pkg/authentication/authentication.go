@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	"github.com/yubo/apiserver/pkg/authentication/authenticatorfactory"
 	"github.com/yubo/apiserver/pkg/authentication/group"
 	"github.com/yubo/apiserver/pkg/authentication/request/anonymous"
 	"github.com/yubo/apiserver/pkg/authentication/request/bearertoken"
@@ -47,6 +48,10 @@ type Config struct {
 	TokenSuccessCacheTTL api.Duration `json:"tokenSuccessCacheTTL" flag:"token-success-cache-ttl" default:"10s" description:"The duration to cache success token."`
 	TokenFailureCacheTTL api.Duration `json:"tokenFailureCacheTTL" flag:"token-failure-cache-ttl" description:"The duration to cache failure token."`
 	Anonymous            bool         `json:"anonymous" flag:"anonymous-auth" default:"false" description:"Enables anonymous requests to the secure port of the API server. Requests that are not rejected by another authentication method are treated as anonymous requests. Anonymous requests have a username of system:anonymous, and a group name of system:unauthenticated."`
+
+	// RequestHeaderConfig is set by the requestheader authenticator, the headers
+	// of the front proxy are removed from every authenticated request.
+	RequestHeaderConfig *authenticatorfactory.RequestHeaderConfig `json:"-"`
 }
 
 // newConfig create a new BuiltInAuthenticationOptions, just set default token cache TTL
@@ -181,6 +186,8 @@ func (p *authentication) init(ctx context.Context) error {
 		APIAudiences:  authenticator.Audiences(p.config.APIAudiences),
 		Authenticator: p.authenticator,
 		Anonymous:     p.config.Anonymous,
+
+		RequestHeaderConfig: p.config.RequestHeaderConfig,
 	}
 
 	options.WithAuthn(ctx, authn)
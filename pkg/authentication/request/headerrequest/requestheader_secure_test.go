@@ -0,0 +1,141 @@
+package headerrequest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/dynamiccertificates"
+)
+
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestCA(t *testing.T, cn string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{key: key, cert: cert}
+}
+
+func (p *testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.cert.Raw})
+}
+
+func (p *testCA) issue(t *testing.T, cn string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.cert, key.Public(), p.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestRequestHeaderSecure(t *testing.T) {
+	proxyCA := newTestCA(t, "proxy-ca")
+	otherCA := newTestCA(t, "other-ca")
+
+	caFile := filepath.Join(t.TempDir(), "proxy-ca.crt")
+	require.NoError(t, os.WriteFile(caFile, proxyCA.pem(), 0600))
+	caBundle, err := dynamiccertificates.NewDynamicCAContentFromFile("request-header", caFile)
+	require.NoError(t, err)
+
+	auth := NewDynamicVerifyOptionsSecure(
+		caBundle.VerifyOptions,
+		StaticStringSlice{"front-proxy"},
+		StaticStringSlice{"X-Remote-User"},
+		StaticStringSlice{"X-Remote-Group"},
+		StaticStringSlice{"X-Remote-Extra-"},
+	)
+
+	newRequest := func(cert *x509.Certificate) *http.Request {
+		req := &http.Request{Header: http.Header{
+			"X-Remote-User":         {"bob"},
+			"X-Remote-Group":        {"dev"},
+			"X-Remote-Extra-Scopes": {"read"},
+		}}
+		if cert != nil {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}
+		return req
+	}
+
+	t.Run("front proxy", func(t *testing.T) {
+		req := newRequest(proxyCA.issue(t, "front-proxy"))
+		resp, ok, err := auth.AuthenticateRequest(req)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "bob", resp.User.GetName())
+		assert.Equal(t, []string{"dev"}, resp.User.GetGroups())
+		assert.Equal(t, []string{"read"}, resp.User.GetExtra()["scopes"])
+		assert.Empty(t, req.Header)
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		_, ok, err := auth.AuthenticateRequest(newRequest(nil))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("name not allowed", func(t *testing.T) {
+		_, ok, err := auth.AuthenticateRequest(newRequest(proxyCA.issue(t, "other-proxy")))
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("untrusted ca", func(t *testing.T) {
+		_, ok, err := auth.AuthenticateRequest(newRequest(otherCA.issue(t, "front-proxy")))
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("reloaded ca", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(caFile, otherCA.pem(), 0600))
+		require.NoError(t, caBundle.RunOnce())
+
+		_, ok, err := auth.AuthenticateRequest(newRequest(otherCA.issue(t, "front-proxy")))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		_, ok, _ = auth.AuthenticateRequest(newRequest(proxyCA.issue(t, "front-proxy")))
+		assert.False(t, ok)
+	})
+}
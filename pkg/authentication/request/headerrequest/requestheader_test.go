@@ -206,3 +206,29 @@ func TestRequestHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestClearAuthenticationHeaders(t *testing.T) {
+	h := http.Header{
+		"X-Remote-User":                    {"Bob"},
+		"X-Remote-Group":                   {"one"},
+		"X-Remote-Extra-Scopes":            {"a"},
+		"X-Remote-Extra-Percent%20encoded": {"b"},
+		"X-Remote-Extra-":                  {"c"},
+		"X-Remote-Extras":                  {"kept"},
+		"Accept":                           {"*/*"},
+	}
+
+	ClearAuthenticationHeaders(h,
+		StaticStringSlice{"X-Remote-User"},
+		StaticStringSlice{"x-remote-group"},
+		StaticStringSlice{"x-remote-extra-"},
+	)
+
+	expected := http.Header{
+		"X-Remote-Extras": {"kept"},
+		"Accept":          {"*/*"},
+	}
+	if !reflect.DeepEqual(expected, h) {
+		t.Errorf("expected %#v, got %#v", expected, h)
+	}
+}
@@ -0,0 +1,324 @@
+package x509
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/yubo/apiserver/pkg/dynamiccertificates"
+	"golang.org/x/crypto/ocsp"
+	"k8s.io/klog/v2"
+)
+
+// RevokedAnnotationKey is the audit annotation of a request which presented a revoked client certificate
+const RevokedAnnotationKey = "authentication.k8s.io/x509-revoked"
+
+var clientCertificateRevocationCheckCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "certificate_revocation_checks_total",
+		Help: "Counter of the revocation checks of the certificates used to authenticate a request, by checker and result.",
+	},
+	[]string{"checker", "result"},
+)
+
+// RevocationChecker checks a verified certificate chain, the first element is
+// the leaf certificate and the last one is the root.
+type RevocationChecker interface {
+	Check(ctx context.Context, chain []*x509.Certificate) error
+}
+
+// RevokedError is returned when a certificate of the chain has been revoked
+type RevokedError struct {
+	Certificate *x509.Certificate
+	// Source is the checker which reported the revocation, e.g. crl, ocsp
+	Source    string
+	RevokedAt time.Time
+}
+
+func (e *RevokedError) Error() string {
+	return fmt.Sprintf("certificate %s was revoked at %s (%s)",
+		certificateIdentifier(e.Certificate), e.RevokedAt.UTC().Format(time.RFC3339), e.Source)
+}
+
+// IsRevoked returns true if the err is a RevokedError
+func IsRevoked(err error) bool {
+	_, ok := err.(*RevokedError)
+	return ok
+}
+
+// RevocationCheckers checks the chain with each of the checkers in order
+type RevocationCheckers []RevocationChecker
+
+func (p RevocationCheckers) Check(ctx context.Context, chain []*x509.Certificate) error {
+	for _, c := range p {
+		if err := c.Check(ctx, chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CRLChecker checks the chain against the revocation lists of the provider,
+// which may be reloaded at any time.
+// It implements dynamiccertificates.Listener, an outdated list is reported
+// once per reload.
+type CRLChecker struct {
+	crl dynamiccertificates.CRLContentProvider
+	now func() time.Time
+
+	mu       sync.Mutex
+	outdated map[*x509.RevocationList]bool
+}
+
+var _ dynamiccertificates.Listener = &CRLChecker{}
+
+func NewCRLChecker(crl dynamiccertificates.CRLContentProvider) *CRLChecker {
+	return &CRLChecker{
+		crl:      crl,
+		now:      time.Now,
+		outdated: map[*x509.RevocationList]bool{},
+	}
+}
+
+// Enqueue forgets the outdated lists reported
+func (p *CRLChecker) Enqueue() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.outdated = map[*x509.RevocationList]bool{}
+}
+
+// reportOutdated returns true if the outdated list has not been reported since the last reload
+func (p *CRLChecker) reportOutdated(crl *x509.RevocationList) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.outdated[crl] {
+		return false
+	}
+	p.outdated[crl] = true
+	return true
+}
+
+// Check looks up every certificate, except the root, in the lists issued by its parent.
+// A list is only trusted if it is signed by the issuer of the certificate.
+func (p *CRLChecker) Check(ctx context.Context, chain []*x509.Certificate) error {
+	crls := p.crl.CurrentCRLs()
+
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+
+		for _, crl := range crls {
+			if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+				continue
+			}
+			if err := crl.CheckSignatureFrom(issuer); err != nil {
+				klog.V(5).InfoS("ignore crl with invalid signature", "crl", p.crl.Name(), "issuer", issuer.Subject.String(), "err", err)
+				continue
+			}
+			if !crl.NextUpdate.IsZero() && p.now().After(crl.NextUpdate) && p.reportOutdated(crl) {
+				klog.Warningf("crl of %q from %s is outdated since %s", issuer.Subject.String(), p.crl.Name(), crl.NextUpdate)
+			}
+
+			for _, revoked := range crl.RevokedCertificates {
+				if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					clientCertificateRevocationCheckCounter.WithLabelValues("crl", "revoked").Inc()
+					return &RevokedError{Certificate: cert, Source: "crl", RevokedAt: revoked.RevocationTime}
+				}
+			}
+		}
+	}
+
+	clientCertificateRevocationCheckCounter.WithLabelValues("crl", "good").Inc()
+	return nil
+}
+
+type OCSPOptions struct {
+	// ResponderURL, if specified, overrides the OCSP servers of the certificates.
+	ResponderURL string
+
+	// CacheTTL is the upper bound of caching a response, the response is
+	// cached until its NextUpdate if that comes first.
+	CacheTTL time.Duration
+
+	// SoftFail allows the certificate if the responder is unreachable or
+	// does not know the certificate.
+	SoftFail bool
+
+	// RequireResponder rejects the certificate without an OCSP server if no
+	// ResponderURL is specified, by default the certificate is not checked,
+	// e.g. the certificates of a CA which only publishes CRLs.
+	RequireResponder bool
+
+	// Timeout of a request to the responder
+	Timeout time.Duration
+
+	// Client is used to query the responder, defaults to an http client with the Timeout.
+	Client *http.Client
+}
+
+// OCSPChecker queries the OCSP responder of the leaf certificate.
+// It implements dynamiccertificates.Listener, the cache is flushed when notified,
+// e.g. when the CA bundle changes.
+type OCSPChecker struct {
+	OCSPOptions
+	now func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]*ocspCacheEntry
+}
+
+var _ dynamiccertificates.Listener = &OCSPChecker{}
+
+type ocspCacheEntry struct {
+	status    int
+	revokedAt time.Time
+	expires   time.Time
+}
+
+func NewOCSPChecker(opts OCSPOptions) *OCSPChecker {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	return &OCSPChecker{
+		OCSPOptions: opts,
+		now:         time.Now,
+		cache:       map[string]*ocspCacheEntry{},
+	}
+}
+
+// Enqueue flushes the cached responses
+func (p *OCSPChecker) Enqueue() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache = map[string]*ocspCacheEntry{}
+}
+
+func (p *OCSPChecker) Check(ctx context.Context, chain []*x509.Certificate) error {
+	if len(chain) < 2 {
+		return nil
+	}
+	cert, issuer := chain[0], chain[1]
+
+	if p.ResponderURL == "" && len(cert.OCSPServer) == 0 && !p.RequireResponder {
+		clientCertificateRevocationCheckCounter.WithLabelValues("ocsp", "skipped").Inc()
+		return nil
+	}
+
+	status, revokedAt, err := p.status(ctx, cert, issuer)
+	if err != nil {
+		clientCertificateRevocationCheckCounter.WithLabelValues("ocsp", "error").Inc()
+		if p.SoftFail {
+			klog.V(3).InfoS("ocsp check soft-failed", "cert", certificateIdentifier(cert), "err", err)
+			return nil
+		}
+		return fmt.Errorf("ocsp check of certificate %s failed: %w", certificateIdentifier(cert), err)
+	}
+
+	switch status {
+	case ocsp.Good:
+		clientCertificateRevocationCheckCounter.WithLabelValues("ocsp", "good").Inc()
+		return nil
+	case ocsp.Revoked:
+		clientCertificateRevocationCheckCounter.WithLabelValues("ocsp", "revoked").Inc()
+		return &RevokedError{Certificate: cert, Source: "ocsp", RevokedAt: revokedAt}
+	default:
+		clientCertificateRevocationCheckCounter.WithLabelValues("ocsp", "unknown").Inc()
+		if p.SoftFail {
+			return nil
+		}
+		return fmt.Errorf("ocsp status of certificate %s is unknown", certificateIdentifier(cert))
+	}
+}
+
+func (p *OCSPChecker) status(ctx context.Context, cert, issuer *x509.Certificate) (int, time.Time, error) {
+	key := ocspCacheKey(cert, issuer)
+	now := p.now()
+
+	p.mu.Lock()
+	entry, ok := p.cache[key]
+	p.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.status, entry.revokedAt, nil
+	}
+
+	resp, err := p.query(ctx, cert, issuer)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	expires := now.Add(p.CacheTTL)
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(expires) {
+		expires = resp.NextUpdate
+	}
+	if expires.After(now) {
+		p.mu.Lock()
+		p.cache[key] = &ocspCacheEntry{status: resp.Status, revokedAt: resp.RevokedAt, expires: expires}
+		p.mu.Unlock()
+	}
+
+	return resp.Status, resp.RevokedAt, nil
+}
+
+func (p *OCSPChecker) query(ctx context.Context, cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	server := p.ResponderURL
+	if server == "" {
+		if len(cert.OCSPServer) == 0 {
+			return nil, fmt.Errorf("no ocsp server")
+		}
+		server = cert.OCSPServer[0]
+	}
+
+	body, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", server, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ocsp server %s returned %s", server, resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := ocsp.ParseResponseForCert(b, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if now := p.now(); !r.NextUpdate.IsZero() && now.After(r.NextUpdate) {
+		return nil, fmt.Errorf("ocsp response is outdated since %s", r.NextUpdate)
+	}
+
+	return r, nil
+}
+
+func ocspCacheKey(cert, issuer *x509.Certificate) string {
+	sum := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	return fmt.Sprintf("%x/%s", sum, cert.SerialNumber.String())
+}
@@ -0,0 +1,279 @@
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auditapi "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/dynamiccertificates"
+	"github.com/yubo/apiserver/pkg/request"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testCA struct {
+	t    *testing.T
+	key  crypto.Signer
+	cert *x509.Certificate
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{t: t, key: key, cert: cert}
+}
+
+func (p *testCA) issue(serial int64, cn string, ocspServer string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(p.t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if ocspServer != "" {
+		tmpl.OCSPServer = []string{ocspServer}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.cert, key.Public(), p.key)
+	require.NoError(p.t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(p.t, err)
+	return cert
+}
+
+func (p *testCA) crl(number int64, revoked ...*x509.Certificate) []byte {
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(number),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, cert := range revoked {
+		tmpl.RevokedCertificates = append(tmpl.RevokedCertificates, pkix.RevokedCertificate{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, p.cert, p.key)
+	require.NoError(p.t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func (p *testCA) authenticator() *Authenticator {
+	opts := DefaultVerifyOptions()
+	opts.Roots = x509.NewCertPool()
+	opts.Roots.AddCert(p.cert)
+	return New(opts, CommonNameUserConversion)
+}
+
+func newTLSRequest(cert *x509.Certificate) (*http.Request, *auditapi.Event) {
+	ev := &auditapi.Event{Level: auditapi.LevelMetadata}
+	req, _ := http.NewRequest("GET", "/", nil)
+	req = req.WithContext(request.WithAuditEvent(req.Context(), ev))
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	return req, ev
+}
+
+func TestCRLChecker(t *testing.T) {
+	ca := newTestCA(t)
+	good := ca.issue(10, "good", "")
+	revoked := ca.issue(11, "revoked", "")
+
+	file := filepath.Join(t.TempDir(), "crl.pem")
+	require.NoError(t, os.WriteFile(file, ca.crl(1, revoked), 0600))
+
+	crl, err := dynamiccertificates.NewDynamicCRLContentFromFile("test", file)
+	require.NoError(t, err)
+
+	a := ca.authenticator().WithRevocationChecker(NewCRLChecker(crl))
+
+	req, _ := newTLSRequest(good)
+	resp, ok, err := a.AuthenticateRequest(req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "good", resp.User.GetName())
+	assert.Equal(t, []string{fmt.Sprintf("X509SHA256=%x", sha256.Sum256(good.Raw))}, resp.User.GetExtra()[user.CredentialIDKey])
+
+	req, ev := newTLSRequest(revoked)
+	_, ok, err = a.AuthenticateRequest(req)
+	assert.False(t, ok)
+	assert.Error(t, err)
+	assert.Equal(t, certificateIdentifier(revoked), ev.Annotations[RevokedAnnotationKey])
+
+	// reload the crl, the revoked certificate is reinstated and the other one is revoked
+	require.NoError(t, os.WriteFile(file, ca.crl(2, good), 0600))
+	require.NoError(t, crl.RunOnce())
+
+	req, _ = newTLSRequest(revoked)
+	_, ok, err = a.AuthenticateRequest(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	req, _ = newTLSRequest(good)
+	_, ok, _ = a.AuthenticateRequest(req)
+	assert.False(t, ok)
+}
+
+func TestCRLCheckerIgnoresForeignCRL(t *testing.T) {
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	cert := ca.issue(10, "good", "")
+
+	// same issuer name and serial, but signed by another key
+	file := filepath.Join(t.TempDir(), "crl.pem")
+	require.NoError(t, os.WriteFile(file, other.crl(1, cert), 0600))
+
+	crl, err := dynamiccertificates.NewDynamicCRLContentFromFile("test", file)
+	require.NoError(t, err)
+
+	req, _ := newTLSRequest(cert)
+	_, ok, err := ca.authenticator().WithRevocationChecker(NewCRLChecker(crl)).AuthenticateRequest(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestOCSPChecker(t *testing.T) {
+	ca := newTestCA(t)
+
+	var (
+		requests int32
+		revoked  = map[string]bool{}
+		down     int32
+	)
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(b)
+		require.NoError(t, err)
+
+		tmpl := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		if revoked[req.SerialNumber.String()] {
+			tmpl.Status = ocsp.Revoked
+			tmpl.RevokedAt = time.Now().Add(-time.Minute)
+		}
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, tmpl, ca.key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+	defer responder.Close()
+
+	good := ca.issue(10, "good", responder.URL)
+	bad := ca.issue(11, "bad", responder.URL)
+	revoked["11"] = true
+
+	checker := NewOCSPChecker(OCSPOptions{CacheTTL: time.Minute})
+	a := ca.authenticator().WithRevocationChecker(checker)
+
+	req, _ := newTLSRequest(good)
+	_, ok, err := a.AuthenticateRequest(req)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	req, ev := newTLSRequest(bad)
+	_, ok, err = a.AuthenticateRequest(req)
+	assert.False(t, ok)
+	assert.Error(t, err)
+	assert.Equal(t, certificateIdentifier(bad), ev.Annotations[RevokedAnnotationKey])
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// the responses are cached
+	req, _ = newTLSRequest(good)
+	_, ok, _ = a.AuthenticateRequest(req)
+	assert.True(t, ok)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// flush the cache, the responder is down
+	checker.Enqueue()
+	atomic.StoreInt32(&down, 1)
+	req, _ = newTLSRequest(good)
+	_, ok, err = a.AuthenticateRequest(req)
+	assert.False(t, ok)
+	assert.Error(t, err)
+
+	// soft fail
+	checker.SoftFail = true
+	req, _ = newTLSRequest(good)
+	_, ok, err = a.AuthenticateRequest(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestOCSPCheckerWithoutResponder(t *testing.T) {
+	ca := newTestCA(t)
+	cert := ca.issue(10, "crl-only", "")
+
+	checker := NewOCSPChecker(OCSPOptions{})
+	a := ca.authenticator().WithRevocationChecker(checker)
+
+	// the certificate of a CA which only publishes CRLs is not checked
+	req, _ := newTLSRequest(cert)
+	_, ok, err := a.AuthenticateRequest(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	checker.RequireResponder = true
+	req, _ = newTLSRequest(cert)
+	_, ok, err = a.AuthenticateRequest(req)
+	assert.False(t, ok)
+	assert.Error(t, err)
+}
+
+func TestCRLCheckerReportOutdated(t *testing.T) {
+	checker := NewCRLChecker(nil)
+	crl := &x509.RevocationList{}
+
+	assert.True(t, checker.reportOutdated(crl))
+	assert.False(t, checker.reportOutdated(crl), "reported once")
+
+	// reloaded
+	checker.Enqueue()
+	assert.True(t, checker.reportOutdated(crl))
+}
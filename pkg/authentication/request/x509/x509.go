@@ -17,6 +17,7 @@ limitations under the License.
 package x509
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/hex"
@@ -27,6 +28,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/yubo/apiserver/pkg/audit"
 	"github.com/yubo/apiserver/pkg/authentication/authenticator"
 	"github.com/yubo/apiserver/pkg/authentication/user"
 	utilerrors "github.com/yubo/golib/util/errors"
@@ -108,6 +110,7 @@ type VerifyOptionFunc func() (x509.VerifyOptions, bool)
 type Authenticator struct {
 	verifyOptionsFn VerifyOptionFunc
 	user            UserConversion
+	revocation      RevocationChecker
 }
 
 // New returns a request.Authenticator that verifies client certificates using the provided
@@ -119,7 +122,13 @@ func New(opts x509.VerifyOptions, user UserConversion) *Authenticator {
 // NewDynamic returns a request.Authenticator that verifies client certificates using the provided
 // VerifyOptionFunc (which may be dynamic), and converts valid certificate chains into user.Info using the provided UserConversion
 func NewDynamic(verifyOptionsFn VerifyOptionFunc, user UserConversion) *Authenticator {
-	return &Authenticator{verifyOptionsFn, user}
+	return &Authenticator{verifyOptionsFn: verifyOptionsFn, user: user}
+}
+
+// WithRevocationChecker rejects the verified chains which contain a revoked certificate
+func (a *Authenticator) WithRevocationChecker(checker RevocationChecker) *Authenticator {
+	a.revocation = checker
+	return a
 }
 
 // AuthenticateRequest authenticates the request using presented client certificates
@@ -154,6 +163,16 @@ func (a *Authenticator) AuthenticateRequest(req *http.Request) (*authenticator.R
 
 	var errlist []error
 	for _, chain := range chains {
+		if a.revocation != nil {
+			if err := a.revocation.Check(req.Context(), chain); err != nil {
+				if revoked, ok := err.(*RevokedError); ok {
+					audit.AddAuditAnnotation(req.Context(), RevokedAnnotationKey, certificateIdentifier(revoked.Certificate))
+				}
+				errlist = append(errlist, err)
+				continue
+			}
+		}
+
 		user, ok, err := a.user.User(chain)
 		if err != nil {
 			errlist = append(errlist, err)
@@ -161,12 +180,31 @@ func (a *Authenticator) AuthenticateRequest(req *http.Request) (*authenticator.R
 		}
 
 		if ok {
-			return user, ok, err
+			return withCredentialID(user, chain[0]), ok, err
 		}
 	}
 	return nil, false, utilerrors.NewAggregate(errlist)
 }
 
+// withCredentialID marks the user as authenticated by the verified certificate
+func withCredentialID(resp *authenticator.Response, cert *x509.Certificate) *authenticator.Response {
+	extra := map[string][]string{}
+	for k, v := range resp.User.GetExtra() {
+		extra[k] = v
+	}
+	extra[user.CredentialIDKey] = []string{fmt.Sprintf("X509SHA256=%x", sha256.Sum256(cert.Raw))}
+
+	return &authenticator.Response{
+		Audiences: resp.Audiences,
+		User: &user.DefaultInfo{
+			Name:   resp.User.GetName(),
+			UID:    resp.User.GetUID(),
+			Groups: resp.User.GetGroups(),
+			Extra:  extra,
+		},
+	}
+}
+
 // Verifier implements request.Authenticator by verifying a client cert on the request, then delegating to the wrapped auth
 type Verifier struct {
 	verifyOptionsFn VerifyOptionFunc
@@ -82,3 +82,7 @@ const (
 	KubeControllerManager = "system:kube-controller-manager"
 	KubeScheduler         = "system:kube-scheduler"
 )
+
+// CredentialIDKey is the key of the extra which identifies the credential the user was
+// authenticated with, e.g. X509SHA256=<sha256 of the client certificate>
+const CredentialIDKey = "authentication.kubernetes.io/credential-id"
@@ -0,0 +1,102 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/yubo/apiserver/pkg/apis/certificates"
+	"github.com/yubo/golib/util/sets"
+)
+
+// AutoApproveRule approves the requests of the matched users without a
+// human in the loop, every condition which is set must be satisfied.
+type AutoApproveRule struct {
+	// Users and Groups match the requesting user, at least one of them is required.
+	Users  []string `json:"users"`
+	Groups []string `json:"groups"`
+
+	// Self requires the common name of the request to be the name of the requesting
+	// user, and its organizations to be groups of the requesting user.
+	Self bool `json:"self"`
+
+	// CommonNames are the allowed common names of the request, "*" matches any name.
+	CommonNames []string `json:"commonNames"`
+
+	// Organizations are the allowed organizations of the request, in addition to
+	// the groups of the requesting user if Self is set.
+	Organizations []string `json:"organizations"`
+
+	// Usages, if set, are the allowed usages of the request.
+	Usages []certificates.KeyUsage `json:"usages"`
+}
+
+func (p *AutoApproveRule) Validate() error {
+	if len(p.Users) == 0 && len(p.Groups) == 0 {
+		return fmt.Errorf("auto approve rule must specify users or groups")
+	}
+	if !p.Self && len(p.CommonNames) == 0 {
+		return fmt.Errorf("auto approve rule must specify self or commonNames")
+	}
+	return nil
+}
+
+// Matches returns true if the request is approved by the rule
+func (p *AutoApproveRule) Matches(csr *certificates.CertificateSigningRequest, req *x509.CertificateRequest) bool {
+	groups := sets.NewString(csr.Spec.Groups...)
+
+	if !sets.NewString(p.Users...).Has(csr.Spec.Username) && !groups.HasAny(p.Groups...) {
+		return false
+	}
+
+	cn := req.Subject.CommonName
+	allowedOrgs := sets.NewString(p.Organizations...)
+	if p.Self {
+		if cn != csr.Spec.Username {
+			return false
+		}
+		allowedOrgs = allowedOrgs.Union(groups)
+	}
+
+	if len(p.CommonNames) > 0 {
+		names := sets.NewString(p.CommonNames...)
+		if !names.Has("*") && !names.Has(cn) {
+			return false
+		}
+	}
+
+	if !allowedOrgs.HasAll(req.Subject.Organization...) {
+		return false
+	}
+
+	if len(p.Usages) > 0 {
+		allowed := sets.NewString()
+		for _, usage := range p.Usages {
+			allowed.Insert(string(usage))
+		}
+		for _, usage := range csr.Spec.Usages {
+			if !allowed.Has(string(usage)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// AutoApprover approves the requests which match any of the rules
+type AutoApprover []AutoApproveRule
+
+// Approve returns the index of the matched rule, or -1 if the request must be approved by a user
+func (p AutoApprover) Approve(csr *certificates.CertificateSigningRequest) int {
+	req, err := certificates.ParseCSR(csr.Spec.Request)
+	if err != nil {
+		return -1
+	}
+
+	for i := range p {
+		if p[i].Matches(csr, req) {
+			return i
+		}
+	}
+	return -1
+}
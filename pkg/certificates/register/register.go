@@ -0,0 +1,146 @@
+package register
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apicertificates "github.com/yubo/apiserver/pkg/apis/certificates"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/certificates"
+	"github.com/yubo/apiserver/pkg/dynamiccertificates"
+	"github.com/yubo/apiserver/pkg/models"
+	"github.com/yubo/apiserver/pkg/proc"
+	v1 "github.com/yubo/apiserver/pkg/proc/api/v1"
+	"github.com/yubo/apiserver/pkg/proc/options"
+	"github.com/yubo/golib/api"
+	"k8s.io/klog/v2"
+)
+
+const (
+	moduleName = "certificates"
+	configPath = "certificates"
+)
+
+// the default usages of a client certificate, they can't be set in the default tag
+// which splits the value by spaces.
+var defaultUsages = []string{
+	string(apicertificates.UsageDigitalSignature),
+	string(apicertificates.UsageKeyEncipherment),
+	string(apicertificates.UsageClientAuth),
+}
+
+type config struct {
+	CertFile string `json:"certFile" flag:"certificates-ca-cert-file" description:"If set with --certificates-ca-key-file, the CertificateSigningRequest API is enabled and the approved requests are signed by the CA. The CA must be trusted by --client-ca-file. The files are reloaded on change."`
+	KeyFile  string `json:"keyFile" flag:"certificates-ca-key-file" description:"The private key of --certificates-ca-cert-file."`
+
+	TTL api.Duration `json:"ttl" default:"8760h" flag:"certificates-ttl" description:"The max duration of the issued certificates, a request may ask for a shorter one with spec.expirationSeconds."`
+
+	Usages []string `json:"usages" flag:"certificates-usages" description:"The usages which may be requested, also the default of the requests which don't specify any. Defaults to digital signature,key encipherment,client auth."`
+
+	// AutoApprove rules approve the matched requests without a human in the loop, only from the config file.
+	AutoApprove []certificates.AutoApproveRule `json:"autoApprove"`
+}
+
+func (o *config) Validate() error {
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return fmt.Errorf("certificates-ca-cert-file and certificates-ca-key-file must be set together")
+	}
+	if o.CertFile != "" && o.TTL.Duration < apicertificates.MinExpirationSeconds*time.Second {
+		return fmt.Errorf("certificates-ttl must be at least %ds", apicertificates.MinExpirationSeconds)
+	}
+	for i := range o.AutoApprove {
+		if err := o.AutoApprove[i].Validate(); err != nil {
+			return fmt.Errorf("autoApprove[%d]: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func (o *config) usages() []apicertificates.KeyUsage {
+	usages := o.Usages
+	if len(usages) == 0 {
+		usages = defaultUsages
+	}
+	ret := make([]apicertificates.KeyUsage, len(usages))
+	for i, usage := range usages {
+		ret[i] = apicertificates.KeyUsage(usage)
+	}
+	return ret
+}
+
+func newConfig() *config {
+	return &config{}
+}
+
+var (
+	_module = &module{name: moduleName}
+	hookOps = []v1.HookOps{{
+		Hook:        _module.init,
+		Owner:       moduleName,
+		HookNum:     v1.ACTION_START,
+		Priority:    v1.PRI_MODULE,
+		SubPriority: v1.PRI_M_AUTHZ,
+	}}
+)
+
+type module struct {
+	name string
+}
+
+func (p *module) init(ctx context.Context) error {
+	cf := newConfig()
+	if err := proc.ReadConfig(configPath, cf); err != nil {
+		return err
+	}
+
+	if cf.CertFile == "" {
+		klog.V(5).InfoS("skip module", "name", p.name, "reason", "certificates-ca-cert-file is not set")
+		return nil
+	}
+
+	ca, err := dynamiccertificates.NewDynamicServingContentFromFiles("certificates-signer", cf.CertFile, cf.KeyFile)
+	if err != nil {
+		return err
+	}
+	go ca.Run(1, ctx.Done())
+
+	server := options.APIServerMustFrom(ctx)
+
+	// the client ca bundle is set by the x509 authenticator
+	var clientCA dynamiccertificates.CAContentProvider
+	if servingInfo := server.Config().SecureServing; servingInfo != nil {
+		clientCA = servingInfo.ClientCA
+	}
+
+	signer := certificates.NewSigner(ca, clientCA, certificates.SignerOptions{
+		TTL:    cf.TTL.Duration,
+		Usages: cf.usages(),
+	})
+	if err := signer.CheckTrust(); err != nil {
+		return fmt.Errorf("%s, add it to --client-ca-file", err)
+	}
+
+	var authz authorizer.Authorizer
+	if info, ok := options.AuthzFrom(ctx); ok {
+		authz = info.Authorizer
+	} else {
+		klog.Warningf("unable to get authorizer from context, the certificate signing requests can only be approved by the autoApprove rules")
+	}
+
+	certificates.New(
+		models.NewCertificateSigningRequest(),
+		signer,
+		cf.AutoApprove,
+		authz,
+	).Install(server)
+
+	klog.InfoS("certificates api installed", "path", certificates.APIPath, "ca", cf.CertFile, "autoApproveRules", len(cf.AutoApprove))
+
+	return nil
+}
+
+func init() {
+	proc.RegisterHooks(hookOps)
+	proc.AddConfig(configPath, newConfig(), proc.WithConfigGroup("certificates"))
+}
@@ -0,0 +1,340 @@
+package certificates
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/apis/certificates"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// APIPath is the root path of the certificates api
+	APIPath = "/apis/" + certificates.GroupName + "/v1"
+
+	// ApprovedByAnnotationKey is the audit annotation of the user or the rule which approved the request
+	ApprovedByAnnotationKey = "certificates.k8s.io/approved-by"
+	// SignedAnnotationKey is the audit annotation of the issued certificate
+	SignedAnnotationKey = "certificates.k8s.io/signed"
+
+	resource = "certificatesigningrequests"
+)
+
+type CertificateSigningRequestStore interface {
+	Create(ctx context.Context, obj *certificates.CertificateSigningRequest) error
+	Get(ctx context.Context, name string) (*certificates.CertificateSigningRequest, error)
+	List(ctx context.Context, opts api.GetListOptions) ([]*certificates.CertificateSigningRequest, error)
+	// UpdateStatus writes the status only if the resourceVersion of the stored request is
+	// still the one of obj, the resourceVersion is increased. A NotFound error is returned
+	// if the request has been changed or deleted.
+	UpdateStatus(ctx context.Context, obj *certificates.CertificateSigningRequest) error
+	Delete(ctx context.Context, name string) error
+}
+
+type Registry struct {
+	csrs       CertificateSigningRequestStore
+	signer     *Signer
+	approver   AutoApprover
+	authorizer authorizer.Authorizer
+}
+
+// New returns a Registry, the authorizer is used to check the approve verb,
+// and may be nil, in which case the requests can only be approved by the auto approver.
+func New(csrs CertificateSigningRequestStore, signer *Signer, approver AutoApprover, authz authorizer.Authorizer) *Registry {
+	return &Registry{
+		csrs:       csrs,
+		signer:     signer,
+		approver:   approver,
+		authorizer: authz,
+	}
+}
+
+func (p *Registry) Install(container rest.GoRestfulContainer) {
+	rest.SwaggerTagRegister("certificates", "certificates Api - request client certificates from the internal ca")
+
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               APIPath,
+		Produces:           []string{rest.MIME_JSON},
+		Consumes:           []string{rest.MIME_JSON},
+		Tags:               []string{"certificates"},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "POST", SubPath: "/certificatesigningrequests", Operation: "createCertificateSigningRequest", Desc: "create CertificateSigningRequest", Handle: p.createCertificateSigningRequest},
+			{Method: "GET", SubPath: "/certificatesigningrequests", Operation: "listCertificateSigningRequest", Desc: "list CertificateSigningRequest", Handle: p.listCertificateSigningRequest},
+			{Method: "GET", SubPath: "/certificatesigningrequests/{name}", Operation: "getCertificateSigningRequest", Desc: "get CertificateSigningRequest by name", Handle: p.getCertificateSigningRequest},
+			{Method: "DELETE", SubPath: "/certificatesigningrequests/{name}", Operation: "deleteCertificateSigningRequest", Desc: "delete CertificateSigningRequest by name", Handle: p.deleteCertificateSigningRequest},
+			{Method: "POST", SubPath: "/certificatesigningrequests/{name}/approve", Operation: "approveCertificateSigningRequest", Desc: "approve the CertificateSigningRequest and issue the certificate", Handle: p.approveCertificateSigningRequest},
+			{Method: "POST", SubPath: "/certificatesigningrequests/{name}/deny", Operation: "denyCertificateSigningRequest", Desc: "deny the CertificateSigningRequest", Handle: p.denyCertificateSigningRequest},
+		},
+	})
+}
+
+type nameParam struct {
+	Name string `param:"path" description:"object name"`
+}
+
+type listParam struct {
+	api.PageParams
+	Query string `param:"query" description:"query"`
+}
+
+type decisionInput struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+type certificateSigningRequestListOutput struct {
+	List  []*certificates.CertificateSigningRequest `json:"list"`
+	Total int                                       `json:"total"`
+}
+
+func (p *Registry) createCertificateSigningRequest(w http.ResponseWriter, req *http.Request, obj *certificates.CertificateSigningRequest) (*certificates.CertificateSigningRequest, error) {
+	ctx := req.Context()
+
+	user, ok := request.UserFrom(ctx)
+	if !ok {
+		return nil, errors.NewForbidden(obj.Name, fmt.Errorf("unable to get the requesting user"))
+	}
+
+	// the requesting user is always set by the server
+	obj.Spec.Username = user.GetName()
+	obj.Spec.UID = user.GetUID()
+	obj.Spec.Groups = user.GetGroups()
+	obj.Spec.Extra = user.GetExtra()
+	if len(obj.Spec.Usages) == 0 {
+		obj.Spec.Usages = p.signer.DefaultUsages()
+	}
+
+	if errs := certificates.ValidateCertificateSigningRequestCreate(obj); len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+	if err := p.signer.CheckUsages(obj.Spec.Usages); err != nil {
+		return nil, errors.NewForbidden(obj.Name, err)
+	}
+
+	if err := p.csrs.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	if i := p.approver.Approve(obj); i >= 0 {
+		approvedBy := fmt.Sprintf("auto-approve-rule-%d", i)
+		p.approve(obj, "", "AutoApproved", fmt.Sprintf("approved by %s", approvedBy))
+		if err := p.updateStatus(ctx, obj); err != nil {
+			return nil, err
+		}
+		p.sign(ctx, obj, approvedBy)
+		if err := p.updateStatus(ctx, obj); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.csrs.Get(ctx, obj.Name)
+}
+
+func (p *Registry) getCertificateSigningRequest(w http.ResponseWriter, req *http.Request, in *nameParam) (*certificates.CertificateSigningRequest, error) {
+	return p.csrs.Get(req.Context(), in.Name)
+}
+
+func (p *Registry) listCertificateSigningRequest(w http.ResponseWriter, req *http.Request, in *listParam) (*certificateSigningRequestListOutput, error) {
+	ret := &certificateSigningRequestListOutput{}
+
+	opts, err := in.GetListOptions(in.Query, &ret.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.List, err = p.csrs.List(req.Context(), *opts); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (p *Registry) deleteCertificateSigningRequest(w http.ResponseWriter, req *http.Request, in *nameParam) (*certificates.CertificateSigningRequest, error) {
+	ctx := req.Context()
+
+	obj, err := p.csrs.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.csrs.Delete(ctx, in.Name); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+func (p *Registry) approveCertificateSigningRequest(w http.ResponseWriter, req *http.Request, in *nameParam, body *decisionInput) (*certificates.CertificateSigningRequest, error) {
+	ctx := req.Context()
+
+	obj, approver, err := p.decide(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := body.Reason
+	if reason == "" {
+		reason = "Approved"
+	}
+	p.approve(obj, approver, reason, body.Message)
+
+	// only the decision which wins the update is signed
+	if err := p.updateStatus(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	p.sign(ctx, obj, approver)
+	if err := p.updateStatus(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.csrs.Get(ctx, obj.Name)
+}
+
+func (p *Registry) denyCertificateSigningRequest(w http.ResponseWriter, req *http.Request, in *nameParam, body *decisionInput) (*certificates.CertificateSigningRequest, error) {
+	ctx := req.Context()
+
+	obj, approver, err := p.decide(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := body.Reason
+	if reason == "" {
+		reason = "Denied"
+	}
+	obj.Status.Conditions = append(obj.Status.Conditions, certificates.CertificateSigningRequestCondition{
+		Type:           certificates.CertificateDenied,
+		Reason:         reason,
+		Message:        body.Message,
+		User:           approver,
+		LastUpdateTime: api.Now(),
+	})
+	if err := p.updateStatus(ctx, obj); err != nil {
+		return nil, err
+	}
+	klog.InfoS("certificate signing request denied", "csr", obj.Name, "requester", obj.Spec.Username, "deniedBy", approver)
+
+	return p.csrs.Get(ctx, obj.Name)
+}
+
+// decide returns the undecided request if the user may "approve" it
+func (p *Registry) decide(ctx context.Context, name string) (*certificates.CertificateSigningRequest, string, error) {
+	user, ok := request.UserFrom(ctx)
+	if !ok {
+		return nil, "", errors.NewForbidden(name, fmt.Errorf("unable to get the requesting user"))
+	}
+
+	if !p.verbAuthorized(ctx, "approve", name) {
+		return nil, "", errors.NewForbidden(name, fmt.Errorf("user %q cannot approve %s", user.GetName(), resource))
+	}
+
+	obj, err := p.csrs.Get(ctx, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if certificates.IsCertificateRequestDecided(obj) {
+		return nil, "", errors.NewConflict(name, fmt.Errorf("the request has already been approved or denied"))
+	}
+
+	return obj, user.GetName(), nil
+}
+
+// updateStatus writes the status of the request read by decide, of the concurrent
+// decisions of a request only the first one is written
+func (p *Registry) updateStatus(ctx context.Context, obj *certificates.CertificateSigningRequest) error {
+	if err := p.csrs.UpdateStatus(ctx, obj); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NewConflict(obj.Name, fmt.Errorf("the request has been modified, it may have already been approved or denied"))
+		}
+		return err
+	}
+	return nil
+}
+
+func (p *Registry) approve(obj *certificates.CertificateSigningRequest, user, reason, message string) {
+	obj.Status.Conditions = append(obj.Status.Conditions, certificates.CertificateSigningRequestCondition{
+		Type:           certificates.CertificateApproved,
+		Reason:         reason,
+		Message:        message,
+		User:           user,
+		LastUpdateTime: api.Now(),
+	})
+}
+
+// sign issues the certificate of an approved request, a failure is recorded in the conditions
+func (p *Registry) sign(ctx context.Context, obj *certificates.CertificateSigningRequest, approvedBy string) {
+	audit.AddAuditAnnotation(ctx, ApprovedByAnnotationKey, approvedBy)
+
+	certPEM, cert, err := p.signer.Sign(obj)
+	if err != nil {
+		klog.ErrorS(err, "unable to sign the certificate signing request", "csr", obj.Name, "requester", obj.Spec.Username, "approvedBy", approvedBy)
+		obj.Status.Conditions = append(obj.Status.Conditions, certificates.CertificateSigningRequestCondition{
+			Type:           certificates.CertificateFailed,
+			Reason:         "SignerValidationFailure",
+			Message:        err.Error(),
+			LastUpdateTime: api.Now(),
+		})
+		return
+	}
+
+	obj.Status.Certificate = certPEM
+
+	audit.AddAuditAnnotation(ctx, SignedAnnotationKey, fmt.Sprintf("serial=%s subject=%q notAfter=%s",
+		cert.SerialNumber.Text(16), cert.Subject.String(), cert.NotAfter.UTC().Format(time.RFC3339)))
+
+	klog.InfoS("certificate signed",
+		"csr", obj.Name,
+		"requester", obj.Spec.Username,
+		"approvedBy", approvedBy,
+		"serial", cert.SerialNumber.Text(16),
+		"subject", cert.Subject.String(),
+		"usages", usagesString(obj.Spec.Usages),
+		"notAfter", cert.NotAfter.UTC().Format(time.RFC3339))
+}
+
+func (p *Registry) verbAuthorized(ctx context.Context, verb, name string) bool {
+	if p.authorizer == nil {
+		return false
+	}
+
+	user, ok := request.UserFrom(ctx)
+	if !ok {
+		return false
+	}
+
+	attrs := authorizer.AttributesRecord{
+		User:            user,
+		Verb:            verb,
+		APIGroup:        certificates.GroupName,
+		Resource:        resource,
+		Name:            name,
+		ResourceRequest: true,
+	}
+
+	decision, _, err := p.authorizer.Authorize(ctx, attrs)
+	if err != nil {
+		klog.V(5).InfoS("error authorizing certificates verb", "verb", verb, "resource", resource, "name", name, "err", err)
+	}
+
+	return decision == authorizer.DecisionAllow
+}
+
+func usagesString(usages []certificates.KeyUsage) string {
+	s := make([]string, len(usages))
+	for i, usage := range usages {
+		s[i] = string(usage)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,432 @@
+package certificates
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auditapi "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/apis/certificates"
+	x509request "github.com/yubo/apiserver/pkg/authentication/request/x509"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/dynamiccertificates"
+	"github.com/yubo/apiserver/pkg/models"
+	"github.com/yubo/apiserver/pkg/request"
+	dbstore "github.com/yubo/apiserver/pkg/storage/db"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/orm"
+	"github.com/yubo/golib/util/keyutil"
+
+	_ "github.com/yubo/golib/orm/sqlite"
+)
+
+// csrStore keeps the copies of the requests, as the db does
+type csrStore struct {
+	sync.Mutex
+	items map[string]*certificates.CertificateSigningRequest
+}
+
+func copyCSR(obj *certificates.CertificateSigningRequest) *certificates.CertificateSigningRequest {
+	c := *obj
+	c.Status.Conditions = append([]certificates.CertificateSigningRequestCondition(nil), obj.Status.Conditions...)
+	return &c
+}
+
+func (p *csrStore) Create(ctx context.Context, obj *certificates.CertificateSigningRequest) error {
+	p.Lock()
+	defer p.Unlock()
+	if _, ok := p.items[obj.Name]; ok {
+		return errors.NewAlreadyExists(obj.Name)
+	}
+	p.items[obj.Name] = copyCSR(obj)
+	return nil
+}
+func (p *csrStore) Get(ctx context.Context, name string) (*certificates.CertificateSigningRequest, error) {
+	p.Lock()
+	defer p.Unlock()
+	if obj, ok := p.items[name]; ok {
+		return copyCSR(obj), nil
+	}
+	return nil, errors.NewNotFound(name)
+}
+func (p *csrStore) List(ctx context.Context, opts api.GetListOptions) (ret []*certificates.CertificateSigningRequest, err error) {
+	p.Lock()
+	defer p.Unlock()
+	for _, v := range p.items {
+		ret = append(ret, copyCSR(v))
+	}
+	return
+}
+func (p *csrStore) UpdateStatus(ctx context.Context, obj *certificates.CertificateSigningRequest) error {
+	p.Lock()
+	defer p.Unlock()
+	cur, ok := p.items[obj.Name]
+	if !ok || cur.ResourceVersion != obj.ResourceVersion {
+		return errors.NewNotFound(obj.Name)
+	}
+	version, _ := strconv.Atoi(obj.ResourceVersion)
+	obj.ResourceVersion = strconv.Itoa(version + 1)
+	p.items[obj.Name] = copyCSR(obj)
+	return nil
+}
+func (p *csrStore) Delete(ctx context.Context, name string) error {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.items, name)
+	return nil
+}
+
+// allowVerbs allows the listed verbs for any user
+type allowVerbs []string
+
+func (p allowVerbs) Authorize(ctx context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+	for _, verb := range p {
+		if verb == a.GetVerb() {
+			return authorizer.DecisionAllow, "", nil
+		}
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+}
+
+// newTestCA returns the signing ca and a bundle which trusts it
+func newTestCA(t *testing.T) (dynamiccertificates.CertKeyContentProvider, dynamiccertificates.CAContentProvider) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM, err := keyutil.MarshalPrivateKeyToPEM(key)
+	require.NoError(t, err)
+
+	ca, err := dynamiccertificates.NewStaticCertKeyContent("test-ca", certPEM, keyPEM)
+	require.NoError(t, err)
+	bundle, err := dynamiccertificates.NewStaticCAContent("client-ca", certPEM)
+	require.NoError(t, err)
+
+	return ca, bundle
+}
+
+func newCSR(t *testing.T, name, cn string, orgs ...string) (*certificates.CertificateSigningRequest, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: cn, Organization: orgs},
+	}, key)
+	require.NoError(t, err)
+
+	return &certificates.CertificateSigningRequest{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Spec: certificates.CertificateSigningRequestSpec{
+			Request: pem.EncodeToMemory(&pem.Block{Type: certificates.CertificateRequestBlockType, Bytes: der}),
+		},
+	}, key
+}
+
+func newTestRequest(u user.Info) (*http.Request, *auditapi.Event) {
+	ev := &auditapi.Event{Level: auditapi.LevelMetadata}
+	req := httptest.NewRequest("POST", APIPath+"/certificatesigningrequests", nil)
+	ctx := request.WithUser(req.Context(), u)
+	ctx = request.WithAuditEvent(ctx, ev)
+	return req.WithContext(ctx), ev
+}
+
+func newTestRegistry(t *testing.T, authz authorizer.Authorizer, rules ...AutoApproveRule) *Registry {
+	ca, bundle := newTestCA(t)
+	signer := NewSigner(ca, bundle, SignerOptions{
+		TTL:    time.Hour,
+		Usages: []certificates.KeyUsage{certificates.UsageDigitalSignature, certificates.UsageClientAuth},
+	})
+	return New(&csrStore{items: map[string]*certificates.CertificateSigningRequest{}}, signer, rules, authz)
+}
+
+// authenticate presents the issued certificate to the x509 authenticator which trusts the client ca bundle
+func authenticate(t *testing.T, r *Registry, certPEM []byte) user.Info {
+	block, _ := pem.Decode(certPEM)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+	resp, ok, err := x509request.NewDynamic(r.signer.clientCA.VerifyOptions, x509request.CommonNameUserConversion).AuthenticateRequest(req)
+	require.NoError(t, err)
+	require.True(t, ok)
+	return resp.User
+}
+
+func TestSigner(t *testing.T) {
+	r := newTestRegistry(t, nil)
+
+	csr, _ := newCSR(t, "test", "alice", "dev")
+	expirationSeconds := int32(600)
+	csr.Spec.ExpirationSeconds = &expirationSeconds
+
+	certPEM, cert, err := r.signer.Sign(csr)
+	require.NoError(t, err)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), cert.NotAfter, time.Minute)
+
+	u := authenticate(t, r, certPEM)
+	assert.Equal(t, "alice", u.GetName())
+	assert.Equal(t, []string{"dev"}, u.GetGroups())
+
+	// the ca is not trusted by the client ca bundle
+	_, other := newTestCA(t)
+	r.signer.clientCA = other
+	assert.Error(t, r.signer.CheckTrust())
+	_, _, err = r.signer.Sign(csr)
+	assert.Error(t, err)
+}
+
+func TestApproval(t *testing.T) {
+	w := httptest.NewRecorder()
+	bob := &user.DefaultInfo{Name: "bob"}
+	admin := &user.DefaultInfo{Name: "admin"}
+
+	t.Run("approve", func(t *testing.T) {
+		r := newTestRegistry(t, allowVerbs{"approve"})
+
+		csr, _ := newCSR(t, "bob", "bob")
+		req, _ := newTestRequest(bob)
+		obj, err := r.createCertificateSigningRequest(w, req, csr)
+		require.NoError(t, err)
+		assert.Equal(t, "bob", obj.Spec.Username)
+		assert.Empty(t, obj.Status.Certificate)
+
+		req, ev := newTestRequest(admin)
+		obj, err = r.approveCertificateSigningRequest(w, req, &nameParam{Name: "bob"}, &decisionInput{})
+		require.NoError(t, err)
+		assert.True(t, certificates.IsCertificateRequestApproved(obj))
+		assert.Equal(t, "admin", certificates.GetCondition(obj, certificates.CertificateApproved).User)
+		assert.Equal(t, "bob", authenticate(t, r, obj.Status.Certificate).GetName())
+
+		// every signing is audited
+		assert.Equal(t, "admin", ev.Annotations[ApprovedByAnnotationKey])
+		assert.Contains(t, ev.Annotations[SignedAnnotationKey], "CN=bob")
+
+		// a decided request can't be decided again
+		req, _ = newTestRequest(admin)
+		_, err = r.denyCertificateSigningRequest(w, req, &nameParam{Name: "bob"}, &decisionInput{})
+		assert.True(t, errors.IsConflict(err), "expected conflict, got %v", err)
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		r := newTestRegistry(t, allowVerbs{"approve"})
+
+		csr, _ := newCSR(t, "bob", "bob")
+		req, _ := newTestRequest(bob)
+		_, err := r.createCertificateSigningRequest(w, req, csr)
+		require.NoError(t, err)
+
+		req, _ = newTestRequest(admin)
+		obj, err := r.denyCertificateSigningRequest(w, req, &nameParam{Name: "bob"}, &decisionInput{Message: "no"})
+		require.NoError(t, err)
+		assert.True(t, certificates.IsCertificateRequestDenied(obj))
+		assert.Empty(t, obj.Status.Certificate)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		r := newTestRegistry(t, allowVerbs{"approve"})
+
+		csr, _ := newCSR(t, "bob", "bob")
+		req, _ := newTestRequest(bob)
+		_, err := r.createCertificateSigningRequest(w, req, csr)
+		require.NoError(t, err)
+
+		// both decisions read the undecided request, only the first update wins
+		approveCtx, _ := newTestRequest(admin)
+		approving, approver, err := r.decide(approveCtx.Context(), "bob")
+		require.NoError(t, err)
+		denyCtx, _ := newTestRequest(admin)
+		denying, _, err := r.decide(denyCtx.Context(), "bob")
+		require.NoError(t, err)
+
+		denying.Status.Conditions = append(denying.Status.Conditions, certificates.CertificateSigningRequestCondition{Type: certificates.CertificateDenied})
+		require.NoError(t, r.updateStatus(denyCtx.Context(), denying))
+
+		r.approve(approving, approver, "Approved", "")
+		err = r.updateStatus(approveCtx.Context(), approving)
+		assert.True(t, errors.IsConflict(err), "expected conflict, got %v", err)
+
+		obj, err := r.getCertificateSigningRequest(w, req, &nameParam{Name: "bob"})
+		require.NoError(t, err)
+		assert.True(t, certificates.IsCertificateRequestDenied(obj))
+		assert.False(t, certificates.IsCertificateRequestApproved(obj))
+		assert.Empty(t, obj.Status.Certificate)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		r := newTestRegistry(t, nil)
+
+		csr, _ := newCSR(t, "bob", "bob")
+		req, _ := newTestRequest(bob)
+		_, err := r.createCertificateSigningRequest(w, req, csr)
+		require.NoError(t, err)
+
+		req, _ = newTestRequest(bob)
+		_, err = r.approveCertificateSigningRequest(w, req, &nameParam{Name: "bob"}, &decisionInput{})
+		assert.True(t, errors.IsForbidden(err), "expected forbidden, got %v", err)
+	})
+}
+
+func TestAutoApprove(t *testing.T) {
+	w := httptest.NewRecorder()
+	node := &user.DefaultInfo{Name: "node-1", Groups: []string{"system:nodes"}}
+	rule := AutoApproveRule{Groups: []string{"system:nodes"}, Self: true}
+
+	cases := []struct {
+		name     string
+		cn       string
+		orgs     []string
+		approved bool
+	}{
+		{name: "self", cn: "node-1", orgs: []string{"system:nodes"}, approved: true},
+		{name: "other user", cn: "node-2"},
+		{name: "other group", cn: "node-1", orgs: []string{"system:masters"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := newTestRegistry(t, nil, rule)
+
+			csr, _ := newCSR(t, "node", c.cn, c.orgs...)
+			req, ev := newTestRequest(node)
+			obj, err := r.createCertificateSigningRequest(w, req, csr)
+			require.NoError(t, err)
+
+			assert.Equal(t, c.approved, certificates.IsCertificateRequestApproved(obj))
+			if !c.approved {
+				assert.Empty(t, obj.Status.Certificate)
+				return
+			}
+			assert.Equal(t, "auto-approve-rule-0", ev.Annotations[ApprovedByAnnotationKey])
+			assert.Equal(t, c.cn, authenticate(t, r, obj.Status.Certificate).GetName())
+		})
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := newTestRegistry(t, nil)
+	req, _ := newTestRequest(&user.DefaultInfo{Name: "bob"})
+
+	csr, _ := newCSR(t, "bad-request", "bob")
+	csr.Spec.Request = []byte("bad")
+	_, err := r.createCertificateSigningRequest(w, req, csr)
+	assert.True(t, errors.IsInvalid(err), "expected invalid, got %v", err)
+
+	csr, _ = newCSR(t, "server-auth", "bob")
+	csr.Spec.Usages = []certificates.KeyUsage{certificates.UsageServerAuth}
+	_, err = r.createCertificateSigningRequest(w, req, csr)
+	assert.True(t, errors.IsForbidden(err), "expected forbidden, got %v", err)
+
+	csr, _ = newCSR(t, "with-status", "bob")
+	csr.Status.Certificate = []byte("cert")
+	_, err = r.createCertificateSigningRequest(w, req, csr)
+	assert.True(t, errors.IsInvalid(err), "expected invalid, got %v", err)
+}
+
+// newDBRegistry returns a registry which stores the requests in sqlite
+func newDBRegistry(t *testing.T, authz authorizer.Authorizer) *Registry {
+	db, err := orm.Open("sqlite3", "file:"+t.Name()+".db?cache=shared&mode=memory")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	csrs := &models.CertificateSigningRequest{DB: db}
+	require.NoError(t, dbstore.New(db).AutoMigrate(context.Background(), csrs.Name(), csrs.NewObj()))
+
+	ca, bundle := newTestCA(t)
+	signer := NewSigner(ca, bundle, SignerOptions{
+		TTL:    time.Hour,
+		Usages: []certificates.KeyUsage{certificates.UsageDigitalSignature, certificates.UsageClientAuth},
+	})
+	return New(csrs, signer, nil, authz)
+}
+
+func TestApprovalWithDB(t *testing.T) {
+	w := httptest.NewRecorder()
+	bob := &user.DefaultInfo{Name: "bob"}
+	admin := &user.DefaultInfo{Name: "admin"}
+
+	t.Run("approve", func(t *testing.T) {
+		r := newDBRegistry(t, allowVerbs{"approve"})
+
+		csr, _ := newCSR(t, "bob", "bob")
+		req, _ := newTestRequest(bob)
+		_, err := r.createCertificateSigningRequest(w, req, csr)
+		require.NoError(t, err)
+
+		req, _ = newTestRequest(admin)
+		_, err = r.approveCertificateSigningRequest(w, req, &nameParam{Name: "bob"}, &decisionInput{})
+		require.NoError(t, err)
+
+		// the decision and the certificate are stored
+		obj, err := r.getCertificateSigningRequest(w, req, &nameParam{Name: "bob"})
+		require.NoError(t, err)
+		assert.True(t, certificates.IsCertificateRequestApproved(obj))
+		assert.Equal(t, "bob", authenticate(t, r, obj.Status.Certificate).GetName())
+
+		req, _ = newTestRequest(admin)
+		_, err = r.denyCertificateSigningRequest(w, req, &nameParam{Name: "bob"}, &decisionInput{})
+		assert.True(t, errors.IsConflict(err), "expected conflict, got %v", err)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		r := newDBRegistry(t, allowVerbs{"approve"})
+
+		csr, _ := newCSR(t, "bob", "bob")
+		req, _ := newTestRequest(bob)
+		_, err := r.createCertificateSigningRequest(w, req, csr)
+		require.NoError(t, err)
+
+		// both decisions read the undecided request, only the first update wins
+		approveCtx, _ := newTestRequest(admin)
+		approving, approver, err := r.decide(approveCtx.Context(), "bob")
+		require.NoError(t, err)
+		denyCtx, _ := newTestRequest(admin)
+		denying, _, err := r.decide(denyCtx.Context(), "bob")
+		require.NoError(t, err)
+
+		denying.Status.Conditions = append(denying.Status.Conditions, certificates.CertificateSigningRequestCondition{Type: certificates.CertificateDenied})
+		require.NoError(t, r.updateStatus(denyCtx.Context(), denying))
+
+		r.approve(approving, approver, "Approved", "")
+		err = r.updateStatus(approveCtx.Context(), approving)
+		assert.True(t, errors.IsConflict(err), "expected conflict, got %v", err)
+
+		obj, err := r.getCertificateSigningRequest(w, req, &nameParam{Name: "bob"})
+		require.NoError(t, err)
+		assert.True(t, certificates.IsCertificateRequestDenied(obj))
+		assert.False(t, certificates.IsCertificateRequestApproved(obj))
+		assert.Empty(t, obj.Status.Certificate)
+	})
+}
@@ -0,0 +1,248 @@
+// Package certificates implements an internal CA which issues client
+// certificates for the approved CertificateSigningRequests.
+package certificates
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/apis/certificates"
+	"github.com/yubo/apiserver/pkg/dynamiccertificates"
+	"github.com/yubo/golib/util/sets"
+)
+
+// k8s.io/kubernetes/pkg/controller/certificates/signer/signer.go
+
+// backdate is how far the NotBefore of the issued certificates is set in the past, to tolerate clock skew
+const backdate = 5 * time.Minute
+
+type SignerOptions struct {
+	// TTL is the duration of the issued certificates, a request may ask for a shorter one.
+	TTL time.Duration
+
+	// Usages are the usages which may be requested, they are also the
+	// default of the requests which don't specify any.
+	Usages []certificates.KeyUsage
+}
+
+// Signer signs the certificate requests with a CA whose cert and key may be reloaded at any time.
+type Signer struct {
+	ca       dynamiccertificates.CertKeyContentProvider
+	clientCA dynamiccertificates.CAContentProvider
+
+	ttl    time.Duration
+	usages []certificates.KeyUsage
+	now    func() time.Time
+}
+
+// NewSigner returns a Signer, the clientCA is the bundle which verifies the
+// client certificates of the server, the CA must chain to it to issue client certificates.
+func NewSigner(ca dynamiccertificates.CertKeyContentProvider, clientCA dynamiccertificates.CAContentProvider, opts SignerOptions) *Signer {
+	return &Signer{
+		ca:       ca,
+		clientCA: clientCA,
+		ttl:      opts.TTL,
+		usages:   opts.Usages,
+		now:      time.Now,
+	}
+}
+
+// DefaultUsages returns the usages of the requests which don't specify any
+func (p *Signer) DefaultUsages() []certificates.KeyUsage {
+	return append([]certificates.KeyUsage{}, p.usages...)
+}
+
+// CheckUsages returns an error if any of the usages is not allowed
+func (p *Signer) CheckUsages(usages []certificates.KeyUsage) error {
+	allowed := sets.NewString()
+	for _, usage := range p.usages {
+		allowed.Insert(string(usage))
+	}
+	for _, usage := range usages {
+		if !allowed.Has(string(usage)) {
+			return fmt.Errorf("usage %q is not allowed by the signer, allowed usages: %v", usage, allowed.List())
+		}
+	}
+	return nil
+}
+
+func (p *Signer) currentCA() (*x509.Certificate, crypto.Signer, error) {
+	certPEM, keyPEM := p.ca.CurrentCertKeyContent()
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load the signing ca %s: %v", p.ca.Name(), err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("the key of the signing ca %s is not a signer", p.ca.Name())
+	}
+	return cert, key, nil
+}
+
+// CheckTrust returns an error if the signing CA does not chain to the client CA bundle,
+// the issued client certificates would be rejected by the server.
+func (p *Signer) CheckTrust() error {
+	caCert, _, err := p.currentCA()
+	if err != nil {
+		return err
+	}
+	return p.checkTrust(caCert)
+}
+
+func (p *Signer) checkTrust(caCert *x509.Certificate) error {
+	if p.clientCA == nil {
+		return fmt.Errorf("the signing ca %q is not trusted for client auth, no client ca bundle is configured", caCert.Subject.CommonName)
+	}
+
+	opts, ok := p.clientCA.VerifyOptions()
+	if !ok {
+		return fmt.Errorf("the signing ca %q is not trusted for client auth, the client ca bundle %s is empty", caCert.Subject.CommonName, p.clientCA.Name())
+	}
+	opts.CurrentTime = p.now()
+	opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+
+	if _, err := caCert.Verify(opts); err != nil {
+		return fmt.Errorf("the signing ca %q is not trusted by the client ca bundle %s: %v", caCert.Subject.CommonName, p.clientCA.Name(), err)
+	}
+	return nil
+}
+
+// Sign issues a certificate of the request, the PEM encoded certificate and the parsed one are returned.
+// The certificate expires after the TTL of the signer, or the requested expiration if that comes first.
+func (p *Signer) Sign(csr *certificates.CertificateSigningRequest) ([]byte, *x509.Certificate, error) {
+	req, err := certificates.ParseCSR(csr.Spec.Request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := req.CheckSignature(); err != nil {
+		return nil, nil, fmt.Errorf("invalid signature of the certificate request: %v", err)
+	}
+
+	usages := csr.Spec.Usages
+	if len(usages) == 0 {
+		usages = p.usages
+	}
+	if err := p.CheckUsages(usages); err != nil {
+		return nil, nil, err
+	}
+	keyUsage, extKeyUsages, err := keyUsagesFromStrings(usages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caCert, caKey, err := p.currentCA()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, usage := range extKeyUsages {
+		if usage == x509.ExtKeyUsageClientAuth {
+			if err := p.checkTrust(caCert); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	now := p.now()
+	ttl := p.ttl
+	if csr.Spec.ExpirationSeconds != nil {
+		if d := time.Duration(*csr.Spec.ExpirationSeconds) * time.Second; d < ttl {
+			ttl = d
+		}
+	}
+	notAfter := now.Add(ttl)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	notBefore := now.Add(-backdate)
+	if notBefore.Before(caCert.NotBefore) {
+		notBefore = caCert.NotBefore
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               req.Subject,
+		DNSNames:              req.DNSNames,
+		IPAddresses:           req.IPAddresses,
+		EmailAddresses:        req.EmailAddresses,
+		URIs:                  req.URIs,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsages,
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, req.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), cert, nil
+}
+
+var keyUsageDict = map[certificates.KeyUsage]x509.KeyUsage{
+	certificates.UsageSigning:           x509.KeyUsageDigitalSignature,
+	certificates.UsageDigitalSignature:  x509.KeyUsageDigitalSignature,
+	certificates.UsageContentCommitment: x509.KeyUsageContentCommitment,
+	certificates.UsageKeyEncipherment:   x509.KeyUsageKeyEncipherment,
+	certificates.UsageKeyAgreement:      x509.KeyUsageKeyAgreement,
+	certificates.UsageDataEncipherment:  x509.KeyUsageDataEncipherment,
+}
+
+var extKeyUsageDict = map[certificates.KeyUsage]x509.ExtKeyUsage{
+	certificates.UsageServerAuth:      x509.ExtKeyUsageServerAuth,
+	certificates.UsageClientAuth:      x509.ExtKeyUsageClientAuth,
+	certificates.UsageCodeSigning:     x509.ExtKeyUsageCodeSigning,
+	certificates.UsageEmailProtection: x509.ExtKeyUsageEmailProtection,
+}
+
+// keyUsagesFromStrings will translate a slice of usage strings from the
+// certificates API ("pkg/apis/certificates".KeyUsage) to x509.KeyUsage and
+// x509.ExtKeyUsage types.
+func keyUsagesFromStrings(usages []certificates.KeyUsage) (x509.KeyUsage, []x509.ExtKeyUsage, error) {
+	var keyUsage x509.KeyUsage
+	var unrecognized []certificates.KeyUsage
+	extKeyUsages := make(map[x509.ExtKeyUsage]struct{})
+	for _, usage := range usages {
+		if val, ok := keyUsageDict[usage]; ok {
+			keyUsage |= val
+		} else if val, ok := extKeyUsageDict[usage]; ok {
+			extKeyUsages[val] = struct{}{}
+		} else {
+			unrecognized = append(unrecognized, usage)
+		}
+	}
+
+	var sorted []x509.ExtKeyUsage
+	for eku := range extKeyUsages {
+		sorted = append(sorted, eku)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	if len(unrecognized) > 0 {
+		return 0, nil, fmt.Errorf("unrecognized usage values: %q", unrecognized)
+	}
+
+	return keyUsage, sorted, nil
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/client/metrics"
+	"github.com/yubo/golib/util/clock"
+)
+
+// CircuitState is the state of the circuit of a host
+type CircuitState int
+
+const (
+	// CircuitClosed passes the requests
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen passes a trial request after the open timeout
+	CircuitHalfOpen
+	// CircuitOpen rejects the requests
+	CircuitOpen
+)
+
+func (p CircuitState) String() string {
+	switch p {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(p))
+	}
+}
+
+// ErrCircuitOpen is returned if the circuit of the host is open
+var ErrCircuitOpen = fmt.Errorf("circuit breaker is open")
+
+// CircuitBreaker rejects the requests to a host after the consecutive
+// failures, the connection errors and the 5xx responses, of the host. After
+// the open timeout a trial request is passed, the circuit is closed if it
+// succeeds, or opened again.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of the consecutive failures opening the circuit
+	FailureThreshold int
+	// OpenTimeout is the time the circuit is open before the trial request
+	OpenTimeout time.Duration
+
+	clock clock.Clock
+
+	sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// trial is true if the trial request of the half-open circuit is in flight
+	trial bool
+	// generation is changed when the circuit is opened and when the trial
+	// request is allowed, the results of the requests allowed before are stale
+	generation uint64
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+		clock:            clock.RealClock{},
+		circuits:         map[string]*circuit{},
+	}
+}
+
+func (p *CircuitBreaker) circuit(host string) *circuit {
+	if p.circuits == nil {
+		p.circuits = map[string]*circuit{}
+	}
+	if p.clock == nil {
+		p.clock = clock.RealClock{}
+	}
+
+	c, ok := p.circuits[host]
+	if !ok {
+		c = &circuit{}
+		p.circuits[host] = c
+	}
+	return c
+}
+
+func (p *CircuitBreaker) setState(host string, c *circuit, state CircuitState) {
+	if c.state != state {
+		c.state = state
+		metrics.CircuitBreakerState.Set(host, int(state))
+	}
+}
+
+// State returns the state of the circuit of the host
+func (p *CircuitBreaker) State(host string) CircuitState {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.circuit(host).state
+}
+
+// Allow returns ErrCircuitOpen if the request to the host is rejected, the
+// allowed request must be reported by Done, or Release if it is canceled, with
+// the generation returned.
+func (p *CircuitBreaker) Allow(host string) (uint64, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	c := p.circuit(host)
+	switch c.state {
+	case CircuitOpen:
+		if p.clock.Since(c.openedAt) < p.OpenTimeout {
+			break
+		}
+		p.setState(host, c, CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if c.trial {
+			break
+		}
+		c.trial = true
+		c.generation++
+		return c.generation, nil
+	default:
+		return c.generation, nil
+	}
+
+	metrics.CircuitBreakerRejected.Increment(host)
+	return 0, fmt.Errorf("%w for host %s", ErrCircuitOpen, host)
+}
+
+// Done reports the result of the request allowed, the result of a stale
+// generation, e.g. a request allowed before the trial, is ignored.
+func (p *CircuitBreaker) Done(host string, generation uint64, failed bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	c := p.circuit(host)
+	if generation != c.generation {
+		return
+	}
+	c.trial = false
+
+	if !failed {
+		c.failures = 0
+		p.setState(host, c, CircuitClosed)
+		return
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= p.FailureThreshold {
+		c.openedAt = p.clock.Now()
+		c.generation++
+		p.setState(host, c, CircuitOpen)
+	}
+}
+
+// Release releases the request allowed without the result, e.g. the request
+// canceled by the caller, the state of the circuit is unchanged
+func (p *CircuitBreaker) Release(host string, generation uint64) {
+	p.Lock()
+	defer p.Unlock()
+
+	if c := p.circuit(host); generation == c.generation {
+		c.trial = false
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	testingclock "github.com/yubo/golib/util/clock/testing"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var code int32 = http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+		w.Write([]byte(`{"Bar":1}`))
+	}))
+	defer server.Close()
+
+	clock := testingclock.NewFakeClock(time.Now())
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.clock = clock
+
+	do := func() error {
+		req, err := NewRequest(server.URL, WithCircuitBreaker(cb), WithOutput(&Foo{}))
+		require.NoError(t, err)
+		return req.Do(context.Background())
+	}
+	host := server.Listener.Addr().String()
+
+	// the consecutive failures open the circuit
+	require.Error(t, do())
+	assert.Equal(t, CircuitClosed, cb.State(host))
+	require.Error(t, do())
+	assert.Equal(t, CircuitOpen, cb.State(host))
+
+	err := do()
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "%v", err)
+
+	// the failed trial opens the circuit again
+	clock.Step(time.Minute)
+	trial, err := cb.Allow(host)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitHalfOpen, cb.State(host))
+	_, err = cb.Allow(host)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "only one trial request")
+	cb.Done(host, trial, true)
+	assert.Equal(t, CircuitOpen, cb.State(host))
+
+	// the succeeded trial closes the circuit
+	clock.Step(time.Minute)
+	atomic.StoreInt32(&code, http.StatusOK)
+	require.NoError(t, do())
+	assert.Equal(t, CircuitClosed, cb.State(host))
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cancel()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	clock := testingclock.NewFakeClock(time.Now())
+	cb := NewCircuitBreaker(1, time.Minute)
+	cb.clock = clock
+	host := server.Listener.Addr().String()
+
+	generation, err := cb.Allow(host)
+	require.NoError(t, err)
+	cb.Done(host, generation, true)
+	assert.Equal(t, CircuitOpen, cb.State(host))
+
+	// the canceled trial releases the circuit without closing or opening it
+	clock.Step(time.Minute)
+	req, err := NewRequest(server.URL, WithCircuitBreaker(cb), WithOutput(&Foo{}))
+	require.NoError(t, err)
+	require.Error(t, req.Do(ctx))
+	assert.Equal(t, CircuitHalfOpen, cb.State(host))
+	_, err = cb.Allow(host)
+	require.NoError(t, err, "the trial is released")
+}
+
+func TestCircuitBreakerStaleDone(t *testing.T) {
+	clock := testingclock.NewFakeClock(time.Now())
+	cb := NewCircuitBreaker(1, time.Minute)
+	cb.clock = clock
+	host := "example.com"
+
+	// two requests are in flight, the first failure opens the circuit
+	first, err := cb.Allow(host)
+	require.NoError(t, err)
+	second, err := cb.Allow(host)
+	require.NoError(t, err)
+	cb.Done(host, first, true)
+	assert.Equal(t, CircuitOpen, cb.State(host))
+
+	// the trial is in flight, the result of the request allowed before is ignored
+	clock.Step(time.Minute)
+	trial, err := cb.Allow(host)
+	require.NoError(t, err)
+	cb.Done(host, second, false)
+	cb.Release(host, second)
+	assert.Equal(t, CircuitHalfOpen, cb.State(host))
+	_, err = cb.Allow(host)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "the trial slot is kept")
+
+	cb.Done(host, trial, false)
+	assert.Equal(t, CircuitClosed, cb.State(host))
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/term"
 	"github.com/yubo/golib/util/interrupt"
+	"k8s.io/klog/v2"
 )
 
 type ExecClient struct {
@@ -28,6 +29,7 @@ type execRequest struct {
 	cmd         []string
 	containerId string
 	ioStreams   *IOStreams
+	transport   StreamTransport
 }
 
 func NewExecClient(config *rest.Config, verb, path string) *ExecClient {
@@ -76,6 +78,13 @@ func (p *execRequest) IO(ioStreams *IOStreams) *execRequest {
 	return p
 }
 
+// Transport sets the transport of the streams, defaults to SPDY with the
+// websocket fallback
+func (p *execRequest) Transport(transport StreamTransport) *execRequest {
+	p.transport = transport
+	return p
+}
+
 func (p *execRequest) Run() error {
 	client, err := rest.RESTClientFor(p.config)
 	if err != nil {
@@ -113,7 +122,11 @@ func (p *execRequest) Run() error {
 		}, scheme.ParameterCodec)
 
 	return t.Safe(func() error {
-		return RemoteExecute(
+		if p.transport == StreamTransportWebSocket {
+			return WebSocketExecute(req.URL(), p.config, o.In, o.Out, o.ErrOut, t.Raw, sizeQueue)
+		}
+
+		err := RemoteExecute(
 			p.verb,
 			req.URL(),
 			p.config,
@@ -123,6 +136,11 @@ func (p *execRequest) Run() error {
 			t.Raw,
 			sizeQueue,
 		)
+		if p.transport == StreamTransportAuto && isUpgradeFailure(err) {
+			klog.V(2).InfoS("spdy upgrade failed, fall back to websocket", "err", err)
+			return WebSocketExecute(req.URL(), p.config, o.In, o.Out, o.ErrOut, t.Raw, sizeQueue)
+		}
+		return err
 	})
 }
 
@@ -206,3 +224,19 @@ func RemoteExecute(method string, url *url.URL, config *rest.Config, stdin io.Re
 		TerminalSizeQueue: terminalSizeQueue,
 	})
 }
+
+// WebSocketExecute executes the remote command over websocket, the websocket
+// connections are always opened by GET
+func WebSocketExecute(url *url.URL, config *rest.Config, stdin io.Reader, stdout, stderr io.Writer, tty bool, terminalSizeQueue term.TerminalSizeQueue) error {
+	exec, err := NewWebSocketExecutor(config, url)
+	if err != nil {
+		return err
+	}
+	return exec.Stream(remotecommand.StreamOptions{
+		Stdin:             stdin,
+		Stdout:            stdout,
+		Stderr:            stderr,
+		Tty:               tty,
+		TerminalSizeQueue: terminalSizeQueue,
+	})
+}
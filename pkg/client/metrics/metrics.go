@@ -0,0 +1,71 @@
+// Package metrics provides the hooks of the metrics of pkg/client, which are
+// registered by components/metrics/prometheus/restclient.
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+var registerMetrics sync.Once
+
+// RetryMetric counts the retries partitioned by the status code of the failed
+// attempt, method and host.
+type RetryMetric interface {
+	Increment(ctx context.Context, code string, method string, host string)
+}
+
+// CircuitStateMetric sets the state of the circuit of the host, the state is
+// the value of client.CircuitState, 0 closed, 1 half-open, 2 open.
+type CircuitStateMetric interface {
+	Set(host string, state int)
+}
+
+// CircuitRejectedMetric counts the requests rejected by the open circuit of the host
+type CircuitRejectedMetric interface {
+	Increment(host string)
+}
+
+var (
+	// RequestRetry is the retry metric that the requests will update.
+	RequestRetry RetryMetric = noopRetry{}
+	// CircuitBreakerState is the state metric that the circuit breakers will update.
+	CircuitBreakerState CircuitStateMetric = noopCircuitState{}
+	// CircuitBreakerRejected is the rejection metric that the circuit breakers will update.
+	CircuitBreakerRejected CircuitRejectedMetric = noopCircuitRejected{}
+)
+
+// RegisterOpts contains all the metrics to register. Metrics may be nil.
+type RegisterOpts struct {
+	RequestRetry           RetryMetric
+	CircuitBreakerState    CircuitStateMetric
+	CircuitBreakerRejected CircuitRejectedMetric
+}
+
+// Register registers metrics for the requests to use. This can
+// only be called once.
+func Register(opts RegisterOpts) {
+	registerMetrics.Do(func() {
+		if opts.RequestRetry != nil {
+			RequestRetry = opts.RequestRetry
+		}
+		if opts.CircuitBreakerState != nil {
+			CircuitBreakerState = opts.CircuitBreakerState
+		}
+		if opts.CircuitBreakerRejected != nil {
+			CircuitBreakerRejected = opts.CircuitBreakerRejected
+		}
+	})
+}
+
+type noopRetry struct{}
+
+func (noopRetry) Increment(context.Context, string, string, string) {}
+
+type noopCircuitState struct{}
+
+func (noopCircuitState) Set(string, int) {}
+
+type noopCircuitRejected struct{}
+
+func (noopCircuitRejected) Increment(string) {}
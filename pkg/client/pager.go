@@ -26,8 +26,9 @@ type Pager struct {
 	pageTotal   int
 
 	//render
-	buff   []byte
-	stdout io.Writer
+	buff    []byte
+	stdout  io.Writer
+	printer Printer
 }
 
 // pageSize == 0 : no limit
@@ -36,6 +37,18 @@ func NewPager(r *Request, stdout io.Writer, disablePage bool) (*Pager, error) {
 		r:           r,
 		disablePage: disablePage,
 		stdout:      stdout,
+		printer:     r.printer,
+	}
+
+	// the pages are printed by the pager
+	if r.printer != nil {
+		req := *r
+		req.printer = nil
+		p.r = &req
+	}
+
+	if p.printer == nil {
+		p.printer = &TablePrinter{}
 	}
 
 	if pagination, err := getPageParamsFrom(r.param); err != nil {
@@ -51,6 +64,15 @@ func NewPager(r *Request, stdout io.Writer, disablePage bool) (*Pager, error) {
 	return p, nil
 }
 
+// Printer sets the printer of the list, defaults to the printer of the
+// request, or the table. The pages are rendered interactively only by the
+// tabular printers, e.g. the table and the custom columns, the others print
+// the list of all the pages at once.
+func (p *Pager) Printer(printer Printer) *Pager {
+	p.printer = printer
+	return p
+}
+
 func getPageParamsFrom(input interface{}) (*api.PageParams, error) {
 	rv := reflect.Indirect(reflect.ValueOf(input))
 	if rv.Kind() != reflect.Struct {
@@ -65,6 +87,10 @@ func getPageParamsFrom(input interface{}) (*api.PageParams, error) {
 	return pagination, nil
 }
 
+func listField(output interface{}) reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(output)).FieldByName("List")
+}
+
 func getListFrom(output interface{}) (int, interface{}) {
 	rv := reflect.Indirect(reflect.ValueOf(output))
 
@@ -128,7 +154,11 @@ func (p *Pager) Render(ctx context.Context, page int, rerend bool) (err error) {
 	p.total = total
 	p.pageTotal = int(math.Ceil(float64(total) / float64(p.PageSize)))
 
-	fmt.Fprintf(p.stdout, strings.Replace(string(Table(list)), "\n", "\033[K\n", -1))
+	buf := &bytes.Buffer{}
+	if err = p.printer.PrintObj(list, buf); err != nil {
+		return
+	}
+	io.WriteString(p.stdout, strings.Replace(buf.String(), "\n", "\033[K\n", -1))
 
 	v := reflect.ValueOf(list)
 	if n := p.PageSize - v.Len(); n > 0 {
@@ -142,28 +172,47 @@ func (p *Pager) Dump(ctx context.Context) (err error) {
 	pageTotal := 1
 	p.PageSize = 100
 	r := p.r
+	tabular := isTabular(p.printer)
 
+	var items reflect.Value
 	for i := 0; i < pageTotal; i++ {
-		p.Current = i
+		p.Current = i + 1
+		if !tabular {
+			// the items of the previous pages are kept
+			listField(r.output).Set(reflect.Zero(listField(r.output).Type()))
+		}
 		if err = p.r.Do(ctx); err != nil {
 			return
 		}
 		total, list := getListFrom(r.output)
 		pageTotal = int(math.Ceil(float64(total) / float64(p.PageSize)))
 
-		output := Table(list)
-		if i > 0 {
-			if i := bytes.IndexByte(output, '\n'); i > 0 {
-				output = output[i+1:]
+		if !tabular {
+			v := reflect.Indirect(reflect.ValueOf(list))
+			if !items.IsValid() {
+				items = reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, total)
 			}
+			items = reflect.AppendSlice(items, v)
+			continue
+		}
+
+		printer := p.printer
+		if i > 0 {
+			printer = withoutHeaders(printer)
+		}
+		if err = printer.PrintObj(list, p.stdout); err != nil {
+			return
 		}
-		p.stdout.Write(output)
+	}
+
+	if !tabular {
+		return p.printer.PrintObj(items.Interface(), p.stdout)
 	}
 	return nil
 }
 
 func (p *Pager) Do(ctx context.Context) error {
-	if p.PageSize == 0 {
+	if p.PageSize == 0 || !isTabular(p.printer) {
 		return p.Dump(ctx)
 	}
 
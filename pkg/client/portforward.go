@@ -0,0 +1,360 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/client-go/tools/portforward"
+	"github.com/yubo/client-go/transport/spdy"
+	"github.com/yubo/golib/api"
+	"golang.org/x/net/websocket"
+	"k8s.io/klog/v2"
+)
+
+// PortForwardClient forwards the local ports to the remote ports of the
+// streaming server, e.g. pkg/streaming/portforward
+type PortForwardClient struct {
+	config *rest.Config
+	verb   string
+	path   string
+}
+
+type portForwardRequest struct {
+	config    *rest.Config
+	verb      string
+	path      string
+	ports     []string
+	addresses []string
+	params    url.Values
+	transport StreamTransport
+	out       io.Writer
+	errOut    io.Writer
+	readyCh   chan struct{}
+
+	sync.Mutex
+	forwarded []portforward.ForwardedPort
+}
+
+func NewPortForwardClient(config *rest.Config, verb, path string) *PortForwardClient {
+	return &PortForwardClient{
+		config: config,
+		verb:   verb,
+		path:   path,
+	}
+}
+
+// Forward returns a request forwards the ports, the valid port specifications:
+//
+//	5000      forwards from localhost:5000 to the remote port 5000
+//	8888:5000 forwards from localhost:8888 to the remote port 5000
+//	:5000     forwards from a random local port to the remote port 5000
+func (p *PortForwardClient) Forward(ports ...string) *portForwardRequest {
+	return &portForwardRequest{
+		config:    p.config,
+		verb:      p.verb,
+		path:      p.path,
+		ports:     ports,
+		addresses: []string{"localhost"},
+		params:    url.Values{},
+		out:       os.Stdout,
+		errOut:    os.Stderr,
+	}
+}
+
+// Address sets the local addresses to listen on, defaults to localhost
+func (p *portForwardRequest) Address(addresses ...string) *portForwardRequest {
+	p.addresses = addresses
+	return p
+}
+
+// Param sets a query parameter of the request, e.g. the id of the sandbox
+func (p *portForwardRequest) Param(name, value string) *portForwardRequest {
+	p.params.Add(name, value)
+	return p
+}
+
+// Transport sets the transport of the streams, defaults to SPDY with the
+// websocket fallback
+func (p *portForwardRequest) Transport(transport StreamTransport) *portForwardRequest {
+	p.transport = transport
+	return p
+}
+
+func (p *portForwardRequest) IO(out, errOut io.Writer) *portForwardRequest {
+	p.out = out
+	p.errOut = errOut
+	return p
+}
+
+// Ready sets the channel closed when the local ports are listened
+func (p *portForwardRequest) Ready(readyCh chan struct{}) *portForwardRequest {
+	p.readyCh = readyCh
+	return p
+}
+
+// Ports returns the forwarded ports, the random local ports are resolved
+// after the request is ready
+func (p *portForwardRequest) Ports() []portforward.ForwardedPort {
+	p.Lock()
+	defer p.Unlock()
+
+	return append([]portforward.ForwardedPort{}, p.forwarded...)
+}
+
+func (p *portForwardRequest) url() (*url.URL, error) {
+	client, err := rest.RESTClientFor(p.config)
+	if err != nil {
+		return nil, err
+	}
+
+	req := client.Verb(p.verb).Prefix(p.path)
+	for k, values := range p.params {
+		for _, v := range values {
+			req = req.Param(k, v)
+		}
+	}
+	return req.URL(), nil
+}
+
+// Run forwards the ports until the stopCh is closed
+func (p *portForwardRequest) Run(stopCh <-chan struct{}) error {
+	u, err := p.url()
+	if err != nil {
+		return err
+	}
+
+	if p.transport == StreamTransportWebSocket {
+		return p.runWebSocket(u, stopCh)
+	}
+
+	err = p.runSPDY(u, stopCh)
+	if p.transport == StreamTransportAuto && isUpgradeFailure(err) {
+		klog.V(2).InfoS("spdy upgrade failed, fall back to websocket", "err", err)
+		return p.runWebSocket(u, stopCh)
+	}
+	return err
+}
+
+func (p *portForwardRequest) runSPDY(u *url.URL, stopCh <-chan struct{}) error {
+	transport, upgrader, err := spdy.RoundTripperFor(p.config)
+	if err != nil {
+		return err
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, p.verb, u)
+
+	// the ready channel of the forwarder is observed to resolve the local ports
+	readyCh := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, p.addresses, p.ports, stopCh, readyCh, p.out, p.errOut)
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- fw.ForwardPorts() }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-readyCh:
+	}
+
+	ports, err := fw.GetPorts()
+	if err != nil {
+		return err
+	}
+	p.ready(ports)
+
+	return <-errCh
+}
+
+func (p *portForwardRequest) ready(ports []portforward.ForwardedPort) {
+	p.Lock()
+	p.forwarded = ports
+	p.Unlock()
+
+	if p.readyCh != nil {
+		close(p.readyCh)
+	}
+}
+
+// runWebSocket listens on the local ports, and opens a websocket connection
+// for each local connection, the remote port is set by the query parameter
+func (p *portForwardRequest) runWebSocket(u *url.URL, stopCh <-chan struct{}) error {
+	if len(p.addresses) == 0 {
+		return errors.New("you must specify at least 1 address")
+	}
+	if len(p.ports) == 0 {
+		return errors.New("you must specify at least 1 port")
+	}
+	ports, err := parsePorts(p.ports)
+	if err != nil {
+		return err
+	}
+
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+
+	for i := range ports {
+		port := &ports[i]
+		for _, address := range p.addresses {
+			l, err := net.Listen("tcp", net.JoinHostPort(address, strconv.Itoa(int(port.Local))))
+			if err != nil {
+				return fmt.Errorf("unable to listen on port %d: %v", port.Local, err)
+			}
+			listeners = append(listeners, l)
+
+			// the random port is shared by the other addresses
+			_, localPort, _ := net.SplitHostPort(l.Addr().String())
+			local, _ := strconv.ParseUint(localPort, 10, 16)
+			port.Local = uint16(local)
+			if p.out != nil {
+				fmt.Fprintf(p.out, "Forwarding from %s -> %d\n", l.Addr().String(), port.Remote)
+			}
+
+			go p.waitForConnection(l, u, *port)
+		}
+	}
+
+	p.ready(ports)
+
+	<-stopCh
+	return nil
+}
+
+func (p *portForwardRequest) waitForConnection(l net.Listener, u *url.URL, port portforward.ForwardedPort) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				klog.ErrorS(err, "error accepting connection", "port", port.Local)
+			}
+			return
+		}
+		go func() {
+			if err := p.handleConnection(conn, u, port); err != nil && p.errOut != nil {
+				fmt.Fprintf(p.errOut, "error forwarding port %d: %v\n", port.Remote, err)
+			}
+		}()
+	}
+}
+
+// handleConnection copies the data between the local connection and the data
+// channel of the websocket connection, the first 2 bytes of each channel is
+// the remote port.
+func (p *portForwardRequest) handleConnection(conn net.Conn, u *url.URL, port portforward.ForwardedPort) error {
+	defer conn.Close()
+
+	location := *u
+	query := location.Query()
+	query.Set(api.PortHeader, strconv.Itoa(int(port.Remote)))
+	location.RawQuery = query.Encode()
+
+	ws, err := dialWebSocket(p.config, &location, v4BinaryWebsocketProtocol)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	// local -> remote
+	go func() {
+		defer ws.Close()
+
+		w := &wsChannelWriter{conn: ws}
+		buf := make([]byte, wsBufSize)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				if err := w.write(stdinChannel, buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// remote -> local
+	var prefixed [2]int
+	var remoteErr []byte
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			break
+		}
+		if len(msg) < 1 || msg[0] > 1 {
+			continue
+		}
+
+		channel, data := msg[0], msg[1:]
+		if skip := 2 - prefixed[channel]; skip > 0 {
+			if skip > len(data) {
+				skip = len(data)
+			}
+			prefixed[channel] += skip
+			data = data[skip:]
+		}
+
+		if channel == 0 {
+			if _, err := conn.Write(data); err != nil {
+				return err
+			}
+		} else {
+			remoteErr = append(remoteErr, data...)
+		}
+	}
+
+	if len(remoteErr) > 0 {
+		return errors.New(string(remoteErr))
+	}
+	return nil
+}
+
+// parsePorts parses the port specifications like the forwarder of client-go
+func parsePorts(ports []string) ([]portforward.ForwardedPort, error) {
+	var forwards []portforward.ForwardedPort
+	for _, s := range ports {
+		parts := strings.Split(s, ":")
+		var local, remote string
+		switch len(parts) {
+		case 1:
+			local, remote = parts[0], parts[0]
+		case 2:
+			local, remote = parts[0], parts[1]
+			if local == "" {
+				local = "0"
+			}
+		default:
+			return nil, fmt.Errorf("invalid port format '%s'", s)
+		}
+
+		localPort, err := strconv.ParseUint(local, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing local port '%s': %s", local, err)
+		}
+		remotePort, err := strconv.ParseUint(remote, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing remote port '%s': %s", remote, err)
+		}
+		if remotePort == 0 {
+			return nil, fmt.Errorf("remote port must be > 0")
+		}
+
+		forwards = append(forwards, portforward.ForwardedPort{Local: uint16(localPort), Remote: uint16(remotePort)})
+	}
+
+	return forwards, nil
+}
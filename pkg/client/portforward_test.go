@@ -0,0 +1,112 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/streaming/portforward"
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/golib/scheme"
+	"github.com/yubo/golib/stream/wsstream"
+	"github.com/yubo/golib/types"
+)
+
+// echoForwarder prints the remote port, and echoes the data
+type echoForwarder struct{}
+
+func (echoForwarder) PortForward(name string, uid types.UID, port int32, stream io.ReadWriteCloser) error {
+	defer stream.Close()
+
+	fmt.Fprintf(stream, "%d:", port)
+	_, err := io.Copy(stream, stream)
+	return err
+}
+
+func newPortForwardServer(t *testing.T, withSPDY bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// a proxy which strips the spdy upgrade
+		if !withSPDY && !wsstream.IsWebSocketRequest(req) {
+			http.Error(w, "upgrade is not supported", http.StatusBadRequest)
+			return
+		}
+
+		opts, err := portforward.NewV4Options(req)
+		require.NoError(t, err)
+
+		portforward.ServePortForward(w, req, echoForwarder{}, "", "", opts,
+			time.Minute, time.Minute, portforward.SupportedProtocols)
+	}))
+}
+
+func TestPortForward(t *testing.T) {
+	cases := []struct {
+		name      string
+		withSPDY  bool
+		transport StreamTransport
+	}{
+		{"spdy", true, StreamTransportSPDY},
+		{"websocket", true, StreamTransportWebSocket},
+		{"fallback", false, StreamTransportAuto},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := newPortForwardServer(t, c.withSPDY)
+			defer server.Close()
+
+			config := &rest.Config{
+				Host:          server.URL,
+				ContentConfig: rest.ContentConfig{NegotiatedSerializer: scheme.NegotiatedSerializer},
+			}
+
+			stopCh := make(chan struct{})
+			readyCh := make(chan struct{})
+			errCh := make(chan error, 1)
+
+			var out bytes.Buffer
+			req := NewPortForwardClient(config, "POST", "/portforward").
+				Forward(":6060").
+				Address("127.0.0.1").
+				Transport(c.transport).
+				IO(&out, io.Discard).
+				Ready(readyCh)
+			go func() { errCh <- req.Run(stopCh) }()
+
+			select {
+			case <-readyCh:
+			case err := <-errCh:
+				t.Fatal(err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout")
+			}
+
+			ports := req.Ports()
+			require.Len(t, ports, 1)
+			require.Equal(t, uint16(6060), ports[0].Remote)
+			require.NotZero(t, ports[0].Local)
+
+			conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(ports[0].Local))))
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("hello\n"))
+			require.NoError(t, err)
+
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			require.NoError(t, err)
+			require.Equal(t, "6060:hello\n", line)
+
+			close(stopCh)
+			require.NoError(t, <-errCh)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
@@ -38,7 +39,48 @@ func TableStr(in interface{}) string {
 	return out
 }
 
-func jsonTagStruct(w io.Writer, rv reflect.Value) {
+func jsonTagStruct(w io.Writer, rv reflect.Value, wide bool) {
+	titles, values := tableColumns(rv, wide)
+	for i := range titles {
+		fmt.Fprintf(w, "%s\t%s\n", titles[i], values[i])
+	}
+}
+
+func jsonTabArray(w io.Writer, v reflect.Value, wide, noHeaders bool) {
+	for i := 0; i < v.Len(); i++ {
+		titles, values := tableColumns(indirect(v.Index(i)), wide)
+		if i == 0 && !noHeaders {
+			fmt.Fprintf(w, "%s\n", strings.Join(titles, "\t"))
+		}
+		for j := range values {
+			if values[j] == "" {
+				values[j] = NotAvailable
+			}
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join(values, "\t"))
+	}
+}
+
+// tableColumns returns the titles and the values of the columns of the
+// struct, declared by the out tag, e.g. `out:"NAME"`, `out:"CREATED,fromNow"`.
+// The columns with the wide option, e.g. `out:"IP,wide"`, are skipped unless
+// wide is set.
+func tableColumns(rv reflect.Value, wide bool) (titles, values []string) {
+	// the generic objects, e.g. the raw response
+	if rv.Kind() == reflect.Map {
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			titles = append(titles, fmt.Sprint(key))
+			values = append(values, fmt.Sprintf("%v", rv.MapIndex(key).Interface()))
+		}
+		return
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+
 	rt := rv.Type()
 	for i := 0; i < rt.NumField(); i++ {
 		fv := rv.Field(i)
@@ -48,7 +90,7 @@ func jsonTagStruct(w io.Writer, rv reflect.Value) {
 			continue
 		}
 
-		if tag, ok := ff.Tag.Lookup("json"); ok && strings.HasSuffix(tag, ",inline") {
+		if jsonTags := strings.Split(ff.Tag.Get("json"), ","); util.StringArrayContains("inline", jsonTags[1:]) {
 			if fv.Kind() == reflect.Ptr {
 				if fv.IsNil() {
 					continue
@@ -56,7 +98,9 @@ func jsonTagStruct(w io.Writer, rv reflect.Value) {
 				fv = fv.Elem()
 			}
 			if fv.Kind() == reflect.Struct {
-				jsonTagStruct(w, fv)
+				t, v := tableColumns(fv, wide)
+				titles = append(titles, t...)
+				values = append(values, v...)
 			}
 			continue
 		}
@@ -66,112 +110,31 @@ func jsonTagStruct(w io.Writer, rv reflect.Value) {
 			continue
 		}
 
-		if outs := strings.Split(out, ","); len(outs) > 0 && outs[0] != "" {
-			fmt.Fprintf(w, "%s\t", outs[0])
-		} else {
-			fmt.Fprintf(w, "%s\t", ff.Name)
-		}
-
-		fmt.Fprintf(w, "%s\n", fieldOut(fv.Interface(), out))
-	}
-}
-
-func jsonTabArrayTitle(w io.Writer, v reflect.Value) {
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		fieldv := v.Field(i)
-		tag := field.Tag
-
-		if !fieldv.CanInterface() {
-			continue
-		}
-
-		out := tag.Get("out")
-		if out == "-" {
-			continue
-		}
-
-		jsonTags := strings.Split(tag.Get("json"), ",")
-		inline := false
-		if len(jsonTags) > 1 {
-			if util.StringArrayContains("inline", jsonTags[1:]) {
-				inline = true
-			}
-		}
-		if inline {
-			if field.Type.Kind() == reflect.Struct {
-				jsonTabArrayTitle(w, fieldv)
-			}
-			continue
-		}
-
-		if i > 0 {
-			fmt.Fprintf(w, "\t")
-		}
-
-		name := field.Name
 		outs := strings.Split(out, ",")
-		if len(outs) > 0 && outs[0] != "" {
-			name = outs[0]
-		}
-
-		fmt.Fprintf(w, "%s", name)
-	}
-}
-
-func jsonTabArrayEntity(w io.Writer, rv reflect.Value) {
-	rt := rv.Type()
-
-	for i := 0; i < rv.NumField(); i++ {
-		fv := rv.Field(i)
-		ff := rt.Field(i)
-		tag := ff.Tag
-
-		if !fv.CanInterface() {
-			continue
-		}
-
-		out := tag.Get("out")
-		if out == "-" {
-			continue
-		}
-
-		jsonTags := strings.Split(tag.Get("json"), ",")
-		inline := false
-		if len(jsonTags) > 1 {
-			if util.StringArrayContains("inline", jsonTags[1:]) {
-				inline = true
-			}
-		}
-		if inline {
-			if ff.Type.Kind() == reflect.Struct {
-				jsonTabArrayEntity(w, fv)
-			}
+		if !wide && util.StringArrayContains("wide", outs[1:]) {
 			continue
 		}
 
-		if i > 0 {
-			fmt.Fprintf(w, "\t")
+		name := ff.Name
+		if outs[0] != "" {
+			name = outs[0]
 		}
 
-		if n, _ := fmt.Fprintf(w, "%v", fieldOut(fv.Interface(), out)); n == 0 {
-			fmt.Fprintf(w, NotAvailable)
-		}
+		titles = append(titles, name)
+		values = append(values, fieldOut(fv.Interface(), out))
 	}
-
+	return
 }
 
-func jsonTabArray(w io.Writer, v reflect.Value) {
-	for i := 0; i < v.Len(); i++ {
-		fieldv := reflect.Indirect(v.Index(i))
-		if i == 0 {
-			jsonTabArrayTitle(w, fieldv)
-			fmt.Fprintf(w, "\n")
+// indirect returns the value that v points to, or the interface contains
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
 		}
-		jsonTabArrayEntity(w, fieldv)
-		fmt.Fprintf(w, "\n")
+		v = v.Elem()
 	}
+	return v
 }
 
 func PrettyTab(in string) string {
@@ -193,27 +156,30 @@ func Printable(in interface{}) bool {
 
 // JsonTabStr(f, 0, 0, 2, ' ', tabwriter.TabIndent)
 func JsonTab(in interface{}, minwidth, tabwidth, padding int, padchar byte, flags uint) ([]byte, error) {
-	v := reflect.Indirect(reflect.ValueOf(in))
-
 	out := bytes.NewBuffer([]byte{})
 	w := tabwriter.NewWriter(out, minwidth, tabwidth, padding, padchar, flags)
 
+	if err := jsonTab(w, in, false, false); err != nil {
+		return []byte{}, err
+	}
+
+	w.Flush()
+	return out.Bytes(), nil
+}
+
+func jsonTab(w io.Writer, in interface{}, wide, noHeaders bool) error {
+	v := indirect(reflect.ValueOf(in))
+
 	switch v.Kind() {
-	case reflect.Struct:
-		jsonTagStruct(w, v)
+	case reflect.Struct, reflect.Map:
+		jsonTagStruct(w, v, wide)
 	case reflect.Slice, reflect.Array:
-		jsonTabArray(w, v)
-	case reflect.Map:
-		for _, key := range v.MapKeys() {
-			fmt.Fprintf(w, "%s\t%v\n",
-				key, v.MapIndex(key).Interface())
-		}
+		jsonTabArray(w, v, wide, noHeaders)
 	default:
-		return []byte{}, fmt.Errorf("unsupported type: %s", v.Type())
+		return fmt.Errorf("unsupported type: %s", v.Type())
 	}
 
-	w.Flush()
-	return out.Bytes(), nil
+	return nil
 }
 
 func JsonTabStr(in interface{}, minwidth, tabwidth, padding int, padchar byte, flags uint) (string, error) {
@@ -0,0 +1,410 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/yubo/golib/util/jsonpath"
+	"github.com/yubo/golib/util/yaml"
+)
+
+// Printer prints the object, e.g. the output of the request, or the list of the pager
+type Printer interface {
+	PrintObj(obj interface{}, w io.Writer) error
+}
+
+// PrintFlags are the kubectl-like output flags of the CLIs, e.g.
+//
+//	pc.AddConfig("print", &client.PrintFlags{})
+type PrintFlags struct {
+	Output    string `json:"output" flag:"output,o" description:"Output format. One of: json|yaml|wide|jsonpath=...|jsonpath-file=...|go-template=...|go-template-file=...|custom-columns=...|custom-columns-file=..."`
+	NoHeaders bool   `json:"noHeaders" flag:"no-headers" description:"When using the default, wide or custom-columns output format, don't print headers."`
+}
+
+// ToPrinter returns the printer of the output format
+func (p *PrintFlags) ToPrinter() (Printer, error) {
+	printer, err := NewPrinter(p.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := printer.(type) {
+	case *TablePrinter:
+		t.NoHeaders = p.NoHeaders
+	case *CustomColumnsPrinter:
+		t.NoHeaders = p.NoHeaders
+	}
+
+	return printer, nil
+}
+
+// NewPrinter returns the printer of the output format:
+//
+//	""                        the table of the columns declared by the out tags
+//	wide                      the table with the wide columns, e.g. `out:"IP,wide"`
+//	json, yaml
+//	jsonpath=<template>       e.g. jsonpath={.name}
+//	jsonpath-file=<file>
+//	go-template=<template>    e.g. go-template={{.name}}
+//	go-template-file=<file>
+//	custom-columns=<spec>     e.g. custom-columns=NAME:.name,PHONE:.phone
+//	custom-columns-file=<file>
+func NewPrinter(output string) (Printer, error) {
+	format, arg := output, ""
+	if i := strings.Index(output, "="); i >= 0 {
+		format, arg = output[:i], output[i+1:]
+	}
+
+	switch format {
+	case "", "table":
+		return &TablePrinter{}, nil
+	case "wide":
+		return &TablePrinter{Wide: true}, nil
+	case "json":
+		return &JSONPrinter{}, nil
+	case "yaml":
+		return &YAMLPrinter{}, nil
+	}
+
+	if strings.HasSuffix(format, "-file") {
+		if arg == "" {
+			return nil, fmt.Errorf("%s format specified but no file given", format)
+		}
+		b, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s file %s: %v", format, arg, err)
+		}
+		format, arg = strings.TrimSuffix(format, "-file"), string(b)
+	}
+
+	switch format {
+	case "jsonpath":
+		if arg == "" {
+			return nil, fmt.Errorf("jsonpath template format specified but no template given")
+		}
+		return NewJSONPathPrinter(arg)
+	case "go-template":
+		if arg == "" {
+			return nil, fmt.Errorf("go-template format specified but no template given")
+		}
+		return NewGoTemplatePrinter(arg)
+	case "custom-columns":
+		if arg == "" {
+			return nil, fmt.Errorf("custom-columns format specified but no custom columns given")
+		}
+		return NewCustomColumnsPrinter(strings.TrimSpace(arg))
+	}
+
+	return nil, fmt.Errorf("unable to match a printer suitable for the output format %q, allowed formats are: json,yaml,wide,jsonpath,jsonpath-file,go-template,go-template-file,custom-columns,custom-columns-file", output)
+}
+
+// isTabular returns true if the printer prints the rows of a list, the pager
+// renders the pages of them
+func isTabular(printer Printer) bool {
+	switch printer.(type) {
+	case *TablePrinter, *CustomColumnsPrinter:
+		return true
+	default:
+		return false
+	}
+}
+
+// withoutHeaders returns a copy of the tabular printer without the headers,
+// e.g. for the pages after the first one
+func withoutHeaders(printer Printer) Printer {
+	switch t := printer.(type) {
+	case *TablePrinter:
+		c := *t
+		c.NoHeaders = true
+		return &c
+	case *CustomColumnsPrinter:
+		c := *t
+		c.NoHeaders = true
+		return &c
+	default:
+		return printer
+	}
+}
+
+// TablePrinter prints the columns declared by the out tags of the struct, a
+// struct is printed as the name-value lines, a slice of struct as the rows
+type TablePrinter struct {
+	Wide      bool
+	NoHeaders bool
+}
+
+func (p *TablePrinter) PrintObj(obj interface{}, w io.Writer) error {
+	if prep, ok := obj.(Preparer); ok {
+		prep.Prepare()
+	}
+
+	if raw, ok := obj.(json.RawMessage); ok {
+		data, err := toGeneric(raw)
+		if err != nil {
+			return err
+		}
+		obj = data
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.TabIndent)
+	if err := jsonTab(tw, obj, p.Wide, p.NoHeaders); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+type JSONPrinter struct{}
+
+func (p *JSONPrinter) PrintObj(obj interface{}, w io.Writer) error {
+	if raw, ok := obj.(json.RawMessage); ok {
+		obj = rawObject(raw)
+	}
+
+	b, err := json.MarshalIndent(obj, "", "    ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.Write(b)
+	return err
+}
+
+type YAMLPrinter struct{}
+
+func (p *YAMLPrinter) PrintObj(obj interface{}, w io.Writer) error {
+	if raw, ok := obj.(json.RawMessage); ok {
+		obj = rawObject(raw)
+	}
+
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// JSONPathPrinter prints the results of the jsonpath template, the fields are
+// referenced by the json names
+type JSONPathPrinter struct {
+	rawTemplate string
+	*jsonpath.JSONPath
+}
+
+func NewJSONPathPrinter(tmpl string) (*JSONPathPrinter, error) {
+	j := jsonpath.New("out").AllowMissingKeys(true)
+	if err := j.Parse(tmpl); err != nil {
+		return nil, err
+	}
+	return &JSONPathPrinter{
+		rawTemplate: tmpl,
+		JSONPath:    j,
+	}, nil
+}
+
+func (p *JSONPathPrinter) PrintObj(obj interface{}, w io.Writer) error {
+	data, err := toGeneric(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := p.JSONPath.Execute(w, data); err != nil {
+		return fmt.Errorf("error executing jsonpath %q: %v", p.rawTemplate, err)
+	}
+	return nil
+}
+
+// GoTemplatePrinter prints the results of the go template, the fields are
+// referenced by the json names
+type GoTemplatePrinter struct {
+	rawTemplate string
+	template    *template.Template
+}
+
+func NewGoTemplatePrinter(tmpl string) (*GoTemplatePrinter, error) {
+	t, err := template.New("output").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return &GoTemplatePrinter{
+		rawTemplate: tmpl,
+		template:    t,
+	}, nil
+}
+
+func (p *GoTemplatePrinter) PrintObj(obj interface{}, w io.Writer) error {
+	data, err := toGeneric(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := p.template.Execute(w, data); err != nil {
+		return fmt.Errorf("error executing template %q: %v", p.rawTemplate, err)
+	}
+	return nil
+}
+
+// Column is a column of the CustomColumnsPrinter
+type Column struct {
+	// Header is the title of the column
+	Header string
+	// FieldSpec is the jsonpath of the field, e.g. {.name}
+	FieldSpec string
+}
+
+// CustomColumnsPrinter prints the columns of the jsonpath, a slice is printed
+// as the rows, others as a single row
+type CustomColumnsPrinter struct {
+	Columns   []Column
+	NoHeaders bool
+
+	parsers []*jsonpath.JSONPath
+}
+
+// NewCustomColumnsPrinter parses the spec of the columns, e.g. NAME:.name,PHONE:.phone
+func NewCustomColumnsPrinter(spec string) (*CustomColumnsPrinter, error) {
+	var columns []Column
+	for _, part := range strings.Split(spec, ",") {
+		colSpec := strings.SplitN(part, ":", 2)
+		if len(colSpec) != 2 || colSpec[0] == "" || colSpec[1] == "" {
+			return nil, fmt.Errorf("unexpected custom-columns spec: %s, expected <header>:<json-path-expr>", part)
+		}
+		columns = append(columns, Column{
+			Header:    colSpec[0],
+			FieldSpec: relaxedJSONPath(colSpec[1]),
+		})
+	}
+
+	p := &CustomColumnsPrinter{Columns: columns}
+	for _, col := range columns {
+		j := jsonpath.New(col.Header).AllowMissingKeys(true)
+		if err := j.Parse(col.FieldSpec); err != nil {
+			return nil, err
+		}
+		p.parsers = append(p.parsers, j)
+	}
+
+	return p, nil
+}
+
+// relaxedJSONPath returns the jsonpath template of the field, e.g. name,
+// .name, {.name}
+func relaxedJSONPath(field string) string {
+	field = strings.TrimSuffix(strings.TrimPrefix(field, "{"), "}")
+	if !strings.HasPrefix(field, ".") {
+		field = "." + field
+	}
+	return "{" + field + "}"
+}
+
+func (p *CustomColumnsPrinter) PrintObj(obj interface{}, w io.Writer) error {
+	data, err := toGeneric(obj)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.TabIndent)
+
+	if !p.NoHeaders {
+		headers := make([]string, len(p.Columns))
+		for i, col := range p.Columns {
+			headers[i] = col.Header
+		}
+		fmt.Fprintf(tw, "%s\n", strings.Join(headers, "\t"))
+	}
+
+	items, ok := data.([]interface{})
+	if !ok {
+		items = []interface{}{data}
+	}
+
+	for _, item := range items {
+		if err := p.printRow(tw, item); err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+func (p *CustomColumnsPrinter) printRow(w io.Writer, item interface{}) error {
+	values := make([]string, len(p.parsers))
+	for i, parser := range p.parsers {
+		results, err := parser.FindResults(item)
+		if err != nil {
+			return err
+		}
+
+		var fields []string
+		for _, result := range results {
+			for _, v := range result {
+				fields = append(fields, fmt.Sprintf("%v", v.Interface()))
+			}
+		}
+
+		if len(fields) == 0 {
+			values[i] = NotAvailable
+		} else {
+			values[i] = strings.Join(fields, ",")
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%s\n", strings.Join(values, "\t"))
+	return err
+}
+
+// rawObject returns the raw message as is if it is not a valid json
+func rawObject(raw json.RawMessage) interface{} {
+	if json.Valid(raw) {
+		return raw
+	}
+	return string(raw)
+}
+
+// toGeneric converts the object to the maps and the slices by the json
+// encoding, the integers are kept as int64
+func toGeneric(obj interface{}) (interface{}, error) {
+	b, ok := obj.(json.RawMessage)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	var data interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return convertNumber(data), nil
+}
+
+func convertNumber(in interface{}) interface{} {
+	switch t := in.(type) {
+	case map[string]interface{}:
+		for k, v := range t {
+			t[k] = convertNumber(v)
+		}
+	case []interface{}:
+		for i, v := range t {
+			t[i] = convertNumber(v)
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+	}
+	return in
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/golib/api"
+)
+
+type printUser struct {
+	Name  string `json:"name" out:"NAME"`
+	Phone string `json:"phone" out:"PHONE"`
+	Age   int    `json:"age" out:"AGE,wide"`
+}
+
+func TestPrinter(t *testing.T) {
+	user := &printUser{"user1", "123", 18}
+	users := []*printUser{user, {"user2", "45678", 20}}
+
+	cases := []struct {
+		output string
+		obj    interface{}
+		want   string
+	}{
+		{"", user, "NAME   user1\nPHONE  123\n"},
+		{"wide", user, "NAME   user1\nPHONE  123\nAGE    18\n"},
+		{"", users, "NAME   PHONE\nuser1  123\nuser2  45678\n"},
+		{"wide", users, "NAME   PHONE  AGE\nuser1  123    18\nuser2  45678  20\n"},
+		{"json", user, "{\n    \"name\": \"user1\",\n    \"phone\": \"123\",\n    \"age\": 18\n}\n"},
+		{"yaml", users, "- age: 18\n  name: user1\n  phone: \"123\"\n- age: 20\n  name: user2\n  phone: \"45678\"\n"},
+		{"jsonpath={.name}", user, "user1"},
+		{"jsonpath={range [*]}{.name}:{.age}{\"\\n\"}{end}", users, "user1:18\nuser2:20\n"},
+		{"go-template={{.name}}", user, "user1"},
+		{"go-template={{range .}}{{.name}}:{{.age}} {{end}}", users, "user1:18 user2:20 "},
+		{"custom-columns=NAME:.name,AGE:age", user, "NAME   AGE\nuser1  18\n"},
+		{"custom-columns=NAME:{.name},EMAIL:.email", users, "NAME   EMAIL\nuser1  -\nuser2  -\n"},
+		{"", json.RawMessage(`{"name":"user1","age":18}`), "age   18\nname  user1\n"},
+		{"", json.RawMessage(`[{"name":"user1","age":18}]`), "age  name\n18   user1\n"},
+		{"jsonpath={.age}", json.RawMessage(`{"name":"user1","age":18}`), "18"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.output, func(t *testing.T) {
+			printer, err := NewPrinter(c.output)
+			require.NoError(t, err)
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, printer.PrintObj(c.obj, buf))
+			assert.Equal(t, c.want, buf.String())
+		})
+	}
+
+	for _, output := range []string{"xml", "jsonpath=", "jsonpath={.name", "go-template={{.name}", "custom-columns=NAME", "jsonpath-file=/nonexistent"} {
+		_, err := NewPrinter(output)
+		assert.Error(t, err, output)
+	}
+
+	printer, err := (&PrintFlags{Output: "wide", NoHeaders: true}).ToPrinter()
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, printer.PrintObj(users, buf))
+	assert.Equal(t, "user1  123    18\nuser2  45678  20\n", buf.String())
+}
+
+type printListInput struct {
+	api.PageParams
+}
+
+type printListOutput struct {
+	Total int          `json:"total"`
+	List  []*printUser `json:"list"`
+}
+
+// usersServer lists the users by pages
+func usersServer(total int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		pageSize, _ := strconv.Atoi(req.URL.Query().Get("pageSize"))
+		current, _ := strconv.Atoi(req.URL.Query().Get("current"))
+
+		out := printListOutput{Total: total, List: []*printUser{}}
+		for i := (current - 1) * pageSize; i < current*pageSize && i < total; i++ {
+			out.List = append(out.List, &printUser{Name: fmt.Sprintf("user%d", i), Age: i})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	}))
+}
+
+func TestPagerPrinter(t *testing.T) {
+	server := usersServer(150)
+	defer server.Close()
+
+	cases := []struct {
+		output string
+		check  func(t *testing.T, out string)
+	}{
+		{"", func(t *testing.T, out string) {
+			lines := bytes.Split(bytes.TrimSpace([]byte(out)), []byte("\n"))
+			assert.Len(t, lines, 151)
+			assert.Contains(t, string(lines[0]), "NAME")
+			assert.Contains(t, string(lines[150]), "user149")
+		}},
+		{"jsonpath={range [*]}{.name} {end}", func(t *testing.T, out string) {
+			assert.True(t, bytes.HasPrefix([]byte(out), []byte("user0 user1 ")))
+			assert.True(t, bytes.HasSuffix([]byte(out), []byte("user148 user149 ")))
+		}},
+		{"json", func(t *testing.T, out string) {
+			var users []printUser
+			require.NoError(t, json.Unmarshal([]byte(out), &users))
+			assert.Len(t, users, 150)
+			assert.Equal(t, "user149", users[149].Name)
+		}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.output, func(t *testing.T) {
+			printer, err := NewPrinter(c.output)
+			require.NoError(t, err)
+
+			req, err := NewRequest(server.URL,
+				WithPath("/users"),
+				WithParams(&printListInput{}),
+				WithOutput(&printListOutput{}),
+				WithPrinter(printer, nil),
+			)
+			require.NoError(t, err)
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, req.Pager(buf, true).Do(context.Background()))
+			c.check(t, buf.String())
+		})
+	}
+}
+
+func TestRequestPrinter(t *testing.T) {
+	server := usersServer(2)
+	defer server.Close()
+
+	printer, err := NewPrinter("custom-columns=TOTAL:.total,NAMES:.list[*].name")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	req, err := NewRequest(server.URL,
+		WithPath("/users"),
+		WithParams(&printListInput{api.PageParams{PageSize: 10, Current: 1}}),
+		WithOutput(&printListOutput{}),
+		WithPrinter(printer, buf),
+	)
+	require.NoError(t, err)
+	require.NoError(t, req.Do(context.Background()))
+	assert.Equal(t, "TOTAL  NAMES\n2      user0,user1\n", buf.String())
+
+	// the raw response
+	printer, err = NewPrinter("jsonpath={.list[*].name}")
+	require.NoError(t, err)
+
+	buf.Reset()
+	req, err = NewRequest(server.URL,
+		WithPath("/users"),
+		WithParams(&printListInput{api.PageParams{PageSize: 10, Current: 1}}),
+		WithPrinter(printer, buf),
+	)
+	require.NoError(t, err)
+	require.NoError(t, req.Do(context.Background()))
+	assert.Equal(t, "user0 user1", buf.String())
+}
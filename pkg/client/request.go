@@ -2,12 +2,16 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/yubo/apiserver/pkg/client/metrics"
 	"github.com/yubo/apiserver/pkg/scheme"
 	"github.com/yubo/client-go/rest"
+	"k8s.io/klog/v2"
 )
 
 // host: http://127.0.0.1:8080
@@ -58,42 +62,32 @@ type Request struct {
 
 // ("GET", "https://example.com/api/v{version}/{model}/{subject}?a=1&b=2", {"subject":"abc", "model": "instance", "version": 1}, nil)
 func (p *Request) Do(ctx context.Context) error {
-	req := p.client.Verb(p.method)
-
 	if p.httpClient != nil {
 		p.client.Client = p.httpClient
 	}
 
-	if p.prefix != "" {
-		req = req.Prefix(p.prefix)
-	}
-
-	if p.debug {
-		req = req.Debug()
-	}
-
-	if p.param != nil {
-		req = req.VersionedParams(p.param, scheme.ParameterCodec)
-	}
-	if p.body != nil {
-		req = req.Body(p.body)
-	}
-	for k, v := range p.header {
-		req.SetHeader(k, v...)
-	}
-
 	if p.timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, p.timeout)
 		defer cancel()
 	}
 
-	if w, ok := p.output.(io.Writer); ok {
-		b, err := req.Do(ctx).Raw()
+	result, err := p.do(ctx)
+	if err != nil {
+		return err
+	}
+
+	w, ok := p.output.(io.Writer)
+	if ok || (p.output == nil && p.printer != nil) {
+		b, err := result.Raw()
 		if err != nil {
 			return err
 		}
 
+		if p.printer != nil {
+			return p.printer.PrintObj(json.RawMessage(b), p.printerOut)
+		}
+
 		if _, err := w.Write(b); err != nil {
 			return err
 		}
@@ -101,7 +95,7 @@ func (p *Request) Do(ctx context.Context) error {
 		return nil
 	}
 
-	if err := req.Do(ctx).Into(p.output); err != nil {
+	if err := result.Into(p.output); err != nil {
 		return err
 	}
 
@@ -111,9 +105,92 @@ func (p *Request) Do(ctx context.Context) error {
 		}
 	}
 
+	if p.printer != nil {
+		return p.printer.PrintObj(p.output, p.printerOut)
+	}
+
 	return nil
 }
 
+// do makes the attempts of the request with the retry policy and the circuit breaker
+func (p *Request) do(ctx context.Context) (rest.Result, error) {
+	retry := p.retryPolicy
+	if retry != nil {
+		// the body of io.Reader can not be sent again
+		if _, ok := p.body.(io.Reader); ok || !isIdempotent(p.method, p.header) {
+			retry = nil
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req := p.newRequest()
+		if retry != nil {
+			// the retries of the Retry-After are made by the policy
+			req = req.MaxRetries(0)
+		}
+		host := req.URL().Host
+
+		var generation uint64
+		if p.circuitBreaker != nil {
+			var err error
+			if generation, err = p.circuitBreaker.Allow(host); err != nil {
+				return rest.Result{}, err
+			}
+		}
+
+		result := req.Do(ctx)
+
+		var code int
+		result.StatusCode(&code)
+		err := result.Error()
+
+		if p.circuitBreaker != nil {
+			if ctx.Err() != nil {
+				// the canceled request says nothing about the host
+				p.circuitBreaker.Release(host, generation)
+			} else {
+				p.circuitBreaker.Done(host, generation, err != nil && (code == 0 || code >= http.StatusInternalServerError))
+			}
+		}
+
+		if retry == nil || ctx.Err() != nil || !retry.Retryable(attempt, code, err) {
+			return result, nil
+		}
+
+		backoff := retry.Backoff(attempt, err)
+		metrics.RequestRetry.Increment(ctx, strconv.Itoa(code), p.method, host)
+		klog.V(3).InfoS("retry request", "method", p.method, "url", req.URL().String(), "code", code, "err", err, "attempt", attempt+1, "backoff", backoff)
+
+		if err := sleep(ctx, backoff); err != nil {
+			return result, nil
+		}
+	}
+}
+
+func (p *Request) newRequest() *rest.Request {
+	req := p.client.Verb(p.method)
+
+	if p.prefix != "" {
+		req = req.Prefix(p.prefix)
+	}
+
+	if p.debug {
+		req = req.Debug()
+	}
+
+	if p.param != nil {
+		req = req.VersionedParams(p.param, scheme.ParameterCodec)
+	}
+	if p.body != nil {
+		req = req.Body(p.body)
+	}
+	for k, v := range p.header {
+		req.SetHeader(k, v...)
+	}
+
+	return req
+}
+
 type RequestOptions struct {
 	method  string
 	prefix  string
@@ -125,6 +202,12 @@ type RequestOptions struct {
 	output  interface{}         // io.Writer, struct{}
 	cb      []func(interface{}) // callback after req.Do()
 
+	retryPolicy    *RetryPolicy
+	circuitBreaker *CircuitBreaker
+
+	printer    Printer
+	printerOut io.Writer
+
 	// Set specific behavior of the client.  If not set http.DefaultClient will be used.
 	httpClient *http.Client
 }
@@ -207,3 +290,36 @@ func WithTimeout(timeout time.Duration) RequestOption {
 		o.timeout = timeout
 	}
 }
+
+// WithRetry retries the failed attempts of the idempotent requests, or the
+// requests with the Idempotency-Key header, the timeout covers all the attempts.
+func WithRetry(policy *RetryPolicy) RequestOption {
+	return func(o *RequestOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithIdempotencyKey sets the Idempotency-Key header, the request is retried
+// by the retry policy even if the verb is not idempotent.
+func WithIdempotencyKey(key string) RequestOption {
+	return WithHeader(http.Header{IdempotencyKeyHeader: []string{key}})
+}
+
+// WithCircuitBreaker rejects the request if the circuit of the host is open,
+// the circuit breaker is usually shared by the requests.
+func WithCircuitBreaker(cb *CircuitBreaker) RequestOption {
+	return func(o *RequestOptions) {
+		o.circuitBreaker = cb
+	}
+}
+
+// WithPrinter prints the output to w after req.Do(), e.g. the printer of
+// PrintFlags. If the output is an io.Writer or nil, the raw response is printed
+// instead of the typed output, and the columns of the table are the keys of
+// the json objects.
+func WithPrinter(printer Printer, w io.Writer) RequestOption {
+	return func(o *RequestOptions) {
+		o.printer = printer
+		o.printerOut = w
+	}
+}
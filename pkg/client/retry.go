@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/yubo/golib/api/errors"
+)
+
+// IdempotencyKeyHeader marks a request of a non-idempotent verb, e.g. POST,
+// safe to retry, the server is expected to deduplicate the requests by the key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+var (
+	DefaultRetryPolicy = RetryPolicy{
+		MaxRetries:     3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryOn:        []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}
+)
+
+// RetryPolicy retries the failed attempts of the idempotent requests, the
+// connection errors and the status codes of RetryOn are retried with the
+// exponential backoff, the Retry-After of the response takes precedence.
+type RetryPolicy struct {
+	// MaxRetries is the max number of the retries, 0 disables the retries
+	MaxRetries int
+	// InitialBackoff is the backoff of the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff and the Retry-After of the response
+	MaxBackoff time.Duration
+	// Multiplier is the factor of the backoff of the next retry
+	Multiplier float64
+	// Jitter in [0, 1] randomizes the backoff in [backoff*(1-jitter), backoff]
+	Jitter float64
+	// RetryOn are the status codes retried
+	RetryOn []int
+}
+
+// Backoff returns the delay before the retry of the attempt, starting from 0
+func (p *RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	if seconds, ok := errors.SuggestsClientDelay(err); ok && seconds > 0 {
+		return p.capped(time.Duration(seconds) * time.Second)
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt))
+	if p.Jitter > 0 {
+		backoff -= backoff * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+
+	return p.capped(time.Duration(backoff))
+}
+
+func (p *RetryPolicy) capped(d time.Duration) time.Duration {
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// Retryable returns true if the result of the attempt, the status code is 0
+// if there is no response, should be retried
+func (p *RetryPolicy) Retryable(attempt, code int, err error) bool {
+	if err == nil || attempt >= p.MaxRetries {
+		return false
+	}
+
+	// connection errors
+	if code == 0 {
+		_, isStatus := err.(errors.APIStatus)
+		return !isStatus
+	}
+
+	for _, c := range p.RetryOn {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdempotent returns true if the request is safe to retry
+func isIdempotent(method string, header http.Header) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE", "TRACE":
+		return true
+	}
+	return header.Get(IdempotencyKeyHeader) != ""
+}
+
+// sleep waits for the duration, or returns the error of the context
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
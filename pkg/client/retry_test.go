@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/golib/api/errors"
+)
+
+// flakyServer responds the codes in order, then 200
+func flakyServer(codes ...int) (*httptest.Server, *int32) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := int(atomic.AddInt32(&attempts, 1))
+		if n <= len(codes) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(codes[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Bar":1}`))
+	}))
+	return server, &attempts
+}
+
+func TestRetry(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+		RetryOn:        []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}
+
+	cases := []struct {
+		name     string
+		codes    []int
+		opts     []RequestOption
+		attempts int32
+		code     int
+	}{
+		{"get", []int{503, 429}, []RequestOption{WithMethod("GET")}, 3, 0},
+		{"max retries", []int{503, 503, 503}, []RequestOption{WithMethod("GET")}, 3, 503},
+		{"not retryable", []int{500}, []RequestOption{WithMethod("GET")}, 1, 500},
+		// the policy is not applied, the Retry-After is retried by the rest client
+		{"post", []int{503}, []RequestOption{WithMethod("POST")}, 2, 0},
+		{"idempotency key", []int{503}, []RequestOption{WithMethod("POST"), WithIdempotencyKey("abc")}, 2, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server, attempts := flakyServer(c.codes...)
+			defer server.Close()
+
+			output := &Foo{}
+			req, err := NewRequest(server.URL, append(c.opts, WithRetry(policy), WithOutput(output))...)
+			require.NoError(t, err)
+
+			err = req.Do(context.Background())
+			assert.Equal(t, c.attempts, atomic.LoadInt32(attempts))
+			if c.code == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, 1, output.Bar)
+				return
+			}
+			status, ok := err.(errors.APIStatus)
+			require.True(t, ok, "%v", err)
+			assert.Equal(t, int32(c.code), status.Status().Code)
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(0, nil))
+	assert.Equal(t, 400*time.Millisecond, policy.Backoff(2, nil))
+	assert.Equal(t, time.Second, policy.Backoff(10, nil))
+
+	// Retry-After
+	policy.MaxBackoff = time.Minute
+	assert.Equal(t, 2*time.Second, policy.Backoff(0, errors.NewTooManyRequests("slow down", 2)))
+
+	// jitter
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		d := policy.Backoff(1, nil)
+		assert.True(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond, d)
+	}
+}
@@ -0,0 +1,274 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/client-go/tools/remotecommand"
+	"github.com/yubo/client-go/util/exec"
+	"github.com/yubo/golib/api"
+	utilremotecommand "github.com/yubo/golib/util/remotecommand"
+	"golang.org/x/net/websocket"
+)
+
+// StreamTransport is the transport of the exec, attach and port-forward streams
+type StreamTransport string
+
+const (
+	// StreamTransportAuto tries SPDY first, and falls back to websocket if
+	// the upgrade fails, e.g. a proxy strips the SPDY upgrade headers
+	StreamTransportAuto      StreamTransport = ""
+	StreamTransportSPDY      StreamTransport = "spdy"
+	StreamTransportWebSocket StreamTransport = "websocket"
+)
+
+// the channels of the websocket streams, see pkg/streaming/remotecommand
+const (
+	stdinChannel = iota
+	stdoutChannel
+	stderrChannel
+	errorChannel
+	resizeChannel
+
+	v4BinaryWebsocketProtocol = "v4.channel.k8s.io"
+	wsBufSize                 = 32 * 1024
+)
+
+// isUpgradeFailure returns true if the server or a proxy between refused the
+// SPDY upgrade, the status errors of the server are not upgrade failures.
+func isUpgradeFailure(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "unable to upgrade connection")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// websocketHeader returns the headers set by the config, e.g. the authorization,
+// impersonation and user agent
+func websocketHeader(config *rest.Config) (http.Header, error) {
+	header := http.Header{}
+	rt, err := rest.HTTPWrappersForConfig(config, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		header = req.Header.Clone()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return header, nil
+}
+
+// dialWebSocket opens a websocket connection to the url with the tls and the
+// headers of the config
+func dialWebSocket(config *rest.Config, u *url.URL, protocols ...string) (*websocket.Conn, error) {
+	location := *u
+	origin := url.URL{Scheme: "http", Host: u.Host}
+	switch u.Scheme {
+	case "https":
+		location.Scheme = "wss"
+		origin.Scheme = "https"
+	default:
+		location.Scheme = "ws"
+	}
+
+	wsConfig, err := websocket.NewConfig(location.String(), origin.String())
+	if err != nil {
+		return nil, err
+	}
+	wsConfig.Protocol = protocols
+
+	if wsConfig.Header, err = websocketHeader(config); err != nil {
+		return nil, err
+	}
+	if location.Scheme == "wss" {
+		if wsConfig.TlsConfig, err = rest.TLSConfigFor(config); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to upgrade websocket connection: %v", err)
+	}
+	conn.PayloadType = websocket.BinaryFrame
+
+	return conn, nil
+}
+
+// wsChannelWriter writes the data to a channel of the websocket connection
+type wsChannelWriter struct {
+	sync.Mutex
+	conn *websocket.Conn
+}
+
+func (p *wsChannelWriter) write(channel byte, data []byte) error {
+	p.Lock()
+	defer p.Unlock()
+
+	return websocket.Message.Send(p.conn, append([]byte{channel}, data...))
+}
+
+// NewWebSocketExecutor returns an executor which transports the streams over
+// the channels of a websocket connection, for the proxies which strip the
+// SPDY upgrade headers.
+func NewWebSocketExecutor(config *rest.Config, url *url.URL) (remotecommand.Executor, error) {
+	return &wsExecutor{config: config, url: url}, nil
+}
+
+type wsExecutor struct {
+	config *rest.Config
+	url    *url.URL
+}
+
+func (p *wsExecutor) Stream(options remotecommand.StreamOptions) error {
+	return p.StreamWithContext(context.Background(), options)
+}
+
+func (p *wsExecutor) StreamWithContext(ctx context.Context, options remotecommand.StreamOptions) error {
+	conn, err := dialWebSocket(p.config, p.url, v4BinaryWebsocketProtocol)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	w := &wsChannelWriter{conn: conn}
+
+	if options.Stdin != nil {
+		go func() {
+			buf := make([]byte, wsBufSize)
+			for {
+				n, err := options.Stdin.Read(buf)
+				if n > 0 {
+					if err := w.write(stdinChannel, buf[:n]); err != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	if options.Tty && options.TerminalSizeQueue != nil {
+		go func() {
+			for size := options.TerminalSizeQueue.Next(); size != nil; size = options.TerminalSizeQueue.Next() {
+				b, err := json.Marshal(size)
+				if err != nil {
+					return
+				}
+				if err := w.write(resizeChannel, b); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	var status []byte
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if len(msg) < 2 {
+			continue
+		}
+
+		switch msg[0] {
+		case stdoutChannel:
+			if options.Stdout != nil {
+				if _, err := options.Stdout.Write(msg[1:]); err != nil {
+					return err
+				}
+			}
+		case stderrChannel:
+			if options.Stderr != nil {
+				if _, err := options.Stderr.Write(msg[1:]); err != nil {
+					return err
+				}
+			}
+		case errorChannel:
+			status = append(status, msg[1:]...)
+		}
+	}
+
+	if len(status) == 0 {
+		return nil
+	}
+	return decodeStatus(status)
+}
+
+// decodeStatus interprets the api.Status of the error channel, like the v4
+// protocol of the SPDY streams, the exit code is returned as exec.CodeExitError
+func decodeStatus(message []byte) error {
+	status := api.Status{}
+	if err := json.Unmarshal(message, &status); err != nil {
+		return fmt.Errorf("error stream protocol error: %v in %q", err, string(message))
+	}
+
+	switch status.Status {
+	case api.StatusSuccess:
+		return nil
+	case api.StatusFailure:
+		if status.Reason != utilremotecommand.NonZeroExitCodeReason {
+			return errors.New(status.Message)
+		}
+		if status.Details == nil {
+			return errors.New("error stream protocol error: details must be set")
+		}
+		for _, c := range status.Details.Causes {
+			if c.Type != utilremotecommand.ExitCodeCauseType {
+				continue
+			}
+			rc, err := strconv.ParseUint(c.Message, 10, 8)
+			if err != nil {
+				return fmt.Errorf("error stream protocol error: invalid exit code value %q", c.Message)
+			}
+			return exec.CodeExitError{
+				Err:  fmt.Errorf("command terminated with exit code %d", rc),
+				Code: int(rc),
+			}
+		}
+		return fmt.Errorf("error stream protocol error: no %s cause given", utilremotecommand.ExitCodeCauseType)
+	default:
+		return errors.New("error stream protocol error: unknown error")
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	remotecommandserver "github.com/yubo/apiserver/pkg/streaming/remotecommand"
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/client-go/util/exec"
+	"github.com/yubo/golib/term"
+	"github.com/yubo/golib/types"
+	utilexec "github.com/yubo/golib/util/exec"
+	"github.com/yubo/golib/util/remotecommand"
+)
+
+// fakeExecutor prints the command and the first terminal size, and exits with the code
+type fakeExecutor struct {
+	code int
+}
+
+func (p *fakeExecutor) ExecInContainer(name string, uid types.UID, container string, cmd []string, in io.Reader, out, errOut io.WriteCloser, tty bool, resize <-chan term.TerminalSize, timeout time.Duration) error {
+	fmt.Fprintf(out, "%s %v", container, cmd)
+	if tty {
+		size := <-resize
+		fmt.Fprintf(out, " %dx%d", size.Width, size.Height)
+	}
+
+	if p.code != 0 {
+		return utilexec.CodeExitError{Err: fmt.Errorf("exit %d", p.code), Code: p.code}
+	}
+	return nil
+}
+
+// fakeSizeQueue returns the size once
+type fakeSizeQueue struct {
+	size *term.TerminalSize
+}
+
+func (p *fakeSizeQueue) Next() *term.TerminalSize {
+	size := p.size
+	p.size = nil
+	return size
+}
+
+func TestWebSocketExec(t *testing.T) {
+	executor := &fakeExecutor{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		opts, err := remotecommandserver.NewOptions(req)
+		require.NoError(t, err)
+
+		remotecommandserver.ServeExec(w, req, executor, "", "", req.FormValue("containerId"), req.Form["cmd"],
+			opts, time.Minute, time.Minute, remotecommand.SupportedStreamingProtocols)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/exec?containerId=foo&cmd=ls&cmd=-l&output=1&tty=1")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = WebSocketExecute(u, &rest.Config{Host: server.URL}, nil, &out, nil, true, &fakeSizeQueue{&term.TerminalSize{Width: 80, Height: 24}})
+	require.NoError(t, err)
+	require.Equal(t, "foo [ls -l] 80x24", out.String())
+
+	// exit code
+	executor.code = 3
+	out.Reset()
+	u, err = url.Parse(server.URL + "/exec?containerId=foo&cmd=false&output=1")
+	require.NoError(t, err)
+
+	err = WebSocketExecute(u, &rest.Config{Host: server.URL}, nil, &out, nil, false, nil)
+	exitErr, ok := err.(exec.CodeExitError)
+	require.True(t, ok, "%v", err)
+	require.Equal(t, 3, exitErr.Code)
+	require.Equal(t, "foo [false]", out.String())
+}
@@ -0,0 +1,280 @@
+package dynamiccertificates
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/yubo/golib/util/keyutil"
+	utilruntime "github.com/yubo/golib/util/runtime"
+	"github.com/yubo/golib/util/wait"
+	"github.com/yubo/golib/util/workqueue"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"k8s.io/klog/v2"
+)
+
+type ACMEOptions struct {
+	// DirectoryURL is the directory of the ACME server
+	DirectoryURL string
+	// Email is the contact of the account, optional
+	Email string
+	// Domains are the host names to obtain certificates for, one certificate per domain
+	Domains []string
+	// CacheDir persists the account key and the certificates
+	CacheDir string
+	// RenewBefore is how early the certificates are renewed before they expire
+	RenewBefore time.Duration
+	// HTTP01 enables the http-01 challenge, the handler from ACMEManager.HTTPHandler must be served on port 80.
+	// The tls-alpn-01 challenge is always enabled.
+	HTTP01 bool
+	// HTTPClient is used to talk to the ACME server
+	HTTPClient *http.Client
+}
+
+// ACMEManager obtains and renews the certificates of the domains from an ACME server.
+type ACMEManager struct {
+	opts        ACMEOptions
+	manager     *autocert.Manager
+	httpHandler http.Handler
+}
+
+func NewACMEManager(opts ACMEOptions) (*ACMEManager, error) {
+	if len(opts.Domains) == 0 {
+		return nil, fmt.Errorf("missing domains for acme")
+	}
+	if opts.CacheDir == "" {
+		return nil, fmt.Errorf("missing cache dir for acme")
+	}
+
+	p := &ACMEManager{
+		opts: opts,
+		manager: &autocert.Manager{
+			Prompt:      autocert.AcceptTOS,
+			Cache:       autocert.DirCache(opts.CacheDir),
+			HostPolicy:  autocert.HostWhitelist(opts.Domains...),
+			RenewBefore: opts.RenewBefore,
+			Email:       opts.Email,
+			Client: &acme.Client{
+				DirectoryURL: opts.DirectoryURL,
+				HTTPClient:   opts.HTTPClient,
+			},
+		},
+	}
+
+	// must be called before any certificate is requested
+	if opts.HTTP01 {
+		p.httpHandler = p.manager.HTTPHandler(nil)
+	}
+
+	return p, nil
+}
+
+// HTTPHandler answers the http-01 challenges, other requests are redirected to https.
+// It returns nil if the http-01 challenge is disabled.
+func (p *ACMEManager) HTTPHandler() http.Handler {
+	return p.httpHandler
+}
+
+// GetConfigForClient wraps an implementation of tls.Config.GetConfigForClient,
+// the tls-alpn-01 challenges are answered before the next one is called.
+func (p *ACMEManager) GetConfigForClient(next func(*tls.ClientHelloInfo) (*tls.Config, error)) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		for _, proto := range hello.SupportedProtos {
+			if proto == acme.ALPNProto {
+				return &tls.Config{
+					GetCertificate: p.manager.GetCertificate,
+					NextProtos:     []string{acme.ALPNProto},
+				}, nil
+			}
+		}
+		return next(hello)
+	}
+}
+
+// CertKeyContents returns a content provider per domain
+func (p *ACMEManager) CertKeyContents() []*DynamicACMECertKeyContent {
+	ret := make([]*DynamicACMECertKeyContent, 0, len(p.opts.Domains))
+	for _, domain := range p.opts.Domains {
+		ret = append(ret, &DynamicACMECertKeyContent{
+			name:    fmt.Sprintf("acme::%s", domain),
+			domain:  domain,
+			manager: p.manager,
+			queue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), fmt.Sprintf("DynamicACMECert-%s", domain)),
+		})
+	}
+	return ret
+}
+
+// DynamicACMECertKeyContent provides a SNICertKeyContentProvider of a domain that can dynamically react to renewed certificates
+type DynamicACMECertKeyContent struct {
+	name    string
+	domain  string
+	manager *autocert.Manager
+
+	// certKeyPair is a certKeyContent that contains the last obtained certificate and key
+	certKeyPair atomic.Value
+
+	listeners []Listener
+
+	// queue only ever has one item, but it has nice error handling backoff/retry semantics
+	queue workqueue.RateLimitingInterface
+}
+
+var _ Notifier = &DynamicACMECertKeyContent{}
+var _ SNICertKeyContentProvider = &DynamicACMECertKeyContent{}
+var _ ControllerRunner = &DynamicACMECertKeyContent{}
+
+// AddListener adds a listener to be notified when the serving cert content changes.
+func (c *DynamicACMECertKeyContent) AddListener(listener Listener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+// loadFromCache loads the persisted certificate without talking to the ACME server.
+// The cache entry of autocert is the PEM encoded private key followed by the certificate chain.
+func (c *DynamicACMECertKeyContent) loadFromCache() error {
+	data, err := c.manager.Cache.Get(context.Background(), c.domain)
+	if err != nil {
+		return fmt.Errorf("certificate of %q is not obtained yet: %v", c.domain, err)
+	}
+
+	var cert, key []byte
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch {
+		case strings.Contains(block.Type, "PRIVATE KEY"):
+			key = pem.EncodeToMemory(block)
+		case block.Type == "CERTIFICATE":
+			cert = append(cert, pem.EncodeToMemory(block)...)
+		}
+	}
+
+	return c.setCertKeyPair(cert, key)
+}
+
+// obtain returns the current certificate of the manager, which is obtained or renewed if needed.
+func (c *DynamicACMECertKeyContent) obtain() error {
+	tlsCert, err := c.manager.GetCertificate(&tls.ClientHelloInfo{
+		ServerName: c.domain,
+		// ask for an ecdsa certificate
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	})
+	if err != nil {
+		return err
+	}
+
+	var cert []byte
+	for _, der := range tlsCert.Certificate {
+		cert = append(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	key, err := keyutil.MarshalPrivateKeyToPEM(tlsCert.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	return c.setCertKeyPair(cert, key)
+}
+
+func (c *DynamicACMECertKeyContent) setCertKeyPair(cert, key []byte) error {
+	if len(cert) == 0 || len(key) == 0 {
+		return fmt.Errorf("missing content for serving cert %q", c.Name())
+	}
+
+	// Ensure that the key matches the cert and both are valid
+	if _, err := tls.X509KeyPair(cert, key); err != nil {
+		return err
+	}
+
+	// check to see if we have a change. If the values are the same, do nothing.
+	newCertKey := &certKeyContent{cert: cert, key: key}
+	if existing, ok := c.certKeyPair.Load().(*certKeyContent); ok && existing != nil && existing.Equal(newCertKey) {
+		return nil
+	}
+
+	c.certKeyPair.Store(newCertKey)
+	klog.V(2).InfoS("Loaded a new cert/key pair", "name", c.Name())
+
+	for _, listener := range c.listeners {
+		listener.Enqueue()
+	}
+
+	return nil
+}
+
+// RunOnce loads the persisted certificate, the certificate is obtained from the ACME server in Run,
+// after the server starts to answer the challenges.
+func (c *DynamicACMECertKeyContent) RunOnce() error {
+	return c.loadFromCache()
+}
+
+// Run starts the controller and blocks until stopCh is closed.
+func (c *DynamicACMECertKeyContent) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.InfoS("Starting controller", "name", c.name)
+	defer klog.InfoS("Shutting down controller", "name", c.name)
+
+	// doesn't matter what workers say, only start one.
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	// the manager renews the certificate in the background, recheck every minute to pick it up.
+	go wait.PollImmediateUntil(FileRefreshDuration, func() (bool, error) {
+		c.queue.Add(workItemKey)
+		return false, nil
+	}, stopCh)
+
+	<-stopCh
+}
+
+func (c *DynamicACMECertKeyContent) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *DynamicACMECertKeyContent) processNextWorkItem() bool {
+	dsKey, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(dsKey)
+
+	err := c.obtain()
+	if err == nil {
+		c.queue.Forget(dsKey)
+		return true
+	}
+
+	utilruntime.HandleError(fmt.Errorf("%v failed with : %v", dsKey, err))
+	c.queue.AddRateLimited(dsKey)
+
+	return true
+}
+
+// Name is just an identifier
+func (c *DynamicACMECertKeyContent) Name() string {
+	return c.name
+}
+
+// CurrentCertKeyContent provides cert and key byte content
+func (c *DynamicACMECertKeyContent) CurrentCertKeyContent() ([]byte, []byte) {
+	certKeyContent, ok := c.certKeyPair.Load().(*certKeyContent)
+	if !ok || certKeyContent == nil {
+		return nil, nil
+	}
+	return certKeyContent.cert, certKeyContent.key
+}
+
+// SNINames returns the domain of the certificate
+func (c *DynamicACMECertKeyContent) SNINames() []string {
+	return []string{c.domain}
+}
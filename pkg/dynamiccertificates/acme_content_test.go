@@ -0,0 +1,475 @@
+package dynamiccertificates
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// acmeServer is a minimal RFC 8555 CA, in the spirit of pebble.
+// The JWS signatures are not verified, the challenges are validated against
+// the addresses registered with resolve.
+type acmeServer struct {
+	*httptest.Server
+	t              *testing.T
+	challengeTypes []string
+	validity       time.Duration
+
+	caKey  *ecdsa.PrivateKey
+	caCert *x509.Certificate
+
+	mu         sync.Mutex
+	seq        int
+	thumbprint string                        // of the account key
+	addrs      map[string]string             // challenge type/domain -> addr
+	orders     map[string]*acmeOrder         // id -> order
+	authzs     map[string]*acmeAuthorization // id -> authz
+	certs      map[string][]byte             // id -> pem chain
+	issued     int
+}
+
+type acmeOrder struct {
+	Status         string         `json:"status"`
+	Identifiers    []acme.AuthzID `json:"identifiers"`
+	Authorizations []string       `json:"authorizations"`
+	Finalize       string         `json:"finalize"`
+	Certificate    string         `json:"certificate,omitempty"`
+	authzs         []*acmeAuthorization
+}
+
+type acmeAuthorization struct {
+	Status     string          `json:"status"`
+	Identifier acme.AuthzID    `json:"identifier"`
+	Challenges []acmeChallenge `json:"challenges"`
+}
+
+type acmeChallenge struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Token  string `json:"token"`
+	Status string `json:"status"`
+}
+
+func newACMEServer(t *testing.T, validity time.Duration, challengeTypes ...string) *acmeServer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "acme test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	s := &acmeServer{
+		t:              t,
+		challengeTypes: challengeTypes,
+		validity:       validity,
+		caKey:          key,
+		caCert:         cert,
+		addrs:          map[string]string{},
+		orders:         map[string]*acmeOrder{},
+		authzs:         map[string]*acmeAuthorization{},
+		certs:          map[string][]byte{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// resolve registers the address which answers the challenges of the type for the domain
+func (s *acmeServer) resolve(typ, domain, addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addrs[typ+"/"+domain] = addr
+}
+
+func (s *acmeServer) issuedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issued
+}
+
+func (s *acmeServer) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.caCert)
+	return pool
+}
+
+func (s *acmeServer) nextID() string {
+	s.seq++
+	return fmt.Sprintf("%d", s.seq)
+}
+
+func (s *acmeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+
+	if r.URL.Path == "/dir" {
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   s.URL + "/nonce",
+			"newAccount": s.URL + "/account",
+			"newOrder":   s.URL + "/order",
+			"revokeCert": s.URL + "/revoke",
+			"keyChange":  s.URL + "/key-change",
+		})
+		return
+	}
+	if r.URL.Path == "/nonce" {
+		return
+	}
+
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+	}
+	require.NoError(s.t, json.NewDecoder(r.Body).Decode(&jws))
+	protected, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	require.NoError(s.t, err)
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	require.NoError(s.t, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/account":
+		var header struct {
+			JWK jose.JSONWebKey `json:"jwk"`
+		}
+		require.NoError(s.t, json.Unmarshal(protected, &header))
+		tp, err := header.JWK.Thumbprint(crypto.SHA256)
+		require.NoError(s.t, err)
+		s.thumbprint = base64.RawURLEncoding.EncodeToString(tp)
+
+		w.Header().Set("Location", s.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": "valid"})
+
+	case r.URL.Path == "/order":
+		var req struct {
+			Identifiers []acme.AuthzID `json:"identifiers"`
+		}
+		require.NoError(s.t, json.Unmarshal(payload, &req))
+
+		id := s.nextID()
+		o := &acmeOrder{Status: "pending", Identifiers: req.Identifiers, Finalize: s.URL + "/finalize/" + id}
+		for _, ident := range req.Identifiers {
+			z := s.authz(ident)
+			o.authzs = append(o.authzs, z)
+		}
+		s.orders[id] = o
+		s.updateOrder(o)
+
+		w.Header().Set("Location", s.URL+"/order/"+id)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(o)
+
+	case len(parts) == 2 && parts[0] == "order":
+		o := s.orders[parts[1]]
+		s.updateOrder(o)
+		w.Header().Set("Location", s.URL+"/order/"+parts[1])
+		json.NewEncoder(w).Encode(o)
+
+	case len(parts) == 2 && parts[0] == "authz":
+		json.NewEncoder(w).Encode(s.authzs[parts[1]])
+
+	case len(parts) == 3 && parts[0] == "challenge":
+		z := s.authzs[parts[2]]
+		for i := range z.Challenges {
+			chal := &z.Challenges[i]
+			if chal.Type != parts[1] {
+				continue
+			}
+			if err := s.validate(chal, z.Identifier.Value); err != nil {
+				s.t.Logf("validate %s challenge of %s: %v", chal.Type, z.Identifier.Value, err)
+				chal.Status, z.Status = "invalid", "invalid"
+			} else {
+				chal.Status, z.Status = "valid", "valid"
+			}
+			json.NewEncoder(w).Encode(chal)
+		}
+
+	case len(parts) == 2 && parts[0] == "finalize":
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		require.NoError(s.t, json.Unmarshal(payload, &req))
+		o := s.orders[parts[1]]
+		s.updateOrder(o)
+		if o.Status != "ready" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		s.certs[parts[1]] = s.issue(req.CSR)
+		o.Status, o.Certificate = "valid", s.URL+"/cert/"+parts[1]
+		w.Header().Set("Location", s.URL+"/order/"+parts[1])
+		json.NewEncoder(w).Encode(o)
+
+	case len(parts) == 2 && parts[0] == "cert":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(s.certs[parts[1]])
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// authz returns the valid authorization of the identifier, or a new pending one
+func (s *acmeServer) authz(ident acme.AuthzID) *acmeAuthorization {
+	for _, z := range s.authzs {
+		if z.Identifier == ident && z.Status == "valid" {
+			return z
+		}
+	}
+
+	id := s.nextID()
+	z := &acmeAuthorization{Status: "pending", Identifier: ident}
+	for _, typ := range s.challengeTypes {
+		z.Challenges = append(z.Challenges, acmeChallenge{
+			Type:   typ,
+			URL:    s.URL + "/challenge/" + typ + "/" + id,
+			Token:  "token-" + id,
+			Status: "pending",
+		})
+	}
+	s.authzs[id] = z
+	return z
+}
+
+func (s *acmeServer) updateOrder(o *acmeOrder) {
+	o.Authorizations = nil
+	ready := true
+	for _, z := range o.authzs {
+		for id, v := range s.authzs {
+			if v == z {
+				o.Authorizations = append(o.Authorizations, s.URL+"/authz/"+id)
+			}
+		}
+		if z.Status != "valid" {
+			ready = false
+		}
+	}
+	if ready && o.Status == "pending" {
+		o.Status = "ready"
+	}
+}
+
+func (s *acmeServer) validate(chal *acmeChallenge, domain string) error {
+	addr, ok := s.addrs[chal.Type+"/"+domain]
+	if !ok {
+		return fmt.Errorf("no address")
+	}
+	keyAuth := chal.Token + "." + s.thumbprint
+
+	switch chal.Type {
+	case "http-01":
+		req, _ := http.NewRequest("GET", "http://"+addr+"/.well-known/acme-challenge/"+chal.Token, nil)
+		req.Host = domain
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		if strings.TrimSpace(string(b)) != keyAuth {
+			return fmt.Errorf("unexpected key authorization %q", b)
+		}
+		return nil
+	case "tls-alpn-01":
+		conn, err := tls.Dial("tcp", addr, &tls.Config{
+			ServerName:         domain,
+			NextProtos:         []string{acme.ALPNProto},
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		sum := sha256.Sum256([]byte(keyAuth))
+		want, _ := asn1.Marshal(sum[:])
+		idPeACMEIdentifier := asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+		for _, ext := range conn.ConnectionState().PeerCertificates[0].Extensions {
+			if ext.Id.Equal(idPeACMEIdentifier) && string(ext.Value) == string(want) {
+				return nil
+			}
+		}
+		return fmt.Errorf("acmeIdentifier extension does not match")
+	}
+	return fmt.Errorf("unsupported challenge type %s", chal.Type)
+}
+
+func (s *acmeServer) issue(b64csr string) []byte {
+	der, err := base64.RawURLEncoding.DecodeString(b64csr)
+	require.NoError(s.t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(s.t, err)
+
+	s.issued++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(100 + s.issued)),
+		Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(s.validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, tmpl, s.caCert, csr.PublicKey, s.caKey)
+	require.NoError(s.t, err)
+
+	return append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.caCert.Raw})...)
+}
+
+// serveACME serves the https listener with the certificates of the manager, and the http-01 handler
+func serveACME(t *testing.T, manager *ACMEManager, content *DynamicACMECertKeyContent, stopCh <-chan struct{}) (tlsAddr, httpAddr string) {
+	controller := NewDynamicServingCertificateController(&tls.Config{NextProtos: []string{"h2", "http/1.1", acme.ALPNProto}}, nil, content, nil)
+	content.AddListener(controller)
+	go controller.Run(1, stopCh)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetConfigForClient: manager.GetConfigForClient(controller.GetConfigForClient),
+	})
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+
+	if handler := manager.HTTPHandler(); handler != nil {
+		hs := httptest.NewServer(handler)
+		t.Cleanup(hs.Close)
+		httpAddr = hs.Listener.Addr().String()
+	}
+
+	return ln.Addr().String(), httpAddr
+}
+
+func getServingCert(t *testing.T, addr, domain string, roots *x509.CertPool) (*x509.Certificate, error) {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: domain, RootCAs: roots})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestACMECertKeyContent(t *testing.T) {
+	for _, typ := range []string{"tls-alpn-01", "http-01"} {
+		t.Run(typ, func(t *testing.T) {
+			const domain = "api.example.com"
+			ca := newACMEServer(t, 90*24*time.Hour, typ)
+			cacheDir := t.TempDir()
+
+			opts := ACMEOptions{
+				DirectoryURL: ca.URL + "/dir",
+				Domains:      []string{domain},
+				CacheDir:     cacheDir,
+				HTTP01:       typ == "http-01",
+			}
+			manager, err := NewACMEManager(opts)
+			require.NoError(t, err)
+			content := manager.CertKeyContents()[0]
+			assert.Equal(t, []string{domain}, content.SNINames())
+
+			// nothing is persisted yet
+			assert.Error(t, content.RunOnce())
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			tlsAddr, httpAddr := serveACME(t, manager, content, stopCh)
+			ca.resolve("tls-alpn-01", domain, tlsAddr)
+			ca.resolve("http-01", domain, httpAddr)
+
+			go content.Run(1, stopCh)
+
+			var cert *x509.Certificate
+			require.Eventually(t, func() bool {
+				cert, err = getServingCert(t, tlsAddr, domain, ca.roots())
+				return err == nil
+			}, 10*time.Second, 50*time.Millisecond)
+			assert.Equal(t, []string{domain}, cert.DNSNames)
+			assert.Equal(t, 1, ca.issuedCount())
+
+			// the certificate is loaded from the cache dir after a restart
+			manager, err = NewACMEManager(opts)
+			require.NoError(t, err)
+			restarted := manager.CertKeyContents()[0]
+			require.NoError(t, restarted.RunOnce())
+			crt, key := restarted.CurrentCertKeyContent()
+			curCrt, curKey := content.CurrentCertKeyContent()
+			assert.Equal(t, curCrt, crt)
+			assert.Equal(t, curKey, key)
+			assert.Equal(t, 1, ca.issuedCount())
+		})
+	}
+}
+
+func TestACMECertKeyContentRenewal(t *testing.T) {
+	defer func(d time.Duration) { FileRefreshDuration = d }(FileRefreshDuration)
+	FileRefreshDuration = 100 * time.Millisecond
+
+	const domain = "api.example.com"
+	// the certificates expire before RenewBefore, so they are renewed right away
+	ca := newACMEServer(t, 2*time.Hour, "tls-alpn-01")
+
+	manager, err := NewACMEManager(ACMEOptions{
+		DirectoryURL: ca.URL + "/dir",
+		Domains:      []string{domain},
+		CacheDir:     t.TempDir(),
+		RenewBefore:  3 * time.Hour,
+	})
+	require.NoError(t, err)
+	content := manager.CertKeyContents()[0]
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	tlsAddr, _ := serveACME(t, manager, content, stopCh)
+	ca.resolve("tls-alpn-01", domain, tlsAddr)
+
+	go content.Run(1, stopCh)
+
+	var first *x509.Certificate
+	require.Eventually(t, func() bool {
+		first, err = getServingCert(t, tlsAddr, domain, ca.roots())
+		return err == nil
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// the renewed certificate is served without a restart
+	assert.Eventually(t, func() bool {
+		cert, err := getServingCert(t, tlsAddr, domain, ca.roots())
+		return err == nil && cert.SerialNumber.Cmp(first.SerialNumber) != 0
+	}, 10*time.Second, 50*time.Millisecond)
+}
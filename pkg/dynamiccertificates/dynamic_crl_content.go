@@ -0,0 +1,196 @@
+package dynamiccertificates
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+
+	utilruntime "github.com/yubo/golib/util/runtime"
+	"github.com/yubo/golib/util/wait"
+	"github.com/yubo/golib/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// CRLContentProvider provides the certificate revocation lists
+type CRLContentProvider interface {
+	// Name is just an identifier
+	Name() string
+	// CurrentCRLs provides the parsed revocation lists, one per issuer.
+	CurrentCRLs() []*x509.RevocationList
+}
+
+// DynamicFileCRLContent provides a CRLContentProvider that can dynamically react to new file content.
+// The file holds one or more PEM encoded "X509 CRL" blocks, or a single DER encoded CRL.
+type DynamicFileCRLContent struct {
+	name string
+
+	// filename is the name the file to read.
+	filename string
+
+	// crl is a crlContent that contains the last read, non-zero length content of the file
+	crl atomic.Value
+
+	listeners []Listener
+
+	// queue only ever has one item, but it has nice error handling backoff/retry semantics
+	queue workqueue.RateLimitingInterface
+}
+
+var _ Notifier = &DynamicFileCRLContent{}
+var _ CRLContentProvider = &DynamicFileCRLContent{}
+var _ ControllerRunner = &DynamicFileCRLContent{}
+
+type crlContent struct {
+	content []byte
+	crls    []*x509.RevocationList
+}
+
+// NewDynamicCRLContentFromFile returns a CRLContentProvider based on a filename that automatically reloads content
+func NewDynamicCRLContentFromFile(purpose, filename string) (*DynamicFileCRLContent, error) {
+	if len(filename) == 0 {
+		return nil, fmt.Errorf("missing filename for crl")
+	}
+	name := fmt.Sprintf("%s::%s", purpose, filename)
+
+	ret := &DynamicFileCRLContent{
+		name:     name,
+		filename: filename,
+		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), fmt.Sprintf("DynamicCRL-%s", purpose)),
+	}
+	if err := ret.loadCRL(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// AddListener adds a listener to be notified when the CRL content changes.
+func (c *DynamicFileCRLContent) AddListener(listener Listener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+// loadCRL determines the next set of content for the file.
+func (c *DynamicFileCRLContent) loadCRL() error {
+	content, err := ioutil.ReadFile(c.filename)
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("missing content for CRL %q", c.Name())
+	}
+
+	// check to see if we have a change. If the values are the same, do nothing.
+	if existing, ok := c.crl.Load().(*crlContent); ok && bytes.Equal(existing.content, content) {
+		return nil
+	}
+
+	crls, err := parseCRLs(content)
+	if err != nil {
+		return fmt.Errorf("error loading CRL for %q: %v", c.Name(), err)
+	}
+	c.crl.Store(&crlContent{content: content, crls: crls})
+	klog.V(2).Infof("Loaded a new CRL for %q", c.Name())
+
+	for _, listener := range c.listeners {
+		listener.Enqueue()
+	}
+
+	return nil
+}
+
+func parseCRLs(content []byte) ([]*x509.RevocationList, error) {
+	if !bytes.Contains(content, []byte("-----BEGIN")) {
+		crl, err := x509.ParseRevocationList(content)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.RevocationList{crl}, nil
+	}
+
+	var crls []*x509.RevocationList
+	for rest := content; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		crls = append(crls, crl)
+	}
+	if len(crls) == 0 {
+		return nil, fmt.Errorf("no X509 CRL block found")
+	}
+	return crls, nil
+}
+
+// RunOnce runs a single sync loop
+func (c *DynamicFileCRLContent) RunOnce() error {
+	return c.loadCRL()
+}
+
+// Run starts the controller and blocks until stopCh is closed.
+func (c *DynamicFileCRLContent) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting %s", c.name)
+	defer klog.Infof("Shutting down %s", c.name)
+
+	// doesn't matter what workers say, only start one.
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	// start timer that rechecks every minute, just in case.  this also serves to prime the controller quickly.
+	go wait.PollImmediateUntil(FileRefreshDuration, func() (bool, error) {
+		c.queue.Add(workItemKey)
+		return false, nil
+	}, stopCh)
+
+	<-stopCh
+}
+
+func (c *DynamicFileCRLContent) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *DynamicFileCRLContent) processNextWorkItem() bool {
+	dsKey, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(dsKey)
+
+	err := c.loadCRL()
+	if err == nil {
+		c.queue.Forget(dsKey)
+		return true
+	}
+
+	utilruntime.HandleError(fmt.Errorf("%v failed with : %v", dsKey, err))
+	c.queue.AddRateLimited(dsKey)
+
+	return true
+}
+
+// Name is just an identifier
+func (c *DynamicFileCRLContent) Name() string {
+	return c.name
+}
+
+// CurrentCRLs provides the parsed revocation lists
+func (c *DynamicFileCRLContent) CurrentCRLs() []*x509.RevocationList {
+	if content, ok := c.crl.Load().(*crlContent); ok {
+		return content.crls
+	}
+	return nil
+}
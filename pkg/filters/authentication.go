@@ -22,6 +22,8 @@ import (
 	"time"
 
 	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	"github.com/yubo/apiserver/pkg/authentication/authenticatorfactory"
+	"github.com/yubo/apiserver/pkg/authentication/request/headerrequest"
 	genericapirequest "github.com/yubo/apiserver/pkg/request"
 	"github.com/yubo/apiserver/pkg/responsewriters"
 	apierrors "github.com/yubo/golib/api/errors"
@@ -74,6 +76,22 @@ func WithAuthentication(handler http.Handler, auth authenticator.Request, failed
 	})
 }
 
+// WithRequestHeaderClearing removes the headers of the front proxy from an authenticated request,
+// the handlers never see the headers, whichever authenticator accepted the request.
+func WithRequestHeaderClearing(handler http.Handler, requestHeaderConfig *authenticatorfactory.RequestHeaderConfig) http.Handler {
+	if requestHeaderConfig == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		headerrequest.ClearAuthenticationHeaders(req.Header,
+			requestHeaderConfig.UsernameHeaders,
+			requestHeaderConfig.GroupHeaders,
+			requestHeaderConfig.ExtraHeaderPrefixes,
+		)
+		handler.ServeHTTP(w, req)
+	})
+}
+
 func Unauthorized(s runtime.NegotiatedSerializer) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 
@@ -24,6 +24,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	"github.com/yubo/apiserver/pkg/authentication/authenticatorfactory"
+	"github.com/yubo/apiserver/pkg/authentication/request/headerrequest"
 	"github.com/yubo/apiserver/pkg/authentication/user"
 	genericapirequest "github.com/yubo/apiserver/pkg/request"
 )
@@ -179,3 +181,39 @@ func TestAuthenticateRequestError(t *testing.T) {
 
 	<-failed
 }
+
+func TestRequestHeaderClearing(t *testing.T) {
+	success := make(chan struct{})
+	requestHeaderConfig := &authenticatorfactory.RequestHeaderConfig{
+		UsernameHeaders:     headerrequest.StaticStringSlice{"X-Remote-User"},
+		GroupHeaders:        headerrequest.StaticStringSlice{"X-Remote-Group"},
+		ExtraHeaderPrefixes: headerrequest.StaticStringSlice{"X-Remote-Extra-"},
+	}
+	auth := WithAuthentication(
+		WithRequestHeaderClearing(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+			// the spoofed headers are removed although the request was authenticated by other means
+			assert.Empty(t, req.Header.Get("X-Remote-User"))
+			assert.Empty(t, req.Header.Get("X-Remote-Group"))
+			assert.Empty(t, req.Header.Get("X-Remote-Extra-Scopes"))
+			assert.Equal(t, "kept", req.Header.Get("X-Other"))
+			close(success)
+		}), requestHeaderConfig),
+		authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+			return &authenticator.Response{User: &user.DefaultInfo{Name: "bob"}}, true, nil
+		}),
+		http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			t.Errorf("unexpected call to failed")
+		}),
+		nil,
+		false,
+	)
+
+	auth.ServeHTTP(httptest.NewRecorder(), &http.Request{Header: http.Header{
+		"X-Remote-User":         {"admin"},
+		"X-Remote-Group":        {"system:masters"},
+		"X-Remote-Extra-Scopes": {"all"},
+		"X-Other":               {"kept"},
+	}})
+
+	<-success
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/yubo/apiserver/pkg/apis/certificates"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/orm"
+)
+
+// pkg/registry/certificates/certificates/storage/storage.go
+func NewCertificateSigningRequest() *CertificateSigningRequest {
+	return &CertificateSigningRequest{DB: DB()}
+}
+
+// CertificateSigningRequest implements the CertificateSigningRequest interface.
+type CertificateSigningRequest struct {
+	orm.DB
+}
+
+func (p *CertificateSigningRequest) Name() string {
+	return "certificate_signing_request"
+}
+
+func (p *CertificateSigningRequest) NewObj() interface{} {
+	return &certificates.CertificateSigningRequest{}
+}
+
+func (p *CertificateSigningRequest) Create(ctx context.Context, obj *certificates.CertificateSigningRequest) error {
+	return p.Insert(ctx, obj)
+}
+
+// Get retrieves the CertificateSigningRequest from the db for a given name.
+func (p *CertificateSigningRequest) Get(ctx context.Context, name string) (ret *certificates.CertificateSigningRequest, err error) {
+	err = p.Query(ctx, "select * from certificate_signing_request where name=?", name).Row(&ret)
+	return
+}
+
+// List lists all CertificateSigningRequests in the indexer.
+func (p *CertificateSigningRequest) List(ctx context.Context, o api.GetListOptions) (list []*certificates.CertificateSigningRequest, err error) {
+	err = p.DB.List(ctx, &list,
+		orm.WithTable(p.Name()),
+		orm.WithTotal(o.Total),
+		orm.WithSelector(o.Query),
+		orm.WithOrderby(o.Orderby...),
+		orm.WithLimit(o.Offset, o.Limit),
+	)
+	return
+}
+
+func (p *CertificateSigningRequest) Update(ctx context.Context, obj *certificates.CertificateSigningRequest) error {
+	return p.DB.Update(ctx, obj)
+}
+
+// UpdateStatus updates the status if the resourceVersion is unchanged since obj was read,
+// the resourceVersion of obj is set to the new one.
+func (p *CertificateSigningRequest) UpdateStatus(ctx context.Context, obj *certificates.CertificateSigningRequest) error {
+	status, err := json.Marshal(obj.Status)
+	if err != nil {
+		return err
+	}
+
+	version, _ := strconv.ParseInt(obj.ResourceVersion, 10, 64)
+	next := strconv.FormatInt(version+1, 10)
+
+	if err := p.ExecNumErr(ctx, "update certificate_signing_request set status=?, resource_version=? where name=? and resource_version=?",
+		status, next, obj.Name, obj.ResourceVersion); err != nil {
+		return err
+	}
+
+	obj.ResourceVersion = next
+	return nil
+}
+
+func (p *CertificateSigningRequest) Delete(ctx context.Context, name string) error {
+	_, err := p.Exec(ctx, "delete from certificate_signing_request where name=?", name)
+	return err
+}
+
+func init() {
+	Register(&CertificateSigningRequest{})
+}
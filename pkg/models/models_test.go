@@ -6,9 +6,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/yubo/apiserver/pkg/apis/certificates"
 	"github.com/yubo/apiserver/pkg/apis/rbac"
 	dbstore "github.com/yubo/apiserver/pkg/storage/db"
 	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
 	"github.com/yubo/golib/orm"
 
 	_ "github.com/yubo/golib/orm/mysql"
@@ -91,3 +93,39 @@ func TestRole(t *testing.T) {
 		})
 	})
 }
+
+func TestCertificateSigningRequestUpdateStatus(t *testing.T) {
+	db, err := orm.Open("sqlite3", "file:csr.db?cache=shared&mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.TODO()
+	csrs := &CertificateSigningRequest{DB: db}
+	dbstore.New(db).AutoMigrate(ctx, csrs.Name(), csrs.NewObj())
+
+	err = csrs.Create(ctx, &certificates.CertificateSigningRequest{
+		ObjectMeta: api.ObjectMeta{Name: "test-csr"},
+	})
+	assert.NoError(t, err)
+
+	// two decisions read the same request, the second one is rejected
+	a, err := csrs.Get(ctx, "test-csr")
+	assert.NoError(t, err)
+	b, err := csrs.Get(ctx, "test-csr")
+	assert.NoError(t, err)
+
+	a.Status.Conditions = []certificates.CertificateSigningRequestCondition{{Type: certificates.CertificateApproved}}
+	assert.NoError(t, csrs.UpdateStatus(ctx, a))
+	assert.Equal(t, "1", a.ResourceVersion)
+
+	b.Status.Conditions = []certificates.CertificateSigningRequestCondition{{Type: certificates.CertificateDenied}}
+	assert.True(t, errors.IsNotFound(csrs.UpdateStatus(ctx, b)))
+
+	ret, err := csrs.Get(ctx, "test-csr")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", ret.ResourceVersion)
+	assert.True(t, certificates.IsCertificateRequestApproved(ret))
+	assert.False(t, certificates.IsCertificateRequestDenied(ret))
+}
@@ -60,6 +60,7 @@ type Process struct {
 
 	sigsCh  chan os.Signal
 	hookOps [v1.ACTION_SIZE]v1.Hooks // catalog of RegisterHooks
+	cmds    []*cobra.Command         // catalog of RegisterCommand
 	status  v1.ProcessStatus
 	err     error
 
@@ -156,6 +157,11 @@ func RegisterHooks(in []v1.HookOps) error {
 	return DefaultProcess.RegisterHooks(in)
 }
 
+// RegisterCommand adds the sub commands of the modules to the root command
+func RegisterCommand(cmds ...*cobra.Command) {
+	DefaultProcess.RegisterCommand(cmds...)
+}
+
 func Configer() configer.ParsedConfiger {
 	return DefaultProcess.parsedConfiger
 }
@@ -211,6 +217,11 @@ func (p *Process) RegisterHooks(in []v1.HookOps) error {
 	return nil
 }
 
+// RegisterCommand adds the sub commands to the command created by NewRootCmd or NewCmd
+func (p *Process) RegisterCommand(cmds ...*cobra.Command) {
+	p.cmds = append(p.cmds, cmds...)
+}
+
 // with proc.Start
 func (p *Process) NewRootCmd(opts ...ProcessOption) *cobra.Command {
 	rand.Seed(time.Now().UnixNano())
@@ -239,6 +250,7 @@ func (p *Process) NewRootCmd(opts ...ProcessOption) *cobra.Command {
 	}
 
 	p.Init(cmd)
+	cmd.AddCommand(p.cmds...)
 
 	return cmd
 }
@@ -268,6 +280,7 @@ func (p *Process) NewCmd(opts ...ProcessOption) *cobra.Command {
 	}
 
 	p.Init(cmd)
+	cmd.AddCommand(p.cmds...)
 
 	return cmd
 }
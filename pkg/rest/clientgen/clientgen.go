@@ -0,0 +1,484 @@
+// Package clientgen generates the typed go client of the routes built by
+// rest.WsRouteBuild, the param, body and output types are taken from the route
+// handles, and the methods are built on pkg/client.Request, e.g.
+//
+//	rest.WsRouteBuild(&rest.WsOption{...})
+//	clientgen.Generate(w, &clientgen.Config{Package: "user"}, rest.WsOptions()...)
+//
+// The types of the package main can not be imported, they are declared in the
+// generated file without the methods.
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"reflect"
+	goruntime "runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/apiserver/pkg/scheme"
+	"github.com/yubo/golib/util/errors"
+)
+
+type Config struct {
+	// Package is the package name of the generated file
+	Package string
+	// Client is the type name of the client, defaults to Client
+	Client string
+}
+
+func (p *Config) Validate() error {
+	if p.Package == "" {
+		return errors.New("package must be set")
+	}
+	if p.Client == "" {
+		p.Client = "Client"
+	}
+	return nil
+}
+
+// Generate writes the client of the routes of the options to w
+func Generate(w io.Writer, config *Config, options ...*rest.WsOption) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	g := newGenerator(config)
+	for _, opt := range options {
+		for i := range opt.Routes {
+			if err := g.addRoute(opt, &opt.Routes[i]); err != nil {
+				return errors.Wrapf(err, "%s %s%s", opt.Routes[i].Method, opt.Path, opt.Routes[i].SubPath)
+			}
+		}
+	}
+
+	b, err := g.generate()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+type method struct {
+	Name   string
+	Desc   string
+	Method string
+	Path   string
+	Param  string
+	Body   string
+	Output string
+	// OutputPtr is true if the output is returned as a pointer
+	OutputPtr bool
+}
+
+type importSpec struct {
+	Path  string
+	Name  string
+	Alias string
+}
+
+type decl struct {
+	Name string
+	Type string
+}
+
+type generator struct {
+	config  *Config
+	imports map[string]*importSpec
+	aliases map[string]bool
+	types   map[reflect.Type]*decl
+	decls   []*decl
+	methods []*method
+	names   map[string]bool
+}
+
+func newGenerator(config *Config) *generator {
+	g := &generator{
+		config:  config,
+		imports: map[string]*importSpec{},
+		aliases: map[string]bool{},
+		types:   map[reflect.Type]*decl{},
+		names:   map[string]bool{},
+	}
+
+	// the packages of the generated code
+	g.importAlias("context", "context")
+	g.importAlias("io", "io")
+	g.importAlias("github.com/yubo/apiserver/pkg/client", "client")
+	g.importAlias("github.com/yubo/apiserver/pkg/scheme", "scheme")
+	g.importAlias("github.com/yubo/client-go/rest", "rest")
+
+	return g
+}
+
+func (g *generator) addRoute(opt *rest.WsOption, route *rest.WsRoute) error {
+	if route.Handle == nil {
+		return nil
+	}
+
+	// the options not built yet have no codec and web service
+	codec, rootPath := opt.ParameterCodec, opt.Path
+	if codec == nil {
+		codec = scheme.ParameterCodec
+	}
+	if opt.Ws != nil {
+		rootPath = opt.Ws.RootPath()
+	}
+
+	rh, err := rest.NewRouteHandle(route.Handle, codec, scheme.NegotiatedSerializer, rest.DefaultRespWriter)
+	if err != nil {
+		return err
+	}
+
+	param, body, output := rh.Param(), rh.Body(), rh.Output()
+	if route.InputParam != nil {
+		param = indirect(reflect.TypeOf(route.InputParam))
+	}
+	if route.InputBody != nil {
+		body = indirect(reflect.TypeOf(route.InputBody))
+	}
+	if route.Output != nil {
+		output = indirect(reflect.TypeOf(route.Output))
+	}
+
+	name := route.Operation
+	if name == "" {
+		name = handleName(route.Handle)
+	}
+	name = exportedName(name)
+	if g.names[name] {
+		name += exportedName(strings.ToLower(route.Method))
+	}
+	if g.names[name] || name == "New"+g.config.Client {
+		return errors.Errorf("duplicate method %s, set the operation of the route", name)
+	}
+	g.names[name] = true
+
+	m := &method{
+		Name:   name,
+		Desc:   strings.TrimSpace(route.Desc),
+		Method: httpMethod(route.Method),
+		Path:   joinPath(rootPath, opt.PrefixPath+route.SubPath),
+	}
+	if param != nil {
+		if m.Param, err = g.typeExpr(param); err != nil {
+			return err
+		}
+	}
+	if body != nil {
+		if m.Body, err = g.typeExpr(body); err != nil {
+			return err
+		}
+	}
+	if output != nil {
+		if m.Output, err = g.typeExpr(output); err != nil {
+			return err
+		}
+		m.OutputPtr = output.Kind() == reflect.Struct
+	}
+
+	g.methods = append(g.methods, m)
+	return nil
+}
+
+// typeExpr returns the expression of the type in the generated file
+func (g *generator) typeExpr(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		if strings.ContainsAny(t.Name(), "[]") {
+			return "", errors.Errorf("generic type %s is not supported", t)
+		}
+		if t.PkgPath() == "" {
+			return t.Name(), nil
+		}
+		if t.PkgPath() == "main" {
+			return g.declare(t)
+		}
+		// the package name is the qualifier of the type string, e.g. api.PageParams
+		name := strings.TrimSuffix(t.String(), "."+t.Name())
+		return g.importAlias(t.PkgPath(), name) + "." + t.Name(), nil
+	}
+
+	return g.underlyingExpr(t)
+}
+
+func (g *generator) underlyingExpr(t reflect.Type) (string, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := g.typeExpr(t.Elem())
+		return "*" + elem, err
+	case reflect.Slice:
+		elem, err := g.typeExpr(t.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := g.typeExpr(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case reflect.Map:
+		key, err := g.typeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.typeExpr(t.Elem())
+		return fmt.Sprintf("map[%s]%s", key, elem), err
+	case reflect.Struct:
+		return g.structExpr(t)
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			return "", errors.Errorf("interface %s is not supported", t)
+		}
+		return "interface{}", nil
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return "", errors.Errorf("type %s is not supported", t)
+	default:
+		return t.Kind().String(), nil
+	}
+}
+
+func (g *generator) structExpr(t reflect.Type) (string, error) {
+	if t.NumField() == 0 {
+		return "struct{}", nil
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("struct {\n")
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+
+		expr, err := g.typeExpr(f.Type)
+		if err != nil {
+			return "", errors.Wrapf(err, "field %s", f.Name)
+		}
+		if !f.Anonymous {
+			buf.WriteString(f.Name + " ")
+		}
+		buf.WriteString(expr)
+		if tag := string(f.Tag); tag != "" {
+			if strings.Contains(tag, "`") {
+				buf.WriteString(" " + strconv.Quote(tag))
+			} else {
+				buf.WriteString(" `" + tag + "`")
+			}
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}")
+
+	return buf.String(), nil
+}
+
+// declare declares the type of the package main in the generated file
+func (g *generator) declare(t reflect.Type) (string, error) {
+	if d, ok := g.types[t]; ok {
+		return d.Name, nil
+	}
+
+	d := &decl{Name: t.Name()}
+	g.types[t] = d
+	g.decls = append(g.decls, d)
+
+	expr, err := g.underlyingExpr(t)
+	if err != nil {
+		return "", err
+	}
+	d.Type = expr
+
+	return d.Name, nil
+}
+
+// importAlias returns the alias of the package, the name of the package with
+// a number suffix if it is used by another package
+func (g *generator) importAlias(path, name string) string {
+	if spec, ok := g.imports[path]; ok {
+		return spec.Alias
+	}
+
+	alias := name
+	for i := 2; g.aliases[alias]; i++ {
+		alias = name + strconv.Itoa(i)
+	}
+
+	g.imports[path] = &importSpec{Path: path, Name: name, Alias: alias}
+	g.aliases[alias] = true
+	return alias
+}
+
+func (g *generator) generate() ([]byte, error) {
+	// io.Discard is the output of the routes which return error only
+	discard := false
+	for _, m := range g.methods {
+		if m.Output == "" {
+			discard = true
+		}
+	}
+
+	// the standard packages, then the others
+	var std, imports []*importSpec
+	for path, spec := range g.imports {
+		switch {
+		case path == "io" && !discard:
+		case !strings.Contains(strings.Split(path, "/")[0], "."):
+			std = append(std, spec)
+		default:
+			imports = append(imports, spec)
+		}
+	}
+	sort.Slice(std, func(i, j int) bool { return std[i].Path < std[j].Path })
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Path < imports[j].Path })
+
+	buf := &bytes.Buffer{}
+	if err := clientTemplate.Execute(buf, map[string]interface{}{
+		"Package": g.config.Package,
+		"Client":  g.config.Client,
+		"Std":     std,
+		"Imports": imports,
+		"Decls":   g.decls,
+		"Methods": g.methods,
+	}); err != nil {
+		return nil, err
+	}
+
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "format the generated client")
+	}
+	return b, nil
+}
+
+var clientTemplate = template.Must(template.New("client").Parse(`// Code generated by clientgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Std}}
+	{{if ne .Alias .Name}}{{.Alias}} {{end}}"{{.Path}}"
+{{- end}}
+{{range .Imports}}
+	{{if ne .Alias .Name}}{{.Alias}} {{end}}"{{.Path}}"
+{{- end}}
+)
+
+{{range .Decls}}
+type {{.Name}} {{.Type}}
+{{end}}
+
+// {{.Client}} is the typed client of the routes
+type {{.Client}} struct {
+	client *rest.RESTClient
+}
+
+func New{{.Client}}(config *rest.Config) (*{{.Client}}, error) {
+	if config.NegotiatedSerializer == nil {
+		c := *config
+		c.NegotiatedSerializer = scheme.Codecs
+		config = &c
+	}
+
+	c, err := rest.RESTClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Client}}{client: c}, nil
+}
+{{range .Methods}}
+{{- if .Desc}}
+// {{.Name}} {{.Desc}}
+{{- end}}
+func (p *{{$.Client}}) {{.Name}}(ctx context.Context{{if .Param}}, param *{{.Param}}{{end}}{{if .Body}}, body *{{.Body}}{{end}}) {{if .Output}}({{if .OutputPtr}}*{{end}}{{.Output}}, error){{else}}error{{end}} {
+{{- if .Output}}
+	{{if .OutputPtr}}output := &{{.Output}}{}{{else}}var output {{.Output}}{{end}}
+{{- end}}
+	err := client.NewRequestWithClient(p.client,
+		client.WithMethod("{{.Method}}"),
+		client.WithPath("{{.Path}}"),
+{{- if .Param}}
+		client.WithParams(param),
+{{- end}}
+{{- if .Body}}
+		client.WithBody(body),
+{{- end}}
+{{- if .Output}}
+		client.WithOutput({{if not .OutputPtr}}&{{end}}output),
+{{- else}}
+		client.WithOutput(io.Discard),
+{{- end}}
+	).Do(ctx)
+{{- if .Output}}
+{{- if .OutputPtr}}
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+{{- else}}
+	return output, err
+{{- end}}
+{{- else}}
+	return err
+{{- end}}
+}
+{{end}}`))
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// handleName returns the name of the handle func, e.g. createUser of
+// main.(*Module).createUser-fm
+func handleName(handle interface{}) string {
+	name := goruntime.FuncForPC(reflect.ValueOf(handle).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// exportedName returns the camel case name with the first letter in upper case
+func exportedName(name string) string {
+	var buf strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func httpMethod(method string) string {
+	switch strings.ToUpper(method) {
+	case "GET", "LIST":
+		return "GET"
+	case "POST", "CREATE":
+		return "POST"
+	case "PUT", "UPDATE":
+		return "PUT"
+	default:
+		return strings.ToUpper(method)
+	}
+}
+
+// joinPath joins the paths like the routes of the web service
+func joinPath(root, subPath string) string {
+	p := strings.TrimSuffix(root, "/") + "/" + strings.TrimPrefix(subPath, "/")
+	for strings.Contains(p, "//") {
+		p = strings.ReplaceAll(p, "//", "/")
+	}
+	return p
+}
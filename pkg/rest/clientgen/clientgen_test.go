@@ -0,0 +1,103 @@
+package clientgen
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/golib/api"
+)
+
+type GetUserParam struct {
+	Name string `param:"path" name:"user-name"`
+}
+
+type ListUsersParam struct {
+	api.PageParams
+	Query *string `param:"query" name:"query"`
+}
+
+type User struct {
+	Name  string  `json:"name"`
+	Phone *string `json:"phone"`
+}
+
+type users struct{}
+
+func (p *users) create(w http.ResponseWriter, req *http.Request, in *User) (*User, error) {
+	return in, nil
+}
+
+func (p *users) list(w http.ResponseWriter, req *http.Request, param *ListUsersParam) ([]User, error) {
+	return nil, nil
+}
+
+func (p *users) update(w http.ResponseWriter, req *http.Request, param *GetUserParam, body *User) (*User, error) {
+	return body, nil
+}
+
+func (p *users) delete(w http.ResponseWriter, req *http.Request, param *GetUserParam) error {
+	return nil
+}
+
+func TestGenerate(t *testing.T) {
+	p := &users{}
+	opt := &rest.WsOption{
+		Path:               "/api/users",
+		GoRestfulContainer: rest.NewBaseContainer(),
+		Routes: []rest.WsRoute{
+			{Method: "POST", SubPath: "/", Desc: "create user", Handle: p.create},
+			{Method: "GET", SubPath: "/", Operation: "list-users", Handle: p.list},
+			{Method: "PUT", SubPath: "/{user-name}", Handle: p.update},
+			{Method: "DELETE", SubPath: "/{user-name}", Handle: p.delete},
+			{Method: "GET", SubPath: "/none"},
+		},
+	}
+	rest.WsRouteBuild(opt)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Generate(buf, &Config{Package: "users", Client: "UserClient"}, opt))
+	src := buf.String()
+
+	_, err := parser.ParseFile(token.NewFileSet(), "client.go", src, 0)
+	require.NoError(t, err, src)
+
+	for _, s := range []string{
+		"package users",
+		`"github.com/yubo/apiserver/pkg/rest/clientgen"`,
+		"func NewUserClient(config *rest.Config) (*UserClient, error) {",
+		"// Create create user",
+		"func (p *UserClient) Create(ctx context.Context, body *clientgen.User) (*clientgen.User, error) {",
+		`client.WithPath("/api/users/"),`,
+		"func (p *UserClient) ListUsers(ctx context.Context, param *clientgen.ListUsersParam) ([]clientgen.User, error) {",
+		"client.WithOutput(&output),",
+		"func (p *UserClient) Update(ctx context.Context, param *clientgen.GetUserParam, body *clientgen.User) (*clientgen.User, error) {",
+		`client.WithPath("/api/users/{user-name}"),`,
+		"func (p *UserClient) Delete(ctx context.Context, param *clientgen.GetUserParam) error {",
+		"client.WithOutput(io.Discard),",
+	} {
+		require.Contains(t, src, s)
+	}
+	require.NotContains(t, src, "None")
+
+	// duplicate methods
+	opt = &rest.WsOption{
+		Path:               "/api/users2",
+		GoRestfulContainer: rest.NewBaseContainer(),
+		Routes: []rest.WsRoute{
+			{Method: "POST", SubPath: "/a", Handle: p.create},
+			{Method: "PUT", SubPath: "/a", Handle: p.create},
+			{Method: "PUT", SubPath: "/b", Handle: p.create},
+		},
+	}
+	rest.WsRouteBuild(opt)
+	buf.Reset()
+	err = Generate(buf, &Config{Package: "users"}, &rest.WsOption{Ws: opt.Ws, Routes: opt.Routes[:2]})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "func (p *Client) CreatePut(")
+	require.Error(t, Generate(&bytes.Buffer{}, &Config{Package: "users"}, opt))
+}
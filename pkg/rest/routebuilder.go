@@ -37,6 +37,11 @@ func WsRouteBuild(opt *WsOption) {
 	defaultWebServiceBuilder.Build(opt)
 }
 
+// WsOptions returns the options built by WsRouteBuild, e.g. to generate the clients
+func WsOptions() []*WsOption {
+	return defaultWebServiceBuilder.WsOptions()
+}
+
 func SetDefaultAclManager(m AclManager) {
 	defaultWebServiceBuilder.WithAclManager(m)
 }
@@ -94,11 +99,18 @@ type WebServiceBuilder struct {
 	securitySchemeCatalog map[string]*spec.SecurityScheme
 	respWriterCatalog     map[string]RespWriter
 	swaggerTags           []spec.Tag
+	wsOptions             []*WsOption
 	AclManager            AclManager
 }
 
 func (p *WebServiceBuilder) Build(opt *WsOption) {
 	p.newBuilder(opt).build()
+	p.wsOptions = append(p.wsOptions, opt)
+}
+
+// WsOptions returns the options built, in the order of the registrations
+func (p *WebServiceBuilder) WsOptions() []*WsOption {
+	return p.wsOptions
 }
 
 func (p *WebServiceBuilder) newBuilder(opts *WsOption) *webserviceBuilder {
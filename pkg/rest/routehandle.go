@@ -171,6 +171,15 @@ func (p *routeHandle) initHandleIO() error {
 	return nil
 }
 
+// Param returns the type of the request param, nil if the handle has no param
+func (p *routeHandle) Param() reflect.Type { return p.param }
+
+// Body returns the type of the request body, nil if the handle has no body
+func (p *routeHandle) Body() reflect.Type { return p.body }
+
+// Output returns the type of the response, nil if the handle returns error only
+func (p *routeHandle) Output() reflect.Type { return p.out }
+
 func (p *routeHandle) isParam(rt reflect.Type) bool {
 	return p.parameterCodec.ValidateParamType(rt) == nil
 }
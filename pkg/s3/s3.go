@@ -10,6 +10,7 @@ import (
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/yubo/apiserver/pkg/config/configtls"
+	"github.com/yubo/golib/api/errors"
 	"k8s.io/klog/v2"
 )
 
@@ -38,6 +39,10 @@ tls:
 
 type S3Client interface {
 	Put(ctx context.Context, objectPath, contentType string, reader io.Reader, objectSize int64) error
+	// Get returns the object, a NotFound error if it doesn't exist
+	Get(ctx context.Context, objectPath string) (io.ReadCloser, error)
+	// List returns the paths of the objects with the prefix, recursively
+	List(ctx context.Context, prefix string) ([]string, error)
 	Remove(ctx context.Context, objectPath string) error
 	Location(objectPath string) string
 }
@@ -100,6 +105,35 @@ func (p *minioClient) Put(ctx context.Context, objectPath, contentType string, r
 	return err
 }
 
+func (p *minioClient) Get(ctx context.Context, objectPath string) (io.ReadCloser, error) {
+	obj, err := p.GetObject(ctx, p.bucketName, objectPath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// the errors of the object are returned by the first call
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, errors.NewNotFound(objectPath)
+		}
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+func (p *minioClient) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	for obj := range p.ListObjects(ctx, p.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		paths = append(paths, obj.Key)
+	}
+	return paths, nil
+}
+
 func (p *minioClient) Remove(ctx context.Context, objectPath string) error {
 	objectPath = strings.TrimPrefix(objectPath, p.externAddress)
 	return p.RemoveObject(ctx, p.bucketName, objectPath, minio.RemoveObjectOptions{})
@@ -172,6 +172,13 @@ type SecureServingInfo struct {
 
 	// DisableHTTP2 indicates that http2 should not be enabled.
 	DisableHTTP2 bool
+
+	// ACME, if set, obtains and renews the serving certificates, the tls-alpn-01 challenges
+	// are answered on the Listener.
+	ACME *dynamiccertificates.ACMEManager
+
+	// ACMEHTTPListener, if set, answers the http-01 challenges of ACME.
+	ACMEHTTPListener net.Listener
 }
 
 type AuthenticationInfo struct {
@@ -181,6 +188,9 @@ type AuthenticationInfo struct {
 	// Authenticator determines which subject is making the request
 	Authenticator authenticator.Request
 	Anonymous     bool
+	// RequestHeaderConfig, if set, the headers of the front proxy are removed
+	// before the request is handled
+	RequestHeaderConfig *authenticatorfactory.RequestHeaderConfig
 }
 
 func (s *SecureServingInfo) ApplyClientCert(clientCA dynamiccertificates.CAContentProvider) error {
@@ -299,7 +309,11 @@ func DefaultBuildHandlerChain(apiHandler http.Handler, s *Config) http.Handler {
 
 	failedHandler = filters.TrackCompleted(failedHandler)
 
+	// the csrf filter reads the authenticated user
+	handler = sessions.WithCSRF(handler)
+
 	handler = filters.TrackCompleted(handler)
+	handler = filters.WithRequestHeaderClearing(handler, s.Authentication.RequestHeaderConfig)
 	handler = filters.WithAuthentication(handler, s.Authentication.Authenticator, failedHandler, s.Authentication.APIAudiences, s.KeepAuthorizationHeader)
 	handler = filters.TrackStarted(handler, "authentication")
 
@@ -53,6 +53,9 @@ type SecureServingOptions struct {
 	// ServerCert is the TLS cert info for serving secure traffic
 	ServerCert GeneratableKeyCert `json:"serverCert"`
 
+	// ACME obtains the serving certificates from an ACME server, it takes precedence over the self-signed certificate.
+	ACME ACMEOptions `json:"acme"`
+
 	// SNICertKeys are named CertKeys for serving secure traffic with SNI support.
 	SNICertKeys cliflag.NamedCertKeyArray `json:"sniCertKeys" flag:"tls-sni-cert-key" description:"A pair of x509 certificate and private key file paths, optionally suffixed with a list of domain patterns which are fully qualified domain names, possibly with prefixed wildcard segments. The domain patterns also allow IP addresses, but IPs should only be used if the apiserver has visibility to the IP address requested by a client. If no domain patterns are provided, the names of the certificate are extracted. Non-wildcard matches trump over wildcard matches, explicit domain patterns trump over extracted names. For multiple key/certificate pairs, use the --tls-sni-cert-key multiple times. Examples: \"example.crt,example.key\" or \"foo.crt,foo.key:*.foo.com,foo.com\"."`
 	// CipherSuites is the list of allowed cipher suites for the server.
@@ -156,6 +159,11 @@ func (p *SecureServingOptions) Validate() error {
 		errors = append(errors, fmt.Errorf("cert/key file and in-memory certificate cannot both be set"))
 	}
 
+	if p.ACME.Enabled() && (len(p.ServerCert.CertFile) != 0 || len(p.ServerCert.KeyFile) != 0) {
+		errors = append(errors, fmt.Errorf("cert/key file and acme cannot both be set"))
+	}
+	errors = append(errors, p.ACME.Validate()...)
+
 	return utilerrors.NewAggregate(errors)
 }
 
@@ -211,6 +219,10 @@ func (p *SecureServingOptions) ApplyTo(config **server.SecureServingInfo) error
 		if err != nil {
 			return err
 		}
+	} else if p.ACME.Enabled() {
+		if err := p.ACME.applyTo(c, p.ServerCert.CertDirectory); err != nil {
+			return err
+		}
 	} else if p.ServerCert.GeneratedCert != nil {
 		c.Cert = p.ServerCert.GeneratedCert
 	}
@@ -239,7 +251,7 @@ func (p *SecureServingOptions) ApplyTo(config **server.SecureServingInfo) error
 			return fmt.Errorf("failed to load SNI cert and key: %v", err)
 		}
 	}
-	c.SNICerts = namedTLSCerts
+	c.SNICerts = append(c.SNICerts, namedTLSCerts...)
 
 	return nil
 }
@@ -249,6 +261,11 @@ func (p *SecureServingOptions) MaybeDefaultWithSelfSignedCerts(publicAddress str
 		return nil
 	}
 
+	// the certificates are obtained from the acme server
+	if p.ACME.Enabled() {
+		return nil
+	}
+
 	keyCert := &p.ServerCert
 	if len(keyCert.CertFile) != 0 || len(keyCert.KeyFile) != 0 {
 		return nil
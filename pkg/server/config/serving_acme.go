@@ -0,0 +1,101 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/dynamiccertificates"
+	"github.com/yubo/apiserver/pkg/server"
+	"github.com/yubo/golib/api"
+	utilcert "github.com/yubo/golib/util/cert"
+	utilnet "github.com/yubo/golib/util/net"
+)
+
+// ACMEOptions obtains and renews the serving certificates from an ACME server, e.g. Let's Encrypt
+type ACMEOptions struct {
+	Domains      []string     `json:"domains" flag:"acme-domains" description:"If set, the serving certificates of the domains are obtained and renewed from the ACME server, the first one is the default certificate. It can't be used with --tls-cert-file."`
+	DirectoryURL string       `json:"directoryURL" flag:"acme-directory-url" default:"https://acme-v02.api.letsencrypt.org/directory" description:"The directory URL of the ACME server."`
+	Email        string       `json:"email" flag:"acme-email" description:"The contact email of the ACME account."`
+	CacheDir     string       `json:"cacheDir" flag:"acme-cache-dir" description:"The directory to persist the ACME account and certificates. Defaults to the acme directory in --cert-dir."`
+	CAFile       string       `json:"caFile" flag:"acme-ca-file" description:"If set, the ACME server is verified by the certificates in the file instead of the system roots."`
+	HTTPAddress  string       `json:"httpAddress" flag:"acme-http-address" description:"If set, the http-01 challenges are answered on the address, e.g. :80. The tls-alpn-01 challenges are always answered on the secure port."`
+	RenewBefore  api.Duration `json:"renewBefore" default:"720h" flag:"acme-renew-before" description:"How early the certificates are renewed before they expire."`
+}
+
+func (p *ACMEOptions) Enabled() bool {
+	return p != nil && len(p.Domains) > 0
+}
+
+func (p *ACMEOptions) Validate() []error {
+	if !p.Enabled() {
+		return nil
+	}
+
+	errors := []error{}
+	if p.DirectoryURL == "" {
+		errors = append(errors, fmt.Errorf("--acme-directory-url is required with --acme-domains"))
+	}
+	for _, domain := range p.Domains {
+		if net.ParseIP(domain) != nil {
+			errors = append(errors, fmt.Errorf("--acme-domains %q: ip addresses are not supported", domain))
+		}
+	}
+	return errors
+}
+
+// applyTo sets the serving certificates and the challenge listeners
+func (p *ACMEOptions) applyTo(c *server.SecureServingInfo, certDir string) error {
+	cacheDir := p.CacheDir
+	if cacheDir == "" {
+		if certDir == "" {
+			return fmt.Errorf("--acme-cache-dir or --cert-dir is required with --acme-domains")
+		}
+		cacheDir = filepath.Join(certDir, "acme")
+	}
+
+	tlsConfig := &tls.Config{}
+	if p.CAFile != "" {
+		roots, err := utilcert.NewPool(p.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to load the acme ca file: %v", err)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	manager, err := dynamiccertificates.NewACMEManager(dynamiccertificates.ACMEOptions{
+		DirectoryURL: p.DirectoryURL,
+		Email:        p.Email,
+		Domains:      p.Domains,
+		CacheDir:     cacheDir,
+		RenewBefore:  p.RenewBefore.Duration,
+		HTTP01:       p.HTTPAddress != "",
+		HTTPClient: &http.Client{
+			Transport: utilnet.SetTransportDefaults(&http.Transport{TLSClientConfig: tlsConfig}),
+			Timeout:   time.Minute,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if p.HTTPAddress != "" {
+		ln, _, err := CreateListener("tcp", p.HTTPAddress, net.ListenConfig{})
+		if err != nil {
+			return fmt.Errorf("failed to create acme http listener: %v", err)
+		}
+		c.ACMEHTTPListener = ln
+	}
+
+	contents := manager.CertKeyContents()
+	c.ACME = manager
+	c.Cert = contents[0]
+	for _, content := range contents[1:] {
+		c.SNICerts = append(c.SNICerts, content)
+	}
+
+	return nil
+}
@@ -28,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/acme"
 	"golang.org/x/net/http2"
 	"k8s.io/klog/v2"
 
@@ -138,6 +139,11 @@ func (s *SecureServingInfo) tlsConfig(stopCh <-chan struct{}) (*tls.Config, erro
 		tlsConfig.GetConfigForClient = dynamicCertificateController.GetConfigForClient
 	}
 
+	if s.ACME != nil {
+		tlsConfig.NextProtos = append(tlsConfig.NextProtos, acme.ALPNProto)
+		tlsConfig.GetConfigForClient = s.ACME.GetConfigForClient(tlsConfig.GetConfigForClient)
+	}
+
 	return tlsConfig, nil
 }
 
@@ -194,6 +200,17 @@ func (s *SecureServingInfo) Serve(handler http.Handler, shutdownTimeout time.Dur
 	tlsErrorLogger := log.New(tlsErrorWriter, "", 0)
 	secureServer.ErrorLog = tlsErrorLogger
 
+	if s.ACMEHTTPListener != nil {
+		if _, err := RunServer(&http.Server{
+			Addr:           s.ACMEHTTPListener.Addr().String(),
+			Handler:        s.ACME.HTTPHandler(),
+			MaxHeaderBytes: 1 << 20,
+		}, s.ACMEHTTPListener, shutdownTimeout, stopCh); err != nil {
+			return nil, err
+		}
+		klog.Infof("Serving acme http-01 challenges on %s", s.ACMEHTTPListener.Addr().String())
+	}
+
 	klog.Infof("Serving securely on %s", secureServer.Addr)
 	return RunServer(secureServer, s.Listener, shutdownTimeout, stopCh)
 }
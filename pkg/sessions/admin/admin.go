@@ -0,0 +1,102 @@
+// Package admin serves the api to list and revoke the sessions of the users,
+// the access is controlled by the authorizer of the server as the "sessions" resource.
+package admin
+
+import (
+	"net/http"
+
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/apiserver/pkg/sessions"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// APIPath is the root path of the sessions api, the root of a WebService
+// must not be shared with the other services of the container
+const APIPath = "/api/v1/sessions"
+
+type Admin struct {
+	manager sessions.Manager
+}
+
+func New(manager sessions.Manager) *Admin {
+	return &Admin{manager: manager}
+}
+
+func (p *Admin) Install(container rest.GoRestfulContainer) {
+	rest.SwaggerTagRegister("sessions", "sessions Api - list and revoke the sessions of the users")
+
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               APIPath,
+		Produces:           []string{rest.MIME_JSON},
+		Tags:               []string{"sessions"},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "GET", SubPath: "/", Operation: "listSession", Desc: "list sessions", Handle: p.listSession},
+			{Method: "DELETE", SubPath: "/", Operation: "revokeUserSessions", Desc: "revoke all sessions of the user", Handle: p.revokeUserSessions},
+			{Method: "DELETE", SubPath: "/{id}", Operation: "revokeSession", Desc: "revoke the session by id", Handle: p.revokeSession},
+		},
+	})
+}
+
+type listParam struct {
+	api.PageParams
+	User  string `param:"query" description:"the name of the user"`
+	Query string `param:"query" description:"query"`
+}
+
+type userParam struct {
+	User string `param:"query" description:"the name of the user"`
+}
+
+type idParam struct {
+	ID string `param:"path" name:"id" description:"session id"`
+}
+
+type sessionListOutput struct {
+	List  []*sessions.SessionInfo `json:"list"`
+	Total int                     `json:"total"`
+}
+
+type revokeOutput struct {
+	Revoked int `json:"revoked"`
+}
+
+func (p *Admin) listSession(w http.ResponseWriter, req *http.Request, in *listParam) (*sessionListOutput, error) {
+	ret := &sessionListOutput{}
+
+	opts, err := in.GetListOptions(in.Query, &ret.Total, "last_active_at desc")
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.List, err = p.manager.ListSessions(req.Context(), in.User, *opts); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (p *Admin) revokeSession(w http.ResponseWriter, req *http.Request, in *idParam) (*revokeOutput, error) {
+	if err := p.manager.RevokeSession(req.Context(), in.ID); err != nil {
+		return nil, err
+	}
+
+	klog.InfoS("session revoked", "id", in.ID)
+	return &revokeOutput{Revoked: 1}, nil
+}
+
+func (p *Admin) revokeUserSessions(w http.ResponseWriter, req *http.Request, in *userParam) (*revokeOutput, error) {
+	if in.User == "" {
+		return nil, errors.NewBadRequest("user must be specified")
+	}
+
+	n, err := p.manager.RevokeUserSessions(req.Context(), in.User)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.InfoS("user sessions revoked", "user", in.User, "revoked", n)
+	return &revokeOutput{Revoked: n}, nil
+}
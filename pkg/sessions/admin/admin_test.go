@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/apiserver/pkg/sessions"
+	"github.com/yubo/golib/api"
+)
+
+type fakeManager struct {
+	list    []*sessions.SessionInfo
+	revoked []string
+}
+
+func (p *fakeManager) ListSessions(ctx context.Context, userName string, opts api.GetListOptions) ([]*sessions.SessionInfo, error) {
+	return p.list, nil
+}
+
+func (p *fakeManager) RevokeSession(ctx context.Context, id string) error {
+	p.revoked = append(p.revoked, id)
+	return nil
+}
+
+func (p *fakeManager) RevokeUserSessions(ctx context.Context, userName string) (int, error) {
+	return len(p.list), nil
+}
+
+func TestInstall(t *testing.T) {
+	container := rest.NewBaseContainer()
+
+	// an app which already serves /api/v1
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               "/api/v1",
+		Produces:           []string{rest.MIME_JSON},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "GET", SubPath: "/users", Handle: func(w http.ResponseWriter, req *http.Request) ([]string, error) {
+				return []string{"alice"}, nil
+			}},
+		},
+	})
+
+	manager := &fakeManager{list: []*sessions.SessionInfo{{ID: "1", UserName: "alice"}}}
+	New(manager).Install(container)
+
+	server := httptest.NewServer(container)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/users")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + APIPath + "?user=alice")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	out := &sessionListOutput{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	require.Len(t, out.List, 1)
+	assert.Equal(t, "alice", out.List[0].UserName)
+
+	req, _ := http.NewRequest("DELETE", server.URL+APIPath+"/1", nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"1"}, manager.revoked)
+}
@@ -0,0 +1,228 @@
+package sessions
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/pkg/responsewriters"
+	"github.com/yubo/apiserver/pkg/scheme"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// CSRFTokenKey is the session key of the synchronizer token
+	CSRFTokenKey = "_csrf"
+
+	DefaultCSRFHeader    = "X-CSRF-Token"
+	DefaultCSRFTokenPath = "/csrf-token"
+)
+
+var (
+	csrfOptions *CSRFOptions
+
+	safeMethods = sets.NewString("GET", "HEAD", "OPTIONS", "TRACE")
+)
+
+// CSRFOptions protects the mutating requests which are authenticated by the session cookie,
+// they must come from a trusted origin and carry the synchronizer token of the session.
+type CSRFOptions struct {
+	// Header carries the token of the mutating requests
+	Header string `json:"header"`
+	// FormField carries the token of the form posts, if the header is not set
+	FormField string `json:"formField"`
+	// TokenPath returns the token of the session for SPAs, as {"token": "..."}
+	// and in the response header
+	TokenPath string `json:"tokenPath"`
+	// TrustedOrigins are allowed besides the host of the request, e.g. https://console.example.com
+	TrustedOrigins []string `json:"trustedOrigins"`
+}
+
+// SetCSRF enables the csrf filter of WithCSRF
+func SetCSRF(opts *CSRFOptions) {
+	if opts.Header == "" {
+		opts.Header = DefaultCSRFHeader
+	}
+	if opts.TokenPath == "" {
+		opts.TokenPath = DefaultCSRFTokenPath
+	}
+	csrfOptions = opts
+}
+
+// WithCSRF checks the mutating requests with the options set by SetCSRF, it must be
+// wrapped by WithSessions and the authentication filter.
+func WithCSRF(handler http.Handler) http.Handler {
+	if defaultStore == nil || csrfOptions == nil {
+		return handler
+	}
+	return CSRF(handler, csrfOptions)
+}
+
+func CSRF(handler http.Handler, opts *CSRFOptions) http.HandlerFunc {
+	trustedOrigins := sets.NewString()
+	for _, origin := range opts.TrustedOrigins {
+		trustedOrigins.Insert(strings.ToLower(strings.TrimRight(origin, "/")))
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		sess, ok := SessionFrom(req.Context())
+		if !ok {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		if req.URL.Path == opts.TokenPath && req.Method == "GET" {
+			serveCSRFToken(w, req, sess, opts)
+			return
+		}
+
+		if safeMethods.Has(req.Method) || !cookieAuthenticated(req, sess) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		if err := checkOrigin(req, trustedOrigins); err != nil {
+			csrfFailed(w, req, err)
+			return
+		}
+
+		if err := checkCSRFToken(req, sess, opts); err != nil {
+			csrfFailed(w, req, err)
+			return
+		}
+
+		handler.ServeHTTP(w, req)
+	}
+}
+
+// CSRFToken returns the token of the session, a new one is generated and saved if absent
+func CSRFToken(sess Session) (string, error) {
+	if token, ok := sess.Get(CSRFTokenKey).(string); ok && token != "" {
+		return token, nil
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+	sess.Set(CSRFTokenKey, token)
+	if err := sess.Save(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func serveCSRFToken(w http.ResponseWriter, req *http.Request, sess Session, opts *CSRFOptions) {
+	token, err := CSRFToken(sess)
+	if err != nil {
+		responsewriters.ErrorNegotiated(err, scheme.NegotiatedSerializer, w, req)
+		return
+	}
+
+	w.Header().Set(opts.Header, token)
+	responsewriters.WriteRawJSON(http.StatusOK, map[string]string{"token": token}, w)
+}
+
+// cookieAuthenticated returns true if the request would be authenticated by the session cookie.
+// The session authenticator is tried first, so a client certificate, which is sent by the browser
+// as ambiently as the cookie, doesn't exempt the request, but a bearer token which can't be set
+// by a cross site request does. Only the user which the x509 authenticator has verified against the
+// client CA, i.e. the cookie was not used, is exempted for the certificate.
+func cookieAuthenticated(req *http.Request, sess Session) bool {
+	if UserFrom(sess) == nil {
+		return false
+	}
+
+	auth := strings.TrimSpace(req.Header.Get("Authorization"))
+	if parts := strings.SplitN(auth, " ", 2); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+		return false
+	}
+
+	if u, ok := request.UserFrom(req.Context()); ok {
+		for _, id := range u.GetExtra()[user.CredentialIDKey] {
+			if strings.HasPrefix(id, "X509SHA256=") {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// checkOrigin requires the Origin, or the Referer if the Origin is absent, to be the host of the request
+// or one of the trusted origins. The request without both of them is left to the token check.
+func checkOrigin(req *http.Request, trustedOrigins sets.String) error {
+	source := req.Header.Get("Origin")
+	if source == "" || source == "null" {
+		source = req.Header.Get("Referer")
+	}
+	if source == "" {
+		if req.TLS != nil {
+			// the browsers always send the referer of the same origin https requests
+			// unless the page opted out, https://owasp.org/www-community/attacks/csrf
+			return fmt.Errorf("origin and referer are missing")
+		}
+		return nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid origin %q", source)
+	}
+
+	if strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+	if trustedOrigins.Has(strings.ToLower(u.Scheme + "://" + u.Host)) {
+		return nil
+	}
+
+	return fmt.Errorf("origin %q is not trusted", u.Scheme+"://"+u.Host)
+}
+
+// CheckCSRFToken checks the synchronizer token of the request, with the header and
+// the form field set by SetCSRF, defaults to the X-CSRF-Token header and the _csrf
+// form field. It protects the handlers which must not rely on the WithCSRF filter
+// being enabled, e.g. logout.
+func CheckCSRFToken(req *http.Request, sess Session) error {
+	opts := csrfOptions
+	if opts == nil {
+		opts = &CSRFOptions{
+			Header:    DefaultCSRFHeader,
+			FormField: CSRFTokenKey,
+			TokenPath: DefaultCSRFTokenPath,
+		}
+	}
+	return checkCSRFToken(req, sess, opts)
+}
+
+func checkCSRFToken(req *http.Request, sess Session, opts *CSRFOptions) error {
+	expected, _ := sess.Get(CSRFTokenKey).(string)
+	if expected == "" {
+		return fmt.Errorf("csrf token of the session is not found, get it from %s", opts.TokenPath)
+	}
+
+	token := req.Header.Get(opts.Header)
+	if token == "" && opts.FormField != "" {
+		token = req.PostFormValue(opts.FormField)
+	}
+	if token == "" {
+		return fmt.Errorf("csrf token is missing")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return fmt.Errorf("csrf token is invalid")
+	}
+
+	return nil
+}
+
+func csrfFailed(w http.ResponseWriter, req *http.Request, err error) {
+	klog.V(3).InfoS("csrf check failed", "method", req.Method, "path", req.URL.Path, "err", err)
+	responsewriters.ErrorNegotiated(errors.NewForbidden("", err), scheme.NegotiatedSerializer, w, req)
+}
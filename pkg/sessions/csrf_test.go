@@ -0,0 +1,131 @@
+package sessions_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/pkg/sessions"
+	"github.com/yubo/apiserver/pkg/sessions/cookie"
+)
+
+type csrfClient struct {
+	t       *testing.T
+	handler http.Handler
+	cookies []*http.Cookie
+}
+
+func (p *csrfClient) do(req *http.Request) *httptest.ResponseRecorder {
+	for _, c := range p.cookies {
+		req.AddCookie(c)
+	}
+	res := httptest.NewRecorder()
+	p.handler.ServeHTTP(res, req)
+	if cookies := res.Result().Cookies(); len(cookies) > 0 {
+		p.cookies = cookies
+	}
+	return res
+}
+
+func (p *csrfClient) token() string {
+	res := p.do(httptest.NewRequest("GET", "http://example.com/csrf-token", nil))
+	require.Equal(p.t, http.StatusOK, res.Code)
+
+	var out map[string]string
+	require.NoError(p.t, json.Unmarshal(res.Body.Bytes(), &out))
+	require.NotEmpty(p.t, out["token"])
+	require.Equal(p.t, out["token"], res.Header().Get(sessions.DefaultCSRFHeader))
+	return out["token"]
+}
+
+func TestCSRF(t *testing.T) {
+	opts := &sessions.CSRFOptions{
+		FormField:      "_csrf",
+		TrustedOrigins: []string{"https://console.example.com"},
+	}
+	sessions.SetCSRF(opts)
+
+	store := cookie.NewStore(&sessions.Options{
+		Name:     "csrf",
+		Path:     "/",
+		MaxAge:   3600,
+		KeyPairs: [][]byte{[]byte("secret")},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, req *http.Request) {
+		require.NoError(t, sessions.WithUser(sessions.Default(req.Context()), &user.DefaultInfo{Name: "tom"}))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	handler := sessions.Sessions(sessions.CSRF(mux, opts), store.Name(), store)
+
+	anonymous := &csrfClient{t: t, handler: handler}
+	client := &csrfClient{t: t, handler: handler}
+	require.Equal(t, http.StatusOK, client.do(httptest.NewRequest("GET", "http://example.com/login", nil)).Code)
+	token := client.token()
+	require.Equal(t, token, client.token(), "the token is kept in the session")
+
+	cases := []struct {
+		name   string
+		client *csrfClient
+		method string
+		header map[string]string
+		form   url.Values
+		tls    bool
+		user   user.Info
+		code   int
+	}{
+		{name: "safe method", client: client, method: "GET", code: http.StatusOK},
+		{name: "not authenticated by cookie", client: anonymous, method: "POST", code: http.StatusOK},
+		{name: "bearer token", client: client, method: "POST", header: map[string]string{"Authorization": "Bearer abc"}, code: http.StatusOK},
+		{name: "unverified client certificate", client: client, method: "POST", tls: true, code: http.StatusForbidden},
+		{name: "session user", client: client, method: "POST", tls: true, user: &user.DefaultInfo{Name: "tom"}, code: http.StatusForbidden},
+		{name: "verified client certificate", client: client, method: "POST", tls: true, user: &user.DefaultInfo{
+			Name:  "tom",
+			Extra: map[string][]string{user.CredentialIDKey: {"X509SHA256=abc"}},
+		}, code: http.StatusOK},
+		{name: "missing token", client: client, method: "POST", code: http.StatusForbidden},
+		{name: "invalid token", client: client, method: "POST", header: map[string]string{"X-CSRF-Token": "abc"}, code: http.StatusForbidden},
+		{name: "header token", client: client, method: "DELETE", header: map[string]string{"X-CSRF-Token": token}, code: http.StatusOK},
+		{name: "form token", client: client, method: "POST", form: url.Values{"_csrf": {token}}, code: http.StatusOK},
+		{name: "same origin", client: client, method: "PUT", header: map[string]string{"X-CSRF-Token": token, "Origin": "http://example.com"}, code: http.StatusOK},
+		{name: "trusted origin", client: client, method: "PUT", header: map[string]string{"X-CSRF-Token": token, "Origin": "https://console.example.com"}, code: http.StatusOK},
+		{name: "untrusted origin", client: client, method: "PUT", header: map[string]string{"X-CSRF-Token": token, "Origin": "https://evil.com"}, code: http.StatusForbidden},
+		{name: "untrusted referer", client: client, method: "PUT", header: map[string]string{"X-CSRF-Token": token, "Referer": "https://evil.com/page"}, code: http.StatusForbidden},
+		{name: "same origin referer", client: client, method: "PUT", header: map[string]string{"X-CSRF-Token": token, "Referer": "http://example.com/page"}, code: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var req *http.Request
+			if c.form != nil {
+				req = httptest.NewRequest(c.method, "http://example.com/api", strings.NewReader(c.form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(c.method, "http://example.com/api", nil)
+			}
+			for k, v := range c.header {
+				req.Header.Set(k, v)
+			}
+			if c.tls {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+			}
+			if c.user != nil {
+				req = req.WithContext(request.WithUser(req.Context(), c.user))
+			}
+
+			res := c.client.do(req)
+			require.Equal(t, c.code, res.Code, res.Body.String())
+		})
+	}
+}
@@ -21,6 +21,11 @@ func SetStore(s Store) {
 	defaultStore = s
 }
 
+// DefaultStore returns the store set by SetStore, or nil
+func DefaultStore() Store {
+	return defaultStore
+}
+
 // http filter
 func WithSessions(handler http.Handler) http.Handler {
 	if defaultStore == nil {
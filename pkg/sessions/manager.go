@@ -0,0 +1,30 @@
+package sessions
+
+import (
+	"context"
+	"time"
+
+	"github.com/yubo/golib/api"
+)
+
+// SessionInfo is the metadata of a session, without its values
+type SessionInfo struct {
+	ID           string    `json:"id"`
+	UserName     string    `json:"userName"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"userAgent"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastActiveAt time.Time `json:"lastActiveAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// Manager is implemented by the stores which keep the sessions on the server side,
+// the sessions of a cookie store can't be revoked before they expire.
+type Manager interface {
+	// ListSessions lists the sessions of the user, or of all users if userName is empty
+	ListSessions(ctx context.Context, userName string, opts api.GetListOptions) ([]*SessionInfo, error)
+	// RevokeSession deletes the session, its cookie is no longer accepted
+	RevokeSession(ctx context.Context, id string) error
+	// RevokeUserSessions deletes all sessions of the user, and returns the number of them
+	RevokeUserSessions(ctx context.Context, userName string) (int, error)
+}
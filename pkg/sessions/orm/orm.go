@@ -10,21 +10,27 @@ import (
 
 	"github.com/gorilla/securecookie"
 	gsessions "github.com/gorilla/sessions"
+	"github.com/yubo/apiserver/pkg/authentication/user"
 	"github.com/yubo/apiserver/pkg/db"
 	"github.com/yubo/apiserver/pkg/proc"
 	procoptions "github.com/yubo/apiserver/pkg/proc/options"
 	"github.com/yubo/apiserver/pkg/sessions"
 	sessionsr "github.com/yubo/apiserver/pkg/sessions/register"
 	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
 	"github.com/yubo/golib/orm"
 	"github.com/yubo/golib/util"
 	"github.com/yubo/golib/util/clock"
+	utilnet "github.com/yubo/golib/util/net"
 	"k8s.io/klog/v2"
 )
 
 const (
 	storeType  = "orm"
 	moduleName = "session.orm"
+
+	// the last active time is written at most once per touchInterval
+	touchInterval = time.Minute
 )
 
 type Config struct {
@@ -48,11 +54,34 @@ func newConfig() *Config {
 }
 
 type OrmSession struct {
-	ID        *string `sql:"unique,where"`
-	Data      *string `sql:"type=text"`
-	CreatedAt *time.Time
-	UpdatedAt *time.Time
-	ExpiresAt *time.Time `sql:"index"`
+	ID           *string `sql:"unique,where"`
+	Data         *string `sql:"type=text"`
+	UserName     *string `sql:"index"`
+	IP           *string
+	UserAgent    *string `sql:"type=text"`
+	CreatedAt    *time.Time
+	UpdatedAt    *time.Time
+	LastActiveAt *time.Time
+	ExpiresAt    *time.Time `sql:"index"`
+}
+
+func (p *OrmSession) info() *sessions.SessionInfo {
+	return &sessions.SessionInfo{
+		ID:           util.StringValue(p.ID),
+		UserName:     util.StringValue(p.UserName),
+		IP:           util.StringValue(p.IP),
+		UserAgent:    util.StringValue(p.UserAgent),
+		CreatedAt:    timeValue(p.CreatedAt),
+		LastActiveAt: timeValue(p.LastActiveAt),
+		ExpiresAt:    timeValue(p.ExpiresAt),
+	}
+}
+
+func timeValue(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
 }
 
 func NewStore(config *Config) (sessions.Store, error) {
@@ -61,6 +90,7 @@ func NewStore(config *Config) (sessions.Store, error) {
 		db:        config.Orm,
 		clock:     config.Options.Clock,
 		tableName: config.TableName,
+		idle:      time.Duration(config.Options.IdleTimeout) * time.Second,
 		Options:   config.Options.ToGorillaOptions(),
 		Codecs:    securecookie.CodecsFromPairs(config.Options.KeyPairs...),
 	}
@@ -97,6 +127,7 @@ type store struct {
 	db        orm.DB
 	clock     clock.WithTicker
 	tableName string
+	idle      time.Duration
 	Codecs    []securecookie.Codec
 	Options   *gsessions.Options
 }
@@ -148,7 +179,7 @@ func (p *store) Save(req *http.Request, w http.ResponseWriter, session *gsession
 		return nil
 	}
 
-	if err := p.save(ctx, session); err != nil {
+	if err := p.save(req, session); err != nil {
 		return err
 	}
 
@@ -160,7 +191,8 @@ func (p *store) Save(req *http.Request, w http.ResponseWriter, session *gsession
 	return nil
 }
 
-func (p *store) save(ctx context.Context, session *gsessions.Session) error {
+func (p *store) save(req *http.Request, session *gsessions.Session) error {
+	ctx := req.Context()
 	data, err := securecookie.EncodeMulti(session.Name(), session.Values, p.Codecs...)
 	if err != nil {
 		return err
@@ -168,41 +200,85 @@ func (p *store) save(ctx context.Context, session *gsessions.Session) error {
 	now := p.clock.Now()
 	expire := now.Add(time.Second * time.Duration(session.Options.MaxAge))
 
+	// index the session by the user, the values are kept up to date on
+	// every save, e.g. login after the session was created
+	var userName string
+	if u, ok := session.Values[sessions.UserInfoKey].(*user.DefaultInfo); ok && u != nil {
+		userName = u.Name
+	}
+	var ip string
+	if clientIP := utilnet.GetClientIP(req); clientIP != nil {
+		ip = clientIP.String()
+	}
+	userAgent := req.UserAgent()
+
 	if session.IsNew {
 		// generate random session ID key suitable for storage in the db
 		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(
 			securecookie.GenerateRandomKey(32)), "=")
 		return p.db.Insert(ctx, &OrmSession{
-			ID:        &session.ID,
-			Data:      &data,
-			CreatedAt: &now,
-			UpdatedAt: &now,
-			ExpiresAt: &expire,
+			ID:           &session.ID,
+			Data:         &data,
+			UserName:     &userName,
+			IP:           &ip,
+			UserAgent:    &userAgent,
+			CreatedAt:    &now,
+			UpdatedAt:    &now,
+			LastActiveAt: &now,
+			ExpiresAt:    &expire,
 		}, orm.WithTable(p.tableName))
 	}
 
 	return p.db.Update(ctx, &OrmSession{
-		ID:        &session.ID,
-		Data:      &data,
-		UpdatedAt: &now,
-		ExpiresAt: &expire,
+		ID:           &session.ID,
+		Data:         &data,
+		UserName:     &userName,
+		IP:           &ip,
+		UserAgent:    &userAgent,
+		UpdatedAt:    &now,
+		LastActiveAt: &now,
+		ExpiresAt:    &expire,
 	}, orm.WithTable(p.tableName))
 }
 
 // load query and decodes its content into session.Values.
+// The session which is idle for longer than the idle timeout is not loaded.
 func (p *store) load(ctx context.Context, session *gsessions.Session) error {
+	now := p.clock.Now()
 	s := &OrmSession{}
-	if err := p.db.Query(ctx, "select * from `"+p.tableName+"` where id=? and expires_at > ?", session.ID, p.clock.Now()).Row(s); err != nil {
+	if err := p.db.Query(ctx, "select * from `"+p.tableName+"` where id=? and expires_at > ?", session.ID, now).Row(s); err != nil {
 		return err
 	}
 
+	lastActive := timeValue(s.LastActiveAt)
+	if lastActive.IsZero() {
+		lastActive = timeValue(s.UpdatedAt)
+	}
+	if p.idle > 0 && !now.Before(lastActive.Add(p.idle)) {
+		return errors.NewNotFound("session")
+	}
+
 	if err := securecookie.DecodeMulti(session.Name(), util.StringValue(s.Data), &session.Values, p.Codecs...); err != nil {
 		return err
 	}
 
+	if now.Sub(lastActive) >= p.touchInterval() {
+		if _, err := p.db.Exec(ctx, "update `"+p.tableName+"` set last_active_at=? where id=?", now, session.ID); err != nil {
+			klog.Warningf("session.touch() err %s", err)
+		}
+	}
+
 	return nil
 }
 
+// touchInterval keeps the precision of the idle timeout
+func (p *store) touchInterval() time.Duration {
+	if p.idle > 0 && p.idle/10 < touchInterval {
+		return p.idle / 10
+	}
+	return touchInterval
+}
+
 func factory(ctx context.Context, options *sessions.Options) (sessions.Store, error) {
 	cf := newConfig()
 	if err := proc.ReadConfig(moduleName, cf); err != nil {
@@ -253,11 +329,80 @@ func (st *store) MaxLength(l int) {
 	}
 }
 
-// Cleanup deletes expired sessions
+// Cleanup deletes expired and idle sessions
 func (p *store) Cleanup(ctx context.Context) {
-	if _, err := p.db.Exec(ctx, "delete from `"+p.tableName+"` where expires_at < ?", p.clock.Now()); err != nil {
+	now := p.clock.Now()
+	if _, err := p.db.Exec(ctx, "delete from `"+p.tableName+"` where expires_at < ?", now); err != nil {
 		klog.Warningf("session.Cleanup() err %s", err)
 	}
+
+	if p.idle > 0 {
+		if _, err := p.db.Exec(ctx, "delete from `"+p.tableName+"` where coalesce(last_active_at, updated_at) < ?", now.Add(-p.idle)); err != nil {
+			klog.Warningf("session.Cleanup() err %s", err)
+		}
+	}
+}
+
+// ListSessions lists the sessions of the user, or of all users if userName is empty
+func (p *store) ListSessions(ctx context.Context, userName string, o api.GetListOptions) (list []*sessions.SessionInfo, err error) {
+	var where []string
+	var args []interface{}
+
+	// the name is bound as an argument, as in RevokeUserSessions
+	if userName != "" {
+		where = append(where, "user_name=?")
+		args = append(args, userName)
+	}
+	if o.Query != "" {
+		selector, err := orm.Parse(o.Query)
+		if err != nil {
+			return nil, errors.NewBadRequest(err.Error())
+		}
+		if q, a := selector.Sql(); q != "" {
+			where = append(where, q)
+			args = append(args, a...)
+		}
+	}
+
+	query := "select * from `" + p.tableName + "`"
+	countQuery := "select count(*) from `" + p.tableName + "`"
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+		countQuery += " where " + strings.Join(where, " and ")
+	}
+	if len(o.Orderby) > 0 {
+		query += " order by " + strings.Join(o.Orderby, ", ")
+	}
+	if o.Limit > 0 {
+		query += fmt.Sprintf(" limit %d, %d", o.Offset, o.Limit)
+	}
+
+	var rows []*OrmSession
+	if err = p.db.Query(ctx, query, args...).Rows(&rows); err != nil {
+		return nil, err
+	}
+	if o.Total != nil {
+		if err = p.db.Query(ctx, countQuery, args...).Row(o.Total); err != nil {
+			return nil, err
+		}
+	}
+
+	list = make([]*sessions.SessionInfo, len(rows))
+	for i, row := range rows {
+		list[i] = row.info()
+	}
+	return list, nil
+}
+
+// RevokeSession deletes the session, its cookie is no longer accepted
+func (p *store) RevokeSession(ctx context.Context, id string) error {
+	return p.db.ExecNumErr(ctx, "delete from `"+p.tableName+"` where id=?", id)
+}
+
+// RevokeUserSessions deletes all sessions of the user
+func (p *store) RevokeUserSessions(ctx context.Context, userName string) (int, error) {
+	n, err := p.db.ExecNum(ctx, "delete from `"+p.tableName+"` where user_name=?", userName)
+	return int(n), err
 }
 
 // PeriodicCleanup runs Cleanup every interval. Close quit channel to stop.
@@ -265,6 +410,8 @@ func (p *store) PeriodicCleanup(ctx context.Context, interval time.Duration) {
 	util.UntilWithTick(func() { p.Cleanup(ctx) }, p.clock.NewTicker(interval).C(), ctx.Done())
 }
 
+var _ sessions.Manager = &store{}
+
 func init() {
 	sessionsr.RegisterStore(storeType, factory)
 }
@@ -1,12 +1,20 @@
 package orm
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/authentication/user"
 	"github.com/yubo/apiserver/pkg/sessions"
 	"github.com/yubo/apiserver/pkg/sessions/tester"
+	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/orm"
+	"github.com/yubo/golib/util/clock"
+	testingclock "github.com/yubo/golib/util/clock/testing"
 
 	_ "github.com/yubo/golib/orm/sqlite"
 )
@@ -56,3 +64,137 @@ func TestGorm_SessionOptions(t *testing.T) {
 func TestGorm_SessionMany(t *testing.T) {
 	tester.Many(t, newStore)
 }
+
+func newManagerStore(t *testing.T, c clock.WithTicker, idleTimeout int) *store {
+	s := newStore(t, &sessions.Options{
+		Clock:       c,
+		Path:        "/",
+		MaxAge:      3600,
+		IdleTimeout: idleTimeout,
+	})
+	return s.(*store)
+}
+
+// login sets the user in a new session, and returns the cookies of the session
+func login(t *testing.T, handler http.Handler, name, userAgent string) []*http.Cookie {
+	req := httptest.NewRequest("GET", "/login?user="+name, nil)
+	req.Header.Set("User-Agent", userAgent)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	return res.Result().Cookies()
+}
+
+// whoami returns the user of the session, or an empty string
+func whoami(t *testing.T, handler http.Handler, cookies []*http.Cookie) string {
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res.Body.String()
+}
+
+func newManagerHandler(t *testing.T, s *store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, req *http.Request) {
+		sess := sessions.Default(req.Context())
+		require.NoError(t, sessions.WithUser(sess, &user.DefaultInfo{Name: req.URL.Query().Get("user")}))
+	})
+	mux.HandleFunc("/whoami", func(w http.ResponseWriter, req *http.Request) {
+		if u := sessions.UserFrom(sessions.Default(req.Context())); u != nil {
+			w.Write([]byte(u.Name))
+		}
+	})
+	return sessions.Sessions(mux, "manager", s)
+}
+
+func TestOrm_SessionManager(t *testing.T) {
+	ctx := context.Background()
+	s := newManagerStore(t, nil, 0)
+	handler := newManagerHandler(t, s)
+
+	alice1 := login(t, handler, "manager-alice", "agent-1")
+	alice2 := login(t, handler, "manager-alice", "agent-2")
+	bob := login(t, handler, "manager-bob", "agent-3")
+	require.Equal(t, "manager-alice", whoami(t, handler, alice1))
+
+	total := 0
+	list, err := s.ListSessions(ctx, "manager-alice", api.GetListOptions{Total: &total})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, 2, total)
+	for _, info := range list {
+		require.Equal(t, "manager-alice", info.UserName)
+		require.Equal(t, "192.0.2.1", info.IP)
+		require.Contains(t, []string{"agent-1", "agent-2"}, info.UserAgent)
+		require.False(t, info.CreatedAt.IsZero())
+		require.False(t, info.LastActiveAt.IsZero())
+	}
+
+	// the user name is a value, it can't inject the requirements of the selector
+	for _, name := range []string{"manager-alice,user_name!=x", "x,id!=", "manager-bob notin", "in", "' or 1=1 --"} {
+		list, err := s.ListSessions(ctx, name, api.GetListOptions{})
+		require.NoError(t, err, name)
+		require.Empty(t, list, name)
+	}
+
+	// the query selects within the sessions of the user
+	list, err = s.ListSessions(ctx, "manager-alice", api.GetListOptions{Query: "user_agent=agent-1"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "agent-1", list[0].UserAgent)
+
+	// revoke one session
+	require.NoError(t, s.RevokeSession(ctx, list[0].ID))
+	require.Error(t, s.RevokeSession(ctx, list[0].ID))
+	list, err = s.ListSessions(ctx, "manager-alice", api.GetListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// revoke all sessions of the user
+	n, err := s.RevokeUserSessions(ctx, "manager-alice")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Empty(t, whoami(t, handler, alice1))
+	require.Empty(t, whoami(t, handler, alice2))
+	require.Equal(t, "manager-bob", whoami(t, handler, bob))
+}
+
+func TestOrm_SessionIdleTimeout(t *testing.T) {
+	ctx := context.Background()
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	s := newManagerStore(t, fakeClock, 600)
+	handler := newManagerHandler(t, s)
+
+	active := login(t, handler, "idle-active", "")
+	idle := login(t, handler, "idle-idle", "")
+
+	// the active session is kept alive by the requests
+	for i := 0; i < 3; i++ {
+		fakeClock.Step(5 * time.Minute)
+		require.Equal(t, "idle-active", whoami(t, handler, active))
+	}
+	require.Empty(t, whoami(t, handler, idle))
+
+	// the idle session is removed by cleanup, the active one is kept
+	s.Cleanup(ctx)
+	list, err := s.ListSessions(ctx, "idle-idle", api.GetListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, list)
+	list, err = s.ListSessions(ctx, "idle-active", api.GetListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// absolute expiry is enforced even if the session is active
+	for i := 0; i < 12; i++ {
+		fakeClock.Step(5 * time.Minute)
+		whoami(t, handler, active)
+	}
+	require.Empty(t, whoami(t, handler, active))
+	s.Cleanup(ctx)
+	list, err = s.ListSessions(ctx, "idle-active", api.GetListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
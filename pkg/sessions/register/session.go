@@ -8,11 +8,14 @@ import (
 	"github.com/yubo/apiserver/pkg/db"
 	"github.com/yubo/apiserver/pkg/proc"
 	v1 "github.com/yubo/apiserver/pkg/proc/api/v1"
+	"github.com/yubo/apiserver/pkg/proc/options"
 	"github.com/yubo/apiserver/pkg/sessions"
+	"github.com/yubo/apiserver/pkg/sessions/admin"
 	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/util"
 	"github.com/yubo/golib/util/clock"
 	"github.com/yubo/golib/util/errors"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -32,6 +35,12 @@ var (
 		HookNum:     v1.ACTION_START,
 		Priority:    v1.PRI_SYS_INIT,
 		SubPriority: v1.PRI_M_AUTHN - 1,
+	}, {
+		Hook:        _module.installAdmin,
+		Owner:       moduleName,
+		HookNum:     v1.ACTION_START,
+		Priority:    v1.PRI_MODULE,
+		SubPriority: v1.PRI_M_AUTHZ,
 	}}
 	factories = map[string]StoreFactory{}
 )
@@ -66,6 +75,33 @@ func (p *module) init(ctx context.Context) error {
 
 	sessions.SetStore(store)
 
+	if cf.CSRF != nil {
+		sessions.SetCSRF(cf.CSRF)
+	}
+
+	return nil
+}
+
+// installAdmin serves the sessions admin api if the store keeps the sessions on the server side
+func (p *module) installAdmin(ctx context.Context) error {
+	cf := newConfig()
+	if err := proc.ReadConfig(p.name, cf); err != nil {
+		return err
+	}
+
+	if !cf.EnableAdminAPI {
+		return nil
+	}
+
+	store := sessions.DefaultStore()
+	manager, ok := store.(sessions.Manager)
+	if !ok {
+		return errors.Errorf("session store %s does not support the admin api", cf.Store)
+	}
+
+	admin.New(manager).Install(options.APIServerMustFrom(ctx))
+	klog.InfoS("sessions admin api installed", "path", admin.APIPath, "store", store.Type())
+
 	return nil
 }
 
@@ -82,9 +118,12 @@ type config struct {
 	// MaxAge=0 means no 'Max-Age' attribute specified.
 	// MaxAge<0 means delete cookie now, equivalently 'Max-Age: 0'.
 	// MaxAge>0 means Max-Age attribute present and given in seconds.
-	MaxAge   api.Duration `json:"maxAge"`
-	Secure   bool         `json:"secure"`
-	HttpOnly bool         `json:"httpOnly"`
+	MaxAge api.Duration `json:"maxAge"`
+	// IdleTimeout expires the session which has not been used for the duration,
+	// 0 means only MaxAge is enforced. Not supported by the cookie store.
+	IdleTimeout api.Duration `json:"idleTimeout"`
+	Secure      bool         `json:"secure"`
+	HttpOnly    bool         `json:"httpOnly"`
 	// rfc-draft to preventing CSRF: https://tools.ietf.org/html/draft-west-first-party-cookies-07
 	//   refer: https://godoc.org/net/http
 	//          https://www.sjoerdlangkemper.nl/2016/04/14/preventing-csrf-with-samesite-cookie-attribute/
@@ -92,7 +131,13 @@ type config struct {
 
 	Name     string   `json:"name"`
 	Store    string   `json:"store"`
-	KeyPairs [][]byte `json:"keyPairs`
+	KeyPairs [][]byte `json:"keyPairs"`
+
+	// CSRF, if set, protects the mutating requests which are authenticated by the session cookie
+	CSRF *sessions.CSRFOptions `json:"csrf"`
+
+	// EnableAdminAPI serves the api to list and revoke the sessions of the users
+	EnableAdminAPI bool `json:"enableAdminAPI"`
 }
 
 func (p *config) Options(c clock.WithTicker) *sessions.Options {
@@ -101,14 +146,15 @@ func (p *config) Options(c clock.WithTicker) *sessions.Options {
 	}
 
 	opts := &sessions.Options{
-		Name:     p.Name,
-		Clock:    c,
-		Path:     p.Path,
-		Domain:   p.Domain,
-		MaxAge:   int(p.MaxAge.Seconds()),
-		Secure:   p.Secure,
-		HttpOnly: p.HttpOnly,
-		KeyPairs: p.KeyPairs,
+		Name:        p.Name,
+		Clock:       c,
+		Path:        p.Path,
+		Domain:      p.Domain,
+		MaxAge:      int(p.MaxAge.Seconds()),
+		IdleTimeout: int(p.IdleTimeout.Seconds()),
+		Secure:      p.Secure,
+		HttpOnly:    p.HttpOnly,
+		KeyPairs:    p.KeyPairs,
 	}
 
 	switch strings.ToLower(p.SameSite) {
@@ -130,6 +176,10 @@ func (p *config) Validate() error {
 		return nil
 	}
 
+	if p.IdleTimeout.Duration < 0 {
+		return errors.Errorf("idleTimeout must not be negative")
+	}
+
 	return nil
 }
 
@@ -2,11 +2,13 @@ package sessions
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/sessions"
 	gsessions "github.com/gorilla/sessions"
+	"github.com/yubo/golib/api/errors"
 	"github.com/yubo/golib/util/clock"
 )
 
@@ -97,6 +99,11 @@ type Session interface {
 	Options(Options)
 	// Save saves all sessions used during the current request.
 	Save() error
+	// Regenerate replaces the session with a new empty one of a new ID, the
+	// current one is destroyed by the stores which keep the sessions on the
+	// server side. It must be called before the user is stored in the session,
+	// e.g. on login, to prevent session fixation.
+	Regenerate() error
 }
 
 type Options struct {
@@ -110,9 +117,12 @@ type Options struct {
 	// MaxAge=0 means no 'Max-Age' attribute specified.
 	// MaxAge<0 means delete cookie now, equivalently 'Max-Age: 0'.
 	// MaxAge>0 means Max-Age attribute present and given in seconds.
-	MaxAge   int
-	Secure   bool
-	HttpOnly bool
+	MaxAge int
+	// IdleTimeout>0 expires the session if it has not been used for the given seconds,
+	// it's enforced only by the stores which keep the sessions on the server side.
+	IdleTimeout int
+	Secure      bool
+	HttpOnly    bool
 	// rfc-draft to preventing CSRF: https://tools.ietf.org/html/draft-west-first-party-cookies-07
 	//   refer: https://godoc.org/net/http
 	//          https://www.sjoerdlangkemper.nl/2016/04/14/preventing-csrf-with-samesite-cookie-attribute/
@@ -189,6 +199,28 @@ func (s *session) Save() error {
 	return nil
 }
 
+func (s *session) Regenerate() error {
+	old := s.Session()
+	if old == nil {
+		return fmt.Errorf("session %s is not available", s.name)
+	}
+
+	if m, ok := s.store.(Manager); ok && !old.IsNew && old.ID != "" {
+		if err := m.RevokeSession(s.request.Context(), old.ID); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	sess := sessions.NewSession(s.store, s.name)
+	opts := *old.Options
+	sess.Options = &opts
+	sess.IsNew = true
+
+	s.session = sess
+	s.written = true
+	return nil
+}
+
 func (s *session) Session() *sessions.Session {
 	if s.session == nil {
 		var err error
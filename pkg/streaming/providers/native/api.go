@@ -0,0 +1,247 @@
+package native
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// APIPath is the root path of the sessions api of the native runtime
+	APIPath = "/api/v1/streaming"
+
+	// RecordingAPIPath is the root path of the recordings api, the access is
+	// authorized as the "recordings" resource.
+	RecordingAPIPath = "/api/v1/recordings"
+
+	// MIME_ASCIICAST is the content type of the asciicast v2 format
+	MIME_ASCIICAST = "application/x-asciicast"
+
+	// maxSearchMatches is the max matches of a recording in the search
+	maxSearchMatches = 10
+)
+
+// API serves the api to list, inspect and kill the sessions of the runtime,
+// the sessions are killed and the viewers are kicked by the owners of the
+// session, which are checked by the authorizer, see AttachModeOf.
+type API struct {
+	manager    SessionManager
+	authorizer authorizer.Authorizer
+}
+
+func NewAPI(manager SessionManager, authz authorizer.Authorizer) *API {
+	return &API{manager: manager, authorizer: authz}
+}
+
+func (p *API) Install(container rest.GoRestfulContainer) {
+	rest.SwaggerTagRegister("streaming", "streaming Api - list, inspect and kill the exec sessions, kick the viewers")
+
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               APIPath,
+		Produces:           []string{rest.MIME_JSON},
+		Tags:               []string{"streaming"},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "GET", SubPath: "/sessions", Operation: "listStreamingSession", Desc: "list sessions", Handle: p.listSession},
+			{Method: "GET", SubPath: "/sessions/{id}", Operation: "getStreamingSession", Desc: "get the session by id", Handle: p.getSession},
+			{Method: "DELETE", SubPath: "/sessions/{id}", Operation: "killStreamingSession", Desc: "kill the process of the session, only for the owners", Handle: p.killSession},
+			{Method: "DELETE", SubPath: "/sessions/{id}/viewers/{viewer}", Operation: "kickStreamingSessionViewer", Desc: "detach the viewer from the session, only for the owners", Handle: p.kickViewer},
+		},
+	})
+}
+
+type idParam struct {
+	ID string `param:"path" name:"id" description:"session id"`
+}
+
+type viewerParam struct {
+	ID     string `param:"path" name:"id" description:"session id"`
+	Viewer string `param:"path" name:"viewer" description:"viewer id"`
+}
+
+type sessionListOutput struct {
+	List  []*SessionStatus `json:"list"`
+	Total int              `json:"total"`
+}
+
+func (p *API) listSession(w http.ResponseWriter, req *http.Request) (*sessionListOutput, error) {
+	list := p.manager.ListSessions()
+	return &sessionListOutput{List: list, Total: len(list)}, nil
+}
+
+func (p *API) getSession(w http.ResponseWriter, req *http.Request, in *idParam) (*SessionStatus, error) {
+	return p.manager.GetSession(in.ID)
+}
+
+func (p *API) killSession(w http.ResponseWriter, req *http.Request, in *idParam) (*SessionStatus, error) {
+	u, err := p.checkOwner(req, in.ID, "kill")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.manager.KillSession(in.ID); err != nil {
+		return nil, err
+	}
+
+	klog.InfoS("session killed", "id", in.ID, "user", u.GetName())
+	return p.manager.GetSession(in.ID)
+}
+
+func (p *API) kickViewer(w http.ResponseWriter, req *http.Request, in *viewerParam) (*SessionStatus, error) {
+	if _, err := p.checkOwner(req, in.ID, "kick"); err != nil {
+		return nil, err
+	}
+
+	if err := p.manager.KickViewer(in.ID, in.Viewer); err != nil {
+		return nil, err
+	}
+
+	return p.manager.GetSession(in.ID)
+}
+
+// checkOwner returns the user of the request if the user is an owner of the session
+func (p *API) checkOwner(req *http.Request, id, action string) (user.Info, error) {
+	u, ok := request.UserFrom(req.Context())
+	if !ok {
+		return nil, errors.NewUnauthorized("no user found for the request")
+	}
+
+	session, err := p.manager.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := AttachModeOf(req.Context(), p.authorizer, u, session)
+	if err != nil {
+		return nil, err
+	}
+	if mode != AttachModeOwner {
+		return nil, errors.NewForbidden(action, fmt.Errorf("user %q is not an owner of the session %s", u.GetName(), id))
+	}
+
+	return u, nil
+}
+
+// RecordingAPI serves the api to list, search and play the recordings
+type RecordingAPI struct {
+	store RecordingStore
+}
+
+func NewRecordingAPI(store RecordingStore) *RecordingAPI {
+	return &RecordingAPI{store: store}
+}
+
+func (p *RecordingAPI) Install(container rest.GoRestfulContainer) {
+	rest.SwaggerTagRegister("recordings", "recordings Api - list, search and play the recordings of the exec sessions")
+
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               RecordingAPIPath,
+		Produces:           []string{rest.MIME_JSON},
+		Tags:               []string{"recordings"},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "GET", SubPath: "/", Operation: "listRecording", Desc: "list or search the recordings", Handle: p.listRecording},
+			{Method: "GET", SubPath: "/{name}", Operation: "getRecording", Desc: "get the recording by name", Handle: p.getRecording},
+			{Method: "GET", SubPath: "/{name}/asciicast", Operation: "playRecording", Desc: "get the recording in the asciicast v2 format", Produce: MIME_ASCIICAST, Handle: p.playRecording},
+		},
+	})
+}
+
+type recordingListParam struct {
+	api.PageParams
+	User  string `param:"query" description:"the name of the user"`
+	Query string `param:"query" name:"q" description:"the text to search in the output"`
+}
+
+type recordingNameParam struct {
+	Name string `param:"path" name:"name" description:"recording name"`
+}
+
+type recordingItem struct {
+	*Recording
+	Matches []Match `json:"matches,omitempty"`
+}
+
+type recordingListOutput struct {
+	List  []*recordingItem `json:"list"`
+	Total int              `json:"total"`
+}
+
+func (p *RecordingAPI) listRecording(w http.ResponseWriter, req *http.Request, in *recordingListParam) (*recordingListOutput, error) {
+	offset, limit := in.OffsetLimit()
+	ret := &recordingListOutput{List: []*recordingItem{}}
+
+	if in.Query == "" {
+		recordings, err := p.store.List(req.Context(), RecordingListOptions{
+			User:   in.User,
+			Offset: offset,
+			Limit:  limit,
+			Total:  &ret.Total,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range recordings {
+			ret.List = append(ret.List, &recordingItem{Recording: rec})
+		}
+		return ret, nil
+	}
+
+	// the page is selected from the matched recordings
+	recordings, err := p.store.List(req.Context(), RecordingListOptions{User: in.User})
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range recordings {
+		matches, err := p.search(req, rec.Name, in.Query)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		if ret.Total >= offset && len(ret.List) < limit {
+			ret.List = append(ret.List, &recordingItem{Recording: rec, Matches: matches})
+		}
+		ret.Total++
+	}
+
+	return ret, nil
+}
+
+func (p *RecordingAPI) search(req *http.Request, name, text string) ([]Match, error) {
+	r, err := p.store.OpenReader(req.Context(), name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return SearchRecording(r, text, maxSearchMatches)
+}
+
+func (p *RecordingAPI) getRecording(w http.ResponseWriter, req *http.Request, in *recordingNameParam) (*Recording, error) {
+	return p.store.Get(req.Context(), in.Name)
+}
+
+func (p *RecordingAPI) playRecording(w http.ResponseWriter, req *http.Request, in *recordingNameParam) error {
+	rec, err := p.store.Get(req.Context(), in.Name)
+	if err != nil {
+		return err
+	}
+
+	r, err := p.store.OpenReader(req.Context(), in.Name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w.Header().Set("Content-Type", MIME_ASCIICAST)
+	return WriteAsciicast(w, r, rec)
+}
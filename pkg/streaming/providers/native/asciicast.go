@@ -0,0 +1,219 @@
+package native
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yubo/golib/stream"
+	"github.com/yubo/golib/term"
+)
+
+const (
+	defTermWidth  = 80
+	defTermHeight = 24
+)
+
+// asciicastHeader is the header of the asciicast v2 format,
+// see https://docs.asciinema.org/manual/asciicast/v2/
+type asciicastHeader struct {
+	Version   int    `json:"version"`
+	Width     uint16 `json:"width"`
+	Height    uint16 `json:"height"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Command   string `json:"command,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// WriteAsciicast converts the recording to the asciicast v2 format, the output
+// is written as the "o" events, the resizes as the "r" events, the input is
+// not written.
+func WriteAsciicast(w io.Writer, r io.Reader, rec *Recording) error {
+	decoder := gob.NewDecoder(r)
+
+	// the size of the header is the first resize before any output
+	var frames []*stream.RecData
+	size := &term.TerminalSize{Width: defTermWidth, Height: defTermHeight}
+	for {
+		frame, err := readFrame(decoder)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		frames = append(frames, frame)
+
+		if t := frame.Data[0]; t == stream.MsgOutput || t == stream.MsgErrOutput {
+			break
+		}
+		if s, ok := resizeFrame(frame); ok {
+			size = s
+			break
+		}
+	}
+
+	header := asciicastHeader{Version: 2, Width: size.Width, Height: size.Height}
+	if rec != nil {
+		header.Command = strings.Join(rec.Cmd, " ")
+		header.Title = rec.Name
+		header.Timestamp = rec.StartedAt.Unix()
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+
+	var start int64
+	write := func(frame *stream.RecData) error {
+		if start == 0 {
+			start = frame.Time
+		}
+		t := float64(frame.Time-start) / float64(time.Second)
+
+		switch frame.Data[0] {
+		case stream.MsgOutput, stream.MsgErrOutput:
+			return enc.Encode([]interface{}{t, "o", string(frame.Data[1:])})
+		case stream.MsgResize:
+			if s, ok := resizeFrame(frame); ok {
+				return enc.Encode([]interface{}{t, "r", fmt.Sprintf("%dx%d", s.Width, s.Height)})
+			}
+		}
+		return nil
+	}
+
+	for _, frame := range frames {
+		if err := write(frame); err != nil {
+			return err
+		}
+	}
+	for {
+		frame, err := readFrame(decoder)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := write(frame); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Match is a match of the search in the output of a recording
+type Match struct {
+	// Time is the seconds since the start of the recording, as the time of the asciicast events
+	Time float64 `json:"time"`
+	// Line is the line of the output the text is found in
+	Line string `json:"line"`
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]|\x1b\][^\x07]*\x07|\x1b[()][A-Z0-9]`)
+
+// SearchRecording returns the matches of the text in the output of the recording,
+// case-insensitive, at most max matches are returned.
+func SearchRecording(r io.Reader, text string, max int) ([]Match, error) {
+	decoder := gob.NewDecoder(r)
+
+	// the output is concatenated, the offsets map the text back to the frames
+	var out bytes.Buffer
+	var offsets []int
+	var times []int64
+	var start int64
+	for {
+		frame, err := readFrame(decoder)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start == 0 {
+			start = frame.Time
+		}
+		if t := frame.Data[0]; t != stream.MsgOutput && t != stream.MsgErrOutput {
+			continue
+		}
+		offsets = append(offsets, out.Len())
+		times = append(times, frame.Time)
+		out.Write(frame.Data[1:])
+	}
+	if len(times) == 0 || text == "" {
+		return nil, nil
+	}
+
+	data := out.Bytes()
+	lower := bytes.ToLower(data)
+	pattern := bytes.ToLower([]byte(text))
+
+	var matches []Match
+	for pos := 0; len(matches) < max; {
+		i := bytes.Index(lower[pos:], pattern)
+		if i < 0 {
+			break
+		}
+		i += pos
+
+		// the frame of the match
+		n := sort.Search(len(offsets), func(k int) bool { return offsets[k] > i }) - 1
+
+		// the line of the match
+		lineStart := bytes.LastIndexByte(data[:i], '\n') + 1
+		lineEnd := bytes.IndexByte(data[i:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(data)
+		} else {
+			lineEnd += i
+		}
+		line := ansiEscape.ReplaceAllString(string(data[lineStart:lineEnd]), "")
+
+		matches = append(matches, Match{
+			Time: float64(times[n]-start) / float64(time.Second),
+			Line: strings.TrimRight(line, "\r"),
+		})
+
+		// the next match is in the next line
+		pos = lineEnd
+		if pos <= i {
+			pos = i + len(pattern)
+		}
+	}
+
+	return matches, nil
+}
+
+func readFrame(decoder *gob.Decoder) (*stream.RecData, error) {
+	for {
+		frame := &stream.RecData{}
+		if err := decoder.Decode(frame); err != nil {
+			return nil, err
+		}
+		if len(frame.Data) > 0 {
+			return frame, nil
+		}
+	}
+}
+
+func resizeFrame(frame *stream.RecData) (*term.TerminalSize, bool) {
+	if frame.Data[0] != stream.MsgResize {
+		return nil, false
+	}
+
+	size := &term.TerminalSize{}
+	if err := json.Unmarshal(frame.Data[1:], size); err != nil || size.Width == 0 || size.Height == 0 {
+		return nil, false
+	}
+	return size, true
+}
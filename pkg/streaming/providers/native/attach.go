@@ -0,0 +1,158 @@
+package native
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	remotecommandserver "github.com/yubo/apiserver/pkg/streaming/remotecommand"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/stream"
+	"github.com/yubo/golib/term"
+	"github.com/yubo/golib/types"
+)
+
+// AttachMode is the mode of a client attached to a session
+type AttachMode string
+
+const (
+	// AttachModeOwner writes to the session, and kicks the other viewers
+	AttachModeOwner AttachMode = "owner"
+	// AttachModeCollaborator writes to the session
+	AttachModeCollaborator AttachMode = "collaborator"
+	// AttachModeObserver only reads the output of the session
+	AttachModeObserver AttachMode = "observer"
+)
+
+// SessionResource is the resource of the sessions in the authorization, the
+// requester of a session is its owner, the other users attach to the session
+// in the first mode allowed by the verbs of the modes, e.g. the rbac rule
+//
+//	{apiGroups: ["*"], resources: ["sessions"], verbs: ["observe"]}
+const SessionResource = "sessions"
+
+// attachVerbs are the verbs of the attach modes in the authorization, in the
+// order of the privileges
+var attachVerbs = []struct {
+	mode AttachMode
+	verb string
+}{
+	{AttachModeOwner, "own"},
+	{AttachModeCollaborator, "collaborate"},
+	{AttachModeObserver, "observe"},
+}
+
+// AttachOptions are the options of a client attached to a session
+type AttachOptions struct {
+	// User is the name of the user attached
+	User string
+	Mode AttachMode
+}
+
+// ViewerStatus is the status of a client attached to a session
+type ViewerStatus struct {
+	ID         string     `json:"id"`
+	User       string     `json:"user,omitempty"`
+	Mode       AttachMode `json:"mode"`
+	AttachedAt time.Time  `json:"attachedAt"`
+}
+
+type viewer struct {
+	ViewerStatus
+	// seq is the order of the viewers attached
+	seq int
+	tty stream.Tty
+}
+
+// observerTty is the read-only tty of the observers, the input is not sent
+// to the process, and the size is not counted in the size of the session.
+type observerTty struct {
+	*stream.StreamTty
+}
+
+func (p *observerTty) Streams() stream.TtyStreams {
+	s := p.StreamTty.Streams()
+	s.Stdin = nil
+	return s
+}
+
+func (p *observerTty) GetSize() *term.TerminalSize {
+	return nil
+}
+
+// discardInput drops the input of the observer, the tty is closed when the
+// client closes the input.
+func (p *observerTty) discardInput(in io.Reader) {
+	if in == nil {
+		return
+	}
+
+	go func() {
+		io.Copy(io.Discard, in)
+		p.Close()
+	}()
+}
+
+// AttachModeOf returns the mode the user attaches to the session in, the
+// requester of the session is the owner, the other users are checked with
+// the authorizer, a forbidden error is returned if no mode is allowed.
+func AttachModeOf(ctx context.Context, authz authorizer.Authorizer, u user.Info, session *SessionStatus) (AttachMode, error) {
+	if session.Requester != "" && u.GetName() == session.Requester {
+		return AttachModeOwner, nil
+	}
+
+	if authz != nil {
+		for _, v := range attachVerbs {
+			decision, _, err := authz.Authorize(ctx, authorizer.AttributesRecord{
+				User:            u,
+				Verb:            v.verb,
+				Resource:        SessionResource,
+				Name:            session.ID,
+				ResourceRequest: true,
+			})
+			if err != nil {
+				return "", err
+			}
+			if decision == authorizer.DecisionAllow {
+				return v.mode, nil
+			}
+		}
+	}
+
+	return "", errors.NewForbidden("attach", fmt.Errorf("user %q is not allowed to attach to the session %s", u.GetName(), session.ID))
+}
+
+// NewAttacher returns an attacher which attaches the user of the ctx to the
+// session in the mode allowed by the authorizer.
+func NewAttacher(ctx context.Context, runtime Runtime, authz authorizer.Authorizer) remotecommandserver.Attacher {
+	return &attacher{ctx: ctx, runtime: runtime, authorizer: authz}
+}
+
+type attacher struct {
+	ctx        context.Context
+	runtime    Runtime
+	authorizer authorizer.Authorizer
+}
+
+func (p *attacher) AttachContainer(name string, uid types.UID, container string, in io.Reader, out, errOut io.WriteCloser, tty bool, resize <-chan term.TerminalSize) error {
+	u, ok := request.UserFrom(p.ctx)
+	if !ok {
+		return errors.NewUnauthorized("no user found for the request")
+	}
+
+	session, err := p.runtime.GetSession(container)
+	if err != nil {
+		return err
+	}
+
+	mode, err := AttachModeOf(p.ctx, p.authorizer, u, session)
+	if err != nil {
+		return err
+	}
+
+	return p.runtime.AttachWithOptions(container, &AttachOptions{User: u.GetName(), Mode: mode}, in, out, errOut, tty, resize)
+}
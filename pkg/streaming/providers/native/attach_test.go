@@ -0,0 +1,110 @@
+package native
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/golib/api/errors"
+)
+
+var testAttachAuthorizer = authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+	if a.GetResource() != SessionResource {
+		return authorizer.DecisionNoOpinion, "", nil
+	}
+
+	switch a.GetUser().GetName() + "/" + a.GetVerb() {
+	case "admin/own", "carol/collaborate", "bob/observe":
+		return authorizer.DecisionAllow, "", nil
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+})
+
+func TestAttachModeOf(t *testing.T) {
+	session := &SessionStatus{ID: "test", Requester: "tom"}
+
+	cases := []struct {
+		user string
+		mode AttachMode
+	}{
+		{"tom", AttachModeOwner},
+		{"admin", AttachModeOwner},
+		{"carol", AttachModeCollaborator},
+		{"bob", AttachModeObserver},
+	}
+	for _, c := range cases {
+		mode, err := AttachModeOf(context.Background(), testAttachAuthorizer, &user.DefaultInfo{Name: c.user}, session)
+		require.NoError(t, err, c.user)
+		require.Equal(t, c.mode, mode, c.user)
+	}
+
+	_, err := AttachModeOf(context.Background(), testAttachAuthorizer, &user.DefaultInfo{Name: "eve"}, session)
+	require.True(t, errors.IsForbidden(err))
+}
+
+func TestObserverAttach(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	s, err := r.newSession(&ExecConfig{Cmd: []string{"cat"}, Requester: "tom"})
+	require.NoError(t, err)
+	defer s.Close()
+
+	// owner
+	ownerIn, ownerInW := io.Pipe()
+	ownerOut := &buffer{}
+	go r.AttachWithOptions(s.id, &AttachOptions{User: "tom", Mode: AttachModeOwner}, ownerIn, ownerOut, nil, false, nil)
+	require.Eventually(t, func() bool { return s.Status().Attached == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// observer, by the attacher with the authorizer
+	observerIn, observerInW := io.Pipe()
+	observerOut := &buffer{}
+	observerDone := make(chan error, 1)
+	attacher := NewAttacher(request.WithUser(context.Background(), &user.DefaultInfo{Name: "bob"}), r, testAttachAuthorizer)
+	go func() {
+		observerDone <- attacher.AttachContainer("", "", s.id, observerIn, observerOut, nil, false, nil)
+	}()
+
+	require.Eventually(t, func() bool { return s.Status().Attached == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	viewers := s.Status().Viewers
+	require.Equal(t, AttachModeOwner, viewers[0].Mode)
+	require.Equal(t, "bob", viewers[1].User)
+	require.Equal(t, AttachModeObserver, viewers[1].Mode)
+
+	// the input of the observer is discarded
+	_, err = observerInW.Write([]byte("observer\n"))
+	require.NoError(t, err)
+	_, err = ownerInW.Write([]byte("owner\n"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(observerOut.String(), "owner") && strings.Contains(ownerOut.String(), "owner")
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotContains(t, ownerOut.String(), "observer")
+	require.NotContains(t, observerOut.String(), "observer")
+
+	// kick the observer
+	require.NoError(t, r.KickViewer(s.id, viewers[1].ID))
+	select {
+	case <-observerDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the observer is not detached")
+	}
+	require.Equal(t, 1, s.Status().Attached)
+	require.True(t, errors.IsNotFound(r.KickViewer(s.id, viewers[1].ID)))
+
+	// forbidden
+	attacher = NewAttacher(request.WithUser(context.Background(), &user.DefaultInfo{Name: "eve"}), r, testAttachAuthorizer)
+	err = attacher.AttachContainer("", "", s.id, nil, &buffer{}, nil, false, nil)
+	require.True(t, errors.IsForbidden(err))
+
+	// the runtime doesn't attach without the user and the mode
+	require.True(t, errors.IsForbidden(r.Attach(s.id, nil, &buffer{}, nil, false, nil)))
+	require.Equal(t, 1, s.Status().Attached)
+}
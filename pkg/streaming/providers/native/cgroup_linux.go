@@ -0,0 +1,88 @@
+//go:build linux
+
+package native
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const cpuPeriod = 100000
+
+// cgroup is the cgroup v2 of a session, the process is cloned into it
+// by the cgroup fd, so that the limits apply from the start.
+type cgroup struct {
+	path string
+	fd   *os.File
+}
+
+// newCgroup creates the cgroup of the session under root, the controllers
+// of the limits are enabled in root, which must be delegated to the server.
+func newCgroup(root, name string, limits *ResourceLimits) (*cgroup, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+cpu +memory +pids"), 0644); err != nil {
+		return nil, fmt.Errorf("enable the controllers of cgroup %s: %w", root, err)
+	}
+
+	path := filepath.Join(root, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, err
+	}
+	c := &cgroup{path: path}
+
+	files := map[string]string{}
+	if limits.CPU > 0 {
+		files["cpu.max"] = fmt.Sprintf("%d %d", int64(limits.CPU*cpuPeriod), cpuPeriod)
+	}
+	if limits.Memory > 0 {
+		files["memory.max"] = strconv.FormatInt(limits.Memory, 10)
+	}
+	if limits.Pids > 0 {
+		files["pids.max"] = strconv.FormatInt(limits.Pids, 10)
+	}
+	for file, value := range files {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(value), 0644); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("write %s of cgroup %s: %w", file, path, err)
+		}
+	}
+
+	fd, err := os.Open(path)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.fd = fd
+
+	return c, nil
+}
+
+func (p *cgroup) apply(attr *syscall.SysProcAttr) {
+	attr.UseCgroupFD = true
+	attr.CgroupFD = int(p.fd.Fd())
+}
+
+// Close kills the processes left in the cgroup, e.g. the background jobs, and removes it
+func (p *cgroup) Close() error {
+	if p.fd != nil {
+		p.fd.Close()
+	}
+
+	// cgroup.kill is available since linux 5.14
+	os.WriteFile(filepath.Join(p.path, "cgroup.kill"), []byte("1"), 0644)
+
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.Remove(p.path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("remove cgroup %s: %w", p.path, err)
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package native
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCgroup(t *testing.T) {
+	root := t.TempDir()
+
+	cg, err := newCgroup(root, "abc", &ResourceLimits{CPU: 0.5, Memory: 64 << 20, Pids: 10})
+	require.NoError(t, err)
+	defer cg.fd.Close()
+
+	for file, value := range map[string]string{
+		"cgroup.subtree_control": "+cpu +memory +pids",
+		"abc/cpu.max":            "50000 100000",
+		"abc/memory.max":         "67108864",
+		"abc/pids.max":           "10",
+	} {
+		b, err := os.ReadFile(filepath.Join(root, file))
+		require.NoError(t, err)
+		require.Equal(t, value, string(b), file)
+	}
+
+	// the cgroup of the session exists
+	_, err = newCgroup(root, "abc", &ResourceLimits{Pids: 10})
+	require.Error(t, err)
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package native
+
+import (
+	"fmt"
+	"syscall"
+)
+
+type cgroup struct{}
+
+func newCgroup(root, name string, limits *ResourceLimits) (*cgroup, error) {
+	return nil, fmt.Errorf("the resource limits are only supported on linux")
+}
+
+func (p *cgroup) apply(attr *syscall.SysProcAttr) {}
+
+func (p *cgroup) Close() error {
+	return nil
+}
@@ -0,0 +1,226 @@
+package native
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/request"
+	remotecommandserver "github.com/yubo/apiserver/pkg/streaming/remotecommand"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/term"
+	"github.com/yubo/golib/types"
+)
+
+// defaultPath is the PATH of the commands run as another user
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+type ExecConfig struct {
+	// User that will run the command, "user[:group]", the name or the id,
+	// the server must be privileged to change the user.
+	User string `json:"user,omitempty"`
+	// Detach returns after the session is started, the session id is
+	// written to the stdout, the client can attach to it later.
+	Detach bool `json:"detach,omitempty"`
+	// DetachKeys is the escape keys for detach, defaults to "ctrl-p,ctrl-q"
+	DetachKeys string `json:"detachKeys,omitempty"`
+	// Env is appended to the environment, which is inherited from the server,
+	// or is a minimal one of the user if the user is set.
+	Env []string `json:"env,omitempty"`
+	// WorkingDir defaults to the working directory of the server, or the home
+	// directory of the user if the user is set.
+	WorkingDir string `json:"workingDir,omitempty"`
+	// Cmd is the command and args
+	Cmd []string `json:"cmd,omitempty"`
+	// Timeout kills the session after the duration, 0 means no timeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// AllowedCommands is the allowlist of the commands, the command is resolved
+	// by PATH and compared with the resolved allowlist, empty allows all.
+	// Only the command, argv[0], is matched, the args are not checked, so an
+	// allowed shell or interpreter, e.g. "sh -c ...", runs any command.
+	AllowedCommands []string `json:"allowedCommands,omitempty"`
+	// Limits is the cgroup v2 resource limits of the session
+	Limits *ResourceLimits `json:"limits,omitempty"`
+	// Requester is the name of the user who requested the session, e.g.
+	// set by the ExecConfigSelector, it's saved with the recording.
+	Requester string `json:"-"`
+}
+
+// ResourceLimits is written to the cgroup v2 of the session, the zero values are unlimited.
+type ResourceLimits struct {
+	// CPU is the number of the cpus, e.g. 0.5, written to cpu.max
+	CPU float64 `json:"cpu,omitempty"`
+	// Memory is the bytes written to memory.max
+	Memory int64 `json:"memory,omitempty"`
+	// Pids is the number of the processes written to pids.max
+	Pids int64 `json:"pids,omitempty"`
+}
+
+func (p *ResourceLimits) isZero() bool {
+	return p == nil || (p.CPU == 0 && p.Memory == 0 && p.Pids == 0)
+}
+
+func (p *ExecConfig) Validate() error {
+	if len(p.Cmd) == 0 {
+		return errors.NewBadRequest("empty command")
+	}
+	if l := p.Limits; l != nil && (l.CPU < 0 || l.Memory < 0 || l.Pids < 0) {
+		return errors.NewBadRequest("resource limits must not be negative")
+	}
+
+	return p.checkCommand()
+}
+
+// checkCommand checks the command with the allowlist
+func (p *ExecConfig) checkCommand() error {
+	if len(p.AllowedCommands) == 0 {
+		return nil
+	}
+
+	if path, err := exec.LookPath(p.Cmd[0]); err == nil {
+		for _, c := range p.AllowedCommands {
+			if allowed, err := exec.LookPath(c); err == nil && allowed == path {
+				return nil
+			}
+		}
+	}
+
+	return errors.NewForbidden("exec", fmt.Errorf("command %q is not allowed", p.Cmd[0]))
+}
+
+// command returns the cmd with the user, env and working directory of the config,
+// the process is killed when the ctx is done.
+func (p *ExecConfig) command(ctx context.Context) (*exec.Cmd, error) {
+	path, err := exec.LookPath(p.Cmd[0])
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, path, p.Cmd[1:]...)
+	cmd.Env = os.Environ()
+	cmd.Dir = p.WorkingDir
+
+	if p.User != "" {
+		u, cred, err := lookupUser(p.User)
+		if err != nil {
+			return nil, err
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+		cmd.Env = []string{
+			"PATH=" + defaultPath,
+			"HOME=" + u.HomeDir,
+			"USER=" + u.Username,
+			"LOGNAME=" + u.Username,
+		}
+		if cmd.Dir == "" {
+			cmd.Dir = u.HomeDir
+		}
+	}
+
+	cmd.Env = append(cmd.Env, p.Env...)
+
+	return cmd, nil
+}
+
+// lookupUser parses "user[:group]", the supplementary groups of the user
+// are set if the group is not specified.
+func lookupUser(spec string) (*user.User, *syscall.Credential, error) {
+	name, group, hasGroup := strings.Cut(spec, ":")
+
+	u, err := user.Lookup(name)
+	if _, ok := err.(user.UnknownUserError); ok {
+		u, err = user.LookupId(name)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("lookup user %q: %w", name, err)
+	}
+
+	uid, err := parseID(u.Uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	cred := &syscall.Credential{Uid: uid}
+
+	if hasGroup {
+		g, err := user.LookupGroup(group)
+		if _, ok := err.(user.UnknownGroupError); ok {
+			g, err = user.LookupGroupId(group)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("lookup group %q: %w", group, err)
+		}
+		if cred.Gid, err = parseID(g.Gid); err != nil {
+			return nil, nil, err
+		}
+		return u, cred, nil
+	}
+
+	if cred.Gid, err = parseID(u.Gid); err != nil {
+		return nil, nil, err
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, nil, fmt.Errorf("lookup groups of user %q: %w", name, err)
+	}
+	for _, gid := range gids {
+		id, err := parseID(gid)
+		if err != nil {
+			return nil, nil, err
+		}
+		cred.Groups = append(cred.Groups, id)
+	}
+
+	return u, cred, nil
+}
+
+func parseID(id string) (uint32, error) {
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", id)
+	}
+	return uint32(n), nil
+}
+
+// NewExecutor returns an executor which runs the commands with the config,
+// e.g. the config chosen by the ExecConfigSelector for the user of the request.
+func NewExecutor(runtime Runtime, config ExecConfig) remotecommandserver.Executor {
+	return &executor{runtime: runtime, config: config}
+}
+
+type executor struct {
+	runtime Runtime
+	config  ExecConfig
+}
+
+func (p *executor) ExecInContainer(podName string, podUID types.UID, container string, cmd []string, in io.Reader, out, errOut io.WriteCloser, tty bool, resize <-chan term.TerminalSize, timeout time.Duration) error {
+	config := p.config
+	config.Cmd = cmd
+	return p.runtime.ExecWithConfig(&config, in, out, errOut, tty, resize)
+}
+
+// NewUserExecutor returns an executor which runs the commands with the default
+// config of the runtime as the user of the ctx, the user owns the sessions.
+func NewUserExecutor(ctx context.Context, runtime Runtime) remotecommandserver.Executor {
+	return &userExecutor{ctx: ctx, runtime: runtime}
+}
+
+type userExecutor struct {
+	ctx     context.Context
+	runtime Runtime
+}
+
+func (p *userExecutor) ExecInContainer(podName string, podUID types.UID, container string, cmd []string, in io.Reader, out, errOut io.WriteCloser, tty bool, resize <-chan term.TerminalSize, timeout time.Duration) error {
+	u, ok := request.UserFrom(p.ctx)
+	if !ok {
+		return errors.NewUnauthorized("no user found for the request")
+	}
+
+	return p.runtime.ExecAs(u.GetName(), cmd, in, out, errOut, tty, resize)
+}
@@ -0,0 +1,122 @@
+package native
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/golib/api/errors"
+)
+
+type buffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (p *buffer) Write(b []byte) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.Buffer.Write(b)
+}
+
+func (p *buffer) String() string {
+	p.Lock()
+	defer p.Unlock()
+	return p.Buffer.String()
+}
+
+func (p *buffer) Close() error { return nil }
+
+func TestExecConfigAllowedCommands(t *testing.T) {
+	config := &ExecConfig{Cmd: []string{"sh"}, AllowedCommands: []string{"sh", "bash"}}
+	require.NoError(t, config.Validate())
+
+	config.Cmd = []string{"sleep", "1"}
+	require.True(t, errors.IsForbidden(config.Validate()))
+
+	// only the command is matched, the args of an allowed shell are not checked
+	config.Cmd = []string{"sh", "-c", "sleep 1"}
+	require.NoError(t, config.Validate())
+
+	config.Cmd = nil
+	require.True(t, errors.IsBadRequest(config.Validate()))
+}
+
+func TestExecConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	config := &ExecConfig{Cmd: []string{"sh"}, Env: []string{"FOO=bar"}, WorkingDir: dir}
+
+	cmd, err := config.command(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, dir, cmd.Dir)
+	require.Equal(t, "FOO=bar", cmd.Env[len(cmd.Env)-1])
+	require.Len(t, cmd.Env, len(os.Environ())+1)
+
+	config = &ExecConfig{Cmd: []string{"sh"}, User: "0:0"}
+	cmd, err = config.command(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), cmd.SysProcAttr.Credential.Uid)
+	require.Equal(t, uint32(0), cmd.SysProcAttr.Credential.Gid)
+	require.Contains(t, cmd.Env, "PATH="+defaultPath)
+
+	config.User = "no-such-user"
+	_, err = config.command(context.Background())
+	require.Error(t, err)
+}
+
+func TestExecWithConfig(t *testing.T) {
+	r, _ := newTestRuntime(t)
+	dir := t.TempDir()
+
+	out := &buffer{}
+	err := r.ExecWithConfig(&ExecConfig{
+		Cmd:        []string{"sh", "-c", "sleep 0.2; echo $FOO; pwd"},
+		Env:        []string{"FOO=bar"},
+		WorkingDir: dir,
+	}, nil, out, nil, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, "bar\r\n"+dir+"\r\n", out.String())
+
+	// detach
+	out = &buffer{}
+	require.NoError(t, r.ExecWithConfig(&ExecConfig{Cmd: []string{"sleep", "10"}, Detach: true}, nil, out, nil, false, nil))
+	status, err := r.GetSession(strings.TrimSpace(out.String()))
+	require.NoError(t, err)
+	require.True(t, status.Running)
+	require.NoError(t, r.KillSession(status.ID))
+}
+
+func TestExecConfigSelector(t *testing.T) {
+	selector := &ExecConfigSelector{
+		Authorizer: authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+			if a.GetVerb() == "use" && a.GetResource() == ExecConfigResource &&
+				(a.GetUser().GetName() == "admin" || a.GetName() == "restricted") {
+				return authorizer.DecisionAllow, "", nil
+			}
+			return authorizer.DecisionNoOpinion, "", nil
+		}),
+		Configs: []NamedExecConfig{
+			{Name: "admin", ExecConfig: ExecConfig{User: "root"}},
+			{Name: "restricted", ExecConfig: ExecConfig{User: "nobody", AllowedCommands: []string{"top"}}},
+		},
+	}
+
+	config, err := selector.Select(request.WithUser(context.Background(), &user.DefaultInfo{Name: "admin"}))
+	require.NoError(t, err)
+	require.Equal(t, "root", config.User)
+
+	config, err = selector.Select(request.WithUser(context.Background(), &user.DefaultInfo{Name: "tom"}))
+	require.NoError(t, err)
+	require.Equal(t, "nobody", config.User)
+
+	selector.Configs = selector.Configs[:1]
+	_, err = selector.Select(request.WithUser(context.Background(), &user.DefaultInfo{Name: "tom"}))
+	require.True(t, errors.IsForbidden(err))
+}
@@ -0,0 +1,183 @@
+package native
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/pkg/streaming/portforward"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defPortForwardHost        = "127.0.0.1"
+	defPortForwardDialTimeout = 10 * time.Second
+
+	// PortForwardResource is the resource of the port forwarding in the authorization,
+	// the verb is "create" and the name is the "host:port" forwarded to.
+	PortForwardResource = "portforward"
+)
+
+// PortForwardConfig restricts the addresses the ports are forwarded to,
+// the pod sandbox id of the request is the host, defaults to 127.0.0.1.
+type PortForwardConfig struct {
+	// AllowedAddresses is the allowlist of "host:port" or "host:port-port",
+	// the host "*" matches any host, empty forbids the port forwarding.
+	AllowedAddresses []string
+	// DialTimeout defaults to 10s
+	DialTimeout time.Duration
+}
+
+func (p *PortForwardConfig) Validate() error {
+	for _, addr := range p.AllowedAddresses {
+		if _, _, _, err := parseAllowedAddress(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// address returns the address to dial if it's allowed
+func (p *PortForwardConfig) address(host string, port int32) (string, error) {
+	if host == "" {
+		host = defPortForwardHost
+	}
+
+	for _, addr := range p.AllowedAddresses {
+		h, min, max, err := parseAllowedAddress(addr)
+		if err != nil {
+			continue
+		}
+		if (h == "*" || h == host) && port >= min && port <= max {
+			return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+		}
+	}
+
+	return "", errors.NewForbidden("portforward", fmt.Errorf("address %s:%d is not allowed", host, port))
+}
+
+func parseAllowedAddress(addr string) (host string, min, max int32, err error) {
+	host, ports, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid allowed address %q: %v", addr, err)
+	}
+	if host == "" {
+		host = defPortForwardHost
+	}
+
+	first, last, found := strings.Cut(ports, "-")
+	if min, err = parsePort(first); err != nil {
+		return "", 0, 0, fmt.Errorf("invalid allowed address %q: %v", addr, err)
+	}
+	max = min
+	if found {
+		if max, err = parsePort(last); err != nil {
+			return "", 0, 0, fmt.Errorf("invalid allowed address %q: %v", addr, err)
+		}
+	}
+	if min > max {
+		return "", 0, 0, fmt.Errorf("invalid allowed address %q: invalid port range", addr)
+	}
+
+	return host, min, max, nil
+}
+
+func parsePort(s string) (int32, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return int32(port), nil
+}
+
+// PortForward forwards the stream to the port of the host, the host is the pod
+// sandbox id of the request, the address must be allowed by the config.
+func (p *streamingRuntime) PortForward(host string, port int32, stream io.ReadWriteCloser) error {
+	config := p.options.portForward
+
+	addr, err := config.address(host, port)
+	if err != nil {
+		return err
+	}
+
+	timeout := config.DialTimeout
+	if timeout == 0 {
+		timeout = defPortForwardDialTimeout
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	klog.V(3).InfoS("port forward", "address", addr)
+	defer klog.V(3).InfoS("port forward done", "address", addr)
+
+	go func() {
+		// half close the conn after the client is done, the error of the
+		// stream closes the conn to stop the copy below
+		if _, err := io.Copy(conn, stream); err != nil {
+			conn.Close()
+			return
+		}
+		if c, ok := conn.(*net.TCPConn); ok {
+			c.CloseWrite()
+		}
+	}()
+
+	if _, err := io.Copy(stream, conn); err != nil {
+		return fmt.Errorf("port forward %s: %v", addr, err)
+	}
+
+	return nil
+}
+
+// NewPortForwarder returns a port forwarder which checks the addresses with the
+// authorizer for the user of the ctx, before forwarding them by the runtime.
+func NewPortForwarder(ctx context.Context, runtime Runtime, authz authorizer.Authorizer) portforward.PortForwarder {
+	return &portForwarder{ctx: ctx, runtime: runtime, authorizer: authz}
+}
+
+type portForwarder struct {
+	ctx        context.Context
+	runtime    Runtime
+	authorizer authorizer.Authorizer
+}
+
+func (p *portForwarder) PortForward(name string, uid types.UID, port int32, stream io.ReadWriteCloser) error {
+	u, ok := request.UserFrom(p.ctx)
+	if !ok {
+		return errors.NewUnauthorized("no user found for the request")
+	}
+
+	host := name
+	if host == "" {
+		host = defPortForwardHost
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	decision, reason, err := p.authorizer.Authorize(p.ctx, authorizer.AttributesRecord{
+		User:            u,
+		Verb:            "create",
+		Resource:        PortForwardResource,
+		Name:            addr,
+		ResourceRequest: true,
+	})
+	if err != nil {
+		return err
+	}
+	if decision != authorizer.DecisionAllow {
+		return errors.NewForbidden("portforward", fmt.Errorf("user %q is not allowed to forward to %s: %s", u.GetName(), addr, reason))
+	}
+
+	return p.runtime.PortForward(host, port, stream)
+}
@@ -0,0 +1,209 @@
+package native
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/pkg/streaming"
+	"github.com/yubo/apiserver/pkg/streaming/portforward"
+	"github.com/yubo/client-go/rest"
+	"github.com/yubo/client-go/transport/spdy"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+)
+
+// pipeStream is the client side of the stream
+type pipeStream struct {
+	io.Reader
+	io.WriteCloser
+}
+
+func newEchoServer(t *testing.T) int32 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return int32(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestPortForwardConfig(t *testing.T) {
+	config := &PortForwardConfig{AllowedAddresses: []string{"127.0.0.1:8080", "localhost:9000-9100", "*:6060"}}
+	require.NoError(t, config.Validate())
+
+	cases := []struct {
+		host    string
+		port    int32
+		allowed bool
+	}{
+		{"", 8080, true},
+		{"127.0.0.1", 8080, true},
+		{"127.0.0.1", 8081, false},
+		{"localhost", 9050, true},
+		{"localhost", 9101, false},
+		{"10.0.0.1", 6060, true},
+		{"10.0.0.1", 8080, false},
+	}
+	for _, c := range cases {
+		_, err := config.address(c.host, c.port)
+		if c.allowed {
+			require.NoError(t, err, "%s:%d", c.host, c.port)
+		} else {
+			require.True(t, errors.IsForbidden(err), "%s:%d", c.host, c.port)
+		}
+	}
+
+	for _, addr := range []string{"8080", "127.0.0.1:0", "127.0.0.1:9100-9000", "127.0.0.1:a"} {
+		config := &PortForwardConfig{AllowedAddresses: []string{addr}}
+		require.Error(t, config.Validate(), addr)
+	}
+}
+
+func TestPortForward(t *testing.T) {
+	port := newEchoServer(t)
+	r, _ := newTestRuntime(t, WithPortForward(PortForwardConfig{
+		AllowedAddresses: []string{"127.0.0.1:" + strconv.Itoa(int(port))},
+	}))
+
+	forward := func(portForward func(stream io.ReadWriteCloser) error) ([]byte, error) {
+		inR, inW := io.Pipe()
+		outR, outW := io.Pipe()
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- portForward(&pipeStream{Reader: inR, WriteCloser: outW})
+			outW.Close()
+		}()
+
+		go func() {
+			inW.Write([]byte("hello"))
+			inW.Close()
+		}()
+
+		b, _ := io.ReadAll(outR)
+		return b, <-errCh
+	}
+
+	b, err := forward(func(stream io.ReadWriteCloser) error { return r.PortForward("", port, stream) })
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+
+	_, err = forward(func(stream io.ReadWriteCloser) error { return r.PortForward("", port+1, stream) })
+	require.True(t, errors.IsForbidden(err))
+
+	// authorized per user
+	authz := authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+		if a.GetUser().GetName() == "admin" && a.GetVerb() == "create" && a.GetResource() == PortForwardResource {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionNoOpinion, "", nil
+	})
+	forwardAs := func(name string) func(stream io.ReadWriteCloser) error {
+		pf := NewPortForwarder(request.WithUser(context.Background(), &user.DefaultInfo{Name: name}), r, authz)
+		return func(stream io.ReadWriteCloser) error { return pf.PortForward("", "", port, stream) }
+	}
+
+	b, err = forward(forwardAs("admin"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+
+	_, err = forward(forwardAs("tom"))
+	require.True(t, errors.IsForbidden(err))
+}
+
+func TestServePortForwardWithPortForwarder(t *testing.T) {
+	port := newEchoServer(t)
+	r, _ := newTestRuntime(t, WithPortForward(PortForwardConfig{
+		AllowedAddresses: []string{"127.0.0.1:" + strconv.Itoa(int(port))},
+	}))
+
+	authz := authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+		if a.GetUser().GetName() == "admin" {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionNoOpinion, "", nil
+	})
+
+	var s streaming.Server
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.ServeHTTP(w, req)
+	}))
+	defer testServer.Close()
+	testURL, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	config := streaming.DefaultConfig
+	config.BaseURL = testURL
+	config.Authenticator = authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+		name := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		return &authenticator.Response{User: &user.DefaultInfo{Name: name}}, true, nil
+	})
+	config.PortForwarder = func(ctx context.Context) portforward.PortForwarder {
+		return NewPortForwarder(ctx, r, authz)
+	}
+	s, err = streaming.NewServer(config, r)
+	require.NoError(t, err)
+
+	// forward returns the echo of the data stream and the error stream
+	forward := func(name string) (string, string) {
+		resp, err := s.GetPortForward(&api.PortForwardRequest{PodSandboxId: "127.0.0.1", Port: []int32{port}})
+		require.NoError(t, err)
+		reqURL, err := url.Parse(resp.Url)
+		require.NoError(t, err)
+
+		transport, upgrader, err := spdy.RoundTripperFor(&rest.Config{BearerToken: name})
+		require.NoError(t, err)
+		conn, _, err := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", reqURL).Dial(portforward.ProtocolV1Name)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		headers := http.Header{}
+		headers.Set(api.StreamType, api.StreamTypeError)
+		headers.Set(api.PortHeader, strconv.Itoa(int(port)))
+		errorStream, err := conn.CreateStream(headers)
+		require.NoError(t, err)
+		errorStream.Close()
+
+		headers.Set(api.StreamType, api.StreamTypeData)
+		dataStream, err := conn.CreateStream(headers)
+		require.NoError(t, err)
+		dataStream.Write([]byte("hello"))
+		dataStream.Close()
+
+		data, _ := io.ReadAll(dataStream)
+		msg, _ := io.ReadAll(errorStream)
+		return string(data), string(msg)
+	}
+
+	data, msg := forward("admin")
+	require.Empty(t, msg)
+	require.Equal(t, "hello", data)
+
+	data, msg = forward("tom")
+	require.Contains(t, msg, "forbidden")
+	require.Empty(t, data)
+}
@@ -1,18 +1,70 @@
 package native
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/yubo/golib/api/errors"
 	"github.com/yubo/golib/stream"
 	"github.com/yubo/golib/util"
 )
 
+// metaSuffix is the suffix of the metadata file of a recording
+const metaSuffix = ".meta.json"
+
 type RecorderProvider interface {
 	Open(path string) (stream.Recorder, error)
 }
 
-func NewFileRecorderProvider(dir string) (RecorderProvider, error) {
+// Recording is the metadata of a recording
+type Recording struct {
+	// Name is the path of the recording, from the rec file path factory
+	Name      string     `json:"name"`
+	SessionID string     `json:"sessionID"`
+	User      string     `json:"user,omitempty"`
+	Cmd       []string   `json:"cmd"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	// Size is the bytes of the recording
+	Size int64 `json:"size"`
+}
+
+// RecordingStore is a RecorderProvider which also saves the metadata of
+// the recordings, and reads them back.
+type RecordingStore interface {
+	RecorderProvider
+
+	// Finish saves the metadata after the recording is closed, the size is set by the store
+	Finish(rec *Recording) error
+	// List returns a page of the recordings selected by the opts, the latest first
+	List(ctx context.Context, opts RecordingListOptions) ([]*Recording, error)
+	Get(ctx context.Context, name string) (*Recording, error)
+	// OpenReader returns the data of the recording
+	OpenReader(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// RecordingListOptions selects the recordings of RecordingStore.List
+type RecordingListOptions struct {
+	// User, if set, selects the recordings of the user
+	User string
+	// Offset and Limit select a page of the recordings, 0 Limit returns all of them
+	Offset int
+	Limit  int
+	// Total, if set, is set to the number of the recordings selected before paging
+	Total *int
+}
+
+// NewFileRecorderProvider saves the recordings in the dir, which is walked to list
+// them, so it should be used for the recordings only, e.g. not /tmp.
+func NewFileRecorderProvider(dir string) (RecordingStore, error) {
 	return &fileRecorderProvider{prefixPath: dir}, nil
 }
 
@@ -37,3 +89,125 @@ func (p *fileRecorderProvider) Open(path string) (stream.Recorder, error) {
 
 	return stream.NewRecorder(fd)
 }
+
+// path returns the file path of the recording, the name must be local
+func (p *fileRecorderProvider) path(name string) (string, error) {
+	if !filepath.IsLocal(name) || strings.HasSuffix(name, metaSuffix) {
+		return "", errors.NewBadRequest("invalid recording name: " + name)
+	}
+	return filepath.Join(p.prefixPath, name), nil
+}
+
+func (p *fileRecorderProvider) Finish(rec *Recording) error {
+	path, err := p.path(rec.Name)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	rec.Size = fi.Size()
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+metaSuffix, b, 0644)
+}
+
+func (p *fileRecorderProvider) List(ctx context.Context, opts RecordingListOptions) ([]*Recording, error) {
+	var list []*Recording
+	err := filepath.WalkDir(p.prefixPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, metaSuffix) {
+			return nil
+		}
+
+		rec, err := readMeta(path)
+		if err != nil {
+			return err
+		}
+		list = append(list, rec)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return selectRecordings(list, opts), nil
+}
+
+func (p *fileRecorderProvider) Get(ctx context.Context, name string) (*Recording, error) {
+	path, err := p.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := readMeta(path + metaSuffix)
+	if os.IsNotExist(err) {
+		return nil, errors.NewNotFound("recording " + name)
+	}
+	return rec, err
+}
+
+func (p *fileRecorderProvider) OpenReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	path, err := p.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NewNotFound("recording " + name)
+	}
+	return fd, err
+}
+
+func readMeta(path string) (*Recording, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &Recording{}
+	if err := json.Unmarshal(b, rec); err != nil {
+		return nil, fmt.Errorf("invalid recording metadata %s: %v", path, err)
+	}
+	return rec, nil
+}
+
+// selectRecordings returns the page of the recordings selected by the opts, the latest first
+func selectRecordings(list []*Recording, opts RecordingListOptions) []*Recording {
+	if opts.User != "" {
+		selected := list[:0]
+		for _, rec := range list {
+			if rec.User == opts.User {
+				selected = append(selected, rec)
+			}
+		}
+		list = selected
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.After(list[j].StartedAt)
+	})
+
+	if opts.Total != nil {
+		*opts.Total = len(list)
+	}
+
+	if opts.Offset > 0 {
+		if opts.Offset >= len(list) {
+			return nil
+		}
+		list = list[opts.Offset:]
+	}
+	if opts.Limit > 0 && opts.Limit < len(list) {
+		list = list[:opts.Limit]
+	}
+	return list
+}
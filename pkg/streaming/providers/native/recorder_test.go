@@ -0,0 +1,261 @@
+package native
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/golib/api/errors"
+)
+
+// record runs the command in a session recorded by the store, and returns the recording
+func record(t *testing.T, store RecordingStore, cmd ...string) *Recording {
+	r, _ := newTestRuntime(t, WithRecorder(store), WithRecFilePathFactroy(func(id string) string { return id }))
+
+	s, err := r.newSession(&ExecConfig{Cmd: cmd, Requester: "tom"})
+	require.NoError(t, err)
+	waitExited(t, r, s.id)
+
+	var rec *Recording
+	require.Eventually(t, func() bool {
+		rec, err = store.Get(context.Background(), s.id)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return rec
+}
+
+func TestFileRecording(t *testing.T) {
+	store, err := NewFileRecorderProvider(t.TempDir())
+	require.NoError(t, err)
+
+	rec := record(t, store, "sh", "-c", "echo hello world")
+	require.Equal(t, "tom", rec.User)
+	require.Equal(t, []string{"sh", "-c", "echo hello world"}, rec.Cmd)
+	require.NotNil(t, rec.EndedAt)
+	require.NotZero(t, rec.Size)
+
+	list, err := store.List(context.Background(), RecordingListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	// asciicast
+	r, err := store.OpenReader(context.Background(), rec.Name)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteAsciicast(&buf, r, rec))
+
+	scanner := bufio.NewScanner(&buf)
+	require.True(t, scanner.Scan())
+	header := &asciicastHeader{}
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), header))
+	require.Equal(t, 2, header.Version)
+	require.Equal(t, "sh -c echo hello world", header.Command)
+
+	var output string
+	for scanner.Scan() {
+		var event []interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Len(t, event, 3)
+		if event[1] == "o" {
+			output += event[2].(string)
+		}
+	}
+	require.Contains(t, output, "hello world")
+
+	// search
+	r2, err := store.OpenReader(context.Background(), rec.Name)
+	require.NoError(t, err)
+	defer r2.Close()
+
+	matches, err := SearchRecording(r2, "HELLO", 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, "hello world", matches[0].Line)
+
+	// invalid names
+	_, err = store.Get(context.Background(), "../etc/passwd")
+	require.True(t, errors.IsBadRequest(err))
+	_, err = store.Get(context.Background(), "unknown")
+	require.True(t, errors.IsNotFound(err))
+}
+
+func TestRecordingAPI(t *testing.T) {
+	store, err := NewFileRecorderProvider(t.TempDir())
+	require.NoError(t, err)
+	rec := record(t, store, "sh", "-c", "echo hello world")
+
+	container := rest.NewBaseContainer()
+	NewRecordingAPI(store).Install(container)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get(RecordingAPIPath + "/?q=world")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	out := &recordingListOutput{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), out))
+	require.Equal(t, 1, out.Total)
+	require.Equal(t, rec.Name, out.List[0].Name)
+	require.Len(t, out.List[0].Matches, 1)
+
+	w = get(RecordingAPIPath + "/?q=nothing")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), out))
+	require.Equal(t, 0, out.Total)
+
+	w = get(RecordingAPIPath + "/" + rec.Name + "/asciicast")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, MIME_ASCIICAST, w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), "hello world")
+
+	w = get(RecordingAPIPath + "/unknown")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestFileRecordingList(t *testing.T) {
+	store, err := NewFileRecorderProvider(t.TempDir())
+	require.NoError(t, err)
+
+	// r0..r4 are started in order, by tom and jerry in turn
+	now := time.Now()
+	for i, u := range []string{"tom", "jerry", "tom", "jerry", "tom"} {
+		name := "r" + strconv.Itoa(i)
+		rec, err := store.Open(name)
+		require.NoError(t, err)
+		require.NoError(t, rec.Close())
+		require.NoError(t, store.Finish(&Recording{Name: name, User: u, StartedAt: now.Add(time.Duration(i) * time.Minute)}))
+	}
+
+	names := func(list []*Recording) (ret []string) {
+		for _, rec := range list {
+			ret = append(ret, rec.Name)
+		}
+		return
+	}
+
+	total := 0
+	list, err := store.List(context.Background(), RecordingListOptions{User: "tom", Offset: 1, Limit: 1, Total: &total})
+	require.NoError(t, err)
+	require.Equal(t, []string{"r2"}, names(list))
+	require.Equal(t, 3, total)
+
+	list, err = store.List(context.Background(), RecordingListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"r4", "r3"}, names(list))
+
+	list, err = store.List(context.Background(), RecordingListOptions{Offset: 5})
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	// the api pages the recordings
+	container := rest.NewBaseContainer()
+	NewRecordingAPI(store).Install(container)
+
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, httptest.NewRequest("GET", RecordingAPIPath+"/?user=jerry&pageSize=1&current=2", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	out := &recordingListOutput{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), out))
+	require.Equal(t, 2, out.Total)
+	require.Len(t, out.List, 1)
+	require.Equal(t, "r1", out.List[0].Name)
+}
+
+// fakeS3 is an in-memory s3.S3Client
+type fakeS3 struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (p *fakeS3) Put(ctx context.Context, objectPath, contentType string, reader io.Reader, objectSize int64) error {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) != objectSize {
+		return io.ErrShortWrite
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	p.objects[objectPath] = b
+	return nil
+}
+
+func (p *fakeS3) Get(ctx context.Context, objectPath string) (io.ReadCloser, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	b, ok := p.objects[objectPath]
+	if !ok {
+		return nil, errors.NewNotFound(objectPath)
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+func (p *fakeS3) List(ctx context.Context, prefix string) ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	var paths []string
+	for k := range p.objects {
+		if strings.HasPrefix(k, prefix) {
+			paths = append(paths, k)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (p *fakeS3) Remove(ctx context.Context, objectPath string) error {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.objects, objectPath)
+	return nil
+}
+
+func (p *fakeS3) Location(objectPath string) string {
+	return objectPath
+}
+
+func TestS3Recording(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	store := NewS3RecorderProvider(client, "recordings")
+
+	rec := record(t, store, "sh", "-c", "echo hello s3")
+	require.NotZero(t, rec.Size)
+	require.Len(t, client.objects, 2)
+	require.Len(t, client.objects["recordings/"+rec.Name], int(rec.Size))
+
+	list, err := store.List(context.Background(), RecordingListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	r, err := store.OpenReader(context.Background(), rec.Name)
+	require.NoError(t, err)
+	defer r.Close()
+
+	matches, err := SearchRecording(r, "s3", 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+
+	_, err = store.Get(context.Background(), "unknown")
+	require.True(t, errors.IsNotFound(err))
+}
@@ -4,21 +4,26 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/yubo/apiserver/pkg/streaming"
 	"github.com/yubo/golib/api/errors"
-	"github.com/yubo/golib/stream"
 	"github.com/yubo/golib/term"
+	"github.com/yubo/golib/util"
+	"github.com/yubo/golib/util/clock"
 	"github.com/yubo/golib/util/rand"
 	"k8s.io/klog/v2"
 )
 
 const (
-	defIdLent     = 10
-	defGcInterval = time.Minute
+	defIdLent           = 10
+	defGcInterval       = time.Minute
+	defExitGracePeriod  = 5 * time.Minute
+	defExitDrainTimeout = time.Second
+	defCgroupRoot       = "/sys/fs/cgroup/apiserver-exec"
 )
 
 func NewProvider(ctx context.Context, opts ...Opt) streaming.Provider {
@@ -30,16 +35,48 @@ func NewProvider(ctx context.Context, opts ...Opt) streaming.Provider {
 	return streaming.NewProvider(runtime)
 }
 
-func NewRuntime(ctx context.Context, opts ...Opt) (streaming.Runtime, error) {
+// SessionManager lists, inspects and kills the sessions of the runtime
+type SessionManager interface {
+	ListSessions() []*SessionStatus
+	GetSession(id string) (*SessionStatus, error)
+	KillSession(id string) error
+	// KickViewer detaches the viewer from the session
+	KickViewer(id, viewerID string) error
+}
+
+// Runtime is the native streaming runtime
+type Runtime interface {
+	streaming.Runtime
+	SessionManager
+
+	// ExecAs runs the command with the default config of the runtime, the user
+	// is the requester of the session, see AttachModeOf
+	ExecAs(user string, cmd []string, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error
+
+	// ExecWithConfig runs the command with the config, instead of the default one of the runtime
+	ExecWithConfig(config *ExecConfig, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error
+
+	// AttachWithOptions attaches to the session in the mode of the options
+	AttachWithOptions(sessionID string, options *AttachOptions, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error
+}
+
+func NewRuntime(ctx context.Context, opts ...Opt) (Runtime, error) {
 	options := &Options{
 		idLen:              defIdLent,
 		gcInterval:         defGcInterval,
+		exitGracePeriod:    defExitGracePeriod,
 		recFilePathFactory: defRecFilePathFactory,
+		cgroupRoot:         defCgroupRoot,
+		clock:              clock.RealClock{},
 	}
 	for _, opt := range opts {
 		opt(options)
 	}
 
+	if err := options.portForward.Validate(); err != nil {
+		return nil, err
+	}
+
 	r := &streamingRuntime{
 		ctx:      ctx,
 		sessions: make(map[string]*Session),
@@ -56,8 +93,14 @@ func NewRuntime(ctx context.Context, opts ...Opt) (streaming.Runtime, error) {
 type Options struct {
 	recorderProvider   RecorderProvider
 	gcInterval         time.Duration
+	exitGracePeriod    time.Duration
+	idleTimeout        time.Duration
 	idLen              int
 	recFilePathFactory func(sessionId string) string
+	execConfig         ExecConfig
+	cgroupRoot         string
+	portForward        PortForwardConfig
+	clock              clock.WithTicker
 }
 
 type Opt func(*Options)
@@ -80,6 +123,50 @@ func WithGcInterval(gcInterval time.Duration) Opt {
 	}
 }
 
+// WithExitGracePeriod keeps the exited sessions for the period, so that the
+// exit code can be inspected, the sessions are removed by the gc after that.
+func WithExitGracePeriod(period time.Duration) Opt {
+	return func(o *Options) {
+		o.exitGracePeriod = period
+	}
+}
+
+// WithIdleTimeout kills the running sessions which have no client attached
+// for the timeout, 0 means the detached sessions are kept until they exit.
+func WithIdleTimeout(timeout time.Duration) Opt {
+	return func(o *Options) {
+		o.idleTimeout = timeout
+	}
+}
+
+// WithExecConfig sets the default config of Exec, the command is from the request.
+func WithExecConfig(config ExecConfig) Opt {
+	return func(o *Options) {
+		o.execConfig = config
+	}
+}
+
+// WithCgroupRoot sets the parent cgroup of the sessions with resource limits,
+// defaults to /sys/fs/cgroup/apiserver-exec
+func WithCgroupRoot(root string) Opt {
+	return func(o *Options) {
+		o.cgroupRoot = root
+	}
+}
+
+// WithPortForward sets the addresses allowed to forward to
+func WithPortForward(config PortForwardConfig) Opt {
+	return func(o *Options) {
+		o.portForward = config
+	}
+}
+
+func WithClock(clock clock.WithTicker) Opt {
+	return func(o *Options) {
+		o.clock = clock
+	}
+}
+
 func WithRecFilePathFactroy(factory func(sessionId string) string) Opt {
 	return func(o *Options) {
 		o.recFilePathFactory = factory
@@ -95,56 +182,132 @@ type streamingRuntime struct {
 	options  *Options
 }
 
-type execConfig struct {
-	User       string   // User that will run the command
-	Detach     bool     // Execute in detach mode
-	DetachKeys string   // Escape keys for detach
-	Env        []string // Environment variables
-	WorkingDir string   // Working directory
-	Cmd        []string // Execution commands and args
-	Timeout    time.Duration
-	recorder   stream.Recorder
+// Exec runs the command without a requester, the session has no owner but the
+// users allowed to "own" it by the authorizer, the executor returned by
+// NewUserExecutor runs the command as the user of the request.
+func (p *streamingRuntime) Exec(containerID string, cmd []string, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
+	return p.ExecAs("", cmd, in, out, errOut, isTty, resize)
 }
 
-func (p *streamingRuntime) Exec(containerID string, cmd []string, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
+func (p *streamingRuntime) ExecAs(user string, cmd []string, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
+	config := p.options.execConfig
+	config.Cmd = cmd
+	config.Requester = user
+
+	return p.ExecWithConfig(&config, in, out, errOut, isTty, resize)
+}
+
+func (p *streamingRuntime) ExecWithConfig(config *ExecConfig, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
 
-	session, err := p.newSession(&execConfig{Cmd: cmd, Timeout: 0})
+	session, err := p.newSession(config)
 	if err != nil {
 		return fmt.Errorf("failed to exec - Exec setup failed - %v", err)
 	}
 
-	return session.Attach(in, out, errOut, isTty, resize)
+	if config.Detach {
+		if out != nil {
+			fmt.Fprintln(out, session.id)
+		}
+		return nil
+	}
+
+	return session.Attach(&AttachOptions{User: config.Requester, Mode: AttachModeOwner}, in, out, errOut, isTty, resize)
 }
 
+// Attach refuses to attach without the user and the mode, the attacher returned
+// by NewAttacher attaches the user of the request in the mode allowed by the
+// authorizer, see streaming.Config.Attacher.
 func (p *streamingRuntime) Attach(sessionID string, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
+	return errors.NewForbidden("attach", fmt.Errorf("unable to attach to the session %s without the user and the mode", sessionID))
+}
+
+func (p *streamingRuntime) AttachWithOptions(sessionID string, options *AttachOptions, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
 	session, err := p.checkSessionStatus(sessionID)
 	if err != nil {
 		return err
 	}
-	return session.Attach(in, out, errOut, isTty, resize)
+	return session.Attach(options, in, out, errOut, isTty, resize)
+}
+
+func (p *streamingRuntime) ListSessions() []*SessionStatus {
+	p.RLock()
+	defer p.RUnlock()
+
+	list := make([]*SessionStatus, 0, len(p.sessions))
+	for _, s := range p.sessions {
+		list = append(list, s.Status())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	return list
+}
+
+func (p *streamingRuntime) GetSession(id string) (*SessionStatus, error) {
+	s, err := p.getSession(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.Status(), nil
+}
+
+// KillSession kills the process of the session, the clients attached are
+// disconnected, the session is kept for the exit grace period.
+func (p *streamingRuntime) KillSession(id string) error {
+	s, err := p.getSession(id)
+	if err != nil {
+		return err
+	}
+	if !s.Status().Running {
+		return errors.NewBadRequest("session is not running: " + id)
+	}
+
+	return s.Close()
 }
 
-func (r *streamingRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
-	return fmt.Errorf("unsupported port forward")
+func (p *streamingRuntime) KickViewer(id, viewerID string) error {
+	s, err := p.getSession(id)
+	if err != nil {
+		return err
+	}
+
+	return s.Kick(viewerID)
 }
 
 func (p *streamingRuntime) start() error {
-	go func() {
-		ticker := time.NewTicker(p.options.gcInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-p.ctx.Done():
-				return
-			case <-ticker.C:
-				// TODO: add sessions gc
-				klog.V(6).Infof("todo gc")
+	util.UntilWithTick(p.gc, p.options.clock.NewTicker(p.options.gcInterval).C(), p.ctx.Done())
+
+	return nil
+}
+
+// gc kills the sessions idle for the idle timeout, and removes the sessions
+// exited for the exit grace period.
+func (p *streamingRuntime) gc() {
+	now := p.options.clock.Now()
+
+	p.Lock()
+	defer p.Unlock()
+
+	for id, s := range p.sessions {
+		status := s.Status()
+
+		if !status.Running {
+			if status.ExitedAt != nil && now.Sub(*status.ExitedAt) >= p.options.exitGracePeriod {
+				delete(p.sessions, id)
+				klog.V(3).InfoS("session removed", "id", id, "exitCode", status.ExitCode)
 			}
+			continue
 		}
-	}()
 
-	return nil
+		if p.options.idleTimeout > 0 && status.IdleSince != nil && now.Sub(*status.IdleSince) >= p.options.idleTimeout {
+			klog.InfoS("session killed, idle timeout", "id", id, "pid", status.Pid, "idleSince", status.IdleSince)
+			s.Close()
+		}
+	}
 }
 
 func (p *streamingRuntime) getSession(id string) (*Session, error) {
@@ -171,8 +334,7 @@ func (p *streamingRuntime) uniqueID() (string, error) {
 	return "", fmt.Errorf("failed to generate unique id")
 }
 
-// TODO
-func (p *streamingRuntime) newSession(config *execConfig) (*Session, error) {
+func (p *streamingRuntime) newSession(config *ExecConfig) (*Session, error) {
 	p.Lock()
 	defer p.Unlock()
 
@@ -182,7 +344,7 @@ func (p *streamingRuntime) newSession(config *execConfig) (*Session, error) {
 	}
 
 	s := &Session{
-		execConfig: config,
+		ExecConfig: config,
 		id:         id,
 		Options:    p.options,
 	}
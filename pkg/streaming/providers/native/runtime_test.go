@@ -0,0 +1,125 @@
+package native
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/golib/api/errors"
+	testingclock "github.com/yubo/golib/util/clock/testing"
+)
+
+func newTestRuntime(t *testing.T, opts ...Opt) (*streamingRuntime, *testingclock.FakeClock) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	clock := testingclock.NewFakeClock(time.Now())
+	r, err := NewRuntime(ctx, append([]Opt{WithClock(clock), WithGcInterval(time.Hour)}, opts...)...)
+	require.NoError(t, err)
+
+	return r.(*streamingRuntime), clock
+}
+
+func waitExited(t *testing.T, r *streamingRuntime, id string) *SessionStatus {
+	var status *SessionStatus
+	require.Eventually(t, func() bool {
+		s, err := r.GetSession(id)
+		require.NoError(t, err)
+		status = s
+		return !s.Running
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return status
+}
+
+func TestSessionExit(t *testing.T) {
+	r, clock := newTestRuntime(t, WithExitGracePeriod(time.Minute))
+
+	s, err := r.newSession(&ExecConfig{Cmd: []string{"sh", "-c", "exit 3"}})
+	require.NoError(t, err)
+	require.NotZero(t, s.Status().Pid)
+
+	status := waitExited(t, r, s.id)
+	require.Equal(t, 3, status.ExitCode)
+	require.NotNil(t, status.ExitedAt)
+	require.Len(t, r.ListSessions(), 1)
+
+	// kept for the exit grace period
+	r.gc()
+	require.Len(t, r.ListSessions(), 1)
+
+	clock.Step(time.Minute)
+	r.gc()
+	_, err = r.GetSession(s.id)
+	require.True(t, errors.IsNotFound(err))
+}
+
+func TestKillSession(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	s, err := r.newSession(&ExecConfig{Cmd: []string{"sleep", "10"}})
+	require.NoError(t, err)
+
+	require.NoError(t, r.KillSession(s.id))
+	status := waitExited(t, r, s.id)
+	require.Equal(t, 128+9, status.ExitCode)
+
+	require.Error(t, r.KillSession(s.id))
+	require.True(t, errors.IsNotFound(r.KillSession("unknown")))
+}
+
+func TestAPIKillSession(t *testing.T) {
+	r, _ := newTestRuntime(t)
+	api := NewAPI(r, testAttachAuthorizer)
+
+	s, err := r.newSession(&ExecConfig{Cmd: []string{"sleep", "10"}, Requester: "tom"})
+	require.NoError(t, err)
+
+	kill := func(name string) error {
+		req := httptest.NewRequest("DELETE", "/", nil)
+		req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: name}))
+		_, err := api.killSession(nil, req, &idParam{ID: s.id})
+		return err
+	}
+
+	// only the owners kill the session
+	require.True(t, errors.IsForbidden(kill("eve")))
+	require.True(t, errors.IsForbidden(kill("bob")))
+	require.True(t, s.Status().Running)
+
+	require.NoError(t, kill("tom"))
+	waitExited(t, r, s.id)
+}
+
+func TestUserExecutor(t *testing.T) {
+	r, _ := newTestRuntime(t)
+
+	executor := NewUserExecutor(request.WithUser(context.Background(), &user.DefaultInfo{Name: "tom"}), r)
+	require.NoError(t, executor.ExecInContainer("", "", "", []string{"true"}, nil, &buffer{}, nil, false, nil, 0))
+
+	list := r.ListSessions()
+	require.Len(t, list, 1)
+	require.Equal(t, "tom", list[0].Requester)
+
+	executor = NewUserExecutor(context.Background(), r)
+	err := executor.ExecInContainer("", "", "", []string{"true"}, nil, &buffer{}, nil, false, nil, 0)
+	require.True(t, errors.IsUnauthorized(err))
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	r, clock := newTestRuntime(t, WithIdleTimeout(time.Minute))
+
+	s, err := r.newSession(&ExecConfig{Cmd: []string{"sleep", "10"}})
+	require.NoError(t, err)
+
+	r.gc()
+	require.True(t, s.Status().Running)
+
+	clock.Step(time.Minute)
+	r.gc()
+	waitExited(t, r, s.id)
+}
@@ -0,0 +1,165 @@
+package native
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/s3"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/stream"
+)
+
+const s3UploadTimeout = 5 * time.Minute
+
+// NewS3RecorderProvider stores the recordings in s3 with the prefix, a recording
+// is buffered in a temporary file, and is uploaded after it's closed.
+func NewS3RecorderProvider(client s3.S3Client, prefix string) RecordingStore {
+	return &s3RecorderProvider{client: client, prefix: prefix}
+}
+
+type s3RecorderProvider struct {
+	client s3.S3Client
+	prefix string
+	// sizes of the recordings uploaded but not finished
+	sizes sync.Map
+}
+
+func (p *s3RecorderProvider) objectPath(name string) (string, error) {
+	if name == "" || strings.HasSuffix(name, metaSuffix) || path.Clean("/"+name) != "/"+name {
+		return "", errors.NewBadRequest("invalid recording name: " + name)
+	}
+	return path.Join(p.prefix, name), nil
+}
+
+func (p *s3RecorderProvider) Open(name string) (stream.Recorder, error) {
+	objectPath, err := p.objectPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := os.CreateTemp("", "recording-")
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.NewRecorder(&s3Upload{
+		File: fd,
+		upload: func(r io.Reader, size int64) error {
+			ctx, cancel := context.WithTimeout(context.Background(), s3UploadTimeout)
+			defer cancel()
+
+			if err := p.client.Put(ctx, objectPath, "application/octet-stream", r, size); err != nil {
+				return err
+			}
+			p.sizes.Store(name, size)
+			return nil
+		},
+	})
+}
+
+// s3Upload uploads the temporary file on close, once
+type s3Upload struct {
+	*os.File
+	upload func(r io.Reader, size int64) error
+	once   sync.Once
+	err    error
+}
+
+func (p *s3Upload) Close() error {
+	p.once.Do(func() {
+		defer os.Remove(p.Name())
+		defer p.File.Close()
+
+		size, err := p.Seek(0, io.SeekCurrent)
+		if err != nil {
+			p.err = err
+			return
+		}
+		if _, err := p.Seek(0, io.SeekStart); err != nil {
+			p.err = err
+			return
+		}
+		p.err = p.upload(p.File, size)
+	})
+	return p.err
+}
+
+func (p *s3RecorderProvider) Finish(rec *Recording) error {
+	objectPath, err := p.objectPath(rec.Name)
+	if err != nil {
+		return err
+	}
+
+	if size, ok := p.sizes.LoadAndDelete(rec.Name); ok {
+		rec.Size = size.(int64)
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3UploadTimeout)
+	defer cancel()
+	return p.client.Put(ctx, objectPath+metaSuffix, "application/json", bytes.NewReader(b), int64(len(b)))
+}
+
+func (p *s3RecorderProvider) List(ctx context.Context, opts RecordingListOptions) ([]*Recording, error) {
+	paths, err := p.client.List(ctx, p.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Recording
+	for _, objectPath := range paths {
+		if !strings.HasSuffix(objectPath, metaSuffix) {
+			continue
+		}
+		rec, err := p.readMeta(ctx, objectPath)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rec)
+	}
+
+	return selectRecordings(list, opts), nil
+}
+
+func (p *s3RecorderProvider) Get(ctx context.Context, name string) (*Recording, error) {
+	objectPath, err := p.objectPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.readMeta(ctx, objectPath+metaSuffix)
+}
+
+func (p *s3RecorderProvider) OpenReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	objectPath, err := p.objectPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.client.Get(ctx, objectPath)
+}
+
+func (p *s3RecorderProvider) readMeta(ctx context.Context, objectPath string) (*Recording, error) {
+	r, err := p.client.Get(ctx, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	rec := &Recording{}
+	if err := json.NewDecoder(r).Decode(rec); err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	return rec, nil
+}
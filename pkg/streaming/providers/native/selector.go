@@ -0,0 +1,60 @@
+package native
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/golib/api/errors"
+)
+
+// ExecConfigResource is the resource of the exec configs in the authorization,
+// a user can use the config if the "use" verb on it is allowed, like the
+// podsecuritypolicies of kubernetes, e.g. the rbac rule
+//
+//	{apiGroups: ["*"], resources: ["execconfigs"], resourceNames: ["operator"], verbs: ["use"]}
+const ExecConfigResource = "execconfigs"
+
+// NamedExecConfig is an exec config named for the authorization
+type NamedExecConfig struct {
+	Name string
+	ExecConfig
+}
+
+// ExecConfigSelector chooses the exec config of the user by the authorizer,
+// the configs are checked in order, the first one allowed is chosen.
+type ExecConfigSelector struct {
+	Authorizer authorizer.Authorizer
+	Configs    []NamedExecConfig
+}
+
+// Select returns the config for the user of the ctx, or a forbidden error if
+// the user is allowed to use none of the configs.
+func (p *ExecConfigSelector) Select(ctx context.Context) (*ExecConfig, error) {
+	u, ok := request.UserFrom(ctx)
+	if !ok {
+		return nil, errors.NewUnauthorized("no user found for the request")
+	}
+
+	for i := range p.Configs {
+		c := &p.Configs[i]
+		decision, _, err := p.Authorizer.Authorize(ctx, authorizer.AttributesRecord{
+			User:            u,
+			Verb:            "use",
+			Resource:        ExecConfigResource,
+			Name:            c.Name,
+			ResourceRequest: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if decision == authorizer.DecisionAllow {
+			config := c.ExecConfig
+			config.Requester = u.GetName()
+			return &config, nil
+		}
+	}
+
+	return nil, errors.NewForbidden("exec", fmt.Errorf("user %q is not allowed to use any exec config", u.GetName()))
+}
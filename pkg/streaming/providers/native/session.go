@@ -2,12 +2,16 @@ package native
 
 import (
 	"context"
-	"errors"
 	"io"
-	"os/exec"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/yubo/golib/api/errors"
 	"github.com/yubo/golib/stream"
 	"github.com/yubo/golib/term"
 	"k8s.io/klog/v2"
@@ -19,55 +23,200 @@ const (
 
 type Session struct {
 	sync.RWMutex
-	*execConfig
+	*ExecConfig
 	*Options
 
-	ctx      context.Context
-	cancel   context.CancelFunc
-	id       string
-	proxyTty *stream.ProxyTty
-	running  bool
+	ctx        context.Context
+	cancel     context.CancelFunc
+	id         string
+	proxyTty   *stream.ProxyTty
+	running    bool
+	pid        int
+	exitCode   int
+	viewers    map[string]*viewer
+	viewerSeq  int
+	createdAt  time.Time
+	exitedAt   time.Time
+	detachedAt time.Time
 }
 
 type SessionStatus struct {
-	ID       string `json:"id"`
-	Running  bool   `json:"running"`
-	ExitCode int    `json:"exitCode"`
-	Pid      int    `json:"pid"`
+	ID  string   `json:"id"`
+	Cmd []string `json:"cmd"`
+	// Requester is the user who requested the session
+	Requester string `json:"requester,omitempty"`
+	Running   bool   `json:"running"`
+	ExitCode  int    `json:"exitCode"`
+	Pid       int    `json:"pid"`
+	// Attached is the number of the clients attached
+	Attached int `json:"attached"`
+	// Viewers are the clients attached, with the owners, collaborators and observers
+	Viewers   []*ViewerStatus `json:"viewers"`
+	CreatedAt time.Time       `json:"createdAt"`
+	ExitedAt  *time.Time      `json:"exitedAt,omitempty"`
+	// IdleSince is the time the last client detached, nil if any client is attached
+	IdleSince *time.Time `json:"idleSince,omitempty"`
 }
 
 func (p *Session) Status() *SessionStatus {
 	p.RLock()
 	defer p.RUnlock()
 
-	return &SessionStatus{
-		ID:      p.id,
-		Running: p.running,
+	status := &SessionStatus{
+		ID:        p.id,
+		Cmd:       p.Cmd,
+		Requester: p.Requester,
+		Running:   p.running,
+		ExitCode:  p.exitCode,
+		Pid:       p.pid,
+		Attached:  len(p.viewers),
+		Viewers:   make([]*ViewerStatus, 0, len(p.viewers)),
+		CreatedAt: p.createdAt,
 	}
+	viewers := make([]*viewer, 0, len(p.viewers))
+	for _, v := range p.viewers {
+		viewers = append(viewers, v)
+	}
+	sort.Slice(viewers, func(i, j int) bool { return viewers[i].seq < viewers[j].seq })
+	for _, v := range viewers {
+		vs := v.ViewerStatus
+		status.Viewers = append(status.Viewers, &vs)
+	}
+	if !p.exitedAt.IsZero() {
+		exitedAt := p.exitedAt
+		status.ExitedAt = &exitedAt
+	}
+	if len(p.viewers) == 0 {
+		detachedAt := p.detachedAt
+		status.IdleSince = &detachedAt
+	}
+
+	return status
 }
 
-func (p *Session) Attach(in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
+// Attach attaches the client to the session in the mode of the options,
+// the input of the observers is discarded.
+func (p *Session) Attach(options *AttachOptions, in io.Reader, out, errOut io.WriteCloser, isTty bool, resize <-chan term.TerminalSize) error {
 	// stream
 	streamTty := stream.NewStreamTty(p.ctx, in, out, errOut, isTty, resize)
 
-	if err := p.proxyTty.AddTty(streamTty); err != nil {
+	var tty stream.Tty = streamTty
+	if options.Mode == AttachModeObserver {
+		observer := &observerTty{StreamTty: streamTty}
+		observer.discardInput(in)
+		tty = observer
+	}
+
+	var opts []stream.Opt
+	if p.DetachKeys != "" {
+		opts = append(opts, stream.WithDetach(true, p.DetachKeys))
+	}
+
+	if err := p.proxyTty.AddTty(tty, opts...); err != nil {
 		return err
 	}
 
+	p.Lock()
+	p.viewerSeq++
+	v := &viewer{
+		ViewerStatus: ViewerStatus{
+			ID:         strconv.Itoa(p.viewerSeq),
+			User:       options.User,
+			Mode:       options.Mode,
+			AttachedAt: p.clock.Now(),
+		},
+		seq: p.viewerSeq,
+		tty: tty,
+	}
+	p.viewers[v.ID] = v
+	p.Unlock()
+
+	klog.V(3).InfoS("attached", "id", p.id, "viewer", v.ID, "user", v.User, "mode", v.Mode)
+
 	<-streamTty.Done()
 	klog.V(6).Infof("attach done")
 
+	p.detach(v.ID)
+
 	return streamTty.Err()
 }
 
-func (p *Session) Close() error {
-	p.running = false
+func (p *Session) detach(viewerID string) *viewer {
+	p.Lock()
+	defer p.Unlock()
+
+	v, ok := p.viewers[viewerID]
+	if !ok {
+		return nil
+	}
+
+	delete(p.viewers, viewerID)
+	if len(p.viewers) == 0 {
+		p.detachedAt = p.clock.Now()
+	}
+	return v
+}
+
+// Kick detaches the viewer from the session
+func (p *Session) Kick(viewerID string) error {
+	v := p.detach(viewerID)
+	if v == nil {
+		return errors.NewNotFound("viewer id: " + viewerID)
+	}
+
+	klog.InfoS("viewer kicked", "id", p.id, "viewer", viewerID, "user", v.User)
+	return v.tty.Close()
+}
 
+// Close kills the process of the session
+func (p *Session) Close() error {
 	p.cancel()
 
 	return nil
 }
 
+// finishRecording saves the metadata of the recording if the provider is a RecordingStore
+func (p *Session) finishRecording(name string) {
+	store, ok := p.recorderProvider.(RecordingStore)
+	if !ok {
+		return
+	}
+
+	endedAt := p.clock.Now()
+	if err := store.Finish(&Recording{
+		Name:      name,
+		SessionID: p.id,
+		User:      p.Requester,
+		Cmd:       p.Cmd,
+		StartedAt: p.createdAt,
+		EndedAt:   &endedAt,
+	}); err != nil {
+		klog.ErrorS(err, "failed to save the recording", "id", p.id, "name", name)
+	}
+}
+
+// exit records the exit status of the process
+func (p *Session) exit(state *os.ProcessState) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.running = false
+	p.exitedAt = p.clock.Now()
+	p.exitCode = exitCode(state)
+}
+
+// exitCode returns the exit code of the process, or 128+n if the process
+// was terminated by the signal n, like the shells.
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
 func (p *Session) init(ctx context.Context) error {
 	if p.Timeout == 0 {
 		p.ctx, p.cancel = context.WithCancel(ctx)
@@ -76,19 +225,28 @@ func (p *Session) init(ctx context.Context) error {
 	}
 
 	p.proxyTty = stream.NewProxyTty(p.ctx, defaultBufSize)
+	p.viewers = make(map[string]*viewer)
+
+	p.createdAt = p.clock.Now()
+	p.detachedAt = p.createdAt
 
 	started := make(chan error)
 
 	go func() {
 		defer p.Close()
+		defer p.proxyTty.Close()
 
 		if p.recorderProvider != nil {
-			recorder, err := p.recorderProvider.Open(p.recFilePathFactory(p.id))
+			name := p.recFilePathFactory(p.id)
+			recorder, err := p.recorderProvider.Open(name)
 			if err != nil {
 				started <- err
 				return
 			}
-			defer recorder.Close()
+			defer func() {
+				recorder.Close()
+				p.finishRecording(name)
+			}()
 
 			if err := recorder.Info([]byte(strings.Join(p.Cmd, " "))); err != nil {
 				started <- err
@@ -102,23 +260,65 @@ func (p *Session) init(ctx context.Context) error {
 		}
 
 		if len(p.Cmd) == 0 {
-			started <- errors.New("empty command")
+			started <- errors.NewBadRequest("empty command")
+			return
+		}
+
+		cmd, err := p.command(p.ctx)
+		if err != nil {
+			started <- err
 			return
 		}
 
-		pty, err := stream.NewCmdPty(exec.CommandContext(p.ctx, p.Cmd[0], p.Cmd[1:]...))
+		if !p.Limits.isZero() {
+			cg, err := newCgroup(p.cgroupRoot, p.id, p.Limits)
+			if err != nil {
+				started <- err
+				return
+			}
+			defer cg.Close()
+
+			if cmd.SysProcAttr == nil {
+				cmd.SysProcAttr = &syscall.SysProcAttr{}
+			}
+			cg.apply(cmd.SysProcAttr)
+		}
+
+		pty, err := stream.NewCmdPty(cmd)
 		if err != nil {
 			started <- err
 			return
 		}
 		defer pty.Close()
 
+		p.Lock()
 		p.running = true
+		p.pid = cmd.Process.Pid
+		p.Unlock()
 
 		started <- nil
 
-		err = <-p.proxyTty.CopyToPty(pty)
-		klog.V(6).Infof("session %s exit %v", p.id, err)
+		// the pty has been released by the parent, Wait only reaps the process
+		waitCh := make(chan error, 1)
+		go func() { waitCh <- cmd.Wait() }()
+
+		copyCh := p.proxyTty.CopyToPty(pty)
+		select {
+		case err = <-copyCh:
+			// the streams are closed, e.g. the session is killed
+			p.cancel()
+			<-waitCh
+		case <-waitCh:
+			// flush the output left in the pty, the children of the
+			// process may hold the pty open, so don't wait forever
+			select {
+			case err = <-copyCh:
+			case <-time.After(defExitDrainTimeout):
+			}
+		}
+
+		p.exit(cmd.ProcessState)
+		klog.V(3).InfoS("session exited", "id", p.id, "pid", p.pid, "exitCode", p.exitCode, "err", err)
 	}()
 
 	return <-started
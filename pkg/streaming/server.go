@@ -17,6 +17,7 @@ limitations under the License.
 package streaming
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"io"
@@ -24,15 +25,23 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 	"time"
 
 	restful "github.com/emicklei/go-restful/v3"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/filters"
+	genericapirequest "github.com/yubo/apiserver/pkg/request"
 	"github.com/yubo/golib/api"
 	"github.com/yubo/apiserver/pkg/streaming/portforward"
 	remotecommandserver "github.com/yubo/apiserver/pkg/streaming/remotecommand"
+	"github.com/yubo/golib/scheme"
 	"github.com/yubo/golib/types"
 	remotecommandconsts "github.com/yubo/golib/util/remotecommand"
 	"github.com/yubo/golib/term"
+	"github.com/yubo/golib/util/sets"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -97,8 +106,43 @@ type Config struct {
 
 	// The config for serving over TLS. If nil, TLS will not be used.
 	TLSConfig *tls.Config
+
+	// The authenticator of the requests, e.g. the authenticator of the apiserver,
+	// which also accepts the bearer token in the websocket protocols.
+	// If nil, the requests are only protected by the one-time tokens.
+	Authenticator authenticator.Request
+	// The authorizer of the requests, the exec and attach requests are checked as
+	// the "exec" and "attach" subresources of the containers, the portforward
+	// requests as the "portforward" subresource of the podsandboxes, with the
+	// verb "create". If nil, the requests are not authorized. It requires the Authenticator.
+	Authorizer authorizer.Authorizer
+	// The auditor of the requests, the command line of the exec requests is
+	// logged as an annotation of the audit events. If nil, the requests are not audited.
+	Auditor audit.Auditor
+
+	// Executor, if set, returns the executor of the exec requests of the user of the ctx,
+	// e.g. native.NewUserExecutor, which runs the command as the user. Defaults to the runtime.
+	Executor func(ctx context.Context) remotecommandserver.Executor
+	// Attacher, if set, returns the attacher of the attach requests of the user of the ctx,
+	// e.g. native.NewAttacher, which attaches in the mode allowed by the authorizer.
+	// Defaults to the runtime.
+	Attacher func(ctx context.Context) remotecommandserver.Attacher
+	// PortForwarder, if set, returns the port forwarder of the portforward requests of the
+	// user of the ctx, e.g. native.NewPortForwarder, which checks the addresses with the
+	// authorizer. Defaults to the runtime.
+	PortForwarder func(ctx context.Context) portforward.PortForwarder
 }
 
+const (
+	// ContainerResource is the resource of the exec and attach requests in the authorization
+	ContainerResource = "containers"
+	// PodSandboxResource is the resource of the portforward requests in the authorization
+	PodSandboxResource = "podsandboxes"
+
+	// commandAnnotationKey is the audit annotation of the command line of the exec requests
+	commandAnnotationKey = "streaming.apiserver/command"
+)
+
 // DefaultConfig provides default values for server Config. The DefaultConfig is partial, so
 // some fields like Addr must still be provided.
 var DefaultConfig = Config{
@@ -109,8 +153,11 @@ var DefaultConfig = Config{
 }
 
 // NewServer creates a new Server for stream requests.
-// TODO(tallclair): Add auth(n/z) interface & handling.
 func NewServer(config Config, runtime Runtime) (Server, error) {
+	if config.Authorizer != nil && config.Authenticator == nil {
+		return nil, errors.New("the authorizer requires an authenticator")
+	}
+
 	s := &server{
 		config:  config,
 		runtime: &criAdapter{runtime},
@@ -148,7 +195,7 @@ func NewServer(config Config, runtime Runtime) (Server, error) {
 	}
 	handler := restful.NewContainer()
 	handler.Add(ws)
-	s.handler = handler
+	s.handler = s.withAuthentication(handler)
 	s.server = &http.Server{
 		Addr:      s.config.Addr,
 		Handler:   s.handler,
@@ -261,6 +308,57 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.handler.ServeHTTP(w, r)
 }
 
+// withAuthentication authenticates the requests by the authenticator of the config,
+// the failed requests are audited as the non-resource requests.
+func (s *server) withAuthentication(handler http.Handler) http.Handler {
+	if s.config.Authenticator == nil {
+		return handler
+	}
+
+	failedHandler := filters.Unauthorized(scheme.NegotiatedSerializer)
+	if auditor := s.config.Auditor; auditor != nil {
+		failedHandler = filters.WithFailedAuthenticationAudit(failedHandler, auditor.Backend(), auditor.Checker())
+	}
+
+	handler = filters.WithAuthentication(handler, s.config.Authenticator, failedHandler, nil, false)
+	handler = filters.WithRequestInfo(handler, &genericapirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString(),
+		GrouplessAPIPrefixes: sets.NewString(),
+	})
+	return filters.WithRequestReceivedTimestamp(handler)
+}
+
+// serveAuthorized serves the request with the authorizer and the auditor of the config,
+// the request is checked as the subresource of the resource, which is resolved from the
+// cached request, because the url only carries the token.
+func (s *server) serveAuthorized(w http.ResponseWriter, req *http.Request, resource, name, subresource string, cmd []string, handler http.HandlerFunc) {
+	var h http.Handler = handler
+	if s.config.Authorizer != nil {
+		h = filters.WithAuthorization(h, s.config.Authorizer, scheme.NegotiatedSerializer)
+	}
+	if auditor := s.config.Auditor; auditor != nil {
+		if len(cmd) > 0 {
+			next := h
+			h = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				audit.AddAuditAnnotation(req.Context(), commandAnnotationKey, strings.Join(cmd, " "))
+				next.ServeHTTP(w, req)
+			})
+		}
+		h = filters.WithAudit(h, auditor.Backend(), auditor.Checker(), func(*http.Request, *genericapirequest.RequestInfo) bool { return true })
+	}
+
+	ctx := genericapirequest.WithRequestInfo(req.Context(), &genericapirequest.RequestInfo{
+		IsResourceRequest: true,
+		Path:              req.URL.Path,
+		Verb:              "create",
+		Resource:          resource,
+		Subresource:       subresource,
+		Name:              name,
+		Parts:             []string{resource, name, subresource},
+	})
+	h.ServeHTTP(w, req.WithContext(ctx))
+}
+
 func (s *server) buildURL(method, token string) string {
 	return s.config.BaseURL.ResolveReference(&url.URL{
 		Path: path.Join(method, token),
@@ -287,18 +385,25 @@ func (s *server) serveExec(req *restful.Request, resp *restful.Response) {
 		TTY:    exec.Tty,
 	}
 
-	remotecommandserver.ServeExec(
-		resp.ResponseWriter,
-		req.Request,
-		s.runtime,
-		"", // unused: podName
-		"", // unusued: podUID
-		exec.ContainerId,
-		exec.Cmd,
-		streamOpts,
-		s.config.StreamIdleTimeout,
-		s.config.StreamCreationTimeout,
-		s.config.SupportedRemoteCommandProtocols)
+	s.serveAuthorized(resp.ResponseWriter, req.Request, ContainerResource, exec.ContainerId, "exec", exec.Cmd, func(w http.ResponseWriter, r *http.Request) {
+		var executor remotecommandserver.Executor = s.runtime
+		if s.config.Executor != nil {
+			executor = s.config.Executor(r.Context())
+		}
+
+		remotecommandserver.ServeExec(
+			w,
+			r,
+			executor,
+			"", // unused: podName
+			"", // unusued: podUID
+			exec.ContainerId,
+			exec.Cmd,
+			streamOpts,
+			s.config.StreamIdleTimeout,
+			s.config.StreamCreationTimeout,
+			s.config.SupportedRemoteCommandProtocols)
+	})
 }
 
 func (s *server) serveAttach(req *restful.Request, resp *restful.Response) {
@@ -320,17 +425,24 @@ func (s *server) serveAttach(req *restful.Request, resp *restful.Response) {
 		Stderr: attach.Stderr,
 		TTY:    attach.Tty,
 	}
-	remotecommandserver.ServeAttach(
-		resp.ResponseWriter,
-		req.Request,
-		s.runtime,
-		"", // unused: podName
-		"", // unusued: podUID
-		attach.ContainerId,
-		streamOpts,
-		s.config.StreamIdleTimeout,
-		s.config.StreamCreationTimeout,
-		s.config.SupportedRemoteCommandProtocols)
+	s.serveAuthorized(resp.ResponseWriter, req.Request, ContainerResource, attach.ContainerId, "attach", nil, func(w http.ResponseWriter, r *http.Request) {
+		var attacher remotecommandserver.Attacher = s.runtime
+		if s.config.Attacher != nil {
+			attacher = s.config.Attacher(r.Context())
+		}
+
+		remotecommandserver.ServeAttach(
+			w,
+			r,
+			attacher,
+			"", // unused: podName
+			"", // unusued: podUID
+			attach.ContainerId,
+			streamOpts,
+			s.config.StreamIdleTimeout,
+			s.config.StreamCreationTimeout,
+			s.config.SupportedRemoteCommandProtocols)
+	})
 }
 
 func (s *server) servePortForward(req *restful.Request, resp *restful.Response) {
@@ -352,16 +464,23 @@ func (s *server) servePortForward(req *restful.Request, resp *restful.Response)
 		return
 	}
 
-	portforward.ServePortForward(
-		resp.ResponseWriter,
-		req.Request,
-		s.runtime,
-		pf.PodSandboxId,
-		"", // unused: podUID
-		portForwardOptions,
-		s.config.StreamIdleTimeout,
-		s.config.StreamCreationTimeout,
-		s.config.SupportedPortForwardProtocols)
+	s.serveAuthorized(resp.ResponseWriter, req.Request, PodSandboxResource, pf.PodSandboxId, "portforward", nil, func(w http.ResponseWriter, r *http.Request) {
+		var forwarder portforward.PortForwarder = s.runtime
+		if s.config.PortForwarder != nil {
+			forwarder = s.config.PortForwarder(r.Context())
+		}
+
+		portforward.ServePortForward(
+			w,
+			r,
+			forwarder,
+			pf.PodSandboxId,
+			"", // unused: podUID
+			portForwardOptions,
+			s.config.StreamIdleTimeout,
+			s.config.StreamCreationTimeout,
+			s.config.SupportedPortForwardProtocols)
+	})
 }
 
 func NewProvider(r Runtime) Provider {
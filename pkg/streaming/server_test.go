@@ -17,6 +17,7 @@ limitations under the License.
 package streaming
 
 import (
+	"context"
 	"crypto/tls"
 	"io"
 	"net/http"
@@ -29,13 +30,22 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/apiserver/pkg/audit/policy"
+	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	genericapirequest "github.com/yubo/apiserver/pkg/request"
 	"github.com/yubo/apiserver/pkg/streaming/portforward"
+	remotecommandserver "github.com/yubo/apiserver/pkg/streaming/remotecommand"
 	"github.com/yubo/client-go/rest"
 	"github.com/yubo/client-go/tools/remotecommand"
 	"github.com/yubo/client-go/transport/spdy"
 	"github.com/yubo/golib/api"
 	runtimeapi "github.com/yubo/golib/api"
 	"github.com/yubo/golib/term"
+	"github.com/yubo/golib/types"
 )
 
 const (
@@ -373,6 +383,10 @@ func runRemoteCommandTest(t *testing.T, commandType string) {
 }
 
 func startTestServer(t *testing.T) (Server, *httptest.Server) {
+	return startTestServerWithConfig(t, nil)
+}
+
+func startTestServerWithConfig(t *testing.T, configure func(*Config)) (Server, *httptest.Server) {
 	var s Server
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s.ServeHTTP(w, r)
@@ -390,6 +404,9 @@ func startTestServer(t *testing.T) (Server, *httptest.Server) {
 	rt := newFakeRuntime(t)
 	config := DefaultConfig
 	config.BaseURL = testURL
+	if configure != nil {
+		configure(&config)
+	}
 	s, err = NewServer(config, rt)
 	require.NoError(t, err)
 
@@ -397,6 +414,138 @@ func startTestServer(t *testing.T) (Server, *httptest.Server) {
 	return s, testServer
 }
 
+type fakeAuditor struct {
+	sync.Mutex
+	events []*auditinternal.Event
+}
+
+func (p *fakeAuditor) Checker() audit.Checker {
+	return policy.FakeChecker(auditinternal.LevelMetadata, nil)
+}
+func (p *fakeAuditor) Backend() audit.Backend           { return p }
+func (p *fakeAuditor) Run(stopCh <-chan struct{}) error { return nil }
+func (p *fakeAuditor) Shutdown()                        {}
+func (p *fakeAuditor) String() string                   { return "fake" }
+func (p *fakeAuditor) ProcessEvents(events ...*auditinternal.Event) bool {
+	p.Lock()
+	defer p.Unlock()
+	p.events = append(p.events, events...)
+	return true
+}
+
+func (p *fakeAuditor) last() *auditinternal.Event {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.events) == 0 {
+		return nil
+	}
+	return p.events[len(p.events)-1]
+}
+
+func TestServeExecAuth(t *testing.T) {
+	auditor := &fakeAuditor{}
+	s, testServer := startTestServerWithConfig(t, func(config *Config) {
+		config.Authenticator = authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+			name := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if name == "" {
+				return nil, false, nil
+			}
+			return &authenticator.Response{User: &user.DefaultInfo{Name: name}}, true, nil
+		})
+		config.Authorizer = authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+			if a.GetUser().GetName() == "alice" &&
+				a.GetVerb() == "create" &&
+				a.GetResource() == ContainerResource &&
+				a.GetSubresource() == "exec" &&
+				a.GetName() == testContainerID {
+				return authorizer.DecisionAllow, "", nil
+			}
+			return authorizer.DecisionNoOpinion, "", nil
+		})
+		config.Auditor = auditor
+	})
+	defer testServer.Close()
+
+	getURL := func() string {
+		resp, err := s.GetExec(&runtimeapi.ExecRequest{
+			ContainerId: testContainerID,
+			Cmd:         []string{"echo", "foo"},
+			Stdin:       true,
+			Stdout:      true,
+			Stderr:      true,
+		})
+		require.NoError(t, err)
+		return resp.Url
+	}
+
+	post := func(token string) int {
+		req, err := http.NewRequest("POST", getURL(), nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// unauthenticated
+	assert.Equal(t, http.StatusUnauthorized, post(""))
+
+	// forbidden, audited with the command line
+	assert.Equal(t, http.StatusForbidden, post("bob"))
+	ev := auditor.last()
+	require.NotNil(t, ev)
+	assert.Equal(t, "bob", ev.User.Username)
+	assert.Equal(t, "echo foo", ev.Annotations[commandAnnotationKey])
+	require.NotNil(t, ev.ObjectRef)
+	assert.Equal(t, ContainerResource, ev.ObjectRef.Resource)
+	assert.Equal(t, "exec", ev.ObjectRef.Subresource)
+	assert.Equal(t, testContainerID, ev.ObjectRef.Name)
+
+	// allowed
+	reqURL, err := url.Parse(getURL())
+	require.NoError(t, err)
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		exec, err := remotecommand.NewSPDYExecutor(&rest.Config{BearerToken: "alice"}, "POST", reqURL)
+		require.NoError(t, err)
+
+		require.NoError(t, exec.Stream(remotecommand.StreamOptions{
+			Stdin:  stdinR,
+			Stdout: stdoutW,
+			Stderr: stderrW,
+		}))
+	}()
+	go func() {
+		defer wg.Done()
+		doClientStreams(t, "exec", stdinW, stdoutR, stderrR)
+	}()
+	wg.Wait()
+
+	ev = auditor.last()
+	require.NotNil(t, ev)
+	assert.Equal(t, "alice", ev.User.Username)
+	assert.Equal(t, "allow", ev.Annotations["authorization.k8s.io/decision"])
+}
+
+func TestNewServerAuthorizerWithoutAuthenticator(t *testing.T) {
+	config := DefaultConfig
+	config.Authorizer = authorizer.AuthorizerFunc(func(a authorizer.Attributes) (authorizer.Decision, string, error) {
+		return authorizer.DecisionAllow, "", nil
+	})
+	_, err := NewServer(config, newFakeRuntime(t))
+	assert.Error(t, err)
+}
+
 const (
 	testInput  = "abcdefg"
 	testOutput = "fooBARbaz"
@@ -404,6 +553,65 @@ const (
 	testPort   = 12345
 )
 
+// userAttacher is the attacher of the user of the ctx, see Config.Attacher
+type userAttacher struct {
+	t    *testing.T
+	user string
+}
+
+func (p *userAttacher) AttachContainer(name string, uid types.UID, container string, stdin io.Reader, stdout, stderr io.WriteCloser, tty bool, resize <-chan term.TerminalSize) error {
+	assert.Equal(p.t, testContainerID, container)
+	doServerStreams(p.t, "attach-"+p.user, stdin, stdout, stderr)
+	return nil
+}
+
+func TestServeAttachWithAttacher(t *testing.T) {
+	s, testServer := startTestServerWithConfig(t, func(config *Config) {
+		config.Authenticator = authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+			return &authenticator.Response{User: &user.DefaultInfo{Name: "alice"}}, true, nil
+		})
+		config.Attacher = func(ctx context.Context) remotecommandserver.Attacher {
+			u, ok := genericapirequest.UserFrom(ctx)
+			require.True(t, ok)
+			return &userAttacher{t: t, user: u.GetName()}
+		}
+	})
+	defer testServer.Close()
+
+	resp, err := s.GetAttach(&runtimeapi.AttachRequest{
+		ContainerId: testContainerID,
+		Stdin:       true,
+		Stdout:      true,
+		Stderr:      true,
+	})
+	require.NoError(t, err)
+	reqURL, err := url.Parse(resp.Url)
+	require.NoError(t, err)
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		exec, err := remotecommand.NewSPDYExecutor(&rest.Config{}, "POST", reqURL)
+		require.NoError(t, err)
+
+		require.NoError(t, exec.Stream(remotecommand.StreamOptions{
+			Stdin:  stdinR,
+			Stdout: stdoutW,
+			Stderr: stderrW,
+		}))
+	}()
+	go func() {
+		defer wg.Done()
+		doClientStreams(t, "attach-alice", stdinW, stdoutR, stderrR)
+	}()
+	wg.Wait()
+}
+
 func newFakeRuntime(t *testing.T) *fakeRuntime {
 	return &fakeRuntime{
 		t: t,
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/emicklei/go-restful/v3"
+	"github.com/yubo/apiserver/pkg/audit"
 	"github.com/yubo/apiserver/pkg/proc"
 	v1 "github.com/yubo/apiserver/pkg/proc/api/v1"
 	"github.com/yubo/apiserver/pkg/proc/options"
@@ -238,6 +239,12 @@ func (p *tracing) filter() restful.FilterFunction {
 		ctx = request.WithTracer(ctx, p.tracer)
 		ctx = request.WithTraceID(ctx, span.SpanContext().TraceID().String())
 
+		// correlate the audit events with the span
+		if sc := span.SpanContext(); sc.IsValid() {
+			audit.AddAuditAnnotation(ctx, audit.TraceIDAnnotationKey, sc.TraceID().String())
+			audit.AddAuditAnnotation(ctx, audit.SpanIDAnnotationKey, sc.SpanID().String())
+		}
+
 		// pass the span through the request context
 		req.Request = req.Request.WithContext(ctx)
 
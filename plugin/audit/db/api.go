@@ -0,0 +1,102 @@
+package db
+
+import (
+	"net/http"
+	"time"
+
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+)
+
+// APIPath is the root path of the events api, the requests are authorized by the
+// server as the "events" resource of the audit.k8s.io group.
+const APIPath = "/apis/audit.k8s.io/v1"
+
+type API struct {
+	backend *Backend
+}
+
+func NewAPI(backend *Backend) *API {
+	return &API{backend: backend}
+}
+
+func (p *API) Install(container rest.GoRestfulContainer) {
+	rest.SwaggerTagRegister("audit", "audit Api - query the audit events")
+
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               APIPath,
+		Produces:           []string{rest.MIME_JSON},
+		Tags:               []string{"audit"},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "GET", SubPath: "/events", Operation: "listEvent", Desc: "list audit events, the latest first", Handle: p.listEvent},
+		},
+	})
+}
+
+type listParam struct {
+	api.PageParams
+	AuditID   string `param:"query" name:"auditID" description:"audit id of the request"`
+	User      string `param:"query" description:"user name"`
+	Verb      string `param:"query" description:"verb, e.g. get, list, create"`
+	APIGroup  string `param:"query" name:"apiGroup" description:"api group of the resource"`
+	Resource  string `param:"query" description:"resource"`
+	Namespace string `param:"query" description:"namespace"`
+	Name      string `param:"query" description:"name of the object"`
+	Stage     string `param:"query" description:"stage, e.g. ResponseComplete"`
+	Code      int32  `param:"query" description:"response code"`
+	Since     string `param:"query" description:"events received at or after the time, RFC3339"`
+	Until     string `param:"query" description:"events received before the time, RFC3339"`
+}
+
+type eventListOutput struct {
+	List  []*auditinternal.Event `json:"list"`
+	Total int                    `json:"total"`
+}
+
+func (p *listParam) query() (*Query, error) {
+	offset, limit := p.OffsetLimit()
+	q := &Query{
+		AuditID:   p.AuditID,
+		User:      p.User,
+		Verb:      p.Verb,
+		APIGroup:  p.APIGroup,
+		Resource:  p.Resource,
+		Namespace: p.Namespace,
+		Name:      p.Name,
+		Stage:     p.Stage,
+		Code:      p.Code,
+		Offset:    offset,
+		Limit:     limit,
+	}
+
+	var err error
+	if p.Since != "" {
+		if q.Since, err = time.Parse(time.RFC3339, p.Since); err != nil {
+			return nil, errors.NewBadRequest("invalid since: " + err.Error())
+		}
+	}
+	if p.Until != "" {
+		if q.Until, err = time.Parse(time.RFC3339, p.Until); err != nil {
+			return nil, errors.NewBadRequest("invalid until: " + err.Error())
+		}
+	}
+
+	return q, nil
+}
+
+func (p *API) listEvent(w http.ResponseWriter, req *http.Request, in *listParam) (*eventListOutput, error) {
+	q, err := in.query()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &eventListOutput{}
+	if ret.List, ret.Total, err = p.backend.List(req.Context(), *q); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,261 @@
+// Package db implements an audit backend which stores the events in a database table,
+// the events can be queried by the api of this package.
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/orm"
+	"github.com/yubo/golib/util"
+	"github.com/yubo/golib/util/clock"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// PluginName is the name of this plugin, to be used in help and logs.
+	PluginName = "db"
+
+	// DefaultTableName is the default table of the events
+	DefaultTableName = "audit_event"
+)
+
+// Config is the configuration of the db backend
+type Config struct {
+	// TableName is the table of the events, it's created if not exist.
+	TableName string
+	// Retention is how long the events are kept, 0 means forever.
+	Retention time.Duration
+	// PruneInterval is the interval of deleting the events older than Retention.
+	PruneInterval time.Duration
+	// Clock is used to prune the events, defaults to the real clock.
+	Clock clock.WithTicker
+}
+
+// EventRow is a row of the events table, the columns besides Event are
+// extracted from the event for querying.
+type EventRow struct {
+	ID                *int64 `sql:"primary_key,auto_increment"`
+	AuditID           string `sql:"index"`
+	Stage             string
+	Level             string
+	Verb              string `sql:"index"`
+	UserName          string `sql:"index"`
+	APIGroup          string
+	Resource          string `sql:"index"`
+	Namespace         string
+	Name              string
+	Code              int32
+	SourceIP          string
+	RequestReceivedAt int64  `sql:"index"` // unix microseconds
+	Event             string `sql:"type=mediumtext"`
+}
+
+func newEventRow(ev *auditinternal.Event) (*EventRow, error) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+
+	row := &EventRow{
+		AuditID:           string(ev.AuditID),
+		Stage:             string(ev.Stage),
+		Level:             string(ev.Level),
+		Verb:              ev.Verb,
+		UserName:          ev.User.Username,
+		RequestReceivedAt: ev.RequestReceivedTimestamp.Time.UnixMicro(),
+		Event:             string(b),
+	}
+	if ref := ev.ObjectRef; ref != nil {
+		row.APIGroup = ref.APIGroup
+		row.Resource = ref.Resource
+		row.Namespace = ref.Namespace
+		row.Name = ref.Name
+	}
+	if status := ev.ResponseStatus; status != nil {
+		row.Code = status.Code
+	}
+	if len(ev.SourceIPs) > 0 {
+		row.SourceIP = ev.SourceIPs[0]
+	}
+
+	return row, nil
+}
+
+// Backend writes the events to the database
+type Backend struct {
+	db     orm.DB
+	config Config
+}
+
+var _ audit.Backend = &Backend{}
+
+func NewBackend(db orm.DB, config Config) (*Backend, error) {
+	if db == nil {
+		return nil, fmt.Errorf("audit %s backend: db is nil", PluginName)
+	}
+	if config.TableName == "" {
+		config.TableName = DefaultTableName
+	}
+	if util.IsNil(config.Clock) {
+		config.Clock = clock.RealClock{}
+	}
+
+	if err := db.AutoMigrate(context.Background(), &EventRow{}, orm.WithTable(config.TableName)); err != nil {
+		return nil, fmt.Errorf("audit %s backend: %s", PluginName, err)
+	}
+
+	return &Backend{db: db, config: config}, nil
+}
+
+func (b *Backend) ProcessEvents(events ...*auditinternal.Event) bool {
+	success := true
+	for _, ev := range events {
+		success = b.insertEvent(ev) && success
+	}
+	return success
+}
+
+func (b *Backend) insertEvent(ev *auditinternal.Event) bool {
+	row, err := newEventRow(ev)
+	if err != nil {
+		audit.HandlePluginError(PluginName, err, ev)
+		return false
+	}
+
+	if err := b.db.Insert(context.Background(), row, orm.WithTable(b.config.TableName)); err != nil {
+		audit.HandlePluginError(PluginName, err, ev)
+		return false
+	}
+	return true
+}
+
+// Run starts pruning the events older than the retention
+func (b *Backend) Run(stopCh <-chan struct{}) error {
+	if b.config.Retention > 0 && b.config.PruneInterval > 0 {
+		util.UntilWithTick(b.Prune, b.config.Clock.NewTicker(b.config.PruneInterval).C(), stopCh)
+	}
+	return nil
+}
+
+// Prune deletes the events older than the retention
+func (b *Backend) Prune() {
+	if b.config.Retention <= 0 {
+		return
+	}
+
+	before := b.config.Clock.Now().Add(-b.config.Retention).UnixMicro()
+	n, err := b.db.ExecNum(context.Background(), "delete from `"+b.config.TableName+"` where request_received_at < ?", before)
+	if err != nil {
+		klog.Warningf("audit %s backend prune err %s", PluginName, err)
+		return
+	}
+	klog.V(5).InfoS("audit events pruned", "backend", PluginName, "count", n)
+}
+
+func (b *Backend) Shutdown() {
+	// Nothing to do here.
+}
+
+func (b *Backend) String() string {
+	return PluginName
+}
+
+// Query is the filter of the events, the empty fields match any value
+type Query struct {
+	AuditID   string
+	User      string
+	Verb      string
+	APIGroup  string
+	Resource  string
+	Namespace string
+	Name      string
+	Stage     string
+	Code      int32
+	// Since and Until are the range of the request received time
+	Since time.Time
+	Until time.Time
+
+	Offset int
+	Limit  int
+}
+
+func (q *Query) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	add := func(cond string, arg interface{}) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+
+	for _, v := range []struct {
+		column string
+		value  string
+	}{
+		{"audit_id", q.AuditID},
+		{"user_name", q.User},
+		{"verb", q.Verb},
+		{"api_group", q.APIGroup},
+		{"resource", q.Resource},
+		{"namespace", q.Namespace},
+		{"name", q.Name},
+		{"stage", q.Stage},
+	} {
+		if v.value != "" {
+			add("`"+v.column+"` = ?", v.value)
+		}
+	}
+	if q.Code != 0 {
+		add("`code` = ?", q.Code)
+	}
+	if !q.Since.IsZero() {
+		add("`request_received_at` >= ?", q.Since.UnixMicro())
+	}
+	if !q.Until.IsZero() {
+		add("`request_received_at` < ?", q.Until.UnixMicro())
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " where " + strings.Join(conds, " and "), args
+}
+
+// List returns the matched events, the latest first, and the total number of them
+func (b *Backend) List(ctx context.Context, q Query) ([]*auditinternal.Event, int, error) {
+	where, args := q.where()
+
+	var total int
+	if err := b.db.Query(ctx, "select count(*) from `"+b.config.TableName+"`"+where, args...).Row(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "select * from `" + b.config.TableName + "`" + where + " order by id desc"
+	if q.Limit > 0 {
+		query += fmt.Sprintf(" limit %d offset %d", q.Limit, q.Offset)
+	}
+
+	var rows []*EventRow
+	if err := b.db.Query(ctx, query, args...).Rows(&rows); err != nil && !errors.IsNotFound(err) {
+		return nil, 0, err
+	}
+
+	events := make([]*auditinternal.Event, 0, len(rows))
+	for _, row := range rows {
+		ev := &auditinternal.Event{}
+		if err := json.Unmarshal([]byte(row.Event), ev); err != nil {
+			klog.Warningf("audit %s backend: unable to decode event %d: %s", PluginName, util.Int64Value(row.ID), err)
+			continue
+		}
+		events = append(events, ev)
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/orm"
+	"github.com/yubo/golib/types"
+	testingclock "github.com/yubo/golib/util/clock/testing"
+
+	_ "github.com/yubo/golib/orm/sqlite"
+)
+
+func newTestBackend(t *testing.T, config Config) *Backend {
+	db, err := orm.Open("sqlite3", fmt.Sprintf("file:%s?mode=memory&parseTime=true", t.Name()))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	b, err := NewBackend(db, config)
+	require.NoError(t, err)
+	return b
+}
+
+func newEvent(id, user, verb, resource string, code int32, received time.Time) *auditinternal.Event {
+	return &auditinternal.Event{
+		Level:                    auditinternal.LevelMetadata,
+		AuditID:                  types.UID(id),
+		Stage:                    auditinternal.StageResponseComplete,
+		RequestURI:               "/api/v1/" + resource,
+		Verb:                     verb,
+		User:                     api.UserInfo{Username: user, Groups: []string{"dev"}},
+		SourceIPs:                []string{"10.0.0.1"},
+		ObjectRef:                &auditinternal.ObjectReference{Resource: resource, Name: "obj-" + id},
+		ResponseStatus:           &api.Status{Code: code},
+		RequestReceivedTimestamp: api.NewMicroTime(received),
+		StageTimestamp:           api.NewMicroTime(received),
+		Annotations:              map[string]string{"key": "value"},
+	}
+}
+
+func TestBackendList(t *testing.T) {
+	ctx := context.Background()
+	b := newTestBackend(t, Config{})
+
+	now := time.Now().Truncate(time.Second)
+	events := []*auditinternal.Event{
+		newEvent("1", "tom", "get", "users", 200, now.Add(-3*time.Hour)),
+		newEvent("2", "tom", "delete", "users", 403, now.Add(-2*time.Hour)),
+		newEvent("3", "jerry", "create", "roles", 201, now.Add(-time.Hour)),
+		newEvent("4", "tom", "create", "roles", 201, now),
+	}
+	require.True(t, b.ProcessEvents(events...))
+
+	cases := []struct {
+		name  string
+		query Query
+		ids   []string
+		total int
+	}{
+		{name: "all, the latest first", query: Query{}, ids: []string{"4", "3", "2", "1"}, total: 4},
+		{name: "user", query: Query{User: "tom"}, ids: []string{"4", "2", "1"}, total: 3},
+		{name: "verb and resource", query: Query{Verb: "create", Resource: "roles"}, ids: []string{"4", "3"}, total: 2},
+		{name: "code", query: Query{Code: 403}, ids: []string{"2"}, total: 1},
+		{name: "time range", query: Query{Since: now.Add(-2 * time.Hour), Until: now}, ids: []string{"3", "2"}, total: 2},
+		{name: "paging", query: Query{User: "tom", Offset: 1, Limit: 1}, ids: []string{"2"}, total: 3},
+		{name: "no match", query: Query{User: "nobody"}, ids: []string{}, total: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, total, err := b.List(ctx, c.query)
+			require.NoError(t, err)
+			require.Equal(t, c.total, total)
+
+			ids := []string{}
+			for _, ev := range list {
+				ids = append(ids, string(ev.AuditID))
+			}
+			require.Equal(t, c.ids, ids)
+		})
+	}
+
+	// the event is stored as is
+	list, _, err := b.List(ctx, Query{AuditID: "2"})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, events[1].User, list[0].User)
+	require.Equal(t, events[1].ObjectRef, list[0].ObjectRef)
+	require.Equal(t, events[1].Annotations, list[0].Annotations)
+	require.True(t, events[1].RequestReceivedTimestamp.Equal(&list[0].RequestReceivedTimestamp))
+}
+
+func TestBackendPrune(t *testing.T) {
+	ctx := context.Background()
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	b := newTestBackend(t, Config{
+		Retention:     24 * time.Hour,
+		PruneInterval: time.Hour,
+		Clock:         fakeClock,
+	})
+
+	now := fakeClock.Now()
+	require.True(t, b.ProcessEvents(
+		newEvent("old", "tom", "get", "users", 200, now.Add(-25*time.Hour)),
+		newEvent("new", "tom", "get", "users", 200, now.Add(-23*time.Hour)),
+	))
+
+	b.Prune()
+	list, total, err := b.List(ctx, Query{})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, types.UID("new"), list[0].AuditID)
+
+	// pruned by the ticker
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	require.NoError(t, b.Run(stopCh))
+
+	fakeClock.Step(2 * time.Hour)
+	require.Eventually(t, func() bool {
+		_, total, err := b.List(ctx, Query{})
+		return err == nil && total == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestListParam(t *testing.T) {
+	in := &listParam{User: "tom", Since: "2023-01-01T00:00:00Z", Until: "2023-01-02T00:00:00Z"}
+	in.PageSize = 10
+	in.Current = 2
+
+	q, err := in.query()
+	require.NoError(t, err)
+	require.Equal(t, "tom", q.User)
+	require.Equal(t, 10, q.Offset)
+	require.Equal(t, 10, q.Limit)
+	require.Equal(t, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), q.Since.UTC())
+
+	in.Since = "yesterday"
+	_, err = in.query()
+	require.Error(t, err)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/yubo/apiserver/pkg/audit"
 	"github.com/yubo/golib/runtime"
 	"github.com/yubo/golib/scheme"
+	"github.com/yubo/golib/util"
 )
 
 const (
@@ -47,6 +48,7 @@ type backend struct {
 	out     io.Writer
 	format  string
 	encoder runtime.Encoder
+	chain   *chainWriter
 }
 
 var _ audit.Backend = &backend{}
@@ -59,6 +61,22 @@ func NewBackend(out io.Writer, format string) audit.Backend {
 	}
 }
 
+// NewChainedBackend returns a json log backend which chains the events by their hashes,
+// and writes the signed checkpoints, see VerifyChain.
+func NewChainedBackend(out io.Writer, config ChainConfig) (audit.Backend, error) {
+	chain, err := newChainWriter(out, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backend{
+		out:     out,
+		format:  FormatJson,
+		encoder: scheme.Codecs.LegacyCodec(),
+		chain:   chain,
+	}, nil
+}
+
 func (b *backend) ProcessEvents(events ...*auditinternal.Event) bool {
 	success := true
 	for _, ev := range events {
@@ -78,6 +96,13 @@ func (b *backend) logEvent(ev *auditinternal.Event) bool {
 			audit.HandlePluginError(PluginName, err, ev)
 			return false
 		}
+		if b.chain != nil {
+			if err := b.chain.writeEvent(bs); err != nil {
+				audit.HandlePluginError(PluginName, err, ev)
+				return false
+			}
+			return true
+		}
 		line = string(bs[:])
 	default:
 		audit.HandlePluginError(PluginName, fmt.Errorf("log format %q is not in list of known formats (%s)",
@@ -92,11 +117,23 @@ func (b *backend) logEvent(ev *auditinternal.Event) bool {
 }
 
 func (b *backend) Run(stopCh <-chan struct{}) error {
+	if b.chain != nil && b.chain.config.CheckpointInterval > 0 {
+		util.UntilWithTick(b.checkpoint, b.chain.config.Clock.NewTicker(b.chain.config.CheckpointInterval).C(), stopCh)
+	}
 	return nil
 }
 
+func (b *backend) checkpoint() {
+	if err := b.chain.writeCheckpoint(); err != nil {
+		audit.HandlePluginError(PluginName, fmt.Errorf("write checkpoint: %s", err))
+	}
+}
+
 func (b *backend) Shutdown() {
-	// Nothing to do here.
+	// sign the last events
+	if b.chain != nil {
+		b.checkpoint()
+	}
 }
 
 func (b *backend) String() string {
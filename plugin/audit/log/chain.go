@@ -0,0 +1,282 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yubo/golib/util/clock"
+)
+
+// The chained log is a json line per record, each event record is chained to the previous
+// record by hash = sha256(prevHash + event), and the checkpoint records sign the hash of the
+// last event, so the events before a checkpoint can't be edited, removed or reordered
+// without the signing key.
+//
+//	{"seq":1,"prevHash":"000...","hash":"5e1...","event":{...}}
+//	{"seq":1,"hash":"5e1...","checkpoint":{"time":"...","signature":"..."}}
+//
+// A chain starts with seq 1 and a zero prevHash, i.e. when the server is restarted.
+
+// ChainRecord is a line of the chained log
+type ChainRecord struct {
+	Seq        uint64          `json:"seq"`
+	PrevHash   string          `json:"prevHash,omitempty"`
+	Hash       string          `json:"hash"`
+	Event      json.RawMessage `json:"event,omitempty"`
+	Checkpoint *Checkpoint     `json:"checkpoint,omitempty"`
+}
+
+// Checkpoint is the signature of the hash of the last event
+type Checkpoint struct {
+	Time      time.Time `json:"time"`
+	Signature []byte    `json:"signature"`
+}
+
+// zeroHash is the prevHash of the first record of a chain
+var zeroHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+func chainHash(prevHash string, event []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(event)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkpointDigest is the signed content of a checkpoint
+func checkpointDigest(seq uint64, hash string, t time.Time) []byte {
+	sum := sha256.Sum256([]byte("audit-checkpoint\n" + strconv.FormatUint(seq, 10) + "\n" + hash + "\n" + t.UTC().Format(time.RFC3339Nano)))
+	return sum[:]
+}
+
+// ChainConfig enables the hash chain of the json log
+type ChainConfig struct {
+	// Signer signs the checkpoints, it must be an rsa or ecdsa key.
+	Signer crypto.Signer
+	// CheckpointInterval is the interval of the checkpoints, a checkpoint
+	// is also written on shutdown.
+	CheckpointInterval time.Duration
+	// Clock defaults to the real clock.
+	Clock clock.WithTicker
+}
+
+// chainWriter writes the chained records, the order in the file is the order of the chain
+type chainWriter struct {
+	sync.Mutex
+	out    io.Writer
+	config ChainConfig
+
+	seq        uint64
+	hash       string
+	checkpoint uint64 // the seq of the last checkpoint
+}
+
+func newChainWriter(out io.Writer, config ChainConfig) (*chainWriter, error) {
+	switch config.Signer.Public().(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported checkpoint signing key %T, must be rsa or ecdsa", config.Signer.Public())
+	}
+	if config.Clock == nil {
+		config.Clock = clock.RealClock{}
+	}
+
+	return &chainWriter{
+		out:    out,
+		config: config,
+		hash:   zeroHash,
+	}, nil
+}
+
+func (p *chainWriter) writeEvent(event []byte) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, event); err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	hash := chainHash(p.hash, buf.Bytes())
+	if err := p.write(&ChainRecord{
+		Seq:      p.seq + 1,
+		PrevHash: p.hash,
+		Hash:     hash,
+		Event:    buf.Bytes(),
+	}); err != nil {
+		return err
+	}
+
+	p.seq++
+	p.hash = hash
+	return nil
+}
+
+// writeCheckpoint signs the last event, it's skipped if there is no event since the last checkpoint
+func (p *chainWriter) writeCheckpoint() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.seq == p.checkpoint {
+		return nil
+	}
+
+	now := p.config.Clock.Now()
+	sig, err := p.config.Signer.Sign(rand.Reader, checkpointDigest(p.seq, p.hash, now), crypto.SHA256)
+	if err != nil {
+		return err
+	}
+
+	if err := p.write(&ChainRecord{
+		Seq:        p.seq,
+		Hash:       p.hash,
+		Checkpoint: &Checkpoint{Time: now, Signature: sig},
+	}); err != nil {
+		return err
+	}
+
+	p.checkpoint = p.seq
+	return nil
+}
+
+func (p *chainWriter) write(record *ChainRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = p.out.Write(append(b, '\n'))
+	return err
+}
+
+// VerifyResult is the summary of a verified log
+type VerifyResult struct {
+	// Events is the number of the verified events
+	Events int
+	// Chains is the number of the chains, a new chain is started when the server restarts
+	Chains int
+	// Checkpoints is the number of the verified checkpoints
+	Checkpoints int
+	// Unsigned is the number of the events after the last checkpoint,
+	// they are chained but not protected by a signature.
+	Unsigned int
+}
+
+// VerifyError reports the first broken link
+type VerifyError struct {
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// VerifyChain validates the chained log with the public keys of the checkpoint signer,
+// and returns a *VerifyError on the first broken link. If prevHash is set, the log is
+// expected to continue the chain from it, e.g. a rotated log file.
+func VerifyChain(r io.Reader, publicKeys []interface{}, prevHash string) (*VerifyResult, error) {
+	ret := &VerifyResult{}
+
+	// the seq and hash of the last event, the hash is empty until the
+	// first event or checkpoint if the file starts in the middle of a chain
+	var (
+		seq      uint64
+		hash     = prevHash
+		unsigned int
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		record := &ChainRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return ret, &VerifyError{Line: line, Seq: seq + 1, Reason: fmt.Sprintf("invalid record: %s", err)}
+		}
+		fail := func(format string, args ...interface{}) (*VerifyResult, error) {
+			return ret, &VerifyError{Line: line, Seq: record.Seq, Reason: fmt.Sprintf(format, args...)}
+		}
+
+		if record.Checkpoint != nil {
+			if hash != "" && (record.Hash != hash || (seq > 0 && record.Seq != seq)) {
+				return fail("checkpoint doesn't match the last event (seq %d)", seq)
+			}
+			digest := checkpointDigest(record.Seq, record.Hash, record.Checkpoint.Time)
+			if !verifySignature(publicKeys, digest, record.Checkpoint.Signature) {
+				return fail("invalid checkpoint signature")
+			}
+			seq = record.Seq
+			hash = record.Hash
+			ret.Checkpoints++
+			unsigned = 0
+			continue
+		}
+
+		if record.Event == nil {
+			return fail("record has neither event nor checkpoint")
+		}
+
+		switch {
+		case record.Seq == 1 && record.PrevHash == zeroHash:
+			// a new chain, the events of the previous chain after its last checkpoint
+			// may have been removed
+			if unsigned > 0 {
+				return fail("new chain started, the last %d events of the previous chain are not signed", unsigned)
+			}
+			ret.Chains++
+		case hash == "":
+			// the file starts in the middle of a chain, e.g. a rotated file
+			ret.Chains++
+		case seq > 0 && record.Seq != seq+1:
+			return fail("expected seq %d", seq+1)
+		case record.PrevHash != hash:
+			return fail("prevHash doesn't match the hash of the previous event")
+		}
+
+		if chainHash(record.PrevHash, record.Event) != record.Hash {
+			return fail("hash mismatch, the event has been modified")
+		}
+
+		seq = record.Seq
+		hash = record.Hash
+		ret.Events++
+		unsigned++
+	}
+	if err := scanner.Err(); err != nil {
+		return ret, err
+	}
+
+	ret.Unsigned = unsigned
+	return ret, nil
+}
+
+func verifySignature(publicKeys []interface{}, digest, sig []byte) bool {
+	for _, key := range publicKeys {
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(k, digest, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package log
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/types"
+	testingclock "github.com/yubo/golib/util/clock/testing"
+)
+
+func newChainedLog(t *testing.T, key *ecdsa.PrivateKey, out *bytes.Buffer, ids ...string) *backend {
+	b, err := NewChainedBackend(out, ChainConfig{
+		Signer: key,
+		Clock:  testingclock.NewFakeClock(time.Now()),
+	})
+	require.NoError(t, err)
+
+	for _, id := range ids {
+		require.True(t, b.ProcessEvents(&auditinternal.Event{
+			AuditID:    types.UID(id),
+			Stage:      auditinternal.StageResponseComplete,
+			Verb:       "get",
+			RequestURI: "/api/v1/users/" + id,
+			User:       api.UserInfo{Username: "tom"},
+		}))
+	}
+	return b.(*backend)
+}
+
+func lines(buf *bytes.Buffer) []string {
+	return strings.Split(strings.TrimSpace(buf.String()), "\n")
+}
+
+func join(lines ...[]string) string {
+	var all []string
+	for _, l := range lines {
+		all = append(all, l...)
+	}
+	return strings.Join(all, "\n") + "\n"
+}
+
+func TestVerifyChain(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keys := []interface{}{&key.PublicKey}
+
+	// two chains, i.e. the server was restarted, each one ends with a checkpoint
+	var buf1, buf2 bytes.Buffer
+	b := newChainedLog(t, key, &buf1, "1", "2", "3")
+	b.checkpoint()
+	b.checkpoint() // skipped, no new events
+	b = newChainedLog(t, key, &buf2, "4", "5")
+	b.Shutdown()
+	chain1, chain2 := lines(&buf1), lines(&buf2)
+	require.Len(t, chain1, 4)
+	require.Len(t, chain2, 3)
+
+	// the event with the modified content, and the recomputed hashes
+	var record ChainRecord
+	require.NoError(t, json.Unmarshal([]byte(chain1[1]), &record))
+	modified := strings.Replace(chain1[1], `"verb":"get"`, `"verb":"delete"`, 1)
+
+	cases := []struct {
+		name     string
+		log      string
+		keys     []interface{}
+		prevHash string
+		result   *VerifyResult
+		errLine  int
+	}{{
+		name:   "valid",
+		log:    join(chain1, chain2),
+		result: &VerifyResult{Events: 5, Chains: 2, Checkpoints: 2},
+	}, {
+		name:   "unsigned tail",
+		log:    join(chain1, chain2[:2]),
+		result: &VerifyResult{Events: 5, Chains: 2, Checkpoints: 1, Unsigned: 2},
+	}, {
+		name:    "modified event",
+		log:     join(chain1[:1], []string{modified}, chain1[2:]),
+		errLine: 2,
+	}, {
+		name:    "removed event",
+		log:     join(chain1[:1], chain1[2:]),
+		errLine: 2,
+	}, {
+		name:    "reordered events",
+		log:     join(chain1[1:2], chain1[0:1], chain1[2:]),
+		errLine: 2,
+	}, {
+		name:    "unsigned events before restart",
+		log:     join(chain1[:3], chain2),
+		errLine: 4,
+	}, {
+		name:    "wrong key",
+		log:     join(chain1),
+		keys:    []interface{}{&otherKey.PublicKey},
+		errLine: 4,
+	}, {
+		name:     "rotated file",
+		log:      join(chain1[2:]),
+		prevHash: record.Hash,
+		result:   &VerifyResult{Events: 1, Chains: 0, Checkpoints: 1},
+	}, {
+		name:     "rotated file with wrong prev hash",
+		log:      join(chain1[2:]),
+		prevHash: zeroHash,
+		errLine:  1,
+	}, {
+		name:   "rotated file without prev hash",
+		log:    join(chain1[2:]),
+		result: &VerifyResult{Events: 1, Chains: 1, Checkpoints: 1},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := c.keys
+			if k == nil {
+				k = keys
+			}
+
+			ret, err := VerifyChain(strings.NewReader(c.log), k, c.prevHash)
+			if c.errLine > 0 {
+				require.Error(t, err)
+				e, ok := err.(*VerifyError)
+				require.True(t, ok, err.Error())
+				require.Equal(t, c.errLine, e.Line, e.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.result, ret)
+		})
+	}
+}
+
+func TestChainedBackendFormat(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	newChainedLog(t, key, &buf, "1")
+
+	var record ChainRecord
+	require.NoError(t, json.Unmarshal([]byte(lines(&buf)[0]), &record))
+	require.Equal(t, uint64(1), record.Seq)
+	require.Equal(t, zeroHash, record.PrevHash)
+
+	// the event is kept as the json log
+	ev := &auditinternal.Event{}
+	require.NoError(t, json.Unmarshal(record.Event, ev))
+	require.Equal(t, types.UID("1"), ev.AuditID)
+}
@@ -0,0 +1,199 @@
+// Package otlp implements the audit.Backend interface with an OTLP logs exporter,
+// each event is a log record of which the body is the json event.
+package otlp
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/golib/runtime"
+	"github.com/yubo/golib/scheme"
+	"github.com/yubo/golib/util/clock"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// PluginName is the name of this plugin, to be used in help and logs.
+	PluginName = "otlp"
+
+	scopeName = "github.com/yubo/apiserver/plugin/audit/otlp"
+)
+
+// Config is the config of the otlp backend
+type Config struct {
+	// ServiceName is the service.name of the resource, defaults to the program name
+	ServiceName string
+	// ResourceAttributes are the other attributes of the resource
+	ResourceAttributes map[string]string
+	// Timeout of an export request, defaults to 10s
+	Timeout time.Duration
+	// Clock defaults to the real clock.
+	Clock clock.PassiveClock
+}
+
+type backend struct {
+	client   collectorlogs.LogsServiceClient
+	conn     grpc.ClientConnInterface
+	config   Config
+	resource *resourcev1.Resource
+	encoder  runtime.Encoder
+}
+
+var _ audit.Backend = &backend{}
+
+// NewBackend returns an otlp logs backend which exports the events by the grpc connection,
+// the connection is closed on shutdown if it's an io.Closer.
+func NewBackend(conn grpc.ClientConnInterface, config Config) audit.Backend {
+	if config.ServiceName == "" {
+		config.ServiceName = filepath.Base(os.Args[0])
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.Clock == nil {
+		config.Clock = clock.RealClock{}
+	}
+
+	resource := &resourcev1.Resource{
+		Attributes: []*commonv1.KeyValue{stringAttr("service.name", config.ServiceName)},
+	}
+	keys := make([]string, 0, len(config.ResourceAttributes))
+	for k := range config.ResourceAttributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		resource.Attributes = append(resource.Attributes, stringAttr(k, config.ResourceAttributes[k]))
+	}
+
+	return &backend{
+		client:   collectorlogs.NewLogsServiceClient(conn),
+		conn:     conn,
+		config:   config,
+		resource: resource,
+		encoder:  scheme.Codecs.LegacyCodec(),
+	}
+}
+
+func (b *backend) ProcessEvents(events ...*auditinternal.Event) bool {
+	records := make([]*logsv1.LogRecord, 0, len(events))
+	var exported []*auditinternal.Event
+	for _, ev := range events {
+		record, err := b.logRecord(ev)
+		if err != nil {
+			audit.HandlePluginError(PluginName, err, ev)
+			continue
+		}
+		records = append(records, record)
+		exported = append(exported, ev)
+	}
+	if len(records) == 0 {
+		return len(events) == 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.Timeout)
+	defer cancel()
+
+	resp, err := b.client.Export(ctx, &collectorlogs.ExportLogsServiceRequest{
+		ResourceLogs: []*logsv1.ResourceLogs{{
+			Resource: b.resource,
+			ScopeLogs: []*logsv1.ScopeLogs{{
+				Scope:      &commonv1.InstrumentationScope{Name: scopeName},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		audit.HandlePluginError(PluginName, err, exported...)
+		return false
+	}
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.RejectedLogRecords > 0 {
+		klog.ErrorS(nil, "Audit events rejected by the otlp receiver", "rejected", ps.RejectedLogRecords, "message", ps.ErrorMessage)
+		return false
+	}
+
+	return len(exported) == len(events)
+}
+
+// logRecord converts the event, the trace context of the record is set from
+// the trace annotations of the event.
+func (b *backend) logRecord(ev *auditinternal.Event) (*logsv1.LogRecord, error) {
+	body, err := runtime.Encode(b.encoder, ev)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := ev.StageTimestamp.Time
+	if ts.IsZero() {
+		ts = b.config.Clock.Now()
+	}
+
+	record := &logsv1.LogRecord{
+		TimeUnixNano:         uint64(ts.UnixNano()),
+		ObservedTimeUnixNano: uint64(b.config.Clock.Now().UnixNano()),
+		SeverityNumber:       logsv1.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:         "INFO",
+		Body:                 &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: string(body)}},
+		Attributes: []*commonv1.KeyValue{
+			stringAttr("audit.id", string(ev.AuditID)),
+			stringAttr("audit.stage", string(ev.Stage)),
+			stringAttr("audit.level", string(ev.Level)),
+			stringAttr("audit.verb", ev.Verb),
+			stringAttr("audit.request_uri", ev.RequestURI),
+			stringAttr("enduser.id", ev.User.Username),
+		},
+	}
+	if ev.Stage == auditinternal.StagePanic {
+		record.SeverityNumber = logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR
+		record.SeverityText = "ERROR"
+	}
+	if ev.ResponseStatus != nil {
+		record.Attributes = append(record.Attributes, &commonv1.KeyValue{
+			Key:   "http.status_code",
+			Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_IntValue{IntValue: int64(ev.ResponseStatus.Code)}},
+		})
+	}
+
+	traceID, spanID := audit.TraceContextFrom(ev)
+	if id, err := hex.DecodeString(traceID); err == nil && len(id) == 16 {
+		record.TraceId = id
+	}
+	if id, err := hex.DecodeString(spanID); err == nil && len(id) == 8 {
+		record.SpanId = id
+	}
+
+	return record, nil
+}
+
+func stringAttr(key, value string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{
+		Key:   key,
+		Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func (b *backend) Run(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (b *backend) Shutdown() {
+	if c, ok := b.conn.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func (b *backend) String() string {
+	return PluginName
+}
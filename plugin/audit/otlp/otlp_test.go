@@ -0,0 +1,119 @@
+package otlp
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/types"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// receiver is an otlp logs receiver stand-in
+type receiver struct {
+	collectorlogs.UnimplementedLogsServiceServer
+
+	sync.Mutex
+	reqs []*collectorlogs.ExportLogsServiceRequest
+	err  error
+}
+
+func (p *receiver) Export(ctx context.Context, req *collectorlogs.ExportLogsServiceRequest) (*collectorlogs.ExportLogsServiceResponse, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.reqs = append(p.reqs, req)
+	return &collectorlogs.ExportLogsServiceResponse{}, nil
+}
+
+func newReceiver(t *testing.T) (*receiver, *grpc.ClientConn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	r := &receiver{}
+	s := grpc.NewServer()
+	collectorlogs.RegisterLogsServiceServer(s, r)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	return r, conn
+}
+
+func TestBackend(t *testing.T) {
+	r, conn := newReceiver(t)
+	b := NewBackend(conn, Config{
+		ServiceName:        "apiserver",
+		ResourceAttributes: map[string]string{"env": "test"},
+		Timeout:            5 * time.Second,
+	})
+	defer b.Shutdown()
+
+	now := time.Now()
+	ev := &auditinternal.Event{
+		Level:          auditinternal.LevelMetadata,
+		AuditID:        types.UID("1"),
+		Stage:          auditinternal.StageResponseComplete,
+		RequestURI:     "/api/v1/users",
+		Verb:           "get",
+		User:           api.UserInfo{Username: "tom"},
+		ResponseStatus: &api.Status{Code: 200},
+		StageTimestamp: api.NewMicroTime(now),
+		Annotations: map[string]string{
+			audit.TraceIDAnnotationKey: "4bf92f3577b34da6a3ce929d0e0e4736",
+			audit.SpanIDAnnotationKey:  "00f067aa0ba902b7",
+		},
+	}
+	require.True(t, b.ProcessEvents(ev, &auditinternal.Event{AuditID: "2", Stage: auditinternal.StagePanic}))
+
+	require.Len(t, r.reqs, 1)
+	rl := r.reqs[0].ResourceLogs[0]
+	require.Equal(t, "service.name", rl.Resource.Attributes[0].Key)
+	require.Equal(t, "apiserver", rl.Resource.Attributes[0].Value.GetStringValue())
+	require.Equal(t, "env", rl.Resource.Attributes[1].Key)
+
+	records := rl.ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+
+	record := records[0]
+	require.Equal(t, uint64(api.NewMicroTime(now).UnixNano()), record.TimeUnixNano)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hex.EncodeToString(record.TraceId))
+	require.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(record.SpanId))
+
+	attrs := map[string]*commonv1.AnyValue{}
+	for _, kv := range record.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	require.Equal(t, "1", attrs["audit.id"].GetStringValue())
+	require.Equal(t, "tom", attrs["enduser.id"].GetStringValue())
+	require.Equal(t, int64(200), attrs["http.status_code"].GetIntValue())
+
+	got := &auditinternal.Event{}
+	require.NoError(t, json.Unmarshal([]byte(record.Body.GetStringValue()), got))
+	require.Equal(t, ev.AuditID, got.AuditID)
+
+	// the event without trace context
+	require.Nil(t, records[1].TraceId)
+	require.Equal(t, "ERROR", records[1].SeverityText)
+
+	// export error
+	r.err = status.Error(codes.Unavailable, "unavailable")
+	require.False(t, b.ProcessEvents(ev))
+}
@@ -0,0 +1,252 @@
+// Package syslog implements the audit.Backend interface with the RFC 5424 syslog
+// messages over TCP or TLS (RFC 5425), the messages are framed by octet counting.
+package syslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/golib/runtime"
+	"github.com/yubo/golib/scheme"
+)
+
+const (
+	// PluginName is the name of this plugin, to be used in help and logs.
+	PluginName = "syslog"
+
+	// DefaultFacility is the "log audit" facility
+	DefaultFacility = 13
+
+	// DefaultStructuredDataID is the SD-ID of the event fields, 32473 is the
+	// enterprise number reserved for documentation, see RFC 5612.
+	DefaultStructuredDataID = "audit@32473"
+
+	severityError = 3
+	severityInfo  = 6
+
+	nilValue        = "-"
+	timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// Config is the config of the syslog backend
+type Config struct {
+	// Address is the host:port of the syslog server
+	Address string
+	// TLSConfig enables TLS if set
+	TLSConfig *tls.Config
+	// Facility defaults to DefaultFacility
+	Facility int
+	// Hostname defaults to the host name
+	Hostname string
+	// AppName defaults to the program name
+	AppName string
+	// StructuredDataID defaults to DefaultStructuredDataID
+	StructuredDataID string
+	// Timeout of the dial and the write, defaults to 10s
+	Timeout time.Duration
+}
+
+type backend struct {
+	sync.Mutex
+	config  Config
+	procID  string
+	encoder runtime.Encoder
+	conn    net.Conn
+}
+
+var _ audit.Backend = &backend{}
+
+// NewBackend returns a syslog backend, the connection is established by the first events,
+// and is reestablished once if a write fails.
+func NewBackend(config Config) (audit.Backend, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("syslog address is not set")
+	}
+	if config.Facility == 0 {
+		config.Facility = DefaultFacility
+	}
+	if config.Facility < 0 || config.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d, must be in [0, 23]", config.Facility)
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+	if config.AppName == "" {
+		config.AppName = filepath.Base(os.Args[0])
+	}
+	if config.StructuredDataID == "" {
+		config.StructuredDataID = DefaultStructuredDataID
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &backend{
+		config:  config,
+		procID:  strconv.Itoa(os.Getpid()),
+		encoder: scheme.Codecs.LegacyCodec(),
+	}, nil
+}
+
+func (b *backend) ProcessEvents(events ...*auditinternal.Event) bool {
+	var buf bytes.Buffer
+	var encoded []*auditinternal.Event
+	for _, ev := range events {
+		msg, err := b.format(ev)
+		if err != nil {
+			audit.HandlePluginError(PluginName, err, ev)
+			continue
+		}
+		// octet counting framing, RFC 6587
+		buf.WriteString(strconv.Itoa(len(msg)))
+		buf.WriteByte(' ')
+		buf.Write(msg)
+		encoded = append(encoded, ev)
+	}
+	if len(encoded) == 0 {
+		return len(events) == 0
+	}
+
+	if err := b.write(buf.Bytes()); err != nil {
+		audit.HandlePluginError(PluginName, err, encoded...)
+		return false
+	}
+	return len(encoded) == len(events)
+}
+
+// write sends the messages, the connection is reestablished once on error,
+// the messages may be duplicated if the previous write was partially done.
+func (b *backend) write(data []byte) (err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	for i := 0; i < 2; i++ {
+		if b.conn == nil {
+			if b.conn, err = b.dial(); err != nil {
+				return err
+			}
+		}
+
+		b.conn.SetWriteDeadline(time.Now().Add(b.config.Timeout))
+		if _, err = b.conn.Write(data); err == nil {
+			return nil
+		}
+
+		b.conn.Close()
+		b.conn = nil
+	}
+	return err
+}
+
+func (b *backend) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: b.config.Timeout}
+	if b.config.TLSConfig != nil {
+		return tls.DialWithDialer(dialer, "tcp", b.config.Address, b.config.TLSConfig)
+	}
+	return dialer.Dial("tcp", b.config.Address)
+}
+
+// format returns the RFC 5424 message of the event
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID k="v"...] MSG
+//
+// the MSGID is the stage, and the MSG is the json event.
+func (b *backend) format(ev *auditinternal.Event) ([]byte, error) {
+	event, err := runtime.Encode(b.encoder, ev)
+	if err != nil {
+		return nil, err
+	}
+
+	severity := severityInfo
+	if ev.Stage == auditinternal.StagePanic {
+		severity = severityError
+	}
+
+	ts := ev.StageTimestamp.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s ",
+		b.config.Facility*8+severity,
+		ts.UTC().Format(timestampFormat),
+		header(b.config.Hostname, 255),
+		header(b.config.AppName, 48),
+		header(b.procID, 128),
+		header(string(ev.Stage), 32),
+	)
+
+	params := [][2]string{
+		{"auditID", string(ev.AuditID)},
+		{"level", string(ev.Level)},
+		{"verb", ev.Verb},
+		{"user", ev.User.Username},
+	}
+	if ev.ResponseStatus != nil {
+		params = append(params, [2]string{"code", strconv.Itoa(int(ev.ResponseStatus.Code))})
+	}
+	if traceID, spanID := audit.TraceContextFrom(ev); traceID != "" {
+		params = append(params, [2]string{"traceID", traceID}, [2]string{"spanID", spanID})
+	}
+
+	buf.WriteString("[" + b.config.StructuredDataID)
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		buf.WriteString(" " + p[0] + `="` + paramEscaper.Replace(p[1]) + `"`)
+	}
+	buf.WriteString("] ")
+
+	buf.Write(bytes.TrimRight(event, "\n"))
+	return buf.Bytes(), nil
+}
+
+// the PARAM-VALUE must escape '"', '\' and ']'
+var paramEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// header returns the printable ascii value of the header field, or the nil value
+func header(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return nilValue
+	}
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+func (b *backend) Run(stopCh <-chan struct{}) error {
+	return nil
+}
+
+func (b *backend) Shutdown() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+}
+
+func (b *backend) String() string {
+	return PluginName
+}
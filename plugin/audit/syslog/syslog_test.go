@@ -0,0 +1,155 @@
+package syslog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	auditinternal "github.com/yubo/apiserver/pkg/apis/audit"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/types"
+	certutil "github.com/yubo/golib/util/cert"
+)
+
+// server is a syslog stand-in, it reads the octet counting framed messages
+type server struct {
+	net.Listener
+	msgs chan string
+}
+
+func newServer(t *testing.T, tlsConfig *tls.Config) *server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	s := &server{Listener: l, msgs: make(chan string, 10)}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		l, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(l))
+		if err != nil {
+			return
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return
+		}
+		s.msgs <- string(msg)
+	}
+}
+
+func (s *server) next(t *testing.T) string {
+	select {
+	case msg := <-s.msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the syslog message")
+		return ""
+	}
+}
+
+func newEvent(id string) *auditinternal.Event {
+	return &auditinternal.Event{
+		Level:          auditinternal.LevelMetadata,
+		AuditID:        types.UID(id),
+		Stage:          auditinternal.StageResponseComplete,
+		RequestURI:     "/api/v1/users",
+		Verb:           "get",
+		User:           api.UserInfo{Username: `tom"]`},
+		ResponseStatus: &api.Status{Code: 200},
+		StageTimestamp: api.NewMicroTime(time.Date(2023, 1, 2, 3, 4, 5, 6000, time.UTC)),
+		Annotations: map[string]string{
+			audit.TraceIDAnnotationKey: "4bf92f3577b34da6a3ce929d0e0e4736",
+			audit.SpanIDAnnotationKey:  "00f067aa0ba902b7",
+		},
+	}
+}
+
+func TestBackend(t *testing.T) {
+	s := newServer(t, nil)
+	b, err := NewBackend(Config{Address: s.Addr().String(), Hostname: "host", AppName: "apiserver"})
+	require.NoError(t, err)
+	defer b.Shutdown()
+
+	require.True(t, b.ProcessEvents(newEvent("1"), newEvent("2")))
+
+	msg := s.next(t)
+	prefix := fmt.Sprintf(`<110>1 2023-01-02T03:04:05.000006Z host apiserver %s ResponseComplete `+
+		`[audit@32473 auditID="1" level="Metadata" verb="get" user="tom\"\]" code="200" `+
+		`traceID="4bf92f3577b34da6a3ce929d0e0e4736" spanID="00f067aa0ba902b7"] `, b.(*backend).procID)
+	require.True(t, strings.HasPrefix(msg, prefix), msg)
+
+	// the msg is the json event
+	ev := &auditinternal.Event{}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(msg, prefix)), ev))
+	require.Equal(t, types.UID("1"), ev.AuditID)
+
+	require.Contains(t, s.next(t), `auditID="2"`)
+
+	// reconnect after the connection is closed
+	b.(*backend).conn.Close()
+	require.True(t, b.ProcessEvents(newEvent("3")))
+	require.Contains(t, s.next(t), `auditID="3"`)
+}
+
+func TestBackendTLS(t *testing.T) {
+	certPEM, keyPEM, err := certutil.GenerateSelfSignedCertKey("localhost", []net.IP{net.ParseIP("127.0.0.1")}, nil)
+	require.NoError(t, err)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(certPEM))
+
+	s := newServer(t, &tls.Config{Certificates: []tls.Certificate{cert}})
+	b, err := NewBackend(Config{
+		Address:   s.Addr().String(),
+		TLSConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"},
+	})
+	require.NoError(t, err)
+	defer b.Shutdown()
+
+	require.True(t, b.ProcessEvents(newEvent("1")))
+	require.Contains(t, s.next(t), `auditID="1"`)
+}
+
+func TestBackendUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	b, err := NewBackend(Config{Address: addr, Timeout: time.Second})
+	require.NoError(t, err)
+	require.False(t, b.ProcessEvents(newEvent("1")))
+}
@@ -7,9 +7,11 @@ import (
 
 	"github.com/yubo/apiserver/pkg/authentication"
 	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	"github.com/yubo/apiserver/pkg/authentication/authenticatorfactory"
 	"github.com/yubo/apiserver/pkg/authentication/request/headerrequest"
 	"github.com/yubo/apiserver/pkg/dynamiccertificates"
 	"github.com/yubo/apiserver/pkg/proc"
+	"github.com/yubo/apiserver/pkg/proc/options"
 	"github.com/yubo/golib/util/errors"
 	"k8s.io/klog/v2"
 )
@@ -47,6 +49,13 @@ func (s *config) Validate() error {
 		allErrors = append(allErrors, err)
 	}
 
+	if len(s.ClientCAFile) > 0 && len(s.UsernameHeaders) == 0 {
+		allErrors = append(allErrors, fmt.Errorf("--requestheader-username-headers must be specified with --requestheader-client-ca-file"))
+	}
+	if len(s.ClientCAFile) == 0 && (len(s.UsernameHeaders) > 0 || len(s.AllowedNames) > 0) {
+		allErrors = append(allErrors, fmt.Errorf("--requestheader-client-ca-file is required, the headers are only trusted from a front proxy with a verified client certificate"))
+	}
+
 	return errors.NewAggregate(allErrors)
 }
 
@@ -78,15 +87,35 @@ func factory(ctx context.Context) (authenticator.Request, error) {
 		return nil, err
 	}
 
+	// the proxy must present a client certificate in the tls handshake,
+	// the bundle is reloaded on change by the serving controller
+	if servingInfo := options.APIServerMustFrom(ctx).Config().SecureServing; servingInfo != nil {
+		if err := servingInfo.ApplyClientCert(caBundleProvider); err != nil {
+			return nil, err
+		}
+	} else {
+		klog.Warningf("authnModule %s: secure serving is not enabled, no request can be verified as from the front proxy", moduleName)
+	}
+
+	requestHeaderConfig := &authenticatorfactory.RequestHeaderConfig{
+		UsernameHeaders:     headerrequest.StaticStringSlice(cf.UsernameHeaders),
+		GroupHeaders:        headerrequest.StaticStringSlice(cf.GroupHeaders),
+		ExtraHeaderPrefixes: headerrequest.StaticStringSlice(cf.ExtraHeaderPrefixes),
+		CAContentProvider:   caBundleProvider,
+		AllowedClientNames:  headerrequest.StaticStringSlice(cf.AllowedNames),
+	}
+
+	// strip the headers from the requests which are not authenticated by the proxy
+	authentication.ConfigFrom(ctx).RequestHeaderConfig = requestHeaderConfig
+
 	return authenticator.WrapAudienceAgnosticRequest(
 		authentication.APIAudiences(), headerrequest.NewDynamicVerifyOptionsSecure(
-			caBundleProvider.VerifyOptions,
-			headerrequest.StaticStringSlice(cf.AllowedNames),
-			headerrequest.StaticStringSlice(cf.UsernameHeaders),
-			headerrequest.StaticStringSlice(cf.GroupHeaders),
-			headerrequest.StaticStringSlice(cf.ExtraHeaderPrefixes),
+			requestHeaderConfig.CAContentProvider.VerifyOptions,
+			requestHeaderConfig.AllowedClientNames,
+			requestHeaderConfig.UsernameHeaders,
+			requestHeaderConfig.GroupHeaders,
+			requestHeaderConfig.ExtraHeaderPrefixes,
 		)), nil
-
 }
 
 func init() {
@@ -0,0 +1,398 @@
+// Package login implements the OAuth2 authorization code flow with PKCE
+// against an OpenID Connect provider, the authenticated user is stored in
+// the session, see plugin/authenticator/session.
+package login
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/responsewriters"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/apiserver/pkg/sessions"
+	"github.com/yubo/golib/api/errors"
+	"github.com/yubo/golib/scheme"
+	certutil "github.com/yubo/golib/util/cert"
+	"github.com/yubo/golib/util/net"
+	"golang.org/x/oauth2"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// session keys of the pending login
+	stateKey        = "oauth2State"
+	nonceKey        = "oauth2Nonce"
+	codeVerifierKey = "oauth2CodeVerifier"
+	redirectKey     = "oauth2Redirect"
+)
+
+type Options struct {
+	// IssuerURL is the URL of the OpenID provider, used for configuration discovery.
+	IssuerURL string
+
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the external URL of the callback route, e.g. https://example.com/auth/callback
+	RedirectURL string
+
+	// Scopes requested in addition to "openid"
+	Scopes []string
+
+	// Path to a PEM encoded root certificate of the provider.
+	CAFile string
+
+	// UsernameClaim is the ID Token claim to use as the user's username.
+	UsernameClaim string
+
+	// UsernamePrefix, if specified, is prepended to the username.
+	UsernamePrefix string
+
+	// GroupsClaim, if specified, is the ID Token claim to use as the user's groups.
+	// The claim value must be a string or list of strings.
+	GroupsClaim string
+
+	// GroupsPrefix, if specified, is prepended to every group.
+	GroupsPrefix string
+
+	// PathPrefix of the login, callback and logout routes
+	PathPrefix string
+
+	// PostLogoutRedirect is the location the user is redirected to after logout.
+	PostLogoutRedirect string
+}
+
+type Login struct {
+	opts   Options
+	client *http.Client
+
+	mu       sync.Mutex
+	config   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func New(opts Options) (*Login, error) {
+	if opts.IssuerURL == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, fmt.Errorf("issuerURL, clientID and redirectURL are required")
+	}
+	if opts.UsernameClaim == "" {
+		opts.UsernameClaim = "sub"
+	}
+	if opts.PathPrefix == "" {
+		opts.PathPrefix = "/auth"
+	}
+	if opts.PostLogoutRedirect == "" {
+		opts.PostLogoutRedirect = "/"
+	}
+
+	tlsConfig := &tls.Config{}
+	if opts.CAFile != "" {
+		roots, err := certutil.NewPool(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the CA file: %v", err)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	return &Login{
+		opts: opts,
+		client: &http.Client{
+			Transport: net.SetTransportDefaults(&http.Transport{TLSClientConfig: tlsConfig}),
+			Timeout:   30 * time.Second,
+		},
+	}, nil
+}
+
+func (p *Login) Install(container rest.GoRestfulContainer) {
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               p.opts.PathPrefix,
+		Tags:               []string{"authentication"},
+		Consumes:           []string{rest.MIME_JSON, rest.MIME_URL_ENCODED},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "GET", SubPath: "/login", Operation: "login", Desc: "redirect to the OpenID provider to login", Handle: p.login},
+			{Method: "GET", SubPath: "/callback", Operation: "loginCallback", Desc: "the redirection endpoint of the OpenID provider", Handle: p.callback},
+			{Method: "GET", SubPath: "/csrf-token", Operation: "logoutToken", Desc: "the csrf token of the session which logout requires", Handle: p.csrfToken},
+			{Method: "POST", SubPath: "/logout", Operation: "logout", Desc: "remove the user from the session", Handle: p.logout},
+		},
+	})
+}
+
+// oauth2Config discovers the provider on the first use, and retries on failure
+func (p *Login) oauth2Config() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.config != nil {
+		return p.config, p.verifier, nil
+	}
+
+	// the provider keeps the context to fetch the signing keys, it must outlive the request
+	provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), p.client), p.opts.IssuerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("discover oidc provider %q: %v", p.opts.IssuerURL, err)
+	}
+
+	p.config = &oauth2.Config{
+		ClientID:     p.opts.ClientID,
+		ClientSecret: p.opts.ClientSecret,
+		RedirectURL:  p.opts.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, p.opts.Scopes...),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.opts.ClientID})
+
+	return p.config, p.verifier, nil
+}
+
+type loginParam struct {
+	Redirect string `param:"query" description:"the location to redirect to after login"`
+}
+
+func (p *Login) login(w http.ResponseWriter, req *http.Request, param *loginParam) {
+	if err := p.doLogin(w, req, param); err != nil {
+		responsewriters.ErrorNegotiated(err, scheme.NegotiatedSerializer, w, req)
+	}
+}
+
+func (p *Login) doLogin(w http.ResponseWriter, req *http.Request, param *loginParam) error {
+	sess, ok := sessions.SessionFrom(req.Context())
+	if !ok {
+		return errors.NewInternalError(fmt.Errorf("session store is not configured"))
+	}
+
+	config, _, err := p.oauth2Config()
+	if err != nil {
+		return errors.NewServiceUnavailable(err.Error())
+	}
+
+	state, err := randString()
+	if err != nil {
+		return err
+	}
+	nonce, err := randString()
+	if err != nil {
+		return err
+	}
+	codeVerifier, err := randString()
+	if err != nil {
+		return err
+	}
+
+	sess.Set(stateKey, state)
+	sess.Set(nonceKey, nonce)
+	sess.Set(codeVerifierKey, codeVerifier)
+	sess.Set(redirectKey, safeRedirect(param.Redirect))
+	if err := sess.Save(); err != nil {
+		return err
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	http.Redirect(w, req, config.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), http.StatusFound)
+	return nil
+}
+
+type callbackParam struct {
+	Code             string `param:"query"`
+	State            string `param:"query"`
+	Error            string `param:"query"`
+	ErrorDescription string `param:"query" name:"error_description"`
+}
+
+func (p *Login) callback(w http.ResponseWriter, req *http.Request, param *callbackParam) {
+	if err := p.doCallback(w, req, param); err != nil {
+		responsewriters.ErrorNegotiated(err, scheme.NegotiatedSerializer, w, req)
+	}
+}
+
+func (p *Login) doCallback(w http.ResponseWriter, req *http.Request, param *callbackParam) error {
+	ctx := req.Context()
+
+	sess, ok := sessions.SessionFrom(ctx)
+	if !ok {
+		return errors.NewInternalError(fmt.Errorf("session store is not configured"))
+	}
+
+	state, _ := sess.Get(stateKey).(string)
+	nonce, _ := sess.Get(nonceKey).(string)
+	codeVerifier, _ := sess.Get(codeVerifierKey).(string)
+	redirect, _ := sess.Get(redirectKey).(string)
+
+	// the pending login can only be used once
+	sess.Delete(stateKey)
+	sess.Delete(nonceKey)
+	sess.Delete(codeVerifierKey)
+	sess.Delete(redirectKey)
+	if err := sess.Save(); err != nil {
+		return err
+	}
+
+	if param.Error != "" {
+		return errors.NewUnauthorized(fmt.Sprintf("login failed: %s %s", param.Error, param.ErrorDescription))
+	}
+	if state == "" || param.State != state {
+		return errors.NewBadRequest("invalid login state")
+	}
+
+	config, verifier, err := p.oauth2Config()
+	if err != nil {
+		return errors.NewServiceUnavailable(err.Error())
+	}
+
+	ctx = oidc.ClientContext(ctx, p.client)
+	token, err := config.Exchange(ctx, param.Code, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+	if err != nil {
+		klog.V(3).InfoS("oauth2 code exchange failed", "err", err)
+		return errors.NewUnauthorized("unable to exchange the authorization code")
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return errors.NewUnauthorized("no id_token in the token response")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		klog.V(3).InfoS("verify id_token failed", "err", err)
+		return errors.NewUnauthorized("invalid id_token")
+	}
+	if idToken.Nonce != nonce {
+		return errors.NewUnauthorized("invalid id_token nonce")
+	}
+
+	u, err := p.userFrom(idToken)
+	if err != nil {
+		return errors.NewUnauthorized(err.Error())
+	}
+
+	// a session which was planted before the login must not be authenticated
+	if err := sess.Regenerate(); err != nil {
+		return err
+	}
+	if err := sessions.WithUser(sess, u); err != nil {
+		return err
+	}
+	klog.V(5).InfoS("login", "user", u.Name, "groups", u.Groups)
+
+	http.Redirect(w, req, redirect, http.StatusFound)
+	return nil
+}
+
+func (p *Login) csrfToken(w http.ResponseWriter, req *http.Request) {
+	sess, ok := sessions.SessionFrom(req.Context())
+	if !ok {
+		responsewriters.ErrorNegotiated(errors.NewInternalError(fmt.Errorf("session store is not configured")), scheme.NegotiatedSerializer, w, req)
+		return
+	}
+
+	token, err := sessions.CSRFToken(sess)
+	if err != nil {
+		responsewriters.ErrorNegotiated(err, scheme.NegotiatedSerializer, w, req)
+		return
+	}
+
+	responsewriters.WriteRawJSON(http.StatusOK, map[string]string{"token": token}, w)
+}
+
+// logout only accepts the POST with the csrf token of the session, see
+// sessions.CheckCSRFToken, so a cross site link or form can't log the user out
+func (p *Login) logout(w http.ResponseWriter, req *http.Request) {
+	if sess, ok := sessions.SessionFrom(req.Context()); ok && sessions.UserFrom(sess) != nil {
+		if err := sessions.CheckCSRFToken(req, sess); err != nil {
+			klog.V(3).InfoS("logout csrf check failed", "err", err)
+			responsewriters.ErrorNegotiated(errors.NewForbidden("", err), scheme.NegotiatedSerializer, w, req)
+			return
+		}
+
+		sess.Clear()
+		if err := sess.Save(); err != nil {
+			responsewriters.ErrorNegotiated(err, scheme.NegotiatedSerializer, w, req)
+			return
+		}
+	}
+
+	http.Redirect(w, req, p.opts.PostLogoutRedirect, http.StatusFound)
+}
+
+func (p *Login) userFrom(idToken *oidc.IDToken) (*user.DefaultInfo, error) {
+	var claims map[string]json.RawMessage
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("parse claims: %v", err)
+	}
+
+	var username string
+	if err := unmarshalClaim(claims, p.opts.UsernameClaim, &username); err != nil || username == "" {
+		return nil, fmt.Errorf("parse username claim %q: %v", p.opts.UsernameClaim, err)
+	}
+
+	u := &user.DefaultInfo{Name: p.opts.UsernamePrefix + username}
+
+	if p.opts.GroupsClaim != "" {
+		if _, ok := claims[p.opts.GroupsClaim]; ok {
+			var groups stringOrArray
+			if err := unmarshalClaim(claims, p.opts.GroupsClaim, &groups); err != nil {
+				return nil, fmt.Errorf("parse groups claim %q: %v", p.opts.GroupsClaim, err)
+			}
+			for _, group := range groups {
+				u.Groups = append(u.Groups, p.opts.GroupsPrefix+group)
+			}
+		}
+	}
+
+	return u, nil
+}
+
+func unmarshalClaim(claims map[string]json.RawMessage, name string, v interface{}) error {
+	val, ok := claims[name]
+	if !ok {
+		return fmt.Errorf("claim not present")
+	}
+	return json.Unmarshal([]byte(val), v)
+}
+
+type stringOrArray []string
+
+func (s *stringOrArray) UnmarshalJSON(b []byte) error {
+	var a []string
+	if err := json.Unmarshal(b, &a); err == nil {
+		*s = a
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err
+	}
+	*s = []string{str}
+	return nil
+}
+
+// safeRedirect only allows local paths, to avoid an open redirect
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return "/"
+	}
+	return redirect
+}
+
+func randString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,281 @@
+package login
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/apiserver/pkg/sessions"
+	"github.com/yubo/apiserver/pkg/sessions/cookie"
+	ormstore "github.com/yubo/apiserver/pkg/sessions/orm"
+	"github.com/yubo/golib/orm"
+	jose "gopkg.in/square/go-jose.v2"
+
+	_ "github.com/yubo/golib/orm/sqlite"
+)
+
+// provider is a minimal OpenID provider which issues an ID Token for a
+// code, once the PKCE code verifier matches the challenge.
+type provider struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]url.Values // code -> authorize request
+}
+
+func newProvider(t *testing.T) *provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &provider{t: t, key: key, codes: map[string]url.Values{}}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serveHTTP))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// authorize returns a code for the authorization request, as if the user had logged in
+func (p *provider) authorize(location string) string {
+	u, err := url.Parse(location)
+	require.NoError(p.t, err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := fmt.Sprintf("code-%d", len(p.codes))
+	p.codes[code] = u.Query()
+	return code
+}
+
+func (p *provider) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	case "/keys":
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &p.key.PublicKey, KeyID: "1", Algorithm: "RS256", Use: "sig"},
+		}})
+	case "/token":
+		r.ParseForm()
+		p.mu.Lock()
+		authz, ok := p.codes[r.PostForm.Get("code")]
+		delete(p.codes, r.PostForm.Get("code"))
+		p.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != authz.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token": p.sign(map[string]interface{}{
+				"iss":    p.URL,
+				"aud":    authz.Get("client_id"),
+				"sub":    "1234",
+				"email":  "jane@example.com",
+				"groups": []string{"team-a"},
+				"nonce":  authz.Get("nonce"),
+				"exp":    time.Now().Add(time.Hour).Unix(),
+			}),
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (p *provider) sign(claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: p.key, KeyID: "1"}}, nil)
+	require.NoError(p.t, err)
+	b, _ := json.Marshal(claims)
+	jws, err := signer.Sign(b)
+	require.NoError(p.t, err)
+	token, err := jws.CompactSerialize()
+	require.NoError(p.t, err)
+	return token
+}
+
+func newCookieStore(t *testing.T, o *sessions.Options) sessions.Store {
+	return cookie.NewStore(o)
+}
+
+func newOrmStore(t *testing.T, o *sessions.Options) sessions.Store {
+	db, err := orm.Open("sqlite3", "file:login.db?cache=shared&mode=memory&parseTime=true")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := ormstore.NewStore(&ormstore.Config{Orm: db, Options: o, TableName: "session"})
+	require.NoError(t, err)
+	return store
+}
+
+func TestLogin(t *testing.T) {
+	stores := map[string]func(*testing.T, *sessions.Options) sessions.Store{
+		"cookie": newCookieStore,
+		"orm":    newOrmStore,
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testLogin(t, newStore(t, &sessions.Options{
+				Name:     "session",
+				Path:     "/",
+				MaxAge:   3600,
+				KeyPairs: [][]byte{[]byte("secret")},
+			}))
+		})
+	}
+}
+
+func testLogin(t *testing.T, store sessions.Store) {
+	idp := newProvider(t)
+
+	container := rest.NewBaseContainer()
+	server := httptest.NewServer(sessions.Sessions(container, store.Name(), store))
+	defer server.Close()
+
+	l, err := New(Options{
+		IssuerURL:   idp.URL,
+		ClientID:    "my-client",
+		RedirectURL: server.URL + "/auth/callback",
+		GroupsClaim: "groups",
+	})
+	require.NoError(t, err)
+	l.Install(container)
+
+	container.Handle("/whoami", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		u := sessions.UserFrom(sessions.SessionMustFrom(req.Context()))
+		if u == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(u)
+	}))
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	get := func(u string) *http.Response {
+		resp, err := client.Get(u)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	// login redirects to the provider with a PKCE challenge
+	resp := get(server.URL + "/auth/login?redirect=/whoami")
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	authorize := resp.Header.Get("Location")
+	authzURL, _ := url.Parse(authorize)
+	assert.Equal(t, "S256", authzURL.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, authzURL.Query().Get("nonce"))
+	state := authzURL.Query().Get("state")
+
+	// a forged state is rejected and consumes the pending login
+	code := idp.authorize(authorize)
+	resp = get(server.URL + "/auth/callback?" + url.Values{"code": {code}, "state": {"forged"}}.Encode())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = get(server.URL + "/auth/callback?" + url.Values{"code": {code}, "state": {state}}.Encode())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// login again
+	resp = get(server.URL + "/auth/login?redirect=/whoami")
+	before := sessionCookie(t, jar, server.URL, store.Name())
+	authorize = resp.Header.Get("Location")
+	authzURL, _ = url.Parse(authorize)
+	code = idp.authorize(authorize)
+	resp = get(server.URL + "/auth/callback?" + url.Values{"code": {code}, "state": {authzURL.Query().Get("state")}}.Encode())
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/whoami", resp.Header.Get("Location"))
+
+	// the session is regenerated on login, the one before is not authenticated
+	assert.NotEqual(t, before, sessionCookie(t, jar, server.URL, store.Name()))
+	fixated, err := http.NewRequest("GET", server.URL+"/whoami", nil)
+	require.NoError(t, err)
+	fixated.AddCookie(&http.Cookie{Name: store.Name(), Value: before})
+	resp, err = http.DefaultClient.Do(fixated)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = client.Get(server.URL + "/whoami")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	u := map[string]interface{}{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&u))
+	assert.Equal(t, "1234", u["Name"])
+	assert.Equal(t, []interface{}{"team-a"}, u["Groups"])
+
+	// logout is POST only, with the csrf token of the session
+	resp = get(server.URL + "/auth/logout")
+	assert.NotEqual(t, http.StatusFound, resp.StatusCode)
+	resp, err = client.PostForm(server.URL+"/auth/logout", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = get(server.URL + "/whoami")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Get(server.URL + "/auth/csrf-token")
+	require.NoError(t, err)
+	token := map[string]string{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&token))
+	resp.Body.Close()
+	require.NotEmpty(t, token["token"])
+
+	resp, err = client.PostForm(server.URL+"/auth/logout", url.Values{sessions.CSRFTokenKey: {token["token"]}})
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	resp = get(server.URL + "/whoami")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func sessionCookie(t *testing.T, jar http.CookieJar, rawurl, name string) string {
+	u, err := url.Parse(rawurl)
+	require.NoError(t, err)
+	for _, c := range jar.Cookies(u) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestSafeRedirect(t *testing.T) {
+	cases := map[string]string{
+		"":                    "/",
+		"/a/b?c=d":            "/a/b?c=d",
+		"//evil.example.com":  "/",
+		"/\\evil.example.com": "/",
+		"https://evil.com":    "/",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, safeRedirect(in), in)
+	}
+}
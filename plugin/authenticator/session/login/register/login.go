@@ -0,0 +1,104 @@
+package register
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yubo/apiserver/pkg/proc"
+	v1 "github.com/yubo/apiserver/pkg/proc/api/v1"
+	"github.com/yubo/apiserver/pkg/proc/options"
+	"github.com/yubo/apiserver/plugin/authenticator/session/login"
+	"k8s.io/klog/v2"
+
+	// the user is read from the session by the session authenticator
+	_ "github.com/yubo/apiserver/plugin/authenticator/session/register"
+)
+
+const (
+	moduleName = "authentication.login"
+	configPath = "authentication.login"
+)
+
+type config struct {
+	IssuerURL          string   `json:"issuerURL" flag:"login-issuer-url" description:"The URL of the OpenID issuer used by the browser login flow. If set, the login, callback and logout routes are installed, the session store and session authentication must be enabled."`
+	ClientID           string   `json:"clientID" flag:"login-client-id" description:"The OAuth2 client ID of the browser login flow."`
+	ClientSecret       string   `json:"clientSecret" flag:"login-client-secret" description:"The OAuth2 client secret of the browser login flow, may be empty for a public client, PKCE is always used."`
+	RedirectURL        string   `json:"redirectURL" flag:"login-redirect-url" description:"The external URL of the callback route, e.g. https://example.com/auth/callback"`
+	Scopes             []string `json:"scopes" flag:"login-scopes" default:"profile,email" description:"The OAuth2 scopes requested in addition to openid."`
+	CAFile             string   `json:"caFile" flag:"login-ca-file" description:"If set, the OpenID server's certificate will be verified by one of the authorities in the login-ca-file, otherwise the host's root CA set will be used."`
+	UsernameClaim      string   `json:"usernameClaim" flag:"login-username-claim" default:"sub" description:"The ID Token claim to use as the user name."`
+	UsernamePrefix     string   `json:"usernamePrefix" flag:"login-username-prefix" description:"If provided, all usernames will be prefixed with this value."`
+	GroupsClaim        string   `json:"groupsClaim" flag:"login-groups-claim" description:"If provided, the ID Token claim to use as the user groups."`
+	GroupsPrefix       string   `json:"groupsPrefix" flag:"login-groups-prefix" description:"If provided, all groups will be prefixed with this value."`
+	PathPrefix         string   `json:"pathPrefix" flag:"login-path-prefix" default:"/auth" description:"The path prefix of the login, callback and logout routes."`
+	PostLogoutRedirect string   `json:"postLogoutRedirect" flag:"login-post-logout-redirect" default:"/" description:"The location the user is redirected to after logout."`
+}
+
+func (o *config) Validate() error {
+	if len(o.IssuerURL) == 0 {
+		return nil
+	}
+	if len(o.ClientID) == 0 || len(o.RedirectURL) == 0 {
+		return fmt.Errorf("login-client-id and login-redirect-url should be specified with login-issuer-url")
+	}
+	return nil
+}
+
+func newConfig() *config {
+	return &config{}
+}
+
+var (
+	_module = &module{name: moduleName}
+	hookOps = []v1.HookOps{{
+		Hook:        _module.init,
+		Owner:       moduleName,
+		HookNum:     v1.ACTION_START,
+		Priority:    v1.PRI_MODULE,
+		SubPriority: v1.PRI_M_AUTHN,
+	}}
+)
+
+type module struct {
+	name string
+}
+
+func (p *module) init(ctx context.Context) error {
+	cf := newConfig()
+	if err := proc.ReadConfig(configPath, cf); err != nil {
+		return err
+	}
+
+	if len(cf.IssuerURL) == 0 {
+		klog.V(5).InfoS("skip module", "name", p.name, "reason", "issuerURL is not set")
+		return nil
+	}
+
+	l, err := login.New(login.Options{
+		IssuerURL:          cf.IssuerURL,
+		ClientID:           cf.ClientID,
+		ClientSecret:       cf.ClientSecret,
+		RedirectURL:        cf.RedirectURL,
+		Scopes:             cf.Scopes,
+		CAFile:             cf.CAFile,
+		UsernameClaim:      cf.UsernameClaim,
+		UsernamePrefix:     cf.UsernamePrefix,
+		GroupsClaim:        cf.GroupsClaim,
+		GroupsPrefix:       cf.GroupsPrefix,
+		PathPrefix:         cf.PathPrefix,
+		PostLogoutRedirect: cf.PostLogoutRedirect,
+	})
+	if err != nil {
+		return err
+	}
+
+	l.Install(options.APIServerMustFrom(ctx))
+	klog.InfoS("login routes installed", "path", cf.PathPrefix, "issuer", cf.IssuerURL)
+
+	return nil
+}
+
+func init() {
+	proc.RegisterHooks(hookOps)
+	proc.AddConfig(configPath, newConfig(), proc.WithConfigGroup("authentication"))
+}
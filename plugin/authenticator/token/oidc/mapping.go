@@ -0,0 +1,314 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// ClaimMappings maps the claims of an ID Token to the user info with expressions.
+//
+// An expression is a text/template evaluated against the claims of the token,
+// e.g. `{{ claim "email" }}` or `{{ .claims.sub }}`. The claim function returns an
+// empty string for a missing claim, and join flattens a list claim into a
+// string.
+//
+// Groups and Extra expressions add the values of the list with the item function,
+// a list claim is added item by item, e.g.
+// `{{ item (claim "groups") }}{{ item (printf "%s:admins" (claim "tenant")) }}`,
+// empty items are dropped. The rendered text is never split, if no item is added
+// it is the only value of the list. A list claim printed without item or join
+// is rejected, e.g. `{{ claim "groups" }}`.
+//
+// The email_verified claim is checked if the Username expression reads the email
+// claim, as it is for the email UsernameClaim.
+type ClaimMappings struct {
+	// Username, if specified, takes precedence over UsernameClaim. UsernamePrefix is not applied.
+	Username string `json:"username"`
+
+	// Groups, if specified, takes precedence over GroupsClaim. GroupsPrefix is not applied.
+	Groups string `json:"groups"`
+
+	// UID, if specified, sets the uid of the user.
+	UID string `json:"uid"`
+
+	// Extra maps the keys of the user's extra info to an expression.
+	Extra map[string]string `json:"extra"`
+}
+
+// ClaimValidationRule rejects the ID Token unless the expression renders "true".
+type ClaimValidationRule struct {
+	Expression string `json:"expression"`
+	// Message is returned when the rule is not satisfied.
+	Message string `json:"message"`
+}
+
+type claimMapper struct {
+	username *template.Template
+	groups   *template.Template
+	uid      *template.Template
+	extra    map[string]*template.Template
+	rules    []validationRule
+
+	// usernameEmail is true if the username expression reads the email claim
+	usernameEmail bool
+}
+
+type validationRule struct {
+	expr    *template.Template
+	message string
+}
+
+func newClaimMapper(m ClaimMappings, rules []ClaimValidationRule) (*claimMapper, error) {
+	var err error
+	p := &claimMapper{extra: map[string]*template.Template{}}
+
+	if p.username, err = compileExpression("username", m.Username); err != nil {
+		return nil, err
+	}
+	p.usernameEmail = p.username != nil && readsClaim(p.username.Tree.Root, "email")
+	if p.groups, err = compileExpression("groups", m.Groups); err != nil {
+		return nil, err
+	}
+	if p.uid, err = compileExpression("uid", m.UID); err != nil {
+		return nil, err
+	}
+	for k, expr := range m.Extra {
+		if k == "" {
+			return nil, fmt.Errorf("oidc: extra mapping key must not be empty")
+		}
+		if p.extra[k], err = compileExpression("extra."+k, expr); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, rule := range rules {
+		expr, err := compileExpression(fmt.Sprintf("claimValidationRules[%d]", i), rule.Expression)
+		if err != nil {
+			return nil, err
+		}
+		if expr == nil {
+			return nil, fmt.Errorf("oidc: claimValidationRules[%d]: expression must not be empty", i)
+		}
+		p.rules = append(p.rules, validationRule{expr: expr, message: rule.Message})
+	}
+
+	return p, nil
+}
+
+var expressionFuncs = template.FuncMap{
+	"join":       joinClaim,
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"hasPrefix":  strings.HasPrefix,
+	"hasSuffix":  strings.HasSuffix,
+	"trimPrefix": strings.TrimPrefix,
+	"trimSuffix": strings.TrimSuffix,
+}
+
+func compileExpression(name, expr string) (*template.Template, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	// the claim func is replaced at execution time
+	funcs := template.FuncMap{
+		"claim": func(string) interface{} { return "" },
+		"item":  func(...interface{}) string { return "" },
+	}
+	t, err := template.New(name).Funcs(expressionFuncs).Funcs(funcs).Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: parse %s expression: %v", name, err)
+	}
+	return t, nil
+}
+
+// readsClaim returns true if the expression may read the claim, by the claim
+// function, a field, e.g. .claims.email, or index
+func readsClaim(node parse.Node, name string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, v := range n.Nodes {
+			if readsClaim(v, name) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return readsClaim(n.Pipe, name)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, v := range n.Cmds {
+			if readsClaim(v, name) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, v := range n.Args {
+			if readsClaim(v, name) {
+				return true
+			}
+		}
+	case *parse.IfNode:
+		return readsClaim(n.Pipe, name) || readsClaim(n.List, name) || readsClaim(n.ElseList, name)
+	case *parse.RangeNode:
+		return readsClaim(n.Pipe, name) || readsClaim(n.List, name) || readsClaim(n.ElseList, name)
+	case *parse.WithNode:
+		return readsClaim(n.Pipe, name) || readsClaim(n.List, name) || readsClaim(n.ElseList, name)
+	case *parse.ChainNode:
+		return readsClaim(n.Node, name) || containsString(n.Field, name)
+	case *parse.FieldNode:
+		return containsString(n.Ident, name)
+	case *parse.VariableNode:
+		return containsString(n.Ident, name)
+	case *parse.StringNode:
+		return n.Text == name
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// listClaim is a list claim of the expressions, it can not be printed
+type listClaim []interface{}
+
+// listMarker is printed for a list claim, the rendered text which contains
+// the marker is rejected
+const listMarker = "\x00list\x00"
+
+func (p listClaim) String() string {
+	return listMarker
+}
+
+func joinClaim(v interface{}, sep string) string {
+	switch t := v.(type) {
+	case listClaim:
+		return joinClaim([]interface{}(t), sep)
+	case []interface{}:
+		s := make([]string, 0, len(t))
+		for _, e := range t {
+			s = append(s, fmt.Sprint(e))
+		}
+		return strings.Join(s, sep)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// values decodes the claims for the evaluation of expressions
+func (c claims) values() (map[string]interface{}, error) {
+	ret := make(map[string]interface{}, len(c))
+	for k, raw := range c {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("oidc: parse claim %s: %v", k, err)
+		}
+		if list, ok := v.([]interface{}); ok {
+			v = listClaim(list)
+		}
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+func evalExpression(t *template.Template, values map[string]interface{}) (string, error) {
+	return execExpression(t, values, func(...interface{}) string { return "" })
+}
+
+// evalListExpression returns the items added by the item function of the
+// expression, the claim values are never split
+func evalListExpression(t *template.Template, values map[string]interface{}) ([]string, error) {
+	var ret []string
+	add := func(v interface{}) {
+		if s := strings.TrimSpace(fmt.Sprint(v)); s != "" {
+			ret = append(ret, s)
+		}
+	}
+	item := func(args ...interface{}) string {
+		for _, arg := range args {
+			switch t := arg.(type) {
+			case listClaim:
+				for _, v := range t {
+					add(v)
+				}
+			case []interface{}:
+				for _, v := range t {
+					add(v)
+				}
+			case []string:
+				for _, v := range t {
+					add(v)
+				}
+			case nil:
+			default:
+				add(t)
+			}
+		}
+		return ""
+	}
+
+	s, err := execExpression(t, values, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret) == 0 && s != "" {
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+func execExpression(t *template.Template, values map[string]interface{}, item func(...interface{}) string) (string, error) {
+	claim := func(name string) interface{} {
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return ""
+	}
+
+	t, err := t.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Funcs(template.FuncMap{"claim": claim, "item": item}).Execute(buf, map[string]interface{}{"claims": values}); err != nil {
+		return "", fmt.Errorf("oidc: eval %s expression: %v", t.Name(), err)
+	}
+	if strings.Contains(buf.String(), listMarker) {
+		return "", fmt.Errorf("oidc: eval %s expression: a list claim must be added by item or join", t.Name())
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// validate checks the claim validation rules
+func (p *claimMapper) validate(values map[string]interface{}) error {
+	for _, rule := range p.rules {
+		s, err := evalExpression(rule.expr, values)
+		if err != nil {
+			return err
+		}
+		if s != "true" {
+			if rule.message != "" {
+				return fmt.Errorf("oidc: claim validation failed: %s", rule.message)
+			}
+			return fmt.Errorf("oidc: claim validation failed: %s", rule.expr.Root.String())
+		}
+	}
+	return nil
+}
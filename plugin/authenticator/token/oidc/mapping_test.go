@@ -0,0 +1,347 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	tokenunion "github.com/yubo/apiserver/pkg/authentication/token/union"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestClaimMappings(t *testing.T) {
+	synchronizeTokenIDVerifierForTest = true
+	claims := fmt.Sprintf(`{
+		"iss": "https://auth.example.com",
+		"aud": ["other-client", "my-client"],
+		"sub": "1234",
+		"email": "Jane@Example.com",
+		"groups": ["team-a", "team-b"],
+		"tenant": "acme",
+		"exp": %d
+	}`, valid.Unix())
+
+	tests := []claimsTest{{
+		name: "expressions",
+		options: Options{
+			IssuerURL: "https://auth.example.com",
+			ClientID:  "my-client",
+			ClaimMappings: ClaimMappings{
+				Username: `{{ lower (claim "email") }}`,
+				Groups:   `{{ item (claim "groups") }}{{ item (printf "%s:admins" (claim "tenant")) }}`,
+				UID:      `{{ .claims.sub }}`,
+				Extra:    map[string]string{"example.com/tenant": `{{ claim "tenant" }}`, "example.com/missing": `{{ claim "missing" }}`},
+			},
+			now: func() time.Time { return now },
+		},
+		claims: claims,
+		want: &user.DefaultInfo{
+			Name:   "jane@example.com",
+			UID:    "1234",
+			Groups: []string{"team-a", "team-b", "acme:admins"},
+			Extra:  map[string][]string{"example.com/tenant": {"acme"}},
+		},
+	}, {
+		name: "list claim values are not split",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			UsernameClaim: "sub",
+			ClaimMappings: ClaimMappings{
+				Groups: `{{ item (claim "groups") }}`,
+				Extra:  map[string]string{"example.com/org": `{{ claim "org" }}`},
+			},
+			now: func() time.Time { return now },
+		},
+		claims: fmt.Sprintf(`{
+			"iss": "https://auth.example.com",
+			"aud": "my-client",
+			"sub": "1234",
+			"groups": ["team-a,admins", "team-b"],
+			"org": "acme, inc",
+			"exp": %d
+		}`, valid.Unix()),
+		want: &user.DefaultInfo{
+			Name:   "1234",
+			Groups: []string{"team-a,admins", "team-b"},
+			Extra:  map[string][]string{"example.com/org": {"acme, inc"}},
+		},
+	}, {
+		name: "printed list claim",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			UsernameClaim: "sub",
+			ClaimMappings: ClaimMappings{Groups: `{{ claim "groups" }}`},
+			now:           func() time.Time { return now },
+		},
+		claims:  claims,
+		wantErr: true,
+	}, {
+		name: "joined list claim",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			UsernameClaim: "sub",
+			ClaimMappings: ClaimMappings{Groups: `{{ join .claims.groups "+" }}`},
+			now:           func() time.Time { return now },
+		},
+		claims: claims,
+		want:   &user.DefaultInfo{Name: "1234", Groups: []string{"team-a+team-b"}},
+	}, {
+		name: "username expression with unverified email",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			ClaimMappings: ClaimMappings{Username: `{{ lower .claims.email }}`},
+			now:           func() time.Time { return now },
+		},
+		claims: fmt.Sprintf(`{
+			"iss": "https://auth.example.com",
+			"aud": "my-client",
+			"email": "jane@example.com",
+			"email_verified": false,
+			"exp": %d
+		}`, valid.Unix()),
+		wantErr: true,
+	}, {
+		name: "username expression without email",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			ClaimMappings: ClaimMappings{Username: `{{ claim "sub" }}`},
+			now:           func() time.Time { return now },
+		},
+		claims: fmt.Sprintf(`{
+			"iss": "https://auth.example.com",
+			"aud": "my-client",
+			"sub": "1234",
+			"email_verified": false,
+			"exp": %d
+		}`, valid.Unix()),
+		want: &user.DefaultInfo{Name: "1234"},
+	}, {
+		name: "empty username expression",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			ClaimMappings: ClaimMappings{Username: `{{ claim "missing" }}`},
+			now:           func() time.Time { return now },
+		},
+		claims:  claims,
+		wantErr: true,
+	}, {
+		name: "invalid expression",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			ClaimMappings: ClaimMappings{Username: `{{ claim "email" `},
+		},
+		claims:      claims,
+		wantInitErr: true,
+	}, {
+		name: "validation rule",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			UsernameClaim: "sub",
+			ClaimValidationRules: []ClaimValidationRule{
+				{Expression: `{{ eq (claim "tenant") "acme" }}`},
+				{Expression: `{{ hasSuffix (lower (claim "email")) "@example.com" }}`},
+			},
+			now: func() time.Time { return now },
+		},
+		claims: claims,
+		want:   &user.DefaultInfo{Name: "1234"},
+	}, {
+		name: "validation rule failed",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			ClientID:      "my-client",
+			UsernameClaim: "sub",
+			ClaimValidationRules: []ClaimValidationRule{
+				{Expression: `{{ eq (claim "tenant") "other" }}`, Message: "tenant must be other"},
+			},
+			now: func() time.Time { return now },
+		},
+		claims:  claims,
+		wantErr: true,
+	}, {
+		name: "audiences",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			Audiences:     []string{"foo", "other-client"},
+			UsernameClaim: "sub",
+			now:           func() time.Time { return now },
+		},
+		claims: claims,
+		want:   &user.DefaultInfo{Name: "1234"},
+	}, {
+		name: "audiences mismatch",
+		options: Options{
+			IssuerURL:     "https://auth.example.com",
+			Audiences:     []string{"foo", "bar"},
+			UsernameClaim: "sub",
+			now:           func() time.Time { return now },
+		},
+		claims:  claims,
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		test.signingKey = loadRSAPrivKey(t, "testdata/rsa_1.pem", jose.RS256)
+		test.pubKeys = []*jose.JSONWebKey{loadRSAKey(t, "testdata/rsa_1.pem", jose.RS256)}
+		t.Run(test.name, test.run)
+	}
+}
+
+func TestReadsClaim(t *testing.T) {
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`{{ claim "email" }}`, true},
+		{`{{ lower (claim "email") }}`, true},
+		{`{{ .claims.email }}`, true},
+		{`{{ $.claims.email }}`, true},
+		{`{{ index .claims "email" }}`, true},
+		{`{{ if .claims.sub }}{{ claim "email" }}{{ end }}`, true},
+		{`{{ claim "sub" }}`, false},
+		{`{{ .claims.email_address }}`, false},
+	}
+
+	for _, c := range cases {
+		tmpl, err := compileExpression("username", c.expr)
+		require.NoError(t, err)
+		assert.Equal(t, c.want, readsClaim(tmpl.Tree.Root, "email"), c.expr)
+	}
+}
+
+func signToken(t *testing.T, key *jose.JSONWebKey, claims string) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.SignatureAlgorithm(key.Algorithm), Key: key}, nil)
+	require.NoError(t, err)
+	jws, err := signer.Sign([]byte(claims))
+	require.NoError(t, err)
+	token, err := jws.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestMultipleIssuers(t *testing.T) {
+	newStatic := func(iss, key string, opts Options) authenticator.Token {
+		opts.IssuerURL = iss
+		opts.now = func() time.Time { return now }
+		a, err := newAuthenticator(opts, func(ctx context.Context, a *Authenticator, config *oidc.Config) {
+			a.setVerifier(oidc.NewVerifier(iss, &staticKeySet{keys: []*jose.JSONWebKey{loadRSAKey(t, key, jose.RS256)}}, config))
+		})
+		require.NoError(t, err)
+		return a
+	}
+
+	authn := tokenunion.New(
+		newStatic("https://a.example.com", "testdata/rsa_1.pem", Options{ClientID: "a", UsernameClaim: "sub", UsernamePrefix: "a:"}),
+		newStatic("https://b.example.com", "testdata/rsa_2.pem", Options{Audiences: []string{"b"}, ClaimMappings: ClaimMappings{Username: `b:{{ claim "email" }}`}}),
+	)
+
+	cases := []struct {
+		name  string
+		key   string
+		claim string
+		want  string
+		ok    bool
+	}{
+		{"issuer a", "testdata/rsa_1.pem", `{"iss": "https://a.example.com", "aud": "a", "sub": "jane", "exp": %d}`, "a:jane", true},
+		{"issuer b", "testdata/rsa_2.pem", `{"iss": "https://b.example.com", "aud": "b", "email": "joe@example.com", "exp": %d}`, "b:joe@example.com", true},
+		{"signed by the key of another issuer", "testdata/rsa_1.pem", `{"iss": "https://b.example.com", "aud": "b", "email": "joe@example.com", "exp": %d}`, "", false},
+		{"unknown issuer", "testdata/rsa_1.pem", `{"iss": "https://c.example.com", "aud": "a", "sub": "jane", "exp": %d}`, "", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			token := signToken(t, loadRSAPrivKey(t, c.key, jose.RS256), fmt.Sprintf(c.claim, valid.Unix()))
+			resp, ok, _ := authn.AuthenticateToken(context.Background(), token)
+			require.Equal(t, c.ok, ok)
+			if ok {
+				assert.Equal(t, c.want, resp.User.GetName())
+			}
+		})
+	}
+}
+
+// TestKeyRotation rotates the signing key of a provider, which is served by the
+// discovered jwks_uri, and moves the jwks_uri to a new location.
+func TestKeyRotation(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		keys    = []*jose.JSONWebKey{loadRSAKey(t, "testdata/rsa_1.pem", jose.RS256)}
+		keyPath = "/keys"
+	)
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"issuer":                                ts.URL,
+				"jwks_uri":                              ts.URL + keyPath,
+				"id_token_signing_alg_values_supported": []string{"RS256"},
+			})
+		case keyPath:
+			json.NewEncoder(w).Encode(toKeySet(keys))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	a, err := New(Options{
+		IssuerURL:              ts.URL,
+		ClientID:               "my-client",
+		UsernameClaim:          "sub",
+		UsernamePrefix:         "-",
+		DiscoveryRefreshPeriod: 100 * time.Millisecond,
+		now:                    func() time.Time { return now },
+	})
+	require.NoError(t, err)
+	defer a.Close()
+
+	claims := fmt.Sprintf(`{"iss": %q, "aud": "my-client", "sub": "jane", "exp": %d}`, ts.URL, valid.Unix())
+	authenticate := func(key string) error {
+		token := signToken(t, loadRSAPrivKey(t, key, jose.RS256), claims)
+		_, ok, err := a.AuthenticateToken(context.Background(), token)
+		if err == nil && !ok {
+			err = fmt.Errorf("token not authenticated")
+		}
+		return err
+	}
+
+	require.Eventually(t, func() bool { return authenticate("testdata/rsa_1.pem") == nil }, 5*time.Second, 50*time.Millisecond)
+
+	// a key with an unknown key id is fetched from the jwks_uri
+	mu.Lock()
+	keys = []*jose.JSONWebKey{loadRSAKey(t, "testdata/rsa_2.pem", jose.RS256)}
+	mu.Unlock()
+	assert.NoError(t, authenticate("testdata/rsa_2.pem"))
+	assert.Error(t, authenticate("testdata/rsa_1.pem"))
+
+	// a moved jwks_uri is picked up by the discovery refresh
+	mu.Lock()
+	keyPath = "/rotated/keys"
+	keys = []*jose.JSONWebKey{loadRSAKey(t, "testdata/rsa_3.pem", jose.RS256)}
+	mu.Unlock()
+	assert.Eventually(t, func() bool { return authenticate("testdata/rsa_3.pem") == nil }, 5*time.Second, 50*time.Millisecond)
+}
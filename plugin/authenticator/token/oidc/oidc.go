@@ -111,6 +111,22 @@ type Options struct {
 	// required claims key value pairs are present in the ID Token.
 	RequiredClaims map[string]string
 
+	// Audiences, if specified, is the list of audiences the JWT may be issued for, the
+	// "aud" claim must contain at least one of them. It takes precedence over ClientID.
+	Audiences []string
+
+	// ClaimMappings, if specified, maps the claims of the ID Token to the user info
+	// with expressions.
+	ClaimMappings ClaimMappings
+
+	// ClaimValidationRules, if specified, are checked after RequiredClaims.
+	ClaimValidationRules []ClaimValidationRule
+
+	// DiscoveryRefreshPeriod, if greater than zero, causes the discovery document of the
+	// issuer to be fetched periodically, so a changed jwks_uri is picked up. Signing keys
+	// with an unknown key id are always fetched again from the jwks_uri.
+	DiscoveryRefreshPeriod time.Duration
+
 	// now is used for testing. It defaults to time.Now.
 	now func() time.Time
 }
@@ -187,6 +203,8 @@ type Authenticator struct {
 	groupsClaim    string
 	groupsPrefix   string
 	requiredClaims map[string]string
+	audiences      []string
+	mapper         *claimMapper
 
 	// Contains an *oidc.IDTokenVerifier. Do not access directly use the
 	// idTokenVerifier method.
@@ -229,17 +247,34 @@ func New(opts Options) (*Authenticator, error) {
 	return newAuthenticator(opts, func(ctx context.Context, a *Authenticator, config *oidc.Config) {
 		// Asynchronously attempt to initialize the authenticator. This enables
 		// self-hosted providers, providers that run on top of Kubernetes itself.
-		go wait.PollImmediateUntil(time.Second*10, func() (done bool, err error) {
-			provider, err := oidc.NewProvider(ctx, a.issuerURL)
-			if err != nil {
-				klog.Errorf("oidc authenticator: initializing plugin: %v", err)
-				return false, nil
+		go func() {
+			wait.PollImmediateUntil(time.Second*10, func() (done bool, err error) {
+				provider, err := oidc.NewProvider(ctx, a.issuerURL)
+				if err != nil {
+					klog.Errorf("oidc authenticator: initializing plugin: %v", err)
+					return false, nil
+				}
+
+				verifier := provider.Verifier(config)
+				a.setVerifier(verifier)
+				return true, nil
+			}, ctx.Done())
+
+			if opts.DiscoveryRefreshPeriod <= 0 {
+				return
 			}
 
-			verifier := provider.Verifier(config)
-			a.setVerifier(verifier)
-			return true, nil
-		}, ctx.Done())
+			// keep the last verifier if the provider is unavailable
+			wait.Until(func() {
+				provider, err := oidc.NewProvider(ctx, a.issuerURL)
+				if err != nil {
+					klog.Errorf("oidc authenticator: refreshing discovery for issuer %q: %v", a.issuerURL, err)
+					return
+				}
+				a.setVerifier(provider.Verifier(config))
+				klog.V(5).InfoS("oidc authenticator: discovery refreshed", "issuer", a.issuerURL)
+			}, opts.DiscoveryRefreshPeriod, ctx.Done())
+		}()
 	})
 }
 
@@ -267,7 +302,7 @@ func newAuthenticator(opts Options, initVerifier func(ctx context.Context, a *Au
 	//	return nil, fmt.Errorf("'oidc-issuer-url' (%q) has invalid scheme (%q), require 'https'", opts.IssuerURL, url.Scheme)
 	//}
 
-	if opts.UsernameClaim == "" {
+	if opts.UsernameClaim == "" && opts.ClaimMappings.Username == "" {
 		return nil, errors.New("no username claim provided")
 	}
 
@@ -283,8 +318,12 @@ func newAuthenticator(opts Options, initVerifier func(ctx context.Context, a *Au
 		}
 	}
 
+	mapper, err := newClaimMapper(opts.ClaimMappings, opts.ClaimValidationRules)
+	if err != nil {
+		return nil, err
+	}
+
 	var roots *x509.CertPool
-	var err error
 	if opts.CAFile != "" {
 		roots, err = certutil.NewPool(opts.CAFile)
 		if err != nil {
@@ -316,6 +355,11 @@ func newAuthenticator(opts Options, initVerifier func(ctx context.Context, a *Au
 		SupportedSigningAlgs: supportedSigningAlgs,
 		Now:                  now,
 	}
+	if len(opts.Audiences) > 0 {
+		// the audiences are checked by the authenticator
+		verifierConfig.ClientID = ""
+		verifierConfig.SkipClientIDCheck = true
+	}
 
 	var resolver *claimResolver
 	if opts.GroupsClaim != "" {
@@ -329,6 +373,8 @@ func newAuthenticator(opts Options, initVerifier func(ctx context.Context, a *Au
 		groupsClaim:    opts.GroupsClaim,
 		groupsPrefix:   opts.GroupsPrefix,
 		requiredClaims: opts.RequiredClaims,
+		audiences:      opts.Audiences,
+		mapper:         mapper,
 		cancel:         cancel,
 		resolver:       resolver,
 	}
@@ -558,6 +604,10 @@ func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*a
 		return nil, false, fmt.Errorf("oidc: verify token: %v", err)
 	}
 
+	if len(a.audiences) > 0 && !hasAudience(a.audiences, idToken.Audience) {
+		return nil, false, fmt.Errorf("oidc: expected audience in %q got %q", a.audiences, idToken.Audience)
+	}
+
 	var c claims
 	if err := idToken.Claims(&c); err != nil {
 		return nil, false, fmt.Errorf("oidc: parse claims: %v", err)
@@ -568,12 +618,24 @@ func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*a
 		}
 	}
 
+	values, err := c.values()
+	if err != nil {
+		return nil, false, err
+	}
+
 	var username string
-	if err := c.unmarshalClaim(a.usernameClaim, &username); err != nil {
+	if a.mapper.username != nil {
+		if username, err = evalExpression(a.mapper.username, values); err != nil {
+			return nil, false, err
+		}
+		if username == "" {
+			return nil, false, fmt.Errorf("oidc: username expression evaluated to an empty string")
+		}
+	} else if err := c.unmarshalClaim(a.usernameClaim, &username); err != nil {
 		return nil, false, fmt.Errorf("oidc: parse username claims %q: %v", a.usernameClaim, err)
 	}
 
-	if a.usernameClaim == "email" {
+	if (a.mapper.username == nil && a.usernameClaim == "email") || a.mapper.usernameEmail {
 		// If the email_verified claim is present, ensure the email is valid.
 		// https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
 		if hasEmailVerified := c.hasClaim("email_verified"); hasEmailVerified {
@@ -589,12 +651,16 @@ func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*a
 		}
 	}
 
-	if a.usernamePrefix != "" {
+	if a.mapper.username == nil && a.usernamePrefix != "" {
 		username = a.usernamePrefix + username
 	}
 
 	info := &user.DefaultInfo{Name: username}
-	if a.groupsClaim != "" {
+	if a.mapper.groups != nil {
+		if info.Groups, err = evalListExpression(a.mapper.groups, values); err != nil {
+			return nil, false, err
+		}
+	} else if a.groupsClaim != "" {
 		if _, ok := c[a.groupsClaim]; ok {
 			// Some admins want to use string claims like "role" as the group value.
 			// Allow the group claim to be a single string instead of an array.
@@ -608,7 +674,7 @@ func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*a
 		}
 	}
 
-	if a.groupsPrefix != "" {
+	if a.mapper.groups == nil && a.groupsPrefix != "" {
 		for i, group := range info.Groups {
 			info.Groups[i] = a.groupsPrefix + group
 		}
@@ -630,6 +696,30 @@ func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*a
 		}
 	}
 
+	if err := a.mapper.validate(values); err != nil {
+		return nil, false, err
+	}
+
+	if a.mapper.uid != nil {
+		if info.UID, err = evalExpression(a.mapper.uid, values); err != nil {
+			return nil, false, err
+		}
+	}
+
+	for key, expr := range a.mapper.extra {
+		v, err := evalListExpression(expr, values)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(v) == 0 {
+			continue
+		}
+		if info.Extra == nil {
+			info.Extra = map[string][]string{}
+		}
+		info.Extra[key] = v
+	}
+
 	return &authenticator.Response{User: info}, true, nil
 }
 
@@ -666,6 +756,17 @@ func getClaimJWT(client *http.Client, url, accessToken string) (string, error) {
 	return string(responseBytes), nil
 }
 
+func hasAudience(want, got []string) bool {
+	for _, w := range want {
+		for _, g := range got {
+			if w == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type stringOrArray []string
 
 func (s *stringOrArray) UnmarshalJSON(b []byte) error {
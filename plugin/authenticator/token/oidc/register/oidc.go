@@ -6,8 +6,10 @@ import (
 
 	"github.com/yubo/apiserver/pkg/authentication"
 	"github.com/yubo/apiserver/pkg/authentication/authenticator"
+	tokenunion "github.com/yubo/apiserver/pkg/authentication/token/union"
 	"github.com/yubo/apiserver/pkg/proc"
 	"github.com/yubo/apiserver/plugin/authenticator/token/oidc"
+	"github.com/yubo/golib/api"
 	"k8s.io/klog/v2"
 )
 
@@ -37,6 +39,28 @@ type config struct {
 	// RequiredClaims, if specified, causes the OIDCAuthenticator to verify that all the
 	// required claims key value pairs are present in the ID Token.
 	RequiredClaims map[string]string `json:"requiredClaims" flag:"oidc-required-claim" description:"A key=value pair that describes a required claim in the ID Token. If set, the claim is verified to be present in the ID Token with a matching value. Repeat this flag to specify multiple claims."`
+
+	DiscoveryRefreshPeriod api.Duration `json:"discoveryRefreshPeriod" flag:"oidc-discovery-refresh-period" description:"If set, the discovery document of the issuer is fetched again periodically, so that a changed jwks_uri is picked up."`
+
+	// Issuers holds additional issuers, each one is tried for a token with a matching "iss" claim.
+	Issuers []issuer `json:"issuers"`
+}
+
+// issuer is the configuration of an additional issuer
+type issuer struct {
+	IssuerURL              string                     `json:"issuerURL"`
+	ClientID               string                     `json:"clientID"`
+	Audiences              []string                   `json:"audiences"`
+	CAFile                 string                     `json:"caFile"`
+	UsernameClaim          string                     `json:"usernameClaim"`
+	UsernamePrefix         string                     `json:"usernamePrefix"`
+	GroupsClaim            string                     `json:"groupsClaim"`
+	GroupsPrefix           string                     `json:"groupsPrefix"`
+	SigningAlgs            []string                   `json:"signingAlgs"`
+	RequiredClaims         map[string]string          `json:"requiredClaims"`
+	ClaimMappings          oidc.ClaimMappings         `json:"claimMappings"`
+	ClaimValidationRules   []oidc.ClaimValidationRule `json:"claimValidationRules"`
+	DiscoveryRefreshPeriod api.Duration               `json:"discoveryRefreshPeriod"`
 }
 
 func (o *config) Validate() error {
@@ -44,20 +68,49 @@ func (o *config) Validate() error {
 		return fmt.Errorf("oidc-issuer-url and oidc-client-id should be specified together")
 	}
 
-	if o.UsernamePrefix == "" && o.UsernameClaim != "email" {
+	o.UsernamePrefix = defaultUsernamePrefix(o.IssuerURL, o.UsernameClaim, o.UsernamePrefix)
+
+	issuers := map[string]bool{o.IssuerURL: len(o.IssuerURL) > 0}
+	for i := range o.Issuers {
+		iss := &o.Issuers[i]
+		if len(iss.IssuerURL) == 0 {
+			return fmt.Errorf("%s.issuers[%d].issuerURL is required", configPath, i)
+		}
+		if issuers[iss.IssuerURL] {
+			return fmt.Errorf("%s.issuers[%d]: duplicate issuerURL %q", configPath, i, iss.IssuerURL)
+		}
+		issuers[iss.IssuerURL] = true
+
+		if len(iss.ClientID) == 0 && len(iss.Audiences) == 0 {
+			return fmt.Errorf("%s.issuers[%d]: one of clientID or audiences is required", configPath, i)
+		}
+		if len(iss.UsernameClaim) == 0 && len(iss.ClaimMappings.Username) == 0 {
+			iss.UsernameClaim = "sub"
+		}
+		if len(iss.SigningAlgs) == 0 {
+			iss.SigningAlgs = o.SigningAlgs
+		}
+		iss.UsernamePrefix = defaultUsernamePrefix(iss.IssuerURL, iss.UsernameClaim, iss.UsernamePrefix)
+	}
+
+	return nil
+}
+
+func defaultUsernamePrefix(issuerURL, usernameClaim, usernamePrefix string) string {
+	if usernamePrefix == "" && usernameClaim != "email" {
 		// Old behavior. If a usernamePrefix isn't provided, prefix all claims other than "email"
 		// with the issuerURL.
 		//
 		// See https://github.com/kubernetes/kubernetes/issues/31380
-		o.UsernamePrefix = o.IssuerURL + "#"
+		return issuerURL + "#"
 	}
 
-	if o.UsernamePrefix == noUsernamePrefix {
+	if usernamePrefix == noUsernamePrefix {
 		// Special value indicating usernames shouldn't be prefixed.
-		o.UsernamePrefix = ""
+		return ""
 	}
 
-	return nil
+	return usernamePrefix
 }
 
 func newConfig() *config {
@@ -70,23 +123,69 @@ func factory(ctx context.Context) (authenticator.Token, error) {
 		return nil, err
 	}
 
-	if len(cf.IssuerURL) == 0 {
+	var opts []oidc.Options
+	if len(cf.IssuerURL) > 0 {
+		opts = append(opts, oidc.Options{
+			IssuerURL:              cf.IssuerURL,
+			ClientID:               cf.ClientID,
+			CAFile:                 cf.CAFile,
+			UsernameClaim:          cf.UsernameClaim,
+			UsernamePrefix:         cf.UsernamePrefix,
+			GroupsClaim:            cf.GroupsClaim,
+			GroupsPrefix:           cf.GroupsPrefix,
+			SupportedSigningAlgs:   cf.SigningAlgs,
+			RequiredClaims:         cf.RequiredClaims,
+			DiscoveryRefreshPeriod: cf.DiscoveryRefreshPeriod.Duration,
+		})
+	}
+	for _, iss := range cf.Issuers {
+		opts = append(opts, oidc.Options{
+			IssuerURL:              iss.IssuerURL,
+			ClientID:               iss.ClientID,
+			Audiences:              iss.Audiences,
+			CAFile:                 iss.CAFile,
+			UsernameClaim:          iss.UsernameClaim,
+			UsernamePrefix:         iss.UsernamePrefix,
+			GroupsClaim:            iss.GroupsClaim,
+			GroupsPrefix:           iss.GroupsPrefix,
+			SupportedSigningAlgs:   iss.SigningAlgs,
+			RequiredClaims:         iss.RequiredClaims,
+			ClaimMappings:          iss.ClaimMappings,
+			ClaimValidationRules:   iss.ClaimValidationRules,
+			DiscoveryRefreshPeriod: iss.DiscoveryRefreshPeriod.Duration,
+		})
+	}
+
+	if len(opts) == 0 {
 		klog.V(5).Infof("%s.issuerURL is not set, skip", configPath)
 		return nil, nil
 	}
-	klog.V(5).InfoS("authmodule init", "name", moduleName, "IssuerURL", cf.IssuerURL)
-
-	return oidc.New(oidc.Options{
-		IssuerURL:            cf.IssuerURL,
-		ClientID:             cf.ClientID,
-		CAFile:               cf.CAFile,
-		UsernameClaim:        cf.UsernameClaim,
-		UsernamePrefix:       cf.UsernamePrefix,
-		GroupsClaim:          cf.GroupsClaim,
-		GroupsPrefix:         cf.GroupsPrefix,
-		SupportedSigningAlgs: cf.SigningAlgs,
-		RequiredClaims:       cf.RequiredClaims,
-	})
+
+	// each authenticator skips the tokens of the other issuers
+	var authenticators []authenticator.Token
+	for _, o := range opts {
+		klog.V(5).InfoS("authmodule init", "name", moduleName, "IssuerURL", o.IssuerURL)
+		a, err := oidc.New(o)
+		if err != nil {
+			return nil, fmt.Errorf("issuer %q: %v", o.IssuerURL, err)
+		}
+		go func() {
+			<-ctx.Done()
+			a.Close()
+		}()
+		authenticators = append(authenticators, a)
+	}
+
+	return issuers{tokenunion.New(authenticators...)}, nil
+}
+
+// issuers keeps the priority of the oidc authenticator for the union of the issuers
+type issuers struct {
+	authenticator.Token
+}
+
+func (p issuers) Priority() int {
+	return authenticator.PRI_TOKEN_OIDC
 }
 
 func init() {
@@ -9,6 +9,7 @@ import (
 	"github.com/yubo/apiserver/pkg/dynamiccertificates"
 	"github.com/yubo/apiserver/pkg/proc"
 	"github.com/yubo/apiserver/pkg/proc/options"
+	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/util/errors"
 	"k8s.io/klog/v2"
 )
@@ -24,6 +25,19 @@ type config struct {
 	// ClientCA is the certificate bundle for all the signers that you'll recognize for incoming client certificates
 	ClientCA string `json:"clientCAFile" flag:"client-ca-file" description:"If set, any request presenting a client certificate signed by one of the authorities in the client-ca-file is authenticated with an identity corresponding to the CommonName of the client certificate."`
 
+	// ClientCRL is a PEM file of the certificate revocation lists of the client CAs, it is reloaded on change
+	ClientCRL string `json:"clientCRLFile" flag:"client-crl-file" description:"If set, client certificates revoked by one of the certificate revocation lists in the file are rejected. The file is reloaded on change."`
+
+	ClientOCSP bool `json:"clientOCSP" flag:"client-ocsp" description:"If set, client certificates are checked against the OCSP responder of the certificate."`
+
+	ClientOCSPResponder string `json:"clientOCSPResponder" flag:"client-ocsp-responder" description:"If set, the OCSP responder overrides the OCSP server of the client certificates."`
+
+	ClientOCSPCacheTTL api.Duration `json:"clientOCSPCacheTTL" default:"5m" flag:"client-ocsp-cache-ttl" description:"The max duration to cache the OCSP responses, a response is cached until its next update if that comes first."`
+
+	ClientOCSPSoftFail bool `json:"clientOCSPSoftFail" flag:"client-ocsp-soft-fail" description:"Allow the client certificate if the OCSP responder is unreachable or the status of the certificate is unknown."`
+
+	ClientOCSPRequireResponder bool `json:"clientOCSPRequireResponder" flag:"client-ocsp-require-responder" description:"Reject the client certificate without an OCSP server if client-ocsp-responder is not set, by default the certificate is not checked by OCSP."`
+
 	// CAContentProvider are the options for verifying incoming connections using mTLS and directly assigning to users.
 	// Generally this is the CA bundle file used to authenticate client certificates
 	// If non-nil, this takes priority over the ClientCA file.
@@ -31,6 +45,9 @@ type config struct {
 }
 
 func (s *config) Validate() error {
+	if s.ClientCA == "" && (s.ClientCRL != "" || s.ClientOCSP) {
+		return errors.Errorf("clientCRLFile and clientOCSP require clientCAFile")
+	}
 	return nil
 }
 
@@ -74,7 +91,40 @@ func factory(ctx context.Context) (authenticator.Request, error) {
 		return nil, err
 	}
 
-	return x509.NewDynamic(servingInfo.ClientCA.VerifyOptions, x509.CommonNameUserConversion), nil
+	// the client ca of the serving info may be a union with other bundles, e.g. the front proxy ca,
+	// which must not authenticate users by the common name.
+	authn := x509.NewDynamic(clientCA.VerifyOptions, x509.CommonNameUserConversion)
+
+	var checkers x509.RevocationCheckers
+	if cf.ClientCRL != "" {
+		crl, err := dynamiccertificates.NewDynamicCRLContentFromFile("client-crl", cf.ClientCRL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "NewDynamicCRLContentFromFile")
+		}
+		go crl.Run(1, ctx.Done())
+
+		checker := x509.NewCRLChecker(crl)
+		crl.AddListener(checker)
+
+		checkers = append(checkers, checker)
+	}
+	if cf.ClientOCSP {
+		ocsp := x509.NewOCSPChecker(x509.OCSPOptions{
+			ResponderURL: cf.ClientOCSPResponder,
+			CacheTTL:     cf.ClientOCSPCacheTTL.Duration,
+			SoftFail:     cf.ClientOCSPSoftFail,
+
+			RequireResponder: cf.ClientOCSPRequireResponder,
+		})
+		clientCA.AddListener(ocsp)
+
+		checkers = append(checkers, ocsp)
+	}
+	if len(checkers) > 0 {
+		authn.WithRevocationChecker(checkers)
+	}
+
+	return authn, nil
 }
 
 func init() {
@@ -0,0 +1,208 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/apiserver/pkg/listers"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/labels"
+	"k8s.io/klog/v2"
+)
+
+// k8s.io/kubernetes/pkg/controller/clusterroleaggregation/clusterroleaggregation_controller.go
+
+// AggregateClusterRoles computes the rules of the ClusterRoles which have an AggregationRule,
+// by collecting the rules of every ClusterRole matched by any of its selectors.
+// The input is not modified, aggregated ClusterRoles are replaced by copies in the returned list.
+func AggregateClusterRoles(clusterRoles []*rbac.ClusterRole) []*rbac.ClusterRole {
+	a := &aggregator{
+		roles:    clusterRoles,
+		resolved: map[string][]rbac.PolicyRule{},
+		visiting: map[string]bool{},
+	}
+
+	ret := make([]*rbac.ClusterRole, len(clusterRoles))
+	for i, role := range clusterRoles {
+		if role.AggregationRule == nil {
+			ret[i] = role
+			continue
+		}
+
+		out := *role
+		out.Rules = a.rulesFor(role)
+		ret[i] = &out
+	}
+
+	return ret
+}
+
+// aggregatedClusterRoleLister returns the ClusterRoles of the source lister
+// with the aggregated rules.
+type aggregatedClusterRoleLister struct {
+	source listers.ClusterRoleLister
+	ttl    time.Duration
+
+	mu         sync.Mutex
+	aggregates map[string]*rbac.ClusterRole
+	expiresAt  time.Time
+
+	// for test
+	now func() time.Time
+}
+
+// NewAggregatedClusterRoleLister returns a ClusterRoleLister which computes the rules of
+// the aggregated ClusterRoles, e.g. for the sources without a resyncing cache.
+// The aggregated ClusterRoles are listed from the source at most once per ttl,
+// a zero ttl lists them on every lookup.
+func NewAggregatedClusterRoleLister(source listers.ClusterRoleLister, ttl time.Duration) listers.ClusterRoleLister {
+	return &aggregatedClusterRoleLister{source: source, ttl: ttl, now: time.Now}
+}
+
+func (p *aggregatedClusterRoleLister) List(ctx context.Context, opts api.GetListOptions) ([]*rbac.ClusterRole, error) {
+	list, err := p.source.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, role := range list {
+		if role.AggregationRule == nil {
+			continue
+		}
+
+		// the selectors match against all the cluster roles, not only the listed ones
+		aggregated, err := p.aggregated(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		ret := make([]*rbac.ClusterRole, len(list))
+		copy(ret, list[:i])
+		for j := i; j < len(list); j++ {
+			ret[j] = list[j]
+			if r, ok := aggregated[list[j].Name]; ok {
+				ret[j] = r
+			}
+		}
+		return ret, nil
+	}
+
+	return list, nil
+}
+
+func (p *aggregatedClusterRoleLister) Get(ctx context.Context, name string) (*rbac.ClusterRole, error) {
+	role, err := p.source.Get(ctx, name)
+	if err != nil || role.AggregationRule == nil {
+		return role, err
+	}
+
+	aggregated, err := p.aggregated(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := aggregated[name]; ok {
+		return r, nil
+	}
+
+	return role, nil
+}
+
+func (p *aggregatedClusterRoleLister) aggregated(ctx context.Context) (map[string]*rbac.ClusterRole, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.aggregates != nil && now.Before(p.expiresAt) {
+		return p.aggregates, nil
+	}
+
+	all, err := p.source.List(ctx, api.GetListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]*rbac.ClusterRole{}
+	for _, role := range AggregateClusterRoles(all) {
+		if role.AggregationRule != nil {
+			ret[role.Name] = role
+		}
+	}
+
+	if p.ttl > 0 {
+		p.aggregates, p.expiresAt = ret, now.Add(p.ttl)
+	}
+	return ret, nil
+}
+
+type aggregator struct {
+	roles    []*rbac.ClusterRole
+	resolved map[string][]rbac.PolicyRule
+	visiting map[string]bool
+}
+
+// rulesFor returns the effective rules of the role, aggregated roles may be
+// nested, cycles are broken by using the literal rules of the role.
+func (p *aggregator) rulesFor(role *rbac.ClusterRole) []rbac.PolicyRule {
+	if role.AggregationRule == nil {
+		return role.Rules
+	}
+	if rules, ok := p.resolved[role.Name]; ok {
+		return rules
+	}
+	if p.visiting[role.Name] {
+		klog.V(3).InfoS("clusterrole aggregation cycle detected", "name", role.Name)
+		return role.Rules
+	}
+	p.visiting[role.Name] = true
+	defer delete(p.visiting, role.Name)
+
+	var selectors []labels.Selector
+	for _, s := range role.AggregationRule.ClusterRoleSelectors {
+		selector, err := rbac.LabelSelectorAsSelector(&s)
+		if err != nil {
+			klog.ErrorS(err, "invalid aggregation selector", "name", role.Name)
+			continue
+		}
+		selectors = append(selectors, selector)
+	}
+
+	// sort by name so the order of the aggregated rules is stable
+	var matched []*rbac.ClusterRole
+	for _, r := range p.roles {
+		if r.Name == role.Name {
+			continue
+		}
+		for _, selector := range selectors {
+			if selector.Matches(labels.Set(r.Labels)) {
+				matched = append(matched, r)
+				break
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Name < matched[j].Name })
+
+	rules := []rbac.PolicyRule{}
+	for _, r := range matched {
+		for _, rule := range p.rulesFor(r) {
+			if !ruleExists(rules, rule) {
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	p.resolved[role.Name] = rules
+	return rules
+}
+
+func ruleExists(haystack []rbac.PolicyRule, needle rbac.PolicyRule) bool {
+	for _, curr := range haystack {
+		if reflect.DeepEqual(curr, needle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/golib/api"
+)
+
+func newLabeledClusterRole(name string, lbls map[string]string, rules ...rbac.PolicyRule) *rbac.ClusterRole {
+	return &rbac.ClusterRole{
+		ObjectMeta: api.ObjectMeta{Name: name, Labels: lbls},
+		Rules:      rules,
+	}
+}
+
+func aggregate(role *rbac.ClusterRole, selectors ...api.LabelSelector) *rbac.ClusterRole {
+	role.AggregationRule = &rbac.AggregationRule{ClusterRoleSelectors: selectors}
+	return role
+}
+
+func TestAggregateClusterRoles(t *testing.T) {
+	getPods := rbac.PolicyRule{Verbs: []string{"get"}, Resources: []string{"pods"}}
+	listPods := rbac.PolicyRule{Verbs: []string{"list"}, Resources: []string{"pods"}}
+	getUsers := rbac.PolicyRule{Verbs: []string{"get"}, Resources: []string{"users"}}
+
+	viewSelector := api.LabelSelector{MatchLabels: map[string]string{"aggregate-to-view": "true"}}
+	editSelector := api.LabelSelector{MatchLabels: map[string]string{"aggregate-to-edit": "true"}}
+
+	view := aggregate(newLabeledClusterRole("view", map[string]string{"aggregate-to-edit": "true"}), viewSelector)
+	edit := aggregate(newLabeledClusterRole("edit", nil, getUsers), editSelector)
+
+	in := []*rbac.ClusterRole{
+		newLabeledClusterRole("pods-b", map[string]string{"aggregate-to-view": "true"}, listPods, getPods),
+		newLabeledClusterRole("pods-a", map[string]string{"aggregate-to-view": "true"}, getPods),
+		newLabeledClusterRole("users", map[string]string{"aggregate-to-view": "false"}, getUsers),
+		view,
+		edit,
+	}
+
+	out := AggregateClusterRoles(in)
+	require.Len(t, out, len(in))
+
+	// sorted by the name of the matched roles, duplicated rules are dropped
+	assert.Equal(t, []rbac.PolicyRule{getPods, listPods}, out[3].Rules)
+	// nested aggregation, the literal rules of edit are replaced
+	assert.Equal(t, []rbac.PolicyRule{getPods, listPods}, out[4].Rules)
+
+	// the input is not modified
+	assert.Empty(t, view.Rules)
+	assert.Equal(t, []rbac.PolicyRule{getUsers}, edit.Rules)
+	assert.Same(t, in[0], out[0])
+}
+
+func TestAggregateClusterRolesCycle(t *testing.T) {
+	getPods := rbac.PolicyRule{Verbs: []string{"get"}, Resources: []string{"pods"}}
+	selector := api.LabelSelector{MatchExpressions: []api.LabelSelectorRequirement{
+		{Key: "aggregate", Operator: api.LabelSelectorOpExists},
+	}}
+
+	out := AggregateClusterRoles([]*rbac.ClusterRole{
+		aggregate(newLabeledClusterRole("a", map[string]string{"aggregate": ""}), selector),
+		aggregate(newLabeledClusterRole("b", map[string]string{"aggregate": ""}), selector),
+		newLabeledClusterRole("c", map[string]string{"aggregate": ""}, getPods),
+	})
+
+	assert.Equal(t, []rbac.PolicyRule{getPods}, out[0].Rules)
+	assert.Equal(t, []rbac.PolicyRule{getPods}, out[1].Rules)
+}
+
+type clusterRoleLister []*rbac.ClusterRole
+
+func (p clusterRoleLister) List(ctx context.Context, opts api.GetListOptions) ([]*rbac.ClusterRole, error) {
+	return p, nil
+}
+
+func (p clusterRoleLister) Get(ctx context.Context, name string) (*rbac.ClusterRole, error) {
+	for _, role := range p {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+func TestAggregatedClusterRoleLister(t *testing.T) {
+	getPods := rbac.PolicyRule{Verbs: []string{"get"}, Resources: []string{"pods"}}
+	viewSelector := api.LabelSelector{MatchLabels: map[string]string{"aggregate-to-view": "true"}}
+
+	lister := NewAggregatedClusterRoleLister(clusterRoleLister{
+		newLabeledClusterRole("pods", map[string]string{"aggregate-to-view": "true"}, getPods),
+		aggregate(newLabeledClusterRole("view", nil), viewSelector),
+	}, 0)
+
+	view, err := lister.Get(context.Background(), "view")
+	require.NoError(t, err)
+	assert.Equal(t, []rbac.PolicyRule{getPods}, view.Rules)
+
+	list, err := lister.List(context.Background(), api.GetListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, []rbac.PolicyRule{getPods}, list[1].Rules)
+}
+
+type countingClusterRoleLister struct {
+	clusterRoleLister
+	lists int
+}
+
+func (p *countingClusterRoleLister) List(ctx context.Context, opts api.GetListOptions) ([]*rbac.ClusterRole, error) {
+	p.lists++
+	return p.clusterRoleLister, nil
+}
+
+func TestAggregatedClusterRoleListerTTL(t *testing.T) {
+	ctx := context.Background()
+	getPods := rbac.PolicyRule{Verbs: []string{"get"}, Resources: []string{"pods"}}
+	viewSelector := api.LabelSelector{MatchLabels: map[string]string{"aggregate-to-view": "true"}}
+
+	source := &countingClusterRoleLister{clusterRoleLister: clusterRoleLister{
+		newLabeledClusterRole("pods", map[string]string{"aggregate-to-view": "true"}, getPods),
+		aggregate(newLabeledClusterRole("view", nil), viewSelector),
+	}}
+	now := time.Now()
+	lister := NewAggregatedClusterRoleLister(source, time.Minute).(*aggregatedClusterRoleLister)
+	lister.now = func() time.Time { return now }
+
+	// the aggregated roles are listed once per ttl
+	for i := 0; i < 3; i++ {
+		view, err := lister.Get(ctx, "view")
+		require.NoError(t, err)
+		assert.Equal(t, []rbac.PolicyRule{getPods}, view.Rules)
+	}
+	assert.Equal(t, 1, source.lists)
+
+	now = now.Add(time.Minute)
+	_, err := lister.Get(ctx, "view")
+	require.NoError(t, err)
+	assert.Equal(t, 2, source.lists)
+}
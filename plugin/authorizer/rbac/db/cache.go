@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/apiserver/pkg/listers"
+	rbacauthorizer "github.com/yubo/apiserver/plugin/authorizer/rbac"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	subsystem = "authorization_rbac_cache"
+
+	hitTag  = "hit"
+	missTag = "miss"
+
+	syncOkTag     = "ok"
+	syncFailedTag = "error"
+)
+
+var (
+	requestCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "request_total",
+			Help:      "Counter of rbac cache lookups broken out by resource and result (hit, miss).",
+		},
+		[]string{"resource", "result"},
+	)
+	syncCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "sync_total",
+			Help:      "Counter of rbac cache resyncs from the storage broken out by result.",
+		},
+		[]string{"result"},
+	)
+	lastSyncTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "last_sync_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful rbac cache resync.",
+		},
+	)
+	staleness = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "staleness_seconds",
+			Help:      "Seconds elapsed since the last successful rbac cache resync, observed at lookup time.",
+		},
+	)
+)
+
+// Cache is an informer-style in-memory copy of the rbac objects.
+// It is refreshed periodically from the source listers, and serves
+// the listers interfaces from memory.
+type Cache struct {
+	roles               listers.RoleLister
+	roleBindings        listers.RoleBindingLister
+	clusterRoles        listers.ClusterRoleLister
+	clusterRoleBindings listers.ClusterRoleBindingLister
+
+	mu       sync.RWMutex
+	synced   bool
+	lastSync time.Time
+	snapshot snapshot
+
+	// for test
+	now func() time.Time
+}
+
+type snapshot struct {
+	roles                  map[string]*rbac.Role
+	roleList               []*rbac.Role
+	roleBindings           map[string]*rbac.RoleBinding
+	roleBindingList        []*rbac.RoleBinding
+	clusterRoles           map[string]*rbac.ClusterRole
+	clusterRoleList        []*rbac.ClusterRole
+	clusterRoleBindings    map[string]*rbac.ClusterRoleBinding
+	clusterRoleBindingList []*rbac.ClusterRoleBinding
+}
+
+// NewCache returns a Cache backed by the given source listers.
+// Call Run or Resync to populate it, lookups fall through to the
+// source until the first successful resync.
+func NewCache(
+	roles listers.RoleLister,
+	roleBindings listers.RoleBindingLister,
+	clusterRoles listers.ClusterRoleLister,
+	clusterRoleBindings listers.ClusterRoleBindingLister,
+) *Cache {
+	return &Cache{
+		roles:               roles,
+		roleBindings:        roleBindings,
+		clusterRoles:        clusterRoles,
+		clusterRoleBindings: clusterRoleBindings,
+		now:                 time.Now,
+	}
+}
+
+// Run resyncs the cache every period until ctx is done.
+func (p *Cache) Run(ctx context.Context, period time.Duration) {
+	go wait.Until(func() {
+		if err := p.Resync(ctx); err != nil {
+			klog.ErrorS(err, "rbac cache resync")
+		}
+	}, period, ctx.Done())
+}
+
+// Resync lists all the objects from the source and swaps them in atomically.
+func (p *Cache) Resync(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil {
+			syncCount.WithLabelValues(syncFailedTag).Inc()
+			return
+		}
+		syncCount.WithLabelValues(syncOkTag).Inc()
+	}()
+
+	opts := api.GetListOptions{}
+	s := snapshot{
+		roles:               map[string]*rbac.Role{},
+		roleBindings:        map[string]*rbac.RoleBinding{},
+		clusterRoles:        map[string]*rbac.ClusterRole{},
+		clusterRoleBindings: map[string]*rbac.ClusterRoleBinding{},
+	}
+
+	if s.roleList, err = p.roles.List(ctx, opts); err != nil {
+		return err
+	}
+	if s.roleBindingList, err = p.roleBindings.List(ctx, opts); err != nil {
+		return err
+	}
+	if s.clusterRoleList, err = p.clusterRoles.List(ctx, opts); err != nil {
+		return err
+	}
+	// aggregated cluster roles are recomputed on every resync
+	s.clusterRoleList = rbacauthorizer.AggregateClusterRoles(s.clusterRoleList)
+	if s.clusterRoleBindingList, err = p.clusterRoleBindings.List(ctx, opts); err != nil {
+		return err
+	}
+
+	for _, v := range s.roleList {
+		s.roles[v.Name] = v
+	}
+	for _, v := range s.roleBindingList {
+		s.roleBindings[v.Name] = v
+	}
+	for _, v := range s.clusterRoleList {
+		s.clusterRoles[v.Name] = v
+	}
+	for _, v := range s.clusterRoleBindingList {
+		s.clusterRoleBindings[v.Name] = v
+	}
+
+	now := p.now()
+
+	p.mu.Lock()
+	p.snapshot = s
+	p.synced = true
+	p.lastSync = now
+	p.mu.Unlock()
+
+	lastSyncTimestamp.Set(float64(now.Unix()))
+	staleness.Set(0)
+
+	klog.V(6).InfoS("rbac cache resynced",
+		"Role", len(s.roleList),
+		"RoleBinding", len(s.roleBindingList),
+		"ClusterRole", len(s.clusterRoleList),
+		"ClusterRoleBinding", len(s.clusterRoleBindingList),
+	)
+
+	return nil
+}
+
+// HasSynced returns true once the first resync has completed.
+func (p *Cache) HasSynced() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.synced
+}
+
+// observe records a lookup result, must be called with the lock held.
+func (p *Cache) observe(resource string, hit bool) {
+	if p.synced {
+		staleness.Set(p.now().Sub(p.lastSync).Seconds())
+	}
+
+	tag := missTag
+	if hit {
+		tag = hitTag
+	}
+	requestCount.WithLabelValues(resource, tag).Inc()
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+)
+
+// fakeLister counts the calls to the source
+type fakeLister[T any] struct {
+	list  []T
+	name  func(T) string
+	calls int
+}
+
+func (p *fakeLister[T]) List(ctx context.Context, opts api.GetListOptions) ([]T, error) {
+	p.calls++
+	return p.list, nil
+}
+
+func (p *fakeLister[T]) Get(ctx context.Context, name string) (ret T, err error) {
+	p.calls++
+	for _, v := range p.list {
+		if p.name(v) == name {
+			return v, nil
+		}
+	}
+	return ret, errors.NewNotFound(name)
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	roles := &fakeLister[*rbac.Role]{
+		list: []*rbac.Role{{ObjectMeta: api.ObjectMeta{Name: "admin"}}},
+		name: func(v *rbac.Role) string { return v.Name },
+	}
+	c := NewCache(roles,
+		&fakeLister[*rbac.RoleBinding]{},
+		&fakeLister[*rbac.ClusterRole]{list: []*rbac.ClusterRole{{ObjectMeta: api.ObjectMeta{Name: "view"}}}},
+		&fakeLister[*rbac.ClusterRoleBinding]{},
+	)
+	lister := NewRoleLister(c)
+
+	t.Run("fall through before synced", func(t *testing.T) {
+		assert.False(t, c.HasSynced())
+		ret, err := lister.Get(ctx, "admin")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", ret.Name)
+		assert.Equal(t, 1, roles.calls)
+	})
+
+	t.Run("serve from memory after synced", func(t *testing.T) {
+		assert.NoError(t, c.Resync(ctx))
+		assert.True(t, c.HasSynced())
+		calls := roles.calls
+
+		ret, err := lister.Get(ctx, "admin")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", ret.Name)
+
+		list, err := lister.List(ctx, api.GetListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, list, 1)
+
+		cr, err := NewClusterRoleLister(c).Get(ctx, "view")
+		assert.NoError(t, err)
+		assert.Equal(t, "view", cr.Name)
+
+		assert.Equal(t, calls, roles.calls)
+	})
+
+	t.Run("miss falls through", func(t *testing.T) {
+		roles.list = append(roles.list, &rbac.Role{ObjectMeta: api.ObjectMeta{Name: "edit"}})
+		calls := roles.calls
+
+		ret, err := lister.Get(ctx, "edit")
+		assert.NoError(t, err)
+		assert.Equal(t, "edit", ret.Name)
+		assert.Equal(t, calls+1, roles.calls)
+
+		_, err = lister.Get(ctx, "none")
+		assert.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("resync picks up changes", func(t *testing.T) {
+		assert.NoError(t, c.Resync(ctx))
+		list, err := lister.List(ctx, api.GetListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, list, 2)
+	})
+}
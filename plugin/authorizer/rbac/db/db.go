@@ -1,15 +1,47 @@
 package db
 
 import (
+	"context"
+	"time"
+
 	"github.com/yubo/apiserver/pkg/models"
 	"github.com/yubo/apiserver/plugin/authorizer/rbac"
+	"github.com/yubo/golib/api"
 )
 
-func NewRBAC() (*rbac.RBACAuthorizer, error) {
+// aggregatesTTL bounds the full lists of the cluster roles made to
+// aggregate the rules when they are read from the db
+const aggregatesTTL = time.Second
+
+type CacheConfig struct {
+	Cache             bool         `json:"cache" flag:"rbac-cache" default:"true" description:"Serve RBAC objects from an in-memory cache instead of querying the db provider on every authorization decision"`
+	CacheResyncPeriod api.Duration `json:"cacheResyncPeriod" flag:"rbac-cache-resync-period" default:"30s" description:"The period of resyncing the RBAC cache from the db provider"`
+}
+
+func NewRBAC(ctx context.Context, config *CacheConfig) (*rbac.RBACAuthorizer, error) {
+	if !config.Cache {
+		return rbac.New(
+			&rbac.RoleGetter{Lister: models.NewRole()},
+			&rbac.RoleBindingLister{Lister: models.NewRoleBinding()},
+			&rbac.ClusterRoleGetter{Lister: rbac.NewAggregatedClusterRoleLister(models.NewClusterRole(), aggregatesTTL)},
+			&rbac.ClusterRoleBindingLister{Lister: models.NewClusterRoleBinding()},
+		), nil
+	}
+
+	c := NewCache(
+		models.NewRole(),
+		models.NewRoleBinding(),
+		models.NewClusterRole(),
+		models.NewClusterRoleBinding(),
+	)
+
+	// lookups fall through to the db until the first resync succeeds
+	c.Run(ctx, config.CacheResyncPeriod.Duration)
+
 	return rbac.New(
-		&rbac.RoleGetter{Lister: models.NewRole()},
-		&rbac.RoleBindingLister{Lister: models.NewRoleBinding()},
-		&rbac.ClusterRoleGetter{Lister: models.NewClusterRole()},
-		&rbac.ClusterRoleBindingLister{Lister: models.NewClusterRoleBinding()},
+		&rbac.RoleGetter{Lister: NewRoleLister(c)},
+		&rbac.RoleBindingLister{Lister: NewRoleBindingLister(c)},
+		&rbac.ClusterRoleGetter{Lister: NewClusterRoleLister(c)},
+		&rbac.ClusterRoleBindingLister{Lister: NewClusterRoleBindingLister(c)},
 	), nil
 }
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/apiserver/pkg/listers"
+	rbacauthorizer "github.com/yubo/apiserver/plugin/authorizer/rbac"
+	"github.com/yubo/golib/api"
+)
+
+// source is the lister interface shared by the rbac listers
+type source[T any] interface {
+	List(ctx context.Context, opts api.GetListOptions) ([]T, error)
+	Get(ctx context.Context, name string) (T, error)
+}
+
+// cacheLister serves the objects from the snapshot of the cache, falls
+// through to the source before the first resync, or on miss.
+type cacheLister[T any] struct {
+	*Cache
+	resource string
+	source   source[T]
+	objects  func(*snapshot) (map[string]T, []T)
+}
+
+func (p *cacheLister[T]) List(ctx context.Context, opts api.GetListOptions) ([]T, error) {
+	p.mu.RLock()
+	synced := p.synced
+	_, list := p.objects(&p.snapshot)
+	p.observe(p.resource, synced)
+	p.mu.RUnlock()
+
+	if synced {
+		return list, nil
+	}
+
+	return p.source.List(ctx, opts)
+}
+
+func (p *cacheLister[T]) Get(ctx context.Context, name string) (T, error) {
+	p.mu.RLock()
+	objs, _ := p.objects(&p.snapshot)
+	ret, ok := objs[name]
+	p.observe(p.resource, ok)
+	p.mu.RUnlock()
+
+	if ok {
+		return ret, nil
+	}
+
+	return p.source.Get(ctx, name)
+}
+
+// NewRoleLister returns a new RoleLister served from the cache.
+func NewRoleLister(c *Cache) listers.RoleLister {
+	return &cacheLister[*rbac.Role]{
+		Cache:    c,
+		resource: "role",
+		source:   c.roles,
+		objects: func(s *snapshot) (map[string]*rbac.Role, []*rbac.Role) {
+			return s.roles, s.roleList
+		},
+	}
+}
+
+// NewRoleBindingLister returns a new RoleBindingLister served from the cache.
+func NewRoleBindingLister(c *Cache) listers.RoleBindingLister {
+	return &cacheLister[*rbac.RoleBinding]{
+		Cache:    c,
+		resource: "rolebinding",
+		source:   c.roleBindings,
+		objects: func(s *snapshot) (map[string]*rbac.RoleBinding, []*rbac.RoleBinding) {
+			return s.roleBindings, s.roleBindingList
+		},
+	}
+}
+
+// NewClusterRoleLister returns a new ClusterRoleLister served from the cache,
+// the cluster roles read from the source are aggregated.
+func NewClusterRoleLister(c *Cache) listers.ClusterRoleLister {
+	return &cacheLister[*rbac.ClusterRole]{
+		Cache:    c,
+		resource: "clusterrole",
+		source:   rbacauthorizer.NewAggregatedClusterRoleLister(c.clusterRoles, aggregatesTTL),
+		objects: func(s *snapshot) (map[string]*rbac.ClusterRole, []*rbac.ClusterRole) {
+			return s.clusterRoles, s.clusterRoleList
+		},
+	}
+}
+
+// NewClusterRoleBindingLister returns a new ClusterRoleBindingLister served from the cache.
+func NewClusterRoleBindingLister(c *Cache) listers.ClusterRoleBindingLister {
+	return &cacheLister[*rbac.ClusterRoleBinding]{
+		Cache:    c,
+		resource: "clusterrolebinding",
+		source:   c.clusterRoleBindings,
+		objects: func(s *snapshot) (map[string]*rbac.ClusterRoleBinding, []*rbac.ClusterRoleBinding) {
+			return s.clusterRoleBindings, s.clusterRoleBindingList
+		},
+	}
+}
@@ -53,6 +53,10 @@ func NewFileStorage(config *Config) (*FileStorage, error) {
 
 	f.sort()
 
+	// the file loader acts as the aggregation controller, the rules of the
+	// aggregated cluster roles are computed once the whole dir is loaded
+	f.clusterRoles = rbac.AggregateClusterRoles(f.clusterRoles)
+
 	klog.V(10).InfoS("rbac.file leaving",
 		"Role", len(f.roles),
 		"RoleBinding", len(f.roleBindings),
@@ -6,9 +6,10 @@ import (
 
 	"github.com/yubo/apiserver/pkg/authorization"
 	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/proc"
 	"github.com/yubo/apiserver/plugin/authorizer/rbac/db"
 	"github.com/yubo/apiserver/plugin/authorizer/rbac/file"
-	"github.com/yubo/apiserver/pkg/proc"
+	"github.com/yubo/golib/api"
 	"github.com/yubo/golib/util/errors"
 	"k8s.io/klog/v2"
 )
@@ -20,6 +21,7 @@ const (
 
 type config struct {
 	file.Config
+	db.CacheConfig
 	//Provider string `json:"provider" flag:"rbac-provider" description:"rbac provider(file,db), used with --authorization-mode=RBAC"`
 }
 
@@ -34,11 +36,20 @@ func (o *config) Validate() error {
 		allErrors = append(allErrors, fmt.Errorf("cannot specify --rbac-provider without mode RBAC"))
 	}
 
+	if o.Cache && o.CacheResyncPeriod.Duration <= 0 {
+		allErrors = append(allErrors, fmt.Errorf("--rbac-cache-resync-period %v must be greater than 0 when --rbac-cache is enabled", o.CacheResyncPeriod))
+	}
+
 	return errors.NewAggregate(allErrors)
 }
 
 func newConfig() *config {
-	return &config{}
+	return &config{
+		CacheConfig: db.CacheConfig{
+			Cache:             true,
+			CacheResyncPeriod: api.NewDuration("30s"),
+		},
+	}
 }
 
 func factory(ctx context.Context) (authorizer.Authorizer, error) {
@@ -52,10 +63,10 @@ func factory(ctx context.Context) (authorizer.Authorizer, error) {
 		return file.NewRBAC(&cf.Config)
 	}
 
-	klog.Info("use ")
+	klog.InfoS("rbac provider", "name", "db", "cache", cf.CacheConfig.Cache)
 
 	// if not set file, try find rbac provider from storage
-	return db.NewRBAC()
+	return db.NewRBAC(ctx, &cf.CacheConfig)
 }
 
 func init() {
@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/golib/api/errors"
+)
+
+type clusterRoleListOutput struct {
+	List  []*rbac.ClusterRole `json:"list"`
+	Total int                 `json:"total"`
+}
+
+func (p *Registry) createClusterRole(w http.ResponseWriter, req *http.Request, obj *rbac.ClusterRole) (*rbac.ClusterRole, error) {
+	ctx := req.Context()
+
+	errs := validateName(obj.Name)
+	errs = append(errs, rbac.ValidateClusterRole(obj)...)
+	if len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	if err := p.confirmRoleNoEscalation(ctx, "clusterroles", "", obj.Name, obj.Rules, obj.AggregationRule != nil); err != nil {
+		return nil, err
+	}
+
+	if err := p.clusterRoles.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.clusterRoles.Get(ctx, obj.Name)
+}
+
+func (p *Registry) getClusterRole(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.ClusterRole, error) {
+	return p.clusterRoles.Get(req.Context(), in.Name)
+}
+
+func (p *Registry) listClusterRole(w http.ResponseWriter, req *http.Request, in *listParam) (*clusterRoleListOutput, error) {
+	ret := &clusterRoleListOutput{}
+
+	opts, err := in.GetListOptions(in.Query, &ret.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.List, err = p.clusterRoles.List(req.Context(), *opts); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (p *Registry) updateClusterRole(w http.ResponseWriter, req *http.Request, in *nameParam, obj *rbac.ClusterRole) (*rbac.ClusterRole, error) {
+	ctx := req.Context()
+	obj.Name = in.Name
+
+	old, err := p.clusterRoles.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs := rbac.ValidateClusterRoleUpdate(obj, old); len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	aggregationChanged := obj.AggregationRule != nil && !reflect.DeepEqual(obj.AggregationRule, old.AggregationRule)
+	if err := p.confirmRoleNoEscalation(ctx, "clusterroles", "", obj.Name, obj.Rules, aggregationChanged); err != nil {
+		return nil, err
+	}
+
+	if err := p.clusterRoles.Update(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.clusterRoles.Get(ctx, obj.Name)
+}
+
+func (p *Registry) deleteClusterRole(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.ClusterRole, error) {
+	ctx := req.Context()
+
+	obj, err := p.clusterRoles.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.clusterRoles.Delete(ctx, in.Name); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
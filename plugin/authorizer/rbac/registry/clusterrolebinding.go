@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"net/http"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/golib/api/errors"
+)
+
+type clusterRoleBindingListOutput struct {
+	List  []*rbac.ClusterRoleBinding `json:"list"`
+	Total int                        `json:"total"`
+}
+
+func (p *Registry) createClusterRoleBinding(w http.ResponseWriter, req *http.Request, obj *rbac.ClusterRoleBinding) (*rbac.ClusterRoleBinding, error) {
+	ctx := req.Context()
+
+	errs := validateName(obj.Name)
+	errs = append(errs, rbac.ValidateClusterRoleBinding(obj)...)
+	if len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	if err := p.confirmBindingNoEscalation(ctx, obj.RoleRef, "", obj.Name); err != nil {
+		return nil, err
+	}
+
+	if err := p.clusterRoleBindings.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.clusterRoleBindings.Get(ctx, obj.Name)
+}
+
+func (p *Registry) getClusterRoleBinding(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.ClusterRoleBinding, error) {
+	return p.clusterRoleBindings.Get(req.Context(), in.Name)
+}
+
+func (p *Registry) listClusterRoleBinding(w http.ResponseWriter, req *http.Request, in *listParam) (*clusterRoleBindingListOutput, error) {
+	ret := &clusterRoleBindingListOutput{}
+
+	opts, err := in.GetListOptions(in.Query, &ret.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.List, err = p.clusterRoleBindings.List(req.Context(), *opts); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (p *Registry) updateClusterRoleBinding(w http.ResponseWriter, req *http.Request, in *nameParam, obj *rbac.ClusterRoleBinding) (*rbac.ClusterRoleBinding, error) {
+	ctx := req.Context()
+	obj.Name = in.Name
+
+	old, err := p.clusterRoleBindings.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs := rbac.ValidateClusterRoleBindingUpdate(obj, old); len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	if err := p.confirmBindingNoEscalation(ctx, obj.RoleRef, "", obj.Name); err != nil {
+		return nil, err
+	}
+
+	if err := p.clusterRoleBindings.Update(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.clusterRoleBindings.Get(ctx, obj.Name)
+}
+
+func (p *Registry) deleteClusterRoleBinding(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.ClusterRoleBinding, error) {
+	ctx := req.Context()
+
+	obj, err := p.clusterRoleBindings.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.clusterRoleBindings.Delete(ctx, in.Name); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/apiserver/pkg/audit"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/plugin/authorizer/rbac/validation"
+	"github.com/yubo/golib/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// k8s.io/kubernetes/pkg/registry/rbac/escalation_check.go
+
+const (
+	escalationAnnotationKey = "rbac.authorization.k8s.io/escalation-check"
+
+	escalationByEscalateVerb = "escalate"
+	escalationByBindVerb     = "bind"
+	escalationByCoveredRules = "covered"
+)
+
+// fullAuthority is required to set or change an aggregationRule, which may
+// select any rule of the cluster
+var fullAuthority = []rbac.PolicyRule{
+	{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+	{Verbs: []string{"*"}, NonResourceURLs: []string{"*"}},
+}
+
+// confirmRoleNoEscalation allows the rules of a role to be written if the user may
+// "escalate" on the resource, or already holds every rule of the role. If the
+// aggregationRule of a cluster role is set or changed, the user must hold the
+// full authority instead.
+func (p *Registry) confirmRoleNoEscalation(ctx context.Context, resource, namespace, name string, rules []rbac.PolicyRule, aggregationChanged bool) error {
+	if p.verbAuthorized(ctx, "escalate", resource, namespace, name) {
+		audit.AddAuditAnnotation(ctx, escalationAnnotationKey, escalationByEscalateVerb)
+		return nil
+	}
+
+	ctx = request.WithNamespace(ctx, namespace)
+	if aggregationChanged {
+		if err := validation.ConfirmNoEscalation(ctx, p.ruleResolver, fullAuthority); err != nil {
+			return errors.NewForbidden(name, fmt.Errorf("must have the escalate verb or cluster-admin privileges to use the aggregationRule"))
+		}
+	}
+	if err := validation.ConfirmNoEscalation(ctx, p.ruleResolver, rules); err != nil {
+		return errors.NewForbidden(name, err)
+	}
+
+	audit.AddAuditAnnotation(ctx, escalationAnnotationKey, escalationByCoveredRules)
+	return nil
+}
+
+// confirmBindingNoEscalation allows a binding to be written if the user may "bind"
+// the referenced role, or already holds every rule of the referenced role.
+func (p *Registry) confirmBindingNoEscalation(ctx context.Context, roleRef rbac.RoleRef, namespace, name string) error {
+	resource := "roles"
+	if roleRef.Kind == "ClusterRole" {
+		resource = "clusterroles"
+	}
+
+	// the bind verb is checked in the binding namespace, cluster roles are bound cluster wide
+	if p.verbAuthorized(ctx, "bind", resource, namespace, roleRef.Name) {
+		audit.AddAuditAnnotation(ctx, escalationAnnotationKey, escalationByBindVerb)
+		return nil
+	}
+
+	rules, err := p.ruleResolver.GetRoleReferenceRules(roleRef, namespace)
+	if err != nil {
+		return errors.NewForbidden(name, fmt.Errorf("unable to resolve roleRef %s/%s: %s", roleRef.Kind, roleRef.Name, err))
+	}
+
+	ctx = request.WithNamespace(ctx, namespace)
+	if err := validation.ConfirmNoEscalation(ctx, p.ruleResolver, rules); err != nil {
+		return errors.NewForbidden(name, err)
+	}
+
+	audit.AddAuditAnnotation(ctx, escalationAnnotationKey, escalationByCoveredRules)
+	return nil
+}
+
+func (p *Registry) verbAuthorized(ctx context.Context, verb, resource, namespace, name string) bool {
+	if p.authorizer == nil {
+		return false
+	}
+
+	user, ok := request.UserFrom(ctx)
+	if !ok {
+		return false
+	}
+
+	attrs := authorizer.AttributesRecord{
+		User:            user,
+		Verb:            verb,
+		Namespace:       namespace,
+		APIGroup:        rbac.GroupName,
+		Resource:        resource,
+		Name:            name,
+		ResourceRequest: true,
+	}
+
+	decision, _, err := p.authorizer.Authorize(ctx, attrs)
+	if err != nil {
+		klog.V(5).InfoS("error authorizing rbac verb", "verb", verb, "resource", resource, "name", name, "err", err)
+	}
+
+	return decision == authorizer.DecisionAllow
+}
@@ -0,0 +1,79 @@
+package register
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yubo/apiserver/pkg/models"
+	"github.com/yubo/apiserver/pkg/proc"
+	v1 "github.com/yubo/apiserver/pkg/proc/api/v1"
+	"github.com/yubo/apiserver/pkg/proc/options"
+	"github.com/yubo/apiserver/plugin/authorizer/rbac/registry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	moduleName = "authorization.rbac.registry"
+	configPath = "authorization.rbac"
+)
+
+type config struct {
+	EnableAPI bool `json:"enableAPI" flag:"rbac-enable-api" description:"Enable the REST API to manage Role, ClusterRole, RoleBinding and ClusterRoleBinding stored in the db provider. The cached RBAC authorizer picks up the changes at the next resync"`
+}
+
+func (o *config) Validate() error {
+	return nil
+}
+
+func newConfig() *config {
+	return &config{}
+}
+
+var (
+	_module = &module{name: moduleName}
+	hookOps = []v1.HookOps{{
+		Hook:        _module.init,
+		Owner:       moduleName,
+		HookNum:     v1.ACTION_START,
+		Priority:    v1.PRI_MODULE,
+		SubPriority: v1.PRI_M_AUTHZ,
+	}}
+)
+
+type module struct {
+	name string
+}
+
+func (p *module) init(ctx context.Context) error {
+	cf := newConfig()
+	if err := proc.ReadConfig(configPath, cf); err != nil {
+		return err
+	}
+
+	if !cf.EnableAPI {
+		klog.V(5).InfoS("skip module", "name", p.name, "reason", "disabled")
+		return nil
+	}
+
+	authz, ok := options.AuthzFrom(ctx)
+	if !ok {
+		return fmt.Errorf("unable to get authorizer from context")
+	}
+
+	registry.New(
+		models.NewRole(),
+		models.NewRoleBinding(),
+		models.NewClusterRole(),
+		models.NewClusterRoleBinding(),
+		authz.Authorizer,
+	).Install(options.APIServerMustFrom(ctx))
+
+	klog.InfoS("rbac api installed", "path", registry.APIPath)
+
+	return nil
+}
+
+func init() {
+	proc.RegisterHooks(hookOps)
+	proc.AddConfig(configPath, newConfig(), proc.WithConfigGroup("authorization"))
+}
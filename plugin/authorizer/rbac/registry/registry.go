@@ -0,0 +1,140 @@
+// Package registry implements a REST API to manage the rbac objects
+// stored in pkg/models.
+package registry
+
+import (
+	"context"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/rest"
+	rbacauthorizer "github.com/yubo/apiserver/plugin/authorizer/rbac"
+	"github.com/yubo/apiserver/plugin/authorizer/rbac/validation"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/util/validation/field"
+)
+
+// k8s.io/kubernetes/pkg/registry/rbac
+
+const (
+	// APIPath is the root path of the rbac api
+	APIPath = "/apis/" + rbac.GroupName + "/v1"
+)
+
+type RoleStore interface {
+	Create(ctx context.Context, obj *rbac.Role) error
+	Get(ctx context.Context, name string) (*rbac.Role, error)
+	List(ctx context.Context, opts api.GetListOptions) ([]*rbac.Role, error)
+	Update(ctx context.Context, obj *rbac.Role) error
+	Delete(ctx context.Context, name string) error
+}
+
+type RoleBindingStore interface {
+	Create(ctx context.Context, obj *rbac.RoleBinding) error
+	Get(ctx context.Context, name string) (*rbac.RoleBinding, error)
+	List(ctx context.Context, opts api.GetListOptions) ([]*rbac.RoleBinding, error)
+	Update(ctx context.Context, obj *rbac.RoleBinding) error
+	Delete(ctx context.Context, name string) error
+}
+
+type ClusterRoleStore interface {
+	Create(ctx context.Context, obj *rbac.ClusterRole) error
+	Get(ctx context.Context, name string) (*rbac.ClusterRole, error)
+	List(ctx context.Context, opts api.GetListOptions) ([]*rbac.ClusterRole, error)
+	Update(ctx context.Context, obj *rbac.ClusterRole) error
+	Delete(ctx context.Context, name string) error
+}
+
+type ClusterRoleBindingStore interface {
+	Create(ctx context.Context, obj *rbac.ClusterRoleBinding) error
+	Get(ctx context.Context, name string) (*rbac.ClusterRoleBinding, error)
+	List(ctx context.Context, opts api.GetListOptions) ([]*rbac.ClusterRoleBinding, error)
+	Update(ctx context.Context, obj *rbac.ClusterRoleBinding) error
+	Delete(ctx context.Context, name string) error
+}
+
+type Registry struct {
+	roles               RoleStore
+	roleBindings        RoleBindingStore
+	clusterRoles        ClusterRoleStore
+	clusterRoleBindings ClusterRoleBindingStore
+
+	authorizer   authorizer.Authorizer
+	ruleResolver validation.AuthorizationRuleResolver
+}
+
+// New returns a Registry, the authorizer is used to check the escalate and bind verbs,
+// and may be nil, in which case only the permissions held by the user are considered.
+func New(roles RoleStore, roleBindings RoleBindingStore, clusterRoles ClusterRoleStore, clusterRoleBindings ClusterRoleBindingStore, authz authorizer.Authorizer) *Registry {
+	return &Registry{
+		roles:               roles,
+		roleBindings:        roleBindings,
+		clusterRoles:        clusterRoles,
+		clusterRoleBindings: clusterRoleBindings,
+		authorizer:          authz,
+		ruleResolver: validation.NewDefaultRuleResolver(
+			&rbacauthorizer.RoleGetter{Lister: roles},
+			&rbacauthorizer.RoleBindingLister{Lister: roleBindings},
+			// bindings and held rules are checked against the current aggregated rules
+			&rbacauthorizer.ClusterRoleGetter{Lister: rbacauthorizer.NewAggregatedClusterRoleLister(clusterRoles, 0)},
+			&rbacauthorizer.ClusterRoleBindingLister{Lister: clusterRoleBindings},
+		),
+	}
+}
+
+func (p *Registry) Install(container rest.GoRestfulContainer) {
+	rest.SwaggerTagRegister("rbac", "rbac Api - manage roles and bindings")
+
+	rest.WsRouteBuild(&rest.WsOption{
+		Path:               APIPath,
+		Produces:           []string{rest.MIME_JSON},
+		Consumes:           []string{rest.MIME_JSON},
+		Tags:               []string{"rbac"},
+		GoRestfulContainer: container,
+		Routes: []rest.WsRoute{
+			{Method: "POST", SubPath: "/roles", Operation: "createRole", Desc: "create Role", Handle: p.createRole},
+			{Method: "GET", SubPath: "/roles", Operation: "listRole", Desc: "list Role", Handle: p.listRole},
+			{Method: "GET", SubPath: "/roles/{name}", Operation: "getRole", Desc: "get Role by name", Handle: p.getRole},
+			{Method: "PUT", SubPath: "/roles/{name}", Operation: "updateRole", Desc: "update Role by name", Handle: p.updateRole},
+			{Method: "DELETE", SubPath: "/roles/{name}", Operation: "deleteRole", Desc: "delete Role by name", Handle: p.deleteRole},
+
+			{Method: "POST", SubPath: "/rolebindings", Operation: "createRoleBinding", Desc: "create RoleBinding", Handle: p.createRoleBinding},
+			{Method: "GET", SubPath: "/rolebindings", Operation: "listRoleBinding", Desc: "list RoleBinding", Handle: p.listRoleBinding},
+			{Method: "GET", SubPath: "/rolebindings/{name}", Operation: "getRoleBinding", Desc: "get RoleBinding by name", Handle: p.getRoleBinding},
+			{Method: "PUT", SubPath: "/rolebindings/{name}", Operation: "updateRoleBinding", Desc: "update RoleBinding by name", Handle: p.updateRoleBinding},
+			{Method: "DELETE", SubPath: "/rolebindings/{name}", Operation: "deleteRoleBinding", Desc: "delete RoleBinding by name", Handle: p.deleteRoleBinding},
+
+			{Method: "POST", SubPath: "/clusterroles", Operation: "createClusterRole", Desc: "create ClusterRole", Handle: p.createClusterRole},
+			{Method: "GET", SubPath: "/clusterroles", Operation: "listClusterRole", Desc: "list ClusterRole", Handle: p.listClusterRole},
+			{Method: "GET", SubPath: "/clusterroles/{name}", Operation: "getClusterRole", Desc: "get ClusterRole by name", Handle: p.getClusterRole},
+			{Method: "PUT", SubPath: "/clusterroles/{name}", Operation: "updateClusterRole", Desc: "update ClusterRole by name", Handle: p.updateClusterRole},
+			{Method: "DELETE", SubPath: "/clusterroles/{name}", Operation: "deleteClusterRole", Desc: "delete ClusterRole by name", Handle: p.deleteClusterRole},
+
+			{Method: "POST", SubPath: "/clusterrolebindings", Operation: "createClusterRoleBinding", Desc: "create ClusterRoleBinding", Handle: p.createClusterRoleBinding},
+			{Method: "GET", SubPath: "/clusterrolebindings", Operation: "listClusterRoleBinding", Desc: "list ClusterRoleBinding", Handle: p.listClusterRoleBinding},
+			{Method: "GET", SubPath: "/clusterrolebindings/{name}", Operation: "getClusterRoleBinding", Desc: "get ClusterRoleBinding by name", Handle: p.getClusterRoleBinding},
+			{Method: "PUT", SubPath: "/clusterrolebindings/{name}", Operation: "updateClusterRoleBinding", Desc: "update ClusterRoleBinding by name", Handle: p.updateClusterRoleBinding},
+			{Method: "DELETE", SubPath: "/clusterrolebindings/{name}", Operation: "deleteClusterRoleBinding", Desc: "delete ClusterRoleBinding by name", Handle: p.deleteClusterRoleBinding},
+		},
+	})
+}
+
+type nameParam struct {
+	Name string `param:"path" description:"object name"`
+}
+
+type listParam struct {
+	api.PageParams
+	Query string `param:"query" description:"query"`
+}
+
+func validateName(name string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if len(name) == 0 {
+		return append(allErrs, field.Required(field.NewPath("metadata", "name"), ""))
+	}
+	for _, msg := range rbac.ValidateRBACName(name, false) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), name, msg))
+	}
+	return allErrs
+}
@@ -0,0 +1,342 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/apiserver/pkg/authentication/user"
+	"github.com/yubo/apiserver/pkg/authorization/authorizer"
+	"github.com/yubo/apiserver/pkg/request"
+	"github.com/yubo/apiserver/pkg/rest"
+	"github.com/yubo/golib/api"
+	"github.com/yubo/golib/api/errors"
+)
+
+type roleStore struct{ items map[string]*rbac.Role }
+
+func (p *roleStore) Create(ctx context.Context, obj *rbac.Role) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *roleStore) Get(ctx context.Context, name string) (*rbac.Role, error) {
+	if obj, ok := p.items[name]; ok {
+		return obj, nil
+	}
+	return nil, errors.NewNotFound(name)
+}
+func (p *roleStore) List(ctx context.Context, opts api.GetListOptions) (ret []*rbac.Role, err error) {
+	for _, v := range p.items {
+		ret = append(ret, v)
+	}
+	return
+}
+func (p *roleStore) Update(ctx context.Context, obj *rbac.Role) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *roleStore) Delete(ctx context.Context, name string) error {
+	delete(p.items, name)
+	return nil
+}
+
+type roleBindingStore struct{ items map[string]*rbac.RoleBinding }
+
+func (p *roleBindingStore) Create(ctx context.Context, obj *rbac.RoleBinding) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *roleBindingStore) Get(ctx context.Context, name string) (*rbac.RoleBinding, error) {
+	if obj, ok := p.items[name]; ok {
+		return obj, nil
+	}
+	return nil, errors.NewNotFound(name)
+}
+func (p *roleBindingStore) List(ctx context.Context, opts api.GetListOptions) (ret []*rbac.RoleBinding, err error) {
+	for _, v := range p.items {
+		ret = append(ret, v)
+	}
+	return
+}
+func (p *roleBindingStore) Update(ctx context.Context, obj *rbac.RoleBinding) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *roleBindingStore) Delete(ctx context.Context, name string) error {
+	delete(p.items, name)
+	return nil
+}
+
+type clusterRoleStore struct{ items map[string]*rbac.ClusterRole }
+
+func (p *clusterRoleStore) Create(ctx context.Context, obj *rbac.ClusterRole) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *clusterRoleStore) Get(ctx context.Context, name string) (*rbac.ClusterRole, error) {
+	if obj, ok := p.items[name]; ok {
+		return obj, nil
+	}
+	return nil, errors.NewNotFound(name)
+}
+func (p *clusterRoleStore) List(ctx context.Context, opts api.GetListOptions) (ret []*rbac.ClusterRole, err error) {
+	for _, v := range p.items {
+		ret = append(ret, v)
+	}
+	return
+}
+func (p *clusterRoleStore) Update(ctx context.Context, obj *rbac.ClusterRole) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *clusterRoleStore) Delete(ctx context.Context, name string) error {
+	delete(p.items, name)
+	return nil
+}
+
+type clusterRoleBindingStore struct {
+	items map[string]*rbac.ClusterRoleBinding
+}
+
+func (p *clusterRoleBindingStore) Create(ctx context.Context, obj *rbac.ClusterRoleBinding) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *clusterRoleBindingStore) Get(ctx context.Context, name string) (*rbac.ClusterRoleBinding, error) {
+	if obj, ok := p.items[name]; ok {
+		return obj, nil
+	}
+	return nil, errors.NewNotFound(name)
+}
+func (p *clusterRoleBindingStore) List(ctx context.Context, opts api.GetListOptions) (ret []*rbac.ClusterRoleBinding, err error) {
+	for _, v := range p.items {
+		ret = append(ret, v)
+	}
+	return
+}
+func (p *clusterRoleBindingStore) Update(ctx context.Context, obj *rbac.ClusterRoleBinding) error {
+	p.items[obj.Name] = obj
+	return nil
+}
+func (p *clusterRoleBindingStore) Delete(ctx context.Context, name string) error {
+	delete(p.items, name)
+	return nil
+}
+
+// allowVerbs allows the listed verbs for any user
+type allowVerbs []string
+
+func (p allowVerbs) Authorize(ctx context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+	for _, verb := range p {
+		if verb == a.GetVerb() {
+			return authorizer.DecisionAllow, "", nil
+		}
+	}
+	return authorizer.DecisionNoOpinion, "", nil
+}
+
+func newTestRegistry(authz authorizer.Authorizer) *Registry {
+	clusterRoles := &clusterRoleStore{items: map[string]*rbac.ClusterRole{
+		"rbac-admin": {
+			ObjectMeta: api.ObjectMeta{Name: "rbac-admin"},
+			Rules: []rbac.PolicyRule{
+				{Verbs: []string{"*"}, Resources: []string{"clusterroles", "clusterrolebindings"}},
+				{Verbs: []string{"get", "list"}, Resources: []string{"users"}},
+			},
+		},
+	}}
+	clusterRoleBindings := &clusterRoleBindingStore{items: map[string]*rbac.ClusterRoleBinding{
+		"alice-rbac-admin": {
+			ObjectMeta: api.ObjectMeta{Name: "alice-rbac-admin"},
+			RoleRef:    rbac.RoleRef{Kind: "ClusterRole", Name: "rbac-admin"},
+			Subjects:   []rbac.Subject{{Kind: rbac.UserKind, Name: "alice"}},
+		},
+	}}
+
+	return New(
+		&roleStore{items: map[string]*rbac.Role{}},
+		&roleBindingStore{items: map[string]*rbac.RoleBinding{}},
+		clusterRoles,
+		clusterRoleBindings,
+		authz,
+	)
+}
+
+func TestClusterRoleEscalation(t *testing.T) {
+	alice := &user.DefaultInfo{Name: "alice"}
+	req := httptest.NewRequest("POST", APIPath+"/clusterroles", nil)
+	req = req.WithContext(request.WithUser(req.Context(), alice))
+	w := httptest.NewRecorder()
+
+	cases := []struct {
+		name      string
+		authz     authorizer.Authorizer
+		rules     []rbac.PolicyRule
+		forbidden bool
+	}{{
+		name:  "covered by held rules",
+		rules: []rbac.PolicyRule{{Verbs: []string{"get"}, Resources: []string{"users"}}},
+	}, {
+		name:      "not covered",
+		rules:     []rbac.PolicyRule{{Verbs: []string{"delete"}, Resources: []string{"users"}}},
+		forbidden: true,
+	}, {
+		name:  "escalate verb",
+		authz: allowVerbs{"escalate"},
+		rules: []rbac.PolicyRule{{Verbs: []string{"delete"}, Resources: []string{"users"}}},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := newTestRegistry(c.authz)
+			obj := &rbac.ClusterRole{ObjectMeta: api.ObjectMeta{Name: "test"}, Rules: c.rules}
+			_, err := r.createClusterRole(w, req, obj)
+			if c.forbidden {
+				assert.True(t, errors.IsForbidden(err), "expected forbidden, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestClusterRoleBindingEscalation(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	cases := []struct {
+		name      string
+		user      string
+		authz     authorizer.Authorizer
+		forbidden bool
+	}{
+		{name: "holder of the role may bind it", user: "alice"},
+		{name: "others may not", user: "bob", forbidden: true},
+		{name: "bind verb", user: "bob", authz: allowVerbs{"bind"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := newTestRegistry(c.authz)
+			req := httptest.NewRequest("POST", APIPath+"/clusterrolebindings", nil)
+			req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: c.user}))
+
+			obj := &rbac.ClusterRoleBinding{
+				ObjectMeta: api.ObjectMeta{Name: "carol-rbac-admin"},
+				RoleRef:    rbac.RoleRef{Kind: "ClusterRole", Name: "rbac-admin"},
+				Subjects:   []rbac.Subject{{Kind: rbac.UserKind, Name: "carol"}},
+			}
+			_, err := r.createClusterRoleBinding(w, req, obj)
+			if c.forbidden {
+				assert.True(t, errors.IsForbidden(err), "expected forbidden, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAggregationRuleEscalation(t *testing.T) {
+	w := httptest.NewRecorder()
+	everything := &rbac.AggregationRule{ClusterRoleSelectors: []api.LabelSelector{{}}}
+
+	cases := []struct {
+		name      string
+		authz     authorizer.Authorizer
+		forbidden bool
+	}{
+		{name: "held rules do not cover the aggregation", forbidden: true},
+		{name: "escalate verb", authz: allowVerbs{"escalate"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := newTestRegistry(c.authz)
+			req := httptest.NewRequest("POST", APIPath+"/clusterroles", nil)
+			req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"}))
+
+			_, err := r.createClusterRole(w, req, &rbac.ClusterRole{
+				ObjectMeta:      api.ObjectMeta{Name: "everything"},
+				AggregationRule: everything,
+			})
+			if c.forbidden {
+				assert.True(t, errors.IsForbidden(err), "expected forbidden, got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// an aggregationRule can not be added by an update either
+			_, err = r.updateClusterRole(w, req, &nameParam{Name: "rbac-admin"}, &rbac.ClusterRole{
+				AggregationRule: everything,
+			})
+			if c.forbidden {
+				assert.True(t, errors.IsForbidden(err), "expected forbidden, got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAggregatedClusterRoleBindingEscalation(t *testing.T) {
+	r := newTestRegistry(nil)
+	r.clusterRoles.Create(context.Background(), &rbac.ClusterRole{
+		ObjectMeta:      api.ObjectMeta{Name: "everything"},
+		AggregationRule: &rbac.AggregationRule{ClusterRoleSelectors: []api.LabelSelector{{}}},
+	})
+
+	// bob holds nothing, the binding is checked against the aggregated rules of rbac-admin
+	req := httptest.NewRequest("POST", APIPath+"/clusterrolebindings", nil)
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "bob"}))
+	_, err := r.createClusterRoleBinding(httptest.NewRecorder(), req, &rbac.ClusterRoleBinding{
+		ObjectMeta: api.ObjectMeta{Name: "bob-everything"},
+		RoleRef:    rbac.RoleRef{Kind: "ClusterRole", Name: "everything"},
+		Subjects:   []rbac.Subject{{Kind: rbac.UserKind, Name: "bob"}},
+	})
+	assert.True(t, errors.IsForbidden(err), "expected forbidden, got %v", err)
+}
+
+func TestValidation(t *testing.T) {
+	r := newTestRegistry(allowVerbs{"escalate", "bind"})
+	req := httptest.NewRequest("POST", APIPath+"/clusterroles", nil)
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"}))
+	w := httptest.NewRecorder()
+
+	_, err := r.createClusterRole(w, req, &rbac.ClusterRole{
+		ObjectMeta: api.ObjectMeta{Name: "no-verbs"},
+		Rules:      []rbac.PolicyRule{{Resources: []string{"users"}}},
+	})
+	assert.True(t, errors.IsInvalid(err), "expected invalid, got %v", err)
+
+	_, err = r.createClusterRoleBinding(w, req, &rbac.ClusterRoleBinding{
+		ObjectMeta: api.ObjectMeta{Name: "bad-ref"},
+		RoleRef:    rbac.RoleRef{Kind: "Role", Name: "rbac-admin"},
+	})
+	assert.True(t, errors.IsInvalid(err), "expected invalid, got %v", err)
+
+	_, err = r.updateClusterRoleBinding(w, req, &nameParam{Name: "alice-rbac-admin"}, &rbac.ClusterRoleBinding{
+		RoleRef: rbac.RoleRef{Kind: "ClusterRole", Name: "other"},
+	})
+	assert.True(t, errors.IsInvalid(err), "expected invalid, got %v", err)
+}
+
+func TestInstall(t *testing.T) {
+	container := rest.NewBaseContainer()
+	newTestRegistry(nil).Install(container)
+
+	testServer := httptest.NewServer(http.Handler(container))
+	defer testServer.Close()
+
+	resp, err := http.Get(testServer.URL + APIPath + "/clusterroles/rbac-admin")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	obj := &rbac.ClusterRole{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(obj))
+	assert.Equal(t, "rbac-admin", obj.Name)
+}
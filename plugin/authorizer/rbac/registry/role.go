@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"net/http"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/golib/api/errors"
+)
+
+type roleListOutput struct {
+	List  []*rbac.Role `json:"list"`
+	Total int          `json:"total"`
+}
+
+func (p *Registry) createRole(w http.ResponseWriter, req *http.Request, obj *rbac.Role) (*rbac.Role, error) {
+	ctx := req.Context()
+
+	errs := validateName(obj.Name)
+	errs = append(errs, rbac.ValidateRole(obj)...)
+	if len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	if err := p.confirmRoleNoEscalation(ctx, "roles", obj.Namespace, obj.Name, obj.Rules, false); err != nil {
+		return nil, err
+	}
+
+	if err := p.roles.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.roles.Get(ctx, obj.Name)
+}
+
+func (p *Registry) getRole(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.Role, error) {
+	return p.roles.Get(req.Context(), in.Name)
+}
+
+func (p *Registry) listRole(w http.ResponseWriter, req *http.Request, in *listParam) (*roleListOutput, error) {
+	ret := &roleListOutput{}
+
+	opts, err := in.GetListOptions(in.Query, &ret.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.List, err = p.roles.List(req.Context(), *opts); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (p *Registry) updateRole(w http.ResponseWriter, req *http.Request, in *nameParam, obj *rbac.Role) (*rbac.Role, error) {
+	ctx := req.Context()
+	obj.Name = in.Name
+
+	old, err := p.roles.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs := rbac.ValidateRoleUpdate(obj, old); len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	if err := p.confirmRoleNoEscalation(ctx, "roles", obj.Namespace, obj.Name, obj.Rules, false); err != nil {
+		return nil, err
+	}
+
+	if err := p.roles.Update(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.roles.Get(ctx, obj.Name)
+}
+
+func (p *Registry) deleteRole(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.Role, error) {
+	ctx := req.Context()
+
+	obj, err := p.roles.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.roles.Delete(ctx, in.Name); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"net/http"
+
+	"github.com/yubo/apiserver/pkg/apis/rbac"
+	"github.com/yubo/golib/api/errors"
+)
+
+type roleBindingListOutput struct {
+	List  []*rbac.RoleBinding `json:"list"`
+	Total int                 `json:"total"`
+}
+
+func (p *Registry) createRoleBinding(w http.ResponseWriter, req *http.Request, obj *rbac.RoleBinding) (*rbac.RoleBinding, error) {
+	ctx := req.Context()
+
+	errs := validateName(obj.Name)
+	errs = append(errs, rbac.ValidateRoleBinding(obj)...)
+	if len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	if err := p.confirmBindingNoEscalation(ctx, obj.RoleRef, obj.Namespace, obj.Name); err != nil {
+		return nil, err
+	}
+
+	if err := p.roleBindings.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.roleBindings.Get(ctx, obj.Name)
+}
+
+func (p *Registry) getRoleBinding(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.RoleBinding, error) {
+	return p.roleBindings.Get(req.Context(), in.Name)
+}
+
+func (p *Registry) listRoleBinding(w http.ResponseWriter, req *http.Request, in *listParam) (*roleBindingListOutput, error) {
+	ret := &roleBindingListOutput{}
+
+	opts, err := in.GetListOptions(in.Query, &ret.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if ret.List, err = p.roleBindings.List(req.Context(), *opts); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (p *Registry) updateRoleBinding(w http.ResponseWriter, req *http.Request, in *nameParam, obj *rbac.RoleBinding) (*rbac.RoleBinding, error) {
+	ctx := req.Context()
+	obj.Name = in.Name
+
+	old, err := p.roleBindings.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs := rbac.ValidateRoleBindingUpdate(obj, old); len(errs) > 0 {
+		return nil, errors.NewInvalid(obj.Name, errs)
+	}
+
+	if err := p.confirmBindingNoEscalation(ctx, obj.RoleRef, obj.Namespace, obj.Name); err != nil {
+		return nil, err
+	}
+
+	if err := p.roleBindings.Update(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	return p.roleBindings.Get(ctx, obj.Name)
+}
+
+func (p *Registry) deleteRoleBinding(w http.ResponseWriter, req *http.Request, in *nameParam) (*rbac.RoleBinding, error) {
+	ctx := req.Context()
+
+	obj, err := p.roleBindings.Get(ctx, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.roleBindings.Delete(ctx, in.Name); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
@@ -17,131 +17,142 @@ limitations under the License.
 package validation
 
 import (
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/yubo/apiserver/pkg/apis/rbac"
-	//"k8s.io/component-helpers/auth/rbac/validation"
 )
 
-//func TestCompactRules(t *testing.T) {
-//	testcases := map[string]struct {
-//		Rules    []rbac.PolicyRule
-//		Expected []rbac.PolicyRule
-//	}{
-//		"empty": {
-//			Rules:    []rbac.PolicyRule{},
-//			Expected: []rbac.PolicyRule{},
-//		},
-//		"simple": {
-//			Rules: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}},
-//				{Verbs: []string{"list"}, Resources: []string{"builds"}},
-//				{Verbs: []string{"update", "patch"}, Resources: []string{"builds"}},
-//
-//				{Verbs: []string{"create"}, Resources: []string{"daemonsets"}},
-//				{Verbs: []string{"delete"}, Resources: []string{"daemonsets"}},
-//				{Verbs: []string{"patch"}, Resources: []string{"daemonsets"}, ResourceNames: []string{""}},
-//				{Verbs: []string{"get"}, Resources: []string{"daemonsets"}, ResourceNames: []string{"foo"}},
-//				{Verbs: []string{"list"}, Resources: []string{"daemonsets"}, ResourceNames: []string{"foo"}},
-//
-//				{Verbs: []string{"educate"}, Resources: []string{"dolphins"}},
-//
-//				// nil verbs are preserved in non-merge cases.
-//				// these are the pirates who don't do anything.
-//				{Verbs: nil, Resources: []string{"pirates"}},
-//
-//				// Test merging into a nil Verbs string set
-//				{Verbs: nil, Resources: []string{"pods"}},
-//				{Verbs: []string{"create"}, Resources: []string{"pods"}},
-//			},
-//			Expected: []rbac.PolicyRule{
-//				{Verbs: []string{"create", "delete"}, Resources: []string{"daemonsets"}},
-//				{Verbs: []string{"patch"}, Resources: []string{"daemonsets"}, ResourceNames: []string{""}},
-//				{Verbs: []string{"get", "list"}, Resources: []string{"daemonsets"}, ResourceNames: []string{"foo"}},
-//				{Verbs: []string{"get", "list", "update", "patch"}, Resources: []string{"builds"}},
-//				{Verbs: []string{"educate"}, Resources: []string{"dolphins"}},
-//				{Verbs: nil, Resources: []string{"pirates"}},
-//				{Verbs: []string{"create"}, Resources: []string{"pods"}},
-//			},
-//		},
-//		"complex multi-group": {
-//			Rules: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}},
-//				{Verbs: []string{"list"}, Resources: []string{"builds"}},
-//			},
-//			Expected: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}},
-//				{Verbs: []string{"list"}, Resources: []string{"builds"}},
-//			},
-//		},
-//
-//		"complex multi-resource": {
-//			Rules: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds", "images"}},
-//				{Verbs: []string{"list"}, Resources: []string{"builds", "images"}},
-//			},
-//			Expected: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds", "images"}},
-//				{Verbs: []string{"list"}, Resources: []string{"builds", "images"}},
-//			},
-//		},
-//
-//		"complex named-resource": {
-//			Rules: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild"}},
-//				{Verbs: []string{"list"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild2"}},
-//			},
-//			Expected: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild"}},
-//				{Verbs: []string{"list"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild2"}},
-//			},
-//		},
-//
-//		"complex non-resource": {
-//			Rules: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/"}},
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/foo"}},
-//			},
-//			Expected: []rbac.PolicyRule{
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/"}},
-//				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/foo"}},
-//			},
-//		},
-//	}
-//
-//	for k, tc := range testcases {
-//		rules := tc.Rules
-//		originalRules := make([]rbac.PolicyRule, len(tc.Rules))
-//		for i := range tc.Rules {
-//			originalRules[i] = *tc.Rules[i].DeepCopy()
-//		}
-//		compacted, err := CompactRules(tc.Rules)
-//		if err != nil {
-//			t.Errorf("%s: unexpected error: %v", k, err)
-//			continue
-//		}
-//		if !reflect.DeepEqual(rules, originalRules) {
-//			t.Errorf("%s: CompactRules mutated rules. Expected\n%#v\ngot\n%#v", k, originalRules, rules)
-//			continue
-//		}
-//		if covers, missing := validation.Covers(compacted, rules); !covers {
-//			t.Errorf("%s: compacted rules did not cover original rules. missing: %#v", k, missing)
-//			continue
-//		}
-//		if covers, missing := validation.Covers(rules, compacted); !covers {
-//			t.Errorf("%s: original rules did not cover compacted rules. missing: %#v", k, missing)
-//			continue
-//		}
-//
-//		sort.Stable(rbacv1helpers.SortableRuleSlice(compacted))
-//		sort.Stable(rbacv1helpers.SortableRuleSlice(tc.Expected))
-//		if !reflect.DeepEqual(compacted, tc.Expected) {
-//			t.Errorf("%s: Expected\n%#v\ngot\n%#v", k, tc.Expected, compacted)
-//			continue
-//		}
-//	}
-//}
-//
+func TestCompactRules(t *testing.T) {
+	testcases := map[string]struct {
+		Rules    []rbac.PolicyRule
+		Expected []rbac.PolicyRule
+	}{
+		"empty": {
+			Rules:    []rbac.PolicyRule{},
+			Expected: []rbac.PolicyRule{},
+		},
+		"simple": {
+			Rules: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds"}},
+				{Verbs: []string{"list"}, Resources: []string{"builds"}},
+				{Verbs: []string{"update", "patch"}, Resources: []string{"builds"}},
+
+				{Verbs: []string{"create"}, Resources: []string{"daemonsets"}},
+				{Verbs: []string{"delete"}, Resources: []string{"daemonsets"}},
+				{Verbs: []string{"patch"}, Resources: []string{"daemonsets"}, ResourceNames: []string{""}},
+				{Verbs: []string{"get"}, Resources: []string{"daemonsets"}, ResourceNames: []string{"foo"}},
+				{Verbs: []string{"list"}, Resources: []string{"daemonsets"}, ResourceNames: []string{"foo"}},
+
+				{Verbs: []string{"educate"}, Resources: []string{"dolphins"}},
+
+				// nil verbs are preserved in non-merge cases.
+				// these are the pirates who don't do anything.
+				{Verbs: nil, Resources: []string{"pirates"}},
+
+				// Test merging into a nil Verbs string set
+				{Verbs: nil, Resources: []string{"pods"}},
+				{Verbs: []string{"create"}, Resources: []string{"pods"}},
+			},
+			Expected: []rbac.PolicyRule{
+				{Verbs: []string{"create", "delete"}, Resources: []string{"daemonsets"}},
+				{Verbs: []string{"patch"}, Resources: []string{"daemonsets"}, ResourceNames: []string{""}},
+				{Verbs: []string{"get", "list"}, Resources: []string{"daemonsets"}, ResourceNames: []string{"foo"}},
+				{Verbs: []string{"get", "list", "update", "patch"}, Resources: []string{"builds"}},
+				{Verbs: []string{"educate"}, Resources: []string{"dolphins"}},
+				{Verbs: nil, Resources: []string{"pirates"}},
+				{Verbs: []string{"create"}, Resources: []string{"pods"}},
+			},
+		},
+		"complex multi-group": {
+			Rules: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, APIGroups: []string{"", "builds.openshift.io"}, Resources: []string{"builds"}},
+				{Verbs: []string{"list"}, APIGroups: []string{"", "builds.openshift.io"}, Resources: []string{"builds"}},
+			},
+			Expected: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, APIGroups: []string{"", "builds.openshift.io"}, Resources: []string{"builds"}},
+				{Verbs: []string{"list"}, APIGroups: []string{"", "builds.openshift.io"}, Resources: []string{"builds"}},
+			},
+		},
+
+		"no group": {
+			Rules: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds"}},
+				{Verbs: []string{"list"}, Resources: []string{"builds"}},
+			},
+			Expected: []rbac.PolicyRule{
+				{Verbs: []string{"get", "list"}, Resources: []string{"builds"}},
+			},
+		},
+
+		"complex multi-resource": {
+			Rules: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds", "images"}},
+				{Verbs: []string{"list"}, Resources: []string{"builds", "images"}},
+			},
+			Expected: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds", "images"}},
+				{Verbs: []string{"list"}, Resources: []string{"builds", "images"}},
+			},
+		},
+
+		"complex named-resource": {
+			Rules: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild"}},
+				{Verbs: []string{"list"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild2"}},
+			},
+			Expected: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild"}},
+				{Verbs: []string{"list"}, Resources: []string{"builds"}, ResourceNames: []string{"mybuild2"}},
+			},
+		},
+
+		"complex non-resource": {
+			Rules: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/"}},
+				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/foo"}},
+			},
+			Expected: []rbac.PolicyRule{
+				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/"}},
+				{Verbs: []string{"get"}, Resources: []string{"builds"}, NonResourceURLs: []string{"/foo"}},
+			},
+		},
+	}
+
+	for k, tc := range testcases {
+		rules := tc.Rules
+		originalRules := make([]rbac.PolicyRule, len(tc.Rules))
+		for i := range tc.Rules {
+			originalRules[i] = *tc.Rules[i].DeepCopy()
+		}
+		compacted, err := CompactRules(tc.Rules)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", k, err)
+			continue
+		}
+		if !reflect.DeepEqual(rules, originalRules) {
+			t.Errorf("%s: CompactRules mutated rules. Expected\n%#v\ngot\n%#v", k, originalRules, rules)
+			continue
+		}
+		if covers, missing := Covers(compacted, rules); !covers {
+			t.Errorf("%s: compacted rules did not cover original rules. missing: %#v", k, missing)
+			continue
+		}
+		if covers, missing := Covers(rules, compacted); !covers {
+			t.Errorf("%s: original rules did not cover compacted rules. missing: %#v", k, missing)
+			continue
+		}
+
+		sort.Stable(rbac.SortableRuleSlice(compacted))
+		sort.Stable(rbac.SortableRuleSlice(tc.Expected))
+		if !reflect.DeepEqual(compacted, tc.Expected) {
+			t.Errorf("%s: Expected\n%#v\ngot\n%#v", k, tc.Expected, compacted)
+			continue
+		}
+	}
+}
+
 func TestIsSimpleResourceRule(t *testing.T) {
 	testcases := map[string]struct {
 		Rule     rbac.PolicyRule